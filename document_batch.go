@@ -0,0 +1,191 @@
+package fycha
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/erniealice/fycha-golang/services/doctemplate"
+)
+
+// BatchFormat selects the output of DocumentService.ProcessBatch.
+type BatchFormat int
+
+const (
+	// BatchMerged produces one DOCX with every record starting on a new page.
+	BatchMerged BatchFormat = iota
+	// BatchZIP produces a ZIP archive with one DOCX per record.
+	BatchZIP
+)
+
+// BatchOptions configures DocumentService.ProcessBatch. The zero value
+// merges into one document using GOMAXPROCS workers.
+type BatchOptions struct {
+	Format BatchFormat
+
+	// Concurrency is the number of records rendered at once. Zero or less
+	// means runtime.GOMAXPROCS(0).
+	Concurrency int
+
+	// FileName names the file of record i in a ZIP. Defaults to
+	// "document-001.docx", "document-002.docx", ... Names must be unique.
+	FileName func(i int, data map[string]any) string
+
+	// Template is passed to doctemplate.ProcessTemplateWithOptions for
+	// every record.
+	Template doctemplate.Options
+}
+
+// BatchError reports a record that could not be rendered.
+type BatchError struct {
+	Index int // position of the record in the input
+	Err   error
+}
+
+func (e BatchError) Error() string {
+	return fmt.Sprintf("record %d: %v", e.Index, e.Err)
+}
+
+func (e BatchError) Unwrap() error { return e.Err }
+
+// BatchResult is the output of DocumentService.ProcessBatch.
+type BatchResult struct {
+	// Data is the merged DOCX or the ZIP, holding the records that
+	// rendered, in input order.
+	Data []byte
+
+	// Errors lists the records left out, in input order.
+	Errors []BatchError
+}
+
+// ProcessBatch renders one DOCX template for many records — a run of
+// payslips, statements or certificates — and returns either one merged
+// document or a ZIP of individual files (opts.Format).
+//
+// Records are rendered concurrently, at most opts.Concurrency at a time. A
+// record that fails to render is left out and reported in
+// BatchResult.Errors; the others are still produced. ProcessBatch returns
+// an error only if no record rendered, the merge itself failed, or ctx was
+// canceled.
+func (s *DocumentService) ProcessBatch(
+	ctx context.Context,
+	templateData []byte,
+	records []map[string]any,
+	opts BatchOptions,
+) (*BatchResult, error) {
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no records to process")
+	}
+
+	docs, errs := renderBatch(ctx, templateData, records, opts)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(errs) == len(records) {
+		return nil, fmt.Errorf("all %d records failed, first: %w", len(records), errs[0])
+	}
+
+	result := &BatchResult{Errors: errs}
+	var err error
+	switch opts.Format {
+	case BatchMerged:
+		var rendered [][]byte
+		for _, doc := range docs {
+			if doc != nil {
+				rendered = append(rendered, doc)
+			}
+		}
+		result.Data, err = doctemplate.MergeDocuments(rendered)
+		if err != nil {
+			return nil, fmt.Errorf("merging documents: %w", err)
+		}
+	case BatchZIP:
+		result.Data, err = zipBatch(docs, records, opts.FileName)
+		if err != nil {
+			return nil, fmt.Errorf("writing zip: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown batch format %d", opts.Format)
+	}
+	return result, nil
+}
+
+// renderBatch renders every record, returning the documents in input order
+// (nil where rendering failed) and the failures.
+func renderBatch(
+	ctx context.Context,
+	templateData []byte,
+	records []map[string]any,
+	opts BatchOptions,
+) ([][]byte, []BatchError) {
+	workers := opts.Concurrency
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	docs := make([][]byte, len(records))
+	failures := make([]error, len(records))
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i, data := range records {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			docs[i], failures[i] = doctemplate.ProcessTemplateWithOptions(templateData, data, opts.Template)
+		}()
+	}
+	wg.Wait()
+
+	var errs []BatchError
+	for i, err := range failures {
+		if err != nil {
+			errs = append(errs, BatchError{Index: i, Err: err})
+		}
+	}
+	return docs, errs
+}
+
+// zipBatch packs the rendered documents into a ZIP archive.
+func zipBatch(docs [][]byte, records []map[string]any, fileName func(int, map[string]any) string) ([]byte, error) {
+	if fileName == nil {
+		fileName = func(i int, _ map[string]any) string {
+			return fmt.Sprintf("document-%03d.docx", i+1)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	names := make(map[string]bool)
+	for i, doc := range docs {
+		if doc == nil {
+			continue
+		}
+		name := fileName(i, records[i])
+		if names[name] {
+			return nil, fmt.Errorf("duplicate file name %q for record %d", name, i)
+		}
+		names[name] = true
+		w, err := zw.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(doc); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
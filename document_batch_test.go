@@ -0,0 +1,162 @@
+package fycha
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/erniealice/fycha-golang/services/doctemplate"
+)
+
+func batchRecords(names ...string) []map[string]any {
+	var records []map[string]any
+	for _, name := range names {
+		if name == "" {
+			records = append(records, map[string]any{})
+			continue
+		}
+		records = append(records, map[string]any{"name": name})
+	}
+	return records
+}
+
+func documentText(t *testing.T, docx []byte) string {
+	t.Helper()
+	archive, err := doctemplate.ReadDocxBytes(docx)
+	if err != nil {
+		t.Fatalf("failed to read docx: %v", err)
+	}
+	return archive.Content
+}
+
+func TestDocumentService_ProcessBatch_Merged(t *testing.T) {
+	t.Parallel()
+
+	svc := NewDocumentService(nil)
+	result, err := svc.ProcessBatch(context.Background(), createMinimalDocx(t),
+		batchRecords("Ana", "Ben", "Cy"), BatchOptions{Concurrency: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Errors) != 0 {
+		t.Errorf("errors = %v, want none", result.Errors)
+	}
+
+	content := documentText(t, result.Data)
+	last := -1
+	for _, want := range []string{"Hello Ana", "Hello Ben", "Hello Cy"} {
+		i := strings.Index(content, want)
+		if i < 0 {
+			t.Fatalf("merged document lacks %q", want)
+		}
+		if i < last {
+			t.Errorf("%q is out of order", want)
+		}
+		last = i
+	}
+	if n := strings.Count(content, `w:val="nextPage"`); n != 2 {
+		t.Errorf("got %d page breaks between records, want 2", n)
+	}
+}
+
+func TestDocumentService_ProcessBatch_ZIP(t *testing.T) {
+	t.Parallel()
+
+	svc := NewDocumentService(nil)
+	result, err := svc.ProcessBatch(context.Background(), createMinimalDocx(t),
+		batchRecords("Ana", "Ben"), BatchOptions{
+			Format: BatchZIP,
+			FileName: func(i int, data map[string]any) string {
+				return fmt.Sprintf("payslip-%s.docx", data["name"])
+			},
+		})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(result.Data), int64(len(result.Data)))
+	if err != nil {
+		t.Fatalf("failed to read zip: %v", err)
+	}
+	if len(reader.File) != 2 {
+		t.Fatalf("got %d files, want 2", len(reader.File))
+	}
+	for i, want := range []string{"Ana", "Ben"} {
+		file := reader.File[i]
+		if file.Name != "payslip-"+want+".docx" {
+			t.Errorf("file %d = %q, want payslip-%s.docx", i, file.Name, want)
+		}
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", file.Name, err)
+		}
+		docx, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", file.Name, err)
+		}
+		if content := documentText(t, docx); !strings.Contains(content, "Hello "+want) {
+			t.Errorf("%s lacks %q", file.Name, "Hello "+want)
+		}
+	}
+}
+
+func TestDocumentService_ProcessBatch_RecordErrors(t *testing.T) {
+	t.Parallel()
+
+	svc := NewDocumentService(nil)
+	opts := BatchOptions{
+		Format:   BatchZIP,
+		Template: doctemplate.Options{Missing: doctemplate.MissingError},
+	}
+	result, err := svc.ProcessBatch(context.Background(), createMinimalDocx(t),
+		batchRecords("Ana", "", "Cy"), opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(result.Errors) != 1 || result.Errors[0].Index != 1 {
+		t.Fatalf("errors = %v, want record 1", result.Errors)
+	}
+	if !errors.Is(result.Errors[0], doctemplate.ErrMissingValues) {
+		t.Errorf("error = %v, want ErrMissingValues", result.Errors[0])
+	}
+	reader, err := zip.NewReader(bytes.NewReader(result.Data), int64(len(result.Data)))
+	if err != nil {
+		t.Fatalf("failed to read zip: %v", err)
+	}
+	var names []string
+	for _, file := range reader.File {
+		names = append(names, file.Name)
+	}
+	if got := strings.Join(names, ","); got != "document-001.docx,document-003.docx" {
+		t.Errorf("files = %s, want document-001.docx,document-003.docx", got)
+	}
+}
+
+func TestDocumentService_ProcessBatch_Failures(t *testing.T) {
+	t.Parallel()
+
+	svc := NewDocumentService(nil)
+
+	if _, err := svc.ProcessBatch(context.Background(), createMinimalDocx(t), nil, BatchOptions{}); err == nil {
+		t.Error("expected error for no records")
+	}
+
+	_, err := svc.ProcessBatch(context.Background(), []byte("not a zip"), batchRecords("Ana", "Ben"), BatchOptions{})
+	if err == nil {
+		t.Error("expected error when every record fails")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = svc.ProcessBatch(ctx, createMinimalDocx(t), batchRecords("Ana"), BatchOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+}
@@ -2,6 +2,7 @@ package fycha
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/erniealice/fycha-golang/services/doctemplate"
@@ -24,17 +25,40 @@ type StorageReadWriter interface {
 // for reading templates and writing results.
 //
 // The core processing is delegated to services/doctemplate.ProcessTemplate,
-// which has zero I/O dependencies. This service adds the storage layer.
+// which has zero I/O dependencies. This service adds the storage layer and
+// PDF conversion through a pdfconv.Converter.
 type DocumentService struct {
-	storage StorageReadWriter
+	storage   StorageReadWriter
+	converter pdfconv.Converter
 }
 
 // NewDocumentService creates a DocumentService with the given storage backend.
 // Pass nil for storage if you only need ProcessBytes (no storage I/O).
+//
+// Its PDF methods start LibreOffice for every document; under load, use
+// NewDocumentServiceWithConverter with a pdfconv.Pool.
 func NewDocumentService(storage StorageReadWriter) *DocumentService {
-	return &DocumentService{storage: storage}
+	return NewDocumentServiceWithConverter(storage, nil)
 }
 
+// NewDocumentServiceWithConverter creates a DocumentService whose PDF methods
+// use converter — typically a pdfconv.Pool shared by the application, or a
+// pdfconv.Fake in tests. A nil converter behaves like NewDocumentService.
+func NewDocumentServiceWithConverter(storage StorageReadWriter, converter pdfconv.Converter) *DocumentService {
+	if converter == nil {
+		converter = oneShotConverter
+	}
+	return &DocumentService{storage: storage, converter: converter}
+}
+
+// oneShotConverter converts with a LibreOffice process per document,
+// bounded by pdfconv.DefaultTimeout.
+var oneShotConverter = pdfconv.ConverterFunc(func(ctx context.Context, docx []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, pdfconv.DefaultTimeout)
+	defer cancel()
+	return pdfconv.Convert(ctx, docx)
+})
+
 // ProcessBytes processes a DOCX template from raw bytes and returns the result as DOCX bytes.
 // This is a convenience wrapper around doctemplate.ProcessTemplate — no storage needed.
 func (s *DocumentService) ProcessBytes(templateData []byte, data map[string]any) ([]byte, error) {
@@ -43,13 +67,19 @@ func (s *DocumentService) ProcessBytes(templateData []byte, data map[string]any)
 
 // ProcessBytesToPDF processes a DOCX template and converts the result to PDF.
 // Returns the PDF bytes, or an error if conversion fails.
-// Requires LibreOffice to be installed (https://www.libreoffice.org/download/).
+// The default converter requires LibreOffice to be installed (https://www.libreoffice.org/download/).
 func (s *DocumentService) ProcessBytesToPDF(templateData []byte, data map[string]any) ([]byte, error) {
+	return s.ProcessBytesToPDFContext(context.Background(), templateData, data)
+}
+
+// ProcessBytesToPDFContext is ProcessBytesToPDF with a context that bounds
+// the conversion, e.g. the HTTP request's.
+func (s *DocumentService) ProcessBytesToPDFContext(ctx context.Context, templateData []byte, data map[string]any) ([]byte, error) {
 	docxBytes, err := doctemplate.ProcessTemplate(templateData, data)
 	if err != nil {
 		return nil, err
 	}
-	return convertToPDF(docxBytes)
+	return s.convertToPDF(ctx, docxBytes)
 }
 
 // ProcessFromStorage reads a template from storage, processes it with the given data,
@@ -113,7 +143,7 @@ func (s *DocumentService) ProcessFromStorageToPDF(
 		return fmt.Errorf("processing template: %w", err)
 	}
 
-	pdfBytes, err := convertToPDF(docxBytes)
+	pdfBytes, err := s.convertToPDF(ctx, docxBytes)
 	if err != nil {
 		return fmt.Errorf("converting to PDF: %w", err)
 	}
@@ -172,18 +202,22 @@ func (s *DocumentService) ProcessFromStorageToPDFBytes(
 		return nil, fmt.Errorf("processing template: %w", err)
 	}
 
-	return convertToPDF(docxBytes)
+	return s.convertToPDF(ctx, docxBytes)
 }
 
-// convertToPDF converts DOCX bytes to PDF using LibreOffice.
+// convertToPDF converts DOCX bytes to PDF with the service's converter.
 // Returns an error if LibreOffice is not installed (no silent fallback).
-func convertToPDF(docxBytes []byte) ([]byte, error) {
-	pdfBytes, ok, err := pdfconv.ConvertDocxToPDF(docxBytes)
+func (s *DocumentService) convertToPDF(ctx context.Context, docxBytes []byte) ([]byte, error) {
+	converter := s.converter
+	if converter == nil {
+		converter = oneShotConverter
+	}
+	pdfBytes, err := converter.Convert(ctx, docxBytes)
+	if errors.Is(err, pdfconv.ErrUnavailable) {
+		return nil, fmt.Errorf("PDF conversion unavailable: %w", err)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("PDF conversion failed: %w", err)
 	}
-	if !ok {
-		return nil, fmt.Errorf("PDF conversion unavailable: LibreOffice is not installed (see https://www.libreoffice.org/download/)")
-	}
 	return pdfBytes, nil
 }
@@ -7,6 +7,9 @@ import (
 	"errors"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/erniealice/fycha-golang/services/pdfconv"
 )
 
 // mockStorageReadWriter implements StorageReadWriter for testing.
@@ -496,6 +499,104 @@ func TestDocumentService_ProcessFromStorageToBytes_EmptyKey(t *testing.T) {
 	}
 }
 
+func TestDocumentService_ProcessBytesToPDF_Converter(t *testing.T) {
+	t.Parallel()
+
+	fake := &pdfconv.Fake{PDF: []byte("%PDF-1.7 rendered")}
+	svc := NewDocumentServiceWithConverter(nil, fake)
+
+	pdf, err := svc.ProcessBytesToPDF(createMinimalDocx(t), map[string]any{"name": "Test Corp"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(pdf) != "%PDF-1.7 rendered" {
+		t.Errorf("pdf = %q, want the converter's output", pdf)
+	}
+
+	// The converter receives the rendered document, not the template.
+	calls := fake.Calls()
+	if len(calls) != 1 {
+		t.Fatalf("converter called %d times, want 1", len(calls))
+	}
+	if text := documentText(t, calls[0]); !strings.Contains(text, "Hello Test Corp") {
+		t.Errorf("converted document text = %q", text)
+	}
+}
+
+func TestDocumentService_ProcessFromStorageToPDF_Converter(t *testing.T) {
+	t.Parallel()
+
+	var written []byte
+	storage := &mockStorageReadWriter{
+		readFunc: func(ctx context.Context, containerName, objectKey string) ([]byte, error) {
+			return createMinimalDocx(t), nil
+		},
+		writeFunc: func(ctx context.Context, containerName, objectKey string, data []byte) error {
+			written = data
+			return nil
+		},
+	}
+	svc := NewDocumentServiceWithConverter(storage, &pdfconv.Fake{})
+
+	err := svc.ProcessFromStorageToPDF(
+		context.Background(),
+		"templates", "receipt.docx",
+		"output", "receipt-123.pdf",
+		map[string]any{"name": "Test Corp"},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.HasPrefix(written, []byte("%PDF-")) {
+		t.Errorf("written data = %q, want a PDF", written)
+	}
+}
+
+func TestDocumentService_PDF_ConverterErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"unavailable", pdfconv.ErrUnavailable, "PDF conversion unavailable"},
+		{"failed", errors.New("soffice crashed"), "PDF conversion failed: soffice crashed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			svc := NewDocumentServiceWithConverter(nil, &pdfconv.Fake{Err: tt.err})
+			_, err := svc.ProcessBytesToPDF(createMinimalDocx(t), map[string]any{"name": "Test"})
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want prefix %q", err, tt.want)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("err = %v does not wrap %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestDocumentService_ProcessFromStorageToPDFBytes_Canceled(t *testing.T) {
+	t.Parallel()
+
+	storage := &mockStorageReadWriter{
+		readFunc: func(ctx context.Context, containerName, objectKey string) ([]byte, error) {
+			return createMinimalDocx(t), nil
+		},
+	}
+	svc := NewDocumentServiceWithConverter(storage, &pdfconv.Fake{Delay: time.Minute})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := svc.ProcessFromStorageToPDFBytes(ctx, "templates", "receipt.docx", map[string]any{"name": "Test"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the context's deadline", err)
+	}
+}
+
 // createMinimalDocx builds a minimal valid DOCX archive in memory.
 // It contains just enough structure for doctemplate.ProcessTemplate to succeed.
 func createMinimalDocx(t *testing.T) []byte {
@@ -0,0 +1,273 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrBusClosed is returned by AsyncEventBus.Publish after Shutdown has begun.
+var ErrBusClosed = errors.New("eventbus: bus is shut down")
+
+// AsyncEventBus is an in-process EventBus that runs handlers on a bounded
+// pool of worker goroutines, so Publish returns as soon as the event is
+// queued instead of waiting for every handler.
+//
+// Events with the same SourceID are always routed to the same worker and are
+// therefore handled in publish order (a loan's "loan.received" lands before
+// its "loan.payment"). Events without a SourceID are spread round-robin.
+//
+// Handler errors cannot be returned to the publisher; they are passed to
+// AsyncConfig.OnError. Like MemoryEventBus, delivery is not durable — queued
+// events are lost if the process exits without Shutdown.
+type AsyncEventBus struct {
+	cfg     AsyncConfig
+	queues  []chan asyncItem
+	wg      sync.WaitGroup
+	next    atomic.Uint64
+	depth   atomic.Int64
+	stats   asyncCounters
+	closing sync.RWMutex
+	closed  bool
+
+	handlers   map[string][]Handler
+	patterns   patternSubscriptions
+	mu         sync.RWMutex
+	middleware middlewareStack
+}
+
+// AsyncConfig configures an AsyncEventBus. Zero values fall back to the
+// defaults noted on each field.
+type AsyncConfig struct {
+	// Workers is the number of worker goroutines. Default runtime.NumCPU().
+	Workers int
+
+	// QueueSize is the number of events each worker can buffer. Publish
+	// blocks while the target worker's queue is full. Default 256.
+	QueueSize int
+
+	// OnError receives handler failures. Default logs them.
+	OnError func(event Event, err error)
+
+	// Metrics receives queue-depth and handler-latency observations. Optional.
+	Metrics AsyncMetrics
+}
+
+// AsyncMetrics receives AsyncEventBus observations, e.g. to feed Prometheus
+// or OpenTelemetry instruments. Implementations must be safe for concurrent use.
+type AsyncMetrics interface {
+	// QueueDepth is called with the number of queued events whenever an
+	// event is enqueued or dequeued.
+	QueueDepth(depth int)
+
+	// HandlerLatency is called after each handler runs. err is the
+	// handler's result.
+	HandlerLatency(eventType string, d time.Duration, err error)
+}
+
+// AsyncStats is a point-in-time snapshot of AsyncEventBus counters.
+type AsyncStats struct {
+	QueueDepth   int
+	Published    uint64
+	Handled      uint64
+	Failed       uint64
+	TotalLatency time.Duration
+	MaxLatency   time.Duration
+}
+
+type asyncCounters struct {
+	published    atomic.Uint64
+	handled      atomic.Uint64
+	failed       atomic.Uint64
+	totalLatency atomic.Int64
+	maxLatency   atomic.Int64
+}
+
+type asyncItem struct {
+	ctx   context.Context
+	event Event
+}
+
+// NewAsyncEventBus creates an AsyncEventBus and starts its workers.
+// Call Shutdown to drain queued events and stop the workers.
+func NewAsyncEventBus(cfg AsyncConfig) *AsyncEventBus {
+	if cfg.Workers <= 0 {
+		cfg.Workers = runtime.NumCPU()
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 256
+	}
+	if cfg.OnError == nil {
+		cfg.OnError = func(event Event, err error) {
+			log.Printf("[eventbus] async %s source=%s failed: %v", event.Type, event.SourceID, err)
+		}
+	}
+
+	b := &AsyncEventBus{
+		cfg:      cfg,
+		queues:   make([]chan asyncItem, cfg.Workers),
+		handlers: make(map[string][]Handler),
+	}
+	for i := range b.queues {
+		b.queues[i] = make(chan asyncItem, cfg.QueueSize)
+		b.wg.Add(1)
+		go b.worker(b.queues[i])
+	}
+	return b
+}
+
+// Subscribe registers a handler for an event type or a pattern such as
+// "revenue.*" (see pattern.go).
+// Thread-safe; multiple handlers per event type are supported.
+func (b *AsyncEventBus) Subscribe(eventType string, handler Handler) {
+	if IsEventPattern(eventType) {
+		b.patterns.add(eventType, handler)
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[eventType] = append(b.handlers[eventType], handler)
+}
+
+// Use adds middleware around every handler. Middleware runs on the worker
+// goroutine, inside the bus's own panic recovery and latency measurement.
+func (b *AsyncEventBus) Use(middleware ...Middleware) {
+	b.middleware.use(middleware...)
+}
+
+// Publish queues the event for asynchronous handling and returns without
+// waiting for handlers. An empty Event.ID is set with NewEventID and a zero
+// Event.Timestamp to the current time. Publish blocks while the worker queue is full and returns ctx.Err()
+// if ctx is done first, or ErrBusClosed once Shutdown has begun.
+//
+// Handlers receive a context carrying ctx's values but not its cancellation,
+// so they are not cut short when the publishing request completes.
+func (b *AsyncEventBus) Publish(ctx context.Context, event Event) error {
+	if event.ID == "" {
+		event.ID = NewEventID()
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+
+	b.closing.RLock()
+	defer b.closing.RUnlock()
+	if b.closed {
+		return ErrBusClosed
+	}
+
+	queue := b.queues[b.shard(event.SourceID)]
+	item := asyncItem{ctx: context.WithoutCancel(ctx), event: event}
+
+	// Count the event before sending so a fast worker never sees depth < 0.
+	depth := b.depth.Add(1)
+	select {
+	case queue <- item:
+	case <-ctx.Done():
+		b.depth.Add(-1)
+		return ctx.Err()
+	}
+
+	b.stats.published.Add(1)
+	b.observeDepth(depth)
+	return nil
+}
+
+// Shutdown stops accepting events and waits for queued events to be handled.
+// It returns ctx.Err() if ctx is done before the queues drain; workers keep
+// draining in the background in that case.
+func (b *AsyncEventBus) Shutdown(ctx context.Context) error {
+	b.closing.Lock()
+	if !b.closed {
+		b.closed = true
+		for _, q := range b.queues {
+			close(q)
+		}
+	}
+	b.closing.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats returns a snapshot of the bus counters.
+func (b *AsyncEventBus) Stats() AsyncStats {
+	return AsyncStats{
+		QueueDepth:   int(b.depth.Load()),
+		Published:    b.stats.published.Load(),
+		Handled:      b.stats.handled.Load(),
+		Failed:       b.stats.failed.Load(),
+		TotalLatency: time.Duration(b.stats.totalLatency.Load()),
+		MaxLatency:   time.Duration(b.stats.maxLatency.Load()),
+	}
+}
+
+// shard picks the worker for a SourceID.
+func (b *AsyncEventBus) shard(sourceID string) int {
+	if sourceID == "" {
+		return int(b.next.Add(1) % uint64(len(b.queues)))
+	}
+	h := fnv.New32a()
+	h.Write([]byte(sourceID))
+	return int(h.Sum32() % uint32(len(b.queues)))
+}
+
+func (b *AsyncEventBus) worker(queue <-chan asyncItem) {
+	defer b.wg.Done()
+	for item := range queue {
+		b.observeDepth(b.depth.Add(-1))
+		b.dispatch(item.ctx, item.event)
+	}
+}
+
+// dispatch runs every handler for the event in registration order. A handler
+// panic is reported through OnError and does not stop the worker.
+func (b *AsyncEventBus) dispatch(ctx context.Context, event Event) {
+	b.mu.RLock()
+	handlers := b.patterns.appendMatches(b.handlers[event.Type], event.Type)
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		start := time.Now()
+		err := callHandler(ctx, b.middleware.wrap(h), event)
+		elapsed := time.Since(start)
+
+		b.stats.handled.Add(1)
+		b.stats.totalLatency.Add(int64(elapsed))
+		for {
+			longest := b.stats.maxLatency.Load()
+			if int64(elapsed) <= longest || b.stats.maxLatency.CompareAndSwap(longest, int64(elapsed)) {
+				break
+			}
+		}
+		if b.cfg.Metrics != nil {
+			b.cfg.Metrics.HandlerLatency(event.Type, elapsed, err)
+		}
+		if err != nil {
+			b.stats.failed.Add(1)
+			b.cfg.OnError(event, fmt.Errorf("eventbus: %s handler: %w", event.Type, err))
+		}
+	}
+}
+
+func (b *AsyncEventBus) observeDepth(depth int64) {
+	if b.cfg.Metrics != nil {
+		b.cfg.Metrics.QueueDepth(int(depth))
+	}
+}
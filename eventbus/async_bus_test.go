@@ -0,0 +1,311 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// asyncTestMetrics records AsyncMetrics observations.
+type asyncTestMetrics struct {
+	mu       sync.Mutex
+	maxDepth int
+	latency  map[string]int
+}
+
+func (m *asyncTestMetrics) QueueDepth(depth int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if depth > m.maxDepth {
+		m.maxDepth = depth
+	}
+}
+
+func (m *asyncTestMetrics) HandlerLatency(eventType string, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.latency == nil {
+		m.latency = make(map[string]int)
+	}
+	m.latency[eventType]++
+}
+
+func TestAsyncEventBus_PublishAndShutdown(t *testing.T) {
+	t.Parallel()
+
+	t.Run("publish returns before handlers finish", func(t *testing.T) {
+		t.Parallel()
+
+		bus := NewAsyncEventBus(AsyncConfig{Workers: 2})
+		release := make(chan struct{})
+		var handled atomic.Bool
+		bus.Subscribe("revenue.completed", func(ctx context.Context, e Event) error {
+			<-release
+			handled.Store(true)
+			return nil
+		})
+
+		if err := bus.Publish(context.Background(), Event{Type: "revenue.completed", SourceID: "rev-1"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if handled.Load() {
+			t.Fatal("handler finished before Publish returned")
+		}
+
+		close(release)
+		if err := bus.Shutdown(context.Background()); err != nil {
+			t.Fatalf("Shutdown: %v", err)
+		}
+		if !handled.Load() {
+			t.Error("Shutdown returned before the queued event was handled")
+		}
+	})
+
+	t.Run("shutdown drains every queued event", func(t *testing.T) {
+		t.Parallel()
+
+		bus := NewAsyncEventBus(AsyncConfig{Workers: 4, QueueSize: 8})
+		var count atomic.Int64
+		bus.Subscribe("collection.received", func(ctx context.Context, e Event) error {
+			time.Sleep(time.Millisecond)
+			count.Add(1)
+			return nil
+		})
+
+		const n = 100
+		for i := 0; i < n; i++ {
+			if err := bus.Publish(context.Background(), Event{Type: "collection.received", SourceID: fmt.Sprintf("col-%d", i)}); err != nil {
+				t.Fatalf("publish %d: %v", i, err)
+			}
+		}
+		if err := bus.Shutdown(context.Background()); err != nil {
+			t.Fatalf("Shutdown: %v", err)
+		}
+		if got := count.Load(); got != n {
+			t.Errorf("handled = %d, want %d", got, n)
+		}
+		if depth := bus.Stats().QueueDepth; depth != 0 {
+			t.Errorf("QueueDepth after drain = %d, want 0", depth)
+		}
+	})
+
+	t.Run("publish after shutdown fails", func(t *testing.T) {
+		t.Parallel()
+
+		bus := NewAsyncEventBus(AsyncConfig{Workers: 1})
+		if err := bus.Shutdown(context.Background()); err != nil {
+			t.Fatalf("Shutdown: %v", err)
+		}
+		if err := bus.Shutdown(context.Background()); err != nil {
+			t.Fatalf("second Shutdown: %v", err)
+		}
+		err := bus.Publish(context.Background(), Event{Type: "x"})
+		if !errors.Is(err, ErrBusClosed) {
+			t.Errorf("error = %v, want ErrBusClosed", err)
+		}
+	})
+
+	t.Run("shutdown honours its context deadline", func(t *testing.T) {
+		t.Parallel()
+
+		bus := NewAsyncEventBus(AsyncConfig{Workers: 1})
+		release := make(chan struct{})
+		defer close(release)
+		bus.Subscribe("slow", func(ctx context.Context, e Event) error {
+			<-release
+			return nil
+		})
+		_ = bus.Publish(context.Background(), Event{Type: "slow"})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		if err := bus.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("error = %v, want DeadlineExceeded", err)
+		}
+	})
+
+	t.Run("full queue blocks publish until context is done", func(t *testing.T) {
+		t.Parallel()
+
+		bus := NewAsyncEventBus(AsyncConfig{Workers: 1, QueueSize: 1})
+		release := make(chan struct{})
+		started := make(chan struct{}, 1)
+		bus.Subscribe("slow", func(ctx context.Context, e Event) error {
+			started <- struct{}{}
+			<-release
+			return nil
+		})
+
+		_ = bus.Publish(context.Background(), Event{Type: "slow"})
+		<-started
+		_ = bus.Publish(context.Background(), Event{Type: "slow"}) // fills the queue
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		if err := bus.Publish(ctx, Event{Type: "slow"}); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("error = %v, want DeadlineExceeded", err)
+		}
+
+		close(release)
+		if err := bus.Shutdown(context.Background()); err != nil {
+			t.Fatalf("Shutdown: %v", err)
+		}
+		if got := bus.Stats().Published; got != 2 {
+			t.Errorf("Published = %d, want 2", got)
+		}
+	})
+
+	t.Run("handler context outlives the publisher's context", func(t *testing.T) {
+		t.Parallel()
+
+		type ctxKey struct{}
+		bus := NewAsyncEventBus(AsyncConfig{Workers: 1})
+		release := make(chan struct{})
+		var (
+			ctxErr error
+			value  any
+		)
+		bus.Subscribe("revenue.completed", func(ctx context.Context, e Event) error {
+			<-release
+			ctxErr = ctx.Err()
+			value = ctx.Value(ctxKey{})
+			return nil
+		})
+
+		ctx, cancel := context.WithCancel(context.WithValue(context.Background(), ctxKey{}, "ws-1"))
+		_ = bus.Publish(ctx, Event{Type: "revenue.completed"})
+		cancel()
+		close(release)
+		_ = bus.Shutdown(context.Background())
+
+		if ctxErr != nil {
+			t.Errorf("handler ctx.Err() = %v, want nil", ctxErr)
+		}
+		if value != "ws-1" {
+			t.Errorf("handler ctx value = %v, want ws-1", value)
+		}
+	})
+}
+
+func TestAsyncEventBus_Ordering(t *testing.T) {
+	t.Parallel()
+
+	bus := NewAsyncEventBus(AsyncConfig{Workers: 8})
+
+	var (
+		mu  sync.Mutex
+		got = make(map[string][]string)
+		ids = make(map[string]bool)
+	)
+	record := func(ctx context.Context, e Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		got[e.SourceID] = append(got[e.SourceID], e.Type)
+		ids[e.ID] = true
+		return nil
+	}
+	bus.Subscribe(EventTypeLoanReceived, record)
+	bus.Subscribe(EventTypeLoanPayment, record)
+
+	const loans = 50
+	var wg sync.WaitGroup
+	for i := 0; i < loans; i++ {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			_ = bus.Publish(context.Background(), Event{Type: EventTypeLoanReceived, SourceID: id})
+			for j := 0; j < 5; j++ {
+				_ = bus.Publish(context.Background(), Event{Type: EventTypeLoanPayment, SourceID: id})
+			}
+		}(fmt.Sprintf("loan-%d", i))
+	}
+	wg.Wait()
+	if err := bus.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	if len(got) != loans {
+		t.Fatalf("sources = %d, want %d", len(got), loans)
+	}
+	if len(ids) != loans*6 || ids[""] {
+		t.Errorf("distinct event IDs = %d, want %d assigned on publish", len(ids), loans*6)
+	}
+	for id, types := range got {
+		if len(types) != 6 || types[0] != EventTypeLoanReceived {
+			t.Errorf("%s order = %v, want loan.received first then 5 payments", id, types)
+		}
+	}
+}
+
+func TestAsyncEventBus_ErrorsAndMetrics(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu   sync.Mutex
+		errs []string
+	)
+	metrics := &asyncTestMetrics{}
+	bus := NewAsyncEventBus(AsyncConfig{
+		Workers: 2,
+		Metrics: metrics,
+		OnError: func(e Event, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			errs = append(errs, err.Error())
+		},
+	})
+
+	bus.Subscribe("payroll.posted", func(ctx context.Context, e Event) error {
+		if e.SourceID == "bad" {
+			return errors.New("account not found")
+		}
+		return nil
+	})
+	bus.Subscribe("payroll.posted", func(ctx context.Context, e Event) error {
+		if e.SourceID == "panic" {
+			panic("boom")
+		}
+		return nil
+	})
+
+	for _, id := range []string{"ok", "bad", "panic"} {
+		if err := bus.Publish(context.Background(), Event{Type: "payroll.posted", SourceID: id}); err != nil {
+			t.Fatalf("publish %s: %v", id, err)
+		}
+	}
+	if err := bus.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(errs) != 2 {
+		t.Fatalf("errors = %v, want 2", errs)
+	}
+	joined := strings.Join(errs, "; ")
+	if !strings.Contains(joined, "account not found") || !strings.Contains(joined, "panicked: boom") {
+		t.Errorf("errors = %q", joined)
+	}
+
+	stats := bus.Stats()
+	if stats.Published != 3 || stats.Handled != 6 || stats.Failed != 2 {
+		t.Errorf("stats = %+v, want 3 published, 6 handled, 2 failed", stats)
+	}
+	if stats.MaxLatency <= 0 || stats.TotalLatency < stats.MaxLatency {
+		t.Errorf("latency stats = %+v", stats)
+	}
+
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	if metrics.latency["payroll.posted"] != 6 {
+		t.Errorf("latency observations = %d, want 6", metrics.latency["payroll.posted"])
+	}
+	if metrics.maxDepth < 1 {
+		t.Errorf("max queue depth = %d, want >= 1", metrics.maxDepth)
+	}
+}
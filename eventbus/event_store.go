@@ -0,0 +1,163 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// EventFilter selects events from an EventStore. Zero fields match everything.
+type EventFilter struct {
+	// Types restricts the result to these event types.
+	Types []string
+
+	// SourceID restricts the result to one source entity.
+	SourceID string
+
+	// WorkspaceID restricts the result to one workspace.
+	WorkspaceID string
+
+	// Since and Until bound Event.Timestamp: Since is inclusive, Until exclusive.
+	Since time.Time
+	Until time.Time
+}
+
+// Matches reports whether event satisfies the filter.
+func (f EventFilter) Matches(event Event) bool {
+	if len(f.Types) > 0 {
+		found := false
+		for _, t := range f.Types {
+			if t == event.Type {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.SourceID != "" && event.SourceID != f.SourceID {
+		return false
+	}
+	if f.WorkspaceID != "" && event.WorkspaceID != f.WorkspaceID {
+		return false
+	}
+	if !f.Since.IsZero() && event.Timestamp.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !event.Timestamp.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// EventStore keeps every published event so it can be replayed later (see
+// JournalPoster.Replay). Implementations must be safe for concurrent use.
+type EventStore interface {
+	// Append records event. Event.ID and Event.Timestamp are set by the
+	// caller; appending an ID that is already stored is not an error.
+	Append(ctx context.Context, event Event) error
+
+	// Query returns the events matching filter, oldest first.
+	Query(ctx context.Context, filter EventFilter) ([]Event, error)
+}
+
+// ---------------------------------------------------------------------------
+// Storing bus
+// ---------------------------------------------------------------------------
+
+// StoringEventBus records every published event in an EventStore before
+// handing it to the wrapped bus. Events are stored even when a handler fails,
+// so the log is complete regardless of delivery outcome.
+type StoringEventBus struct {
+	bus        EventBus
+	store      EventStore
+	now        func() time.Time
+	middleware middlewareStack
+}
+
+// NewStoringEventBus wraps bus so every published event is appended to store.
+func NewStoringEventBus(bus EventBus, store EventStore) *StoringEventBus {
+	return &StoringEventBus{bus: bus, store: store, now: time.Now}
+}
+
+// Publish assigns Event.ID and Event.Timestamp when empty, appends the event
+// to the store and publishes it on the wrapped bus. Nothing is published if
+// the append fails. With an OutboxEventBus and a SQLEventStore on the same
+// database, a transaction in ctx (see ContextWithTx) covers both writes.
+func (b *StoringEventBus) Publish(ctx context.Context, event Event) error {
+	if event.Type == "" {
+		return errors.New("eventbus: event type is required")
+	}
+	if event.ID == "" {
+		event.ID = NewEventID()
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = b.now()
+	}
+	if err := b.store.Append(ctx, event); err != nil {
+		return err
+	}
+	return b.bus.Publish(ctx, event)
+}
+
+// Subscribe registers handler on the wrapped bus.
+func (b *StoringEventBus) Subscribe(eventType string, handler Handler) {
+	b.bus.Subscribe(eventType, func(ctx context.Context, event Event) error {
+		return b.middleware.wrap(handler)(ctx, event)
+	})
+}
+
+// Use adds middleware around every handler subscribed through this bus. It
+// runs inside any middleware registered on the wrapped bus.
+func (b *StoringEventBus) Use(middleware ...Middleware) {
+	b.middleware.use(middleware...)
+}
+
+// ---------------------------------------------------------------------------
+// In-memory store
+// ---------------------------------------------------------------------------
+
+// MemoryEventStore is an in-process EventStore. State is lost on restart;
+// use SQLEventStore in production.
+type MemoryEventStore struct {
+	mu     sync.RWMutex
+	events []Event
+	ids    map[string]bool
+}
+
+// NewMemoryEventStore creates an empty MemoryEventStore.
+func NewMemoryEventStore() *MemoryEventStore {
+	return &MemoryEventStore{ids: make(map[string]bool)}
+}
+
+// Append records event.
+func (s *MemoryEventStore) Append(ctx context.Context, event Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if event.ID != "" {
+		if s.ids[event.ID] {
+			return nil
+		}
+		s.ids[event.ID] = true
+	}
+	s.events = append(s.events, event)
+	return nil
+}
+
+// Query returns the events matching filter, oldest first.
+func (s *MemoryEventStore) Query(ctx context.Context, filter EventFilter) ([]Event, error) {
+	s.mu.RLock()
+	var events []Event
+	for _, e := range s.events {
+		if filter.Matches(e) {
+			events = append(events, e)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Timestamp.Before(events[j].Timestamp) })
+	return events, nil
+}
@@ -0,0 +1,164 @@
+package eventbus
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SQLEventStore is an EventStore backed by database/sql. Rows are never
+// updated or deleted, so the table is a complete log of published events.
+type SQLEventStore struct {
+	db  *sql.DB
+	cfg SQLEventStoreConfig
+	now func() time.Time
+}
+
+// SQLEventStoreConfig configures a SQLEventStore. Zero values fall back to
+// the defaults noted on each field.
+type SQLEventStoreConfig struct {
+	// Table holds the event log. Default "eventbus_events".
+	Table string
+
+	// Placeholder renders the n-th (1-based) bind parameter.
+	// Default QuestionPlaceholder.
+	Placeholder func(n int) string
+}
+
+// NewSQLEventStore creates a SQLEventStore backed by db.
+// Call CreateTables (or apply equivalent migrations) before use.
+func NewSQLEventStore(db *sql.DB, cfg SQLEventStoreConfig) *SQLEventStore {
+	if cfg.Table == "" {
+		cfg.Table = "eventbus_events"
+	}
+	if cfg.Placeholder == nil {
+		cfg.Placeholder = QuestionPlaceholder
+	}
+	return &SQLEventStore{db: db, cfg: cfg, now: time.Now}
+}
+
+// CreateTables creates the event log table if it does not exist.
+func (s *SQLEventStore) CreateTables(ctx context.Context) error {
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS ` + s.cfg.Table + ` (
+			id           VARCHAR(64) PRIMARY KEY,
+			event_type   VARCHAR(255) NOT NULL,
+			source_id    VARCHAR(255) NOT NULL,
+			workspace_id VARCHAR(255) NOT NULL,
+			payload      TEXT NOT NULL,
+			occurred_at  BIGINT NOT NULL,
+			recorded_at  BIGINT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS ` + s.cfg.Table + `_occurred_idx
+			ON ` + s.cfg.Table + ` (occurred_at)`,
+		`CREATE INDEX IF NOT EXISTS ` + s.cfg.Table + `_source_idx
+			ON ` + s.cfg.Table + ` (source_id, occurred_at)`,
+	}
+	for _, stmt := range stmts {
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("eventbus: creating event store table: %w", err)
+		}
+	}
+	return nil
+}
+
+// Append records event. If ctx carries a transaction (see ContextWithTx) the
+// row is written inside it. Outside a transaction a duplicate ID surfaces as
+// a primary-key violation, which is treated as success.
+func (s *SQLEventStore) Append(ctx context.Context, event Event) error {
+	if event.ID == "" {
+		return errors.New("eventbus: event ID is required")
+	}
+	payload, err := json.Marshal(event.Payload)
+	if err != nil {
+		return fmt.Errorf("eventbus: encoding %s payload: %w", event.Type, err)
+	}
+
+	var exec execer = s.db
+	if tx, ok := TxFromContext(ctx); ok {
+		exec = tx
+	}
+	query := `INSERT INTO ` + s.cfg.Table + ` (id, event_type, source_id, workspace_id, payload, occurred_at, recorded_at)
+		VALUES (` + joinPlaceholders(s.cfg.Placeholder, 1, 7) + `)`
+	_, err = exec.ExecContext(ctx, query, event.ID, event.Type, event.SourceID, event.WorkspaceID,
+		string(payload), event.Timestamp.UnixNano(), s.now().UnixNano())
+	if err == nil {
+		return nil
+	}
+	if _, ok := TxFromContext(ctx); !ok {
+		var n int
+		exists := `SELECT COUNT(*) FROM ` + s.cfg.Table + ` WHERE id = ` + s.cfg.Placeholder(1)
+		if checkErr := s.db.QueryRowContext(ctx, exists, event.ID).Scan(&n); checkErr == nil && n > 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("eventbus: storing %s event %s: %w", event.Type, event.ID, err)
+}
+
+// Query returns the events matching filter, oldest first.
+func (s *SQLEventStore) Query(ctx context.Context, filter EventFilter) ([]Event, error) {
+	var (
+		where []string
+		args  []any
+	)
+	arg := func(v any) string {
+		args = append(args, v)
+		return s.cfg.Placeholder(len(args))
+	}
+	if len(filter.Types) > 0 {
+		in := make([]string, len(filter.Types))
+		for i, t := range filter.Types {
+			in[i] = arg(t)
+		}
+		where = append(where, `event_type IN (`+strings.Join(in, ", ")+`)`)
+	}
+	if filter.SourceID != "" {
+		where = append(where, `source_id = `+arg(filter.SourceID))
+	}
+	if filter.WorkspaceID != "" {
+		where = append(where, `workspace_id = `+arg(filter.WorkspaceID))
+	}
+	if !filter.Since.IsZero() {
+		where = append(where, `occurred_at >= `+arg(filter.Since.UnixNano()))
+	}
+	if !filter.Until.IsZero() {
+		where = append(where, `occurred_at < `+arg(filter.Until.UnixNano()))
+	}
+
+	query := `SELECT id, event_type, source_id, workspace_id, payload, occurred_at FROM ` + s.cfg.Table
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+	query += ` ORDER BY occurred_at, recorded_at, id`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("eventbus: querying event store: %w", err)
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var (
+			event      Event
+			payload    string
+			occurredAt int64
+		)
+		if err := rows.Scan(&event.ID, &event.Type, &event.SourceID, &event.WorkspaceID, &payload, &occurredAt); err != nil {
+			return nil, fmt.Errorf("eventbus: scanning stored event: %w", err)
+		}
+		if err := json.Unmarshal([]byte(payload), &event.Payload); err != nil {
+			return nil, fmt.Errorf("eventbus: decoding stored payload %s: %w", event.ID, err)
+		}
+		event.Timestamp = time.Unix(0, occurredAt).UTC()
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("eventbus: reading stored events: %w", err)
+	}
+	return events, nil
+}
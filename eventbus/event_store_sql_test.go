@@ -0,0 +1,106 @@
+package eventbus
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSQLEventStore(t *testing.T) {
+	t.Parallel()
+
+	db := openTestSQLite(t)
+	store := NewSQLEventStore(db, SQLEventStoreConfig{})
+	ctx := context.Background()
+	if err := store.CreateTables(ctx); err != nil {
+		t.Fatalf("CreateTables: %v", err)
+	}
+
+	base := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	events := []Event{
+		{ID: "e2", Type: EventTypeRevenueCompleted, SourceID: "rev-1", WorkspaceID: "ws", Timestamp: base.Add(time.Hour), Payload: map[string]any{"amount": 1000.0}},
+		{ID: "e1", Type: EventTypeCollectionReceived, SourceID: "col-1", WorkspaceID: "ws", Timestamp: base},
+		{ID: "e3", Type: EventTypeRevenueCompleted, SourceID: "rev-2", Timestamp: base.Add(2 * time.Hour)},
+	}
+	for _, e := range events {
+		if err := store.Append(ctx, e); err != nil {
+			t.Fatalf("Append %s: %v", e.ID, err)
+		}
+	}
+	if err := store.Append(ctx, events[0]); err != nil {
+		t.Errorf("duplicate Append: %v", err)
+	}
+
+	all, err := store.Query(ctx, EventFilter{})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(all) != 3 || all[0].ID != "e1" || all[2].ID != "e3" {
+		t.Fatalf("events = %+v, want e1, e2, e3", all)
+	}
+	if all[1].Payload["amount"] != 1000.0 || !all[1].Timestamp.Equal(base.Add(time.Hour)) || all[1].WorkspaceID != "ws" {
+		t.Errorf("round-tripped event = %+v", all[1])
+	}
+
+	tests := []struct {
+		name   string
+		filter EventFilter
+		want   []string
+	}{
+		{"by type", EventFilter{Types: []string{EventTypeRevenueCompleted}}, []string{"e2", "e3"}},
+		{"by several types", EventFilter{Types: []string{EventTypeRevenueCompleted, EventTypeCollectionReceived}}, []string{"e1", "e2", "e3"}},
+		{"by source", EventFilter{SourceID: "rev-2"}, []string{"e3"}},
+		{"by workspace", EventFilter{WorkspaceID: "ws"}, []string{"e1", "e2"}},
+		{"time range", EventFilter{Since: base.Add(time.Hour), Until: base.Add(2 * time.Hour)}, []string{"e2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.Query(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("Query: %v", err)
+			}
+			var ids []string
+			for _, e := range got {
+				ids = append(ids, e.ID)
+			}
+			if len(ids) != len(tt.want) {
+				t.Fatalf("ids = %v, want %v", ids, tt.want)
+			}
+			for i := range ids {
+				if ids[i] != tt.want[i] {
+					t.Errorf("ids = %v, want %v", ids, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestStoringEventBus_OutboxTransaction(t *testing.T) {
+	t.Parallel()
+
+	outbox, db, _ := newTestOutbox(t, OutboxConfig{})
+	store := NewSQLEventStore(db, SQLEventStoreConfig{})
+	ctx := context.Background()
+	if err := store.CreateTables(ctx); err != nil {
+		t.Fatalf("CreateTables: %v", err)
+	}
+	bus := NewStoringEventBus(outbox, store)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+	if err := bus.Publish(ContextWithTx(ctx, tx), Event{Type: EventTypeRevenueCompleted, SourceID: "rev-1"}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+
+	stored, _ := store.Query(ctx, EventFilter{})
+	var pending int
+	_ = db.QueryRow(`SELECT COUNT(*) FROM eventbus_outbox`).Scan(&pending)
+	if len(stored) != 0 || pending != 0 {
+		t.Errorf("after rollback: stored = %d, outbox = %d, want 0 and 0", len(stored), pending)
+	}
+}
@@ -0,0 +1,80 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStoringEventBus(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryEventStore()
+	bus := NewStoringEventBus(NewMemoryEventBus(), store)
+	base := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	bus.now = func() time.Time { return base }
+
+	var handled []Event
+	bus.Subscribe("revenue.completed", func(ctx context.Context, e Event) error {
+		handled = append(handled, e)
+		if e.SourceID == "bad" {
+			return errors.New("account not found")
+		}
+		return nil
+	})
+
+	ctx := context.Background()
+	if err := bus.Publish(ctx, Event{Type: "revenue.completed", SourceID: "rev-1"}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if err := bus.Publish(ctx, Event{Type: "revenue.completed", SourceID: "bad", Timestamp: base.Add(time.Hour)}); err == nil {
+		t.Fatal("handler error not returned")
+	}
+	if err := bus.Publish(ctx, Event{Type: "collection.received", SourceID: "col-1", Timestamp: base.Add(-time.Hour)}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if err := bus.Publish(ctx, Event{}); err == nil {
+		t.Error("empty event type accepted")
+	}
+
+	all, _ := store.Query(ctx, EventFilter{})
+	if len(all) != 3 {
+		t.Fatalf("stored = %d, want 3 (including the failed delivery)", len(all))
+	}
+	if all[0].SourceID != "col-1" || all[2].SourceID != "bad" {
+		t.Errorf("order = %s, %s, %s, want oldest first", all[0].SourceID, all[1].SourceID, all[2].SourceID)
+	}
+	if all[1].ID == "" || all[1].ID != handled[0].ID || !all[1].Timestamp.Equal(base) {
+		t.Errorf("stored event %+v does not match delivered %+v", all[1], handled[0])
+	}
+
+	tests := []struct {
+		name   string
+		filter EventFilter
+		want   int
+	}{
+		{"by type", EventFilter{Types: []string{"revenue.completed"}}, 2},
+		{"by source", EventFilter{SourceID: "col-1"}, 1},
+		{"since inclusive", EventFilter{Since: base}, 2},
+		{"until exclusive", EventFilter{Until: base}, 1},
+		{"combined", EventFilter{Types: []string{"revenue.completed"}, Since: base, Until: base.Add(time.Hour)}, 1},
+		{"no match", EventFilter{WorkspaceID: "clinic"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := store.Query(ctx, tt.filter)
+			if len(got) != tt.want {
+				t.Errorf("events = %d, want %d", len(got), tt.want)
+			}
+		})
+	}
+
+	t.Run("duplicate IDs are stored once", func(t *testing.T) {
+		_ = store.Append(ctx, all[0])
+		got, _ := store.Query(ctx, EventFilter{SourceID: "col-1"})
+		if len(got) != 1 {
+			t.Errorf("events = %d, want 1", len(got))
+		}
+	})
+}
@@ -12,12 +12,18 @@ package eventbus
 
 import (
 	"context"
+	"crypto/rand"
+	"fmt"
 	"time"
 )
 
 // Event represents a domain event published by an operational module.
 // The Type field drives the journal posting logic in JournalPoster.
 type Event struct {
+	// ID uniquely identifies the event. Every bus assigns one with
+	// NewEventID on publish when it is empty.
+	ID string
+
 	// Type is the event type string, e.g. "revenue.completed".
 	// See the full list of recognized types in journal_poster.go.
 	Type string
@@ -25,9 +31,14 @@ type Event struct {
 	// SourceID is the ID of the originating entity (revenue ID, loan ID, etc.).
 	SourceID string
 
+	// WorkspaceID scopes the event to a workspace. JournalPoster uses it to
+	// select workspace posting rules and resolve account codes. Optional.
+	WorkspaceID string
+
 	// Payload carries event-specific data used to populate journal lines.
-	// Keys are domain-specific; the journal poster documents expected keys
-	// in each handler stub.
+	// Keys are domain-specific; default_posting_rules.yaml documents the
+	// keys expected for each event type and payloads.go defines typed
+	// structs for them (see NewEvent and DecodePayload).
 	Payload map[string]any
 
 	// Timestamp is when the event occurred. Defaults to now if zero.
@@ -35,18 +46,45 @@ type Event struct {
 }
 
 // Handler is a function that processes a domain event.
-// Handlers must be idempotent where possible — the event bus may retry
-// failed deliveries in future implementations.
+// Handlers must be idempotent where possible — OutboxEventBus redelivers
+// failed events. Wrap a handler with Idempotent to skip duplicates.
 type Handler func(ctx context.Context, event Event) error
 
 // EventBus is the interface for publishing and subscribing to domain events.
-// Phase 9 uses MemoryEventBus; a persistent implementation can replace it.
+// MemoryEventBus is the in-process default; AsyncEventBus moves handlers off
+// the publishing goroutine and OutboxEventBus adds durable, transactional
+// delivery. StoringEventBus wraps any of them to keep an EventStore of every
+// published event for replay, and ValidatingEventBus to reject malformed
+// payloads at Publish time.
 type EventBus interface {
 	// Publish sends an event to all registered handlers for the event type.
 	// Returns the first handler error encountered; does not short-circuit.
 	Publish(ctx context.Context, event Event) error
 
-	// Subscribe registers a handler for a specific event type.
+	// Subscribe registers a handler for a specific event type, or for every
+	// type matching a pattern such as "revenue.*" or "*" (see pattern.go).
 	// Multiple handlers may be registered for the same event type.
 	Subscribe(eventType string, handler Handler)
 }
+
+// callHandler invokes h and converts a panic into an error, for buses whose
+// dispatch loop must survive a misbehaving handler.
+func callHandler(ctx context.Context, h Handler, event Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("eventbus: handler panicked: %v", r)
+		}
+	}()
+	return h(ctx, event)
+}
+
+// NewEventID returns a random RFC 4122 version 4 UUID string.
+func NewEventID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("eventbus: generating event ID: %v", err))
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
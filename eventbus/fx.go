@@ -0,0 +1,340 @@
+package eventbus
+
+// Multi-currency posting. An event whose payload carries a "currency" other
+// than the functional (ledger) currency has its rule amounts read in that
+// transaction currency. JournalPoster converts every line to the functional
+// currency at the rate from JournalPosterDeps.FXRates (or the payload's
+// "fx_rate" when the publisher already knows the rate, e.g. the bank's
+// settlement rate), and keeps the transaction amounts and rate on the line:
+//
+//   Debit, Credit                          functional currency, in centavos
+//   TransactionDebit, TransactionCredit    transaction currency, in minor units
+//   Currency, FXRate                       transaction currency and the rate used
+//
+// Minor units follow the currency's ISO 4217 exponent: cents for USD, whole
+// yen for JPY. Rule amounts are evaluated to two decimal places, so a
+// currency with three (KWD, BHD and the like) is rejected, as is a fraction
+// of a unit in a currency with none.
+//
+// Lines are converted one by one and rounded half away from zero; any
+// rounding difference left between total debits and credits is absorbed by
+// the largest line on the lighter side, so the entry still balances in the
+// functional currency.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CurrencyKey is the payload key holding the ISO 4217 transaction currency.
+// Omit it for events in the functional currency.
+const CurrencyKey = "currency"
+
+// FXRateKey is the payload key holding an explicit rate from the transaction
+// currency to the functional currency. Optional; overrides the provider.
+const FXRateKey = "fx_rate"
+
+// DefaultFunctionalCurrency is the ledger currency used when
+// JournalPosterDeps.FunctionalCurrency is empty.
+const DefaultFunctionalCurrency = "PHP"
+
+// ErrNoFXRate is returned when no exchange rate is known for a currency pair.
+var ErrNoFXRate = errors.New("no exchange rate")
+
+// FXRateProvider returns the rate that converts one unit of from into to,
+// effective on the given date (the event timestamp; zero means latest).
+// Consumer apps can back it with a treasury rates table or a market feed.
+type FXRateProvider interface {
+	Rate(ctx context.Context, from, to string, on time.Time) (float64, error)
+}
+
+// FXRate is one exchange rate, effective from Effective onward until a rate
+// with a later Effective date replaces it. A zero Effective applies to all
+// dates.
+type FXRate struct {
+	From      string    `json:"from" yaml:"from"`
+	To        string    `json:"to" yaml:"to"`
+	Rate      float64   `json:"rate" yaml:"rate"`
+	Effective time.Time `json:"-" yaml:"-"`
+}
+
+// StaticFXRates is an FXRateProvider over a fixed list of rates, typically
+// loaded from a file with LoadFXRatesFile. A pair without a rate of its own
+// is served from the inverse pair (1/rate).
+type StaticFXRates struct {
+	rates map[string][]FXRate // "FROM/TO" → rates, oldest first
+}
+
+// NewStaticFXRates validates rates and builds a provider from them.
+func NewStaticFXRates(rates ...FXRate) (*StaticFXRates, error) {
+	s := &StaticFXRates{rates: make(map[string][]FXRate)}
+	for i, r := range rates {
+		r.From, r.To = normalizeCurrency(r.From), normalizeCurrency(r.To)
+		if !validCurrency(r.From) || !validCurrency(r.To) {
+			return nil, fmt.Errorf("eventbus: fx rate %d: invalid currency pair %q/%q", i+1, r.From, r.To)
+		}
+		if r.Rate <= 0 || math.IsInf(r.Rate, 0) || math.IsNaN(r.Rate) {
+			return nil, fmt.Errorf("eventbus: fx rate %d: %s/%s rate must be positive, got %v", i+1, r.From, r.To, r.Rate)
+		}
+		key := r.From + "/" + r.To
+		s.rates[key] = append(s.rates[key], r)
+	}
+	for _, list := range s.rates {
+		sort.SliceStable(list, func(i, j int) bool { return list[i].Effective.Before(list[j].Effective) })
+	}
+	return s, nil
+}
+
+// Rate returns the latest rate for from/to effective on or before on.
+func (s *StaticFXRates) Rate(ctx context.Context, from, to string, on time.Time) (float64, error) {
+	from, to = normalizeCurrency(from), normalizeCurrency(to)
+	if from == to {
+		return 1, nil
+	}
+	if r, ok := effectiveRate(s.rates[from+"/"+to], on); ok {
+		return r, nil
+	}
+	if r, ok := effectiveRate(s.rates[to+"/"+from], on); ok {
+		return 1 / r, nil
+	}
+	if on.IsZero() {
+		return 0, fmt.Errorf("eventbus: %w for %s/%s", ErrNoFXRate, from, to)
+	}
+	return 0, fmt.Errorf("eventbus: %w for %s/%s on %s", ErrNoFXRate, from, to, on.Format("2006-01-02"))
+}
+
+// effectiveRate picks the last rate in list effective on or before on.
+func effectiveRate(list []FXRate, on time.Time) (float64, bool) {
+	for i := len(list) - 1; i >= 0; i-- {
+		if on.IsZero() || list[i].Effective.IsZero() || !list[i].Effective.After(on) {
+			return list[i].Rate, true
+		}
+	}
+	return 0, false
+}
+
+// fxRateFile is the on-disk form of a rate list:
+//
+//	rates:
+//	  - from: USD
+//	    to: PHP
+//	    rate: 56.25
+//	    effective: 2026-01-01
+type fxRateFile struct {
+	Rates []struct {
+		FXRate    `yaml:",inline"`
+		Effective string `json:"effective" yaml:"effective"`
+	} `json:"rates" yaml:"rates"`
+}
+
+// ParseFXRatesYAML parses a YAML rate list (see LoadFXRatesFile).
+func ParseFXRatesYAML(data []byte) (*StaticFXRates, error) {
+	var f fxRateFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("eventbus: parsing fx rates YAML: %w", err)
+	}
+	return f.build()
+}
+
+// ParseFXRatesJSON parses a JSON rate list (see LoadFXRatesFile).
+func ParseFXRatesJSON(data []byte) (*StaticFXRates, error) {
+	var f fxRateFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("eventbus: parsing fx rates JSON: %w", err)
+	}
+	return f.build()
+}
+
+// LoadFXRatesFile reads a rate list from a .json, .yaml or .yml file. Each
+// entry has from, to, rate and an optional effective date (YYYY-MM-DD).
+func LoadFXRatesFile(path string) (*StaticFXRates, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("eventbus: reading fx rates: %w", err)
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return ParseFXRatesJSON(data)
+	}
+	return ParseFXRatesYAML(data)
+}
+
+func (f fxRateFile) build() (*StaticFXRates, error) {
+	rates := make([]FXRate, len(f.Rates))
+	for i, r := range f.Rates {
+		rates[i] = r.FXRate
+		if r.Effective != "" {
+			on, err := time.Parse("2006-01-02", r.Effective)
+			if err != nil {
+				return nil, fmt.Errorf("eventbus: fx rate %d: effective date %q: want YYYY-MM-DD", i+1, r.Effective)
+			}
+			rates[i].Effective = on
+		}
+	}
+	return NewStaticFXRates(rates...)
+}
+
+// ---------------------------------------------------------------------------
+// Conversion
+// ---------------------------------------------------------------------------
+
+var currencyCodeRegex = regexp.MustCompile(`^[A-Z]{3}$`)
+
+func normalizeCurrency(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func validCurrency(code string) bool {
+	return currencyCodeRegex.MatchString(code)
+}
+
+// minorUnitExponents lists the ISO 4217 currencies whose minor unit is not
+// the hundredth; every other currency has two decimal places.
+var minorUnitExponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// minorUnitExponent returns the number of decimal places of currency.
+func minorUnitExponent(currency string) int {
+	if exp, ok := minorUnitExponents[currency]; ok {
+		return exp
+	}
+	return 2
+}
+
+// toMinorUnits converts an amount in hundredths of a currency unit, as rule
+// amounts are evaluated, to minor units with the given exponent (0 or 2).
+// Fractions of the minor unit are an error.
+func toMinorUnits(hundredths int64, exp int) (int64, error) {
+	if exp == 2 {
+		return hundredths, nil
+	}
+	if hundredths%100 != 0 {
+		return 0, fmt.Errorf("%s is not a whole amount", formatCentavos(hundredths))
+	}
+	return hundredths / 100, nil
+}
+
+// formatMinorUnits formats an amount in minor units with exp decimal places.
+func formatMinorUnits(amount int64, exp int) string {
+	return strconv.FormatFloat(float64(amount)/math.Pow10(exp), 'f', exp, 64)
+}
+
+// payloadCurrency reads CurrencyKey and FXRateKey from a payload. rate is 0
+// when the payload carries no explicit rate.
+func payloadCurrency(payload map[string]any) (currency string, rate float64, err error) {
+	if raw, ok := payload[CurrencyKey]; ok && raw != nil {
+		s, isString := raw.(string)
+		if !isString || !validCurrency(normalizeCurrency(s)) {
+			return "", 0, fmt.Errorf("%q must be an ISO 4217 code such as \"USD\", got %s", CurrencyKey, describeValue(raw))
+		}
+		currency = normalizeCurrency(s)
+		if exp := minorUnitExponent(currency); exp > 2 {
+			return "", 0, fmt.Errorf("%q %s has %d decimal places; only currencies with 2 or none are supported", CurrencyKey, currency, exp)
+		}
+	}
+	if raw, ok := payload[FXRateKey]; ok && raw != nil {
+		rate, err = toFloat(raw)
+		if err != nil || rate <= 0 {
+			return "", 0, fmt.Errorf("%q must be a positive number, got %s", FXRateKey, describeValue(raw))
+		}
+	}
+	return currency, rate, nil
+}
+
+// functionalCurrency returns the configured ledger currency.
+func (p *JournalPoster) functionalCurrency() string {
+	if p.deps.FunctionalCurrency == "" {
+		return DefaultFunctionalCurrency
+	}
+	return normalizeCurrency(p.deps.FunctionalCurrency)
+}
+
+// convert translates an entry evaluated in the event's transaction currency
+// into the functional currency. Entries already in the functional currency
+// are left untouched.
+func (p *JournalPoster) convert(ctx context.Context, event Event, entry *PostingEntry) error {
+	currency, rate, err := payloadCurrency(event.Payload)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+	functional := p.functionalCurrency()
+	if currency == "" || currency == functional {
+		return nil
+	}
+	if rate == 0 {
+		if p.deps.FXRates == nil {
+			return fmt.Errorf("no FXRateProvider configured for %s/%s", currency, functional)
+		}
+		rate, err = p.deps.FXRates.Rate(ctx, currency, functional, event.Timestamp)
+		if err != nil {
+			return err
+		}
+	}
+
+	exp := minorUnitExponent(currency)
+	entry.Currency = currency
+	entry.FXRate = rate
+	for i := range entry.Lines {
+		l := &entry.Lines[i]
+		l.Currency = currency
+		l.FXRate = rate
+		if l.TransactionDebit, err = toMinorUnits(l.Debit, exp); err != nil {
+			return fmt.Errorf("%w: line %d: %s %v", ErrInvalidPayload, i+1, currency, err)
+		}
+		if l.TransactionCredit, err = toMinorUnits(l.Credit, exp); err != nil {
+			return fmt.Errorf("%w: line %d: %s %v", ErrInvalidPayload, i+1, currency, err)
+		}
+		l.Debit = int64(math.Round(float64(l.Debit) * rate))
+		l.Credit = int64(math.Round(float64(l.Credit) * rate))
+	}
+	absorbRounding(entry.Lines)
+	return nil
+}
+
+// absorbRounding moves the functional-currency rounding difference of a
+// converted entry onto the largest line of the lighter side. It does nothing
+// when the transaction amounts themselves do not balance.
+func absorbRounding(lines []JournalLine) {
+	var txDebit, txCredit, debit, credit int64
+	for _, l := range lines {
+		txDebit += l.TransactionDebit
+		txCredit += l.TransactionCredit
+		debit += l.Debit
+		credit += l.Credit
+	}
+	if txDebit != txCredit || debit == credit {
+		return
+	}
+
+	largest := -1
+	for i, l := range lines {
+		if debit < credit && l.Debit > 0 && (largest < 0 || l.Debit > lines[largest].Debit) {
+			largest = i
+		}
+		if credit < debit && l.Credit > 0 && (largest < 0 || l.Credit > lines[largest].Credit) {
+			largest = i
+		}
+	}
+	if largest < 0 {
+		return
+	}
+	if debit < credit {
+		lines[largest].Debit += credit - debit
+	} else {
+		lines[largest].Credit += debit - credit
+	}
+}
@@ -0,0 +1,245 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStaticFXRates(t *testing.T) {
+	t.Parallel()
+
+	jan := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	mar := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	rates, err := NewStaticFXRates(
+		FXRate{From: "USD", To: "PHP", Rate: 57.10, Effective: mar},
+		FXRate{From: "usd", To: "php", Rate: 56.25, Effective: jan},
+		FXRate{From: "PHP", To: "JPY", Rate: 2.5},
+	)
+	if err != nil {
+		t.Fatalf("NewStaticFXRates: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		from, to string
+		on       time.Time
+		want     float64
+		wantErr  bool
+	}{
+		{"before the first rate", "USD", "PHP", jan.AddDate(0, 0, -1), 0, true},
+		{"on the effective date", "USD", "PHP", jan, 56.25, false},
+		{"between rates", "USD", "PHP", mar.AddDate(0, 0, -1), 56.25, false},
+		{"after the latest rate", "USD", "PHP", mar.AddDate(0, 1, 0), 57.10, false},
+		{"zero date uses the latest", "USD", "PHP", time.Time{}, 57.10, false},
+		{"inverse pair", "JPY", "PHP", jan, 0.4, false},
+		{"same currency", "EUR", "EUR", jan, 1, false},
+		{"unknown pair", "EUR", "PHP", jan, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := rates.Rate(context.Background(), tt.from, tt.to, tt.on)
+			if tt.wantErr {
+				if !errors.Is(err, ErrNoFXRate) {
+					t.Errorf("error = %v, want ErrNoFXRate", err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("Rate = %v, %v; want %v", got, err, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewStaticFXRates(FXRate{From: "USD", To: "PHP", Rate: 0}); err == nil {
+		t.Error("zero rate accepted")
+	}
+	if _, err := NewStaticFXRates(FXRate{From: "US", To: "PHP", Rate: 1}); err == nil {
+		t.Error("invalid currency code accepted")
+	}
+}
+
+func TestLoadFXRatesFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"rates.yaml": "rates:\n  - from: USD\n    to: PHP\n    rate: 56.25\n    effective: 2026-01-01\n",
+		"rates.json": `{"rates": [{"from": "USD", "to": "PHP", "rate": 56.25, "effective": "2026-01-01"}]}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		rates, err := LoadFXRatesFile(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got, err := rates.Rate(context.Background(), "USD", "PHP", time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)); err != nil || got != 56.25 {
+			t.Errorf("%s: Rate = %v, %v", name, got, err)
+		}
+		if _, err := rates.Rate(context.Background(), "USD", "PHP", time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC)); err == nil {
+			t.Errorf("%s: rate applied before its effective date", name)
+		}
+	}
+
+	if _, err := ParseFXRatesYAML([]byte("rates:\n  - {from: USD, to: PHP, rate: 56, effective: 01/02/2026}\n")); err == nil {
+		t.Error("bad effective date accepted")
+	}
+}
+
+func TestJournalPoster_ForeignCurrency(t *testing.T) {
+	t.Parallel()
+
+	rates, _ := NewStaticFXRates(FXRate{From: "USD", To: "PHP", Rate: 56.255})
+	poster := NewJournalPoster(&JournalPosterDeps{FXRates: rates})
+	ctx := context.Background()
+
+	t.Run("lines carry both amounts and the rate", func(t *testing.T) {
+		t.Parallel()
+
+		entry, err := poster.BuildEntry(ctx, Event{Type: EventTypeRevenueCompleted, SourceID: "rev-1", Payload: map[string]any{
+			"amount": 1000, "currency": "usd", "account_ar": "ar", "account_rev": "rev",
+		}})
+		if err != nil {
+			t.Fatalf("BuildEntry: %v", err)
+		}
+		if entry.Currency != "USD" || entry.FXRate != 56.255 {
+			t.Errorf("entry currency = %s @ %v", entry.Currency, entry.FXRate)
+		}
+		for _, l := range entry.Lines {
+			if l.Currency != "USD" || l.FXRate != 56.255 || l.TransactionDebit+l.TransactionCredit != 100000 || l.Debit+l.Credit != 5625500 {
+				t.Errorf("line = %+v, want USD 1000.00 → PHP 56255.00", l)
+			}
+		}
+		if notes := entry.toProto().GetNotes(); !strings.Contains(notes, "(USD 1000.00 at 56.255)") {
+			t.Errorf("notes = %q", notes)
+		}
+	})
+
+	t.Run("rounding difference is absorbed", func(t *testing.T) {
+		t.Parallel()
+
+		// 0.01 + 0.01 USD convert to 0.56 + 0.56 PHP but 0.02 USD to 1.13.
+		entry, err := poster.BuildEntry(ctx, Event{Type: EventTypeLoanPayment, SourceID: "lp-1", Payload: map[string]any{
+			"total_amount": 0.02, "principal_amount": 0.01, "interest_amount": 0.01, "currency": "USD",
+			"account_loan": "loan", "account_interest": "interest", "account_cash": "cash",
+		}})
+		if err != nil {
+			t.Fatalf("BuildEntry: %v", err)
+		}
+		debit, credit := entry.Totals()
+		if debit != 113 || credit != 113 || entry.Lines[0].Debit != 57 {
+			t.Errorf("lines = %+v, want the loan line raised to 0.57", entry.Lines)
+		}
+	})
+
+	t.Run("explicit rate overrides the provider", func(t *testing.T) {
+		t.Parallel()
+
+		entry, err := NewJournalPoster(nil).BuildEntry(ctx, Event{Type: EventTypeCollectionReceived, SourceID: "col-1", Payload: map[string]any{
+			"amount": 10, "currency": "USD", "fx_rate": 55.5, "account_cash": "cash", "account_ar": "ar",
+		}})
+		if err != nil {
+			t.Fatalf("BuildEntry: %v", err)
+		}
+		if debit, _ := entry.Totals(); debit != 55500 {
+			t.Errorf("debit = %d, want 55500", debit)
+		}
+	})
+
+	t.Run("zero-decimal currency keeps whole units", func(t *testing.T) {
+		t.Parallel()
+
+		entry, err := NewJournalPoster(nil).BuildEntry(ctx, Event{Type: EventTypeCollectionReceived, SourceID: "col-4", Payload: map[string]any{
+			"amount": 15000, "currency": "JPY", "fx_rate": 0.37, "account_cash": "cash", "account_ar": "ar",
+		}})
+		if err != nil {
+			t.Fatalf("BuildEntry: %v", err)
+		}
+		if l := entry.Lines[0]; l.TransactionDebit != 15000 || l.Debit != 555000 {
+			t.Errorf("first line = %+v, want JPY 15000 → PHP 5550.00", l)
+		}
+		if notes := entry.toProto().GetNotes(); !strings.Contains(notes, "(JPY 15000 at 0.37)") {
+			t.Errorf("notes = %q", notes)
+		}
+	})
+
+	t.Run("functional currency is not converted", func(t *testing.T) {
+		t.Parallel()
+
+		entry, err := poster.BuildEntry(ctx, Event{Type: EventTypeCollectionReceived, SourceID: "col-2", Payload: map[string]any{
+			"amount": 10, "currency": "PHP", "account_cash": "cash", "account_ar": "ar",
+		}})
+		if err != nil {
+			t.Fatalf("BuildEntry: %v", err)
+		}
+		if entry.Currency != "" || entry.Lines[0].Currency != "" || entry.Lines[0].Debit != 1000 {
+			t.Errorf("entry = %+v", entry)
+		}
+	})
+
+	t.Run("reversal swaps transaction amounts", func(t *testing.T) {
+		t.Parallel()
+
+		entry, err := poster.BuildReversalEntry(ctx, Event{Type: EventTypeRevenueCancelled, SourceID: "rev-1", Payload: map[string]any{
+			"partial": true, "amount": 100, "currency": "USD", "account_ar": "ar", "account_rev": "rev",
+		}})
+		if err != nil {
+			t.Fatalf("BuildReversalEntry: %v", err)
+		}
+		if l := entry.Lines[0]; l.Credit != 562550 || l.TransactionCredit != 10000 || l.TransactionDebit != 0 {
+			t.Errorf("first counter line = %+v", l)
+		}
+	})
+
+	rejects := []struct {
+		name    string
+		poster  *JournalPoster
+		payload map[string]any
+		want    string
+	}{
+		{"no provider", NewJournalPoster(nil), map[string]any{"currency": "USD"}, "no FXRateProvider configured for USD/PHP"},
+		{"unknown rate", poster, map[string]any{"currency": "EUR"}, "no exchange rate for EUR/PHP"},
+		{"bad currency code", poster, map[string]any{"currency": "dollars"}, `"currency" must be an ISO 4217 code`},
+		{"bad rate", poster, map[string]any{"currency": "USD", "fx_rate": "56"}, `"fx_rate" must be a positive number`},
+		{"three-decimal currency", poster, map[string]any{"currency": "KWD", "fx_rate": 185.0}, `"currency" KWD has 3 decimal places`},
+		{"fraction of a yen", poster, map[string]any{"currency": "JPY", "fx_rate": 0.37, "amount": 10.5}, "line 1: JPY 10.50 is not a whole amount"},
+	}
+	for _, tt := range rejects {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			payload := map[string]any{"amount": 10, "account_cash": "cash", "account_ar": "ar"}
+			for k, v := range tt.payload {
+				payload[k] = v
+			}
+			_, err := tt.poster.BuildEntry(ctx, Event{Type: EventTypeCollectionReceived, SourceID: "col-3", Payload: payload})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatingEventBus_Currency(t *testing.T) {
+	t.Parallel()
+
+	bus := NewValidatingEventBus(NewMemoryEventBus(), ValidatingConfig{})
+	ctx := context.Background()
+	ok := Event{Type: EventTypeCollectionReceived, Payload: map[string]any{"amount": 10, "currency": "USD", "fx_rate": 56.1}}
+	if err := bus.Publish(ctx, ok); err != nil {
+		t.Errorf("currency keys rejected: %v", err)
+	}
+	bad := Event{Type: EventTypeCollectionReceived, Payload: map[string]any{"amount": 10, "currency": 840}}
+	if err := bus.Publish(ctx, bad); !errors.Is(err, ErrInvalidPayload) {
+		t.Errorf("error = %v, want ErrInvalidPayload", err)
+	}
+}
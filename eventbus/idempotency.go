@@ -0,0 +1,255 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ProcessedKey identifies what a consumer has already handled.
+//
+// Consumer names the handler (e.g. "journal_poster") so several idempotent
+// handlers on the same event type are tracked independently. Idempotent
+// leaves EventID empty, so the key is one delivery per (EventType, SourceID):
+// single-shot events such as "revenue.completed" are handled once per source
+// even when published twice, though each publish gets a fresh Event.ID.
+// IdempotentPerEvent sets EventID, for event types a source raises
+// repeatedly (monthly "asset.depreciated", partial reversals).
+type ProcessedKey struct {
+	Consumer  string
+	EventType string
+	SourceID  string
+	EventID   string
+}
+
+// processedKeyFor builds the key for consumer handling event, including the
+// event ID when perEvent is set.
+func processedKeyFor(consumer string, event Event, perEvent bool) ProcessedKey {
+	key := ProcessedKey{
+		Consumer:  consumer,
+		EventType: event.Type,
+		SourceID:  event.SourceID,
+	}
+	if perEvent {
+		key.EventID = event.ID
+	}
+	return key
+}
+
+// ProcessedEventStore records which events a consumer has already handled.
+// Implementations must be safe for concurrent use.
+type ProcessedEventStore interface {
+	// IsProcessed reports whether key has been marked processed.
+	IsProcessed(ctx context.Context, key ProcessedKey) (bool, error)
+
+	// MarkProcessed records key as processed. Marking an already processed
+	// key is not an error.
+	MarkProcessed(ctx context.Context, key ProcessedKey) error
+}
+
+// JournalEntryLink ties an operational source entity to the journal entry
+// JournalPoster created for it.
+type JournalEntryLink struct {
+	EventType      string
+	SourceID       string
+	EventID        string
+	WorkspaceID    string
+	JournalEntryID string
+
+	// Amount is the entry's total debit in centavos.
+	Amount int64
+
+	// Reversed is set once the entry has been reversed (see MarkReversed).
+	Reversed bool
+
+	// Stage is how far JournalPoster got persisting the entry. The link is
+	// saved as soon as the header exists, so a failed delivery can resume
+	// the same entry instead of creating another.
+	Stage LinkStage
+
+	CreatedAt time.Time
+}
+
+// LinkStage records how far JournalPoster got persisting a linked entry.
+type LinkStage string
+
+const (
+	// LinkComplete means the lines are stored and, when requested, the
+	// entry is posted.
+	LinkComplete LinkStage = ""
+
+	// LinkHeaderCreated means the header exists but its lines do not.
+	LinkHeaderCreated LinkStage = "header"
+
+	// LinkLinesCreated means the lines are stored but the entry is not yet
+	// posted.
+	LinkLinesCreated LinkStage = "lines"
+)
+
+// JournalLinkStore persists source-to-journal-entry links.
+// Implementations must be safe for concurrent use.
+type JournalLinkStore interface {
+	// SaveLink records a link. Saving the same JournalEntryID twice is not an error.
+	SaveLink(ctx context.Context, link JournalEntryLink) error
+
+	// LinksForSource returns every link recorded for sourceID, oldest first.
+	LinksForSource(ctx context.Context, sourceID string) ([]JournalEntryLink, error)
+
+	// MarkReversed flags the link for journalEntryID as reversed.
+	MarkReversed(ctx context.Context, journalEntryID string) error
+
+	// SetLinkStage records how far the entry for journalEntryID has got.
+	SetLinkStage(ctx context.Context, journalEntryID string, stage LinkStage) error
+}
+
+// Idempotent wraps a handler so each source is handled at most once per
+// consumer and event type: events whose (EventType, SourceID) is already
+// recorded in store are skipped, whatever their Event.ID, and an event is
+// recorded only after next succeeds, so failed deliveries are retried.
+//
+// The check and the record are separate calls; two concurrent deliveries of
+// the same event can both run. OutboxEventBus leases rows to one dispatcher
+// at a time, which closes that window in practice.
+func Idempotent(store ProcessedEventStore, consumer string) Middleware {
+	return idempotent(store, consumer, false)
+}
+
+// IdempotentPerEvent is Idempotent keyed on Event.ID as well: redeliveries of
+// one event are skipped, but each new event for the same source is handled.
+// Use it for event types a source raises more than once. An event without an
+// ID fails with ErrMissingEventID rather than being taken for a duplicate;
+// the buses assign IDs on publish, so this only happens when a handler is
+// called directly.
+func IdempotentPerEvent(store ProcessedEventStore, consumer string) Middleware {
+	return idempotent(store, consumer, true)
+}
+
+// ErrMissingEventID is returned by IdempotentPerEvent for an event with an
+// empty ID, which cannot be told apart from other events of its source.
+var ErrMissingEventID = errors.New("eventbus: event ID is required for per-event idempotency")
+
+func idempotent(store ProcessedEventStore, consumer string, perEvent bool) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, event Event) error {
+			if perEvent && event.ID == "" {
+				return fmt.Errorf("%w: %s source=%s for %s", ErrMissingEventID, event.Type, event.SourceID, consumer)
+			}
+			key := processedKeyFor(consumer, event, perEvent)
+			done, err := store.IsProcessed(ctx, key)
+			if err != nil {
+				return fmt.Errorf("eventbus: checking %s source=%s for %s: %w", event.Type, event.SourceID, consumer, err)
+			}
+			if done {
+				log.Printf("[eventbus] %s source=%s id=%s already processed by %s, skipping",
+					event.Type, event.SourceID, event.ID, consumer)
+				return nil
+			}
+			if err := next(ctx, event); err != nil {
+				return err
+			}
+			if err := store.MarkProcessed(ctx, key); err != nil {
+				return fmt.Errorf("eventbus: recording %s source=%s for %s: %w", event.Type, event.SourceID, consumer, err)
+			}
+			return nil
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// In-memory store
+// ---------------------------------------------------------------------------
+
+// MemoryProcessedStore is an in-process ProcessedEventStore and
+// JournalLinkStore. State is lost on restart; use SQLProcessedStore when
+// duplicates must be detected across processes or deployments.
+type MemoryProcessedStore struct {
+	mu        sync.RWMutex
+	processed map[ProcessedKey]time.Time
+	links     map[string][]JournalEntryLink
+}
+
+// NewMemoryProcessedStore creates an empty MemoryProcessedStore.
+func NewMemoryProcessedStore() *MemoryProcessedStore {
+	return &MemoryProcessedStore{
+		processed: make(map[ProcessedKey]time.Time),
+		links:     make(map[string][]JournalEntryLink),
+	}
+}
+
+// IsProcessed reports whether key has been marked processed.
+func (s *MemoryProcessedStore) IsProcessed(ctx context.Context, key ProcessedKey) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.processed[key]
+	return ok, nil
+}
+
+// MarkProcessed records key as processed.
+func (s *MemoryProcessedStore) MarkProcessed(ctx context.Context, key ProcessedKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.processed[key]; !ok {
+		s.processed[key] = time.Now()
+	}
+	return nil
+}
+
+// SaveLink records a source-to-journal-entry link.
+func (s *MemoryProcessedStore) SaveLink(ctx context.Context, link JournalEntryLink) error {
+	if link.CreatedAt.IsZero() {
+		link.CreatedAt = time.Now()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.links[link.SourceID] {
+		if existing.JournalEntryID == link.JournalEntryID {
+			return nil
+		}
+	}
+	s.links[link.SourceID] = append(s.links[link.SourceID], link)
+	return nil
+}
+
+// LinksForSource returns the links recorded for sourceID, oldest first.
+func (s *MemoryProcessedStore) LinksForSource(ctx context.Context, sourceID string) ([]JournalEntryLink, error) {
+	s.mu.RLock()
+	links := append([]JournalEntryLink(nil), s.links[sourceID]...)
+	s.mu.RUnlock()
+
+	sort.SliceStable(links, func(i, j int) bool { return links[i].CreatedAt.Before(links[j].CreatedAt) })
+	return links, nil
+}
+
+// MarkReversed flags the link for journalEntryID as reversed.
+func (s *MemoryProcessedStore) MarkReversed(ctx context.Context, journalEntryID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for sourceID, links := range s.links {
+		for i := range links {
+			if links[i].JournalEntryID == journalEntryID {
+				s.links[sourceID][i].Reversed = true
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("eventbus: no journal link for entry %s", journalEntryID)
+}
+
+// SetLinkStage records how far the entry for journalEntryID has got.
+func (s *MemoryProcessedStore) SetLinkStage(ctx context.Context, journalEntryID string, stage LinkStage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for sourceID, links := range s.links {
+		for i := range links {
+			if links[i].JournalEntryID == journalEntryID {
+				s.links[sourceID][i].Stage = stage
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("eventbus: no journal link for entry %s", journalEntryID)
+}
@@ -0,0 +1,194 @@
+package eventbus
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SQLProcessedStore is a ProcessedEventStore and JournalLinkStore backed by
+// database/sql. It pairs with OutboxEventBus: together they turn the outbox's
+// at-least-once delivery into effectively-once handling.
+type SQLProcessedStore struct {
+	db  *sql.DB
+	cfg SQLProcessedStoreConfig
+	now func() time.Time
+}
+
+// SQLProcessedStoreConfig configures a SQLProcessedStore. Zero values fall
+// back to the defaults noted on each field.
+type SQLProcessedStoreConfig struct {
+	// Table records processed events. Default "eventbus_processed".
+	Table string
+
+	// LinkTable records source-to-journal-entry links.
+	// Default "eventbus_journal_links".
+	LinkTable string
+
+	// Placeholder renders the n-th (1-based) bind parameter.
+	// Default QuestionPlaceholder.
+	Placeholder func(n int) string
+}
+
+// NewSQLProcessedStore creates a SQLProcessedStore backed by db.
+// Call CreateTables (or apply equivalent migrations) before use.
+func NewSQLProcessedStore(db *sql.DB, cfg SQLProcessedStoreConfig) *SQLProcessedStore {
+	if cfg.Table == "" {
+		cfg.Table = "eventbus_processed"
+	}
+	if cfg.LinkTable == "" {
+		cfg.LinkTable = "eventbus_journal_links"
+	}
+	if cfg.Placeholder == nil {
+		cfg.Placeholder = QuestionPlaceholder
+	}
+	return &SQLProcessedStore{db: db, cfg: cfg, now: time.Now}
+}
+
+// CreateTables creates the processed-event and link tables if they do not exist.
+// Like OutboxEventBus.CreateTables, it runs on SQLite and PostgreSQL only.
+func (s *SQLProcessedStore) CreateTables(ctx context.Context) error {
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS ` + s.cfg.Table + ` (
+			consumer     VARCHAR(255) NOT NULL,
+			event_type   VARCHAR(255) NOT NULL,
+			source_id    VARCHAR(255) NOT NULL,
+			event_id     VARCHAR(64) NOT NULL,
+			processed_at BIGINT NOT NULL,
+			PRIMARY KEY (consumer, event_type, source_id, event_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS ` + s.cfg.LinkTable + ` (
+			journal_entry_id VARCHAR(255) PRIMARY KEY,
+			event_type       VARCHAR(255) NOT NULL,
+			source_id        VARCHAR(255) NOT NULL,
+			event_id         VARCHAR(64) NOT NULL,
+			workspace_id     VARCHAR(255) NOT NULL,
+			amount           BIGINT NOT NULL DEFAULT 0,
+			stage            VARCHAR(16) NOT NULL DEFAULT '',
+			created_at       BIGINT NOT NULL,
+			reversed_at      BIGINT
+		)`,
+		`CREATE INDEX IF NOT EXISTS ` + s.cfg.LinkTable + `_source_idx
+			ON ` + s.cfg.LinkTable + ` (source_id, created_at)`,
+	}
+	for _, stmt := range stmts {
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("eventbus: creating processed-event tables: %w", err)
+		}
+	}
+	return nil
+}
+
+// IsProcessed reports whether key has been marked processed.
+func (s *SQLProcessedStore) IsProcessed(ctx context.Context, key ProcessedKey) (bool, error) {
+	query := `SELECT COUNT(*) FROM ` + s.cfg.Table + `
+		WHERE consumer = ` + s.cfg.Placeholder(1) + ` AND event_type = ` + s.cfg.Placeholder(2) + `
+		AND source_id = ` + s.cfg.Placeholder(3) + ` AND event_id = ` + s.cfg.Placeholder(4)
+	var n int
+	if err := s.db.QueryRowContext(ctx, query, key.Consumer, key.EventType, key.SourceID, key.EventID).Scan(&n); err != nil {
+		return false, fmt.Errorf("eventbus: querying processed events: %w", err)
+	}
+	return n > 0, nil
+}
+
+// MarkProcessed records key as processed. A concurrent insert of the same key
+// surfaces as a primary-key violation, which is treated as success.
+func (s *SQLProcessedStore) MarkProcessed(ctx context.Context, key ProcessedKey) error {
+	query := `INSERT INTO ` + s.cfg.Table + ` (consumer, event_type, source_id, event_id, processed_at)
+		VALUES (` + s.cfg.Placeholder(1) + `, ` + s.cfg.Placeholder(2) + `, ` + s.cfg.Placeholder(3) + `, ` +
+		s.cfg.Placeholder(4) + `, ` + s.cfg.Placeholder(5) + `)`
+	_, err := s.db.ExecContext(ctx, query, key.Consumer, key.EventType, key.SourceID, key.EventID, s.now().UnixNano())
+	if err == nil {
+		return nil
+	}
+	if done, checkErr := s.IsProcessed(ctx, key); checkErr == nil && done {
+		return nil
+	}
+	return fmt.Errorf("eventbus: recording processed event: %w", err)
+}
+
+// SaveLink records a source-to-journal-entry link.
+func (s *SQLProcessedStore) SaveLink(ctx context.Context, link JournalEntryLink) error {
+	if link.CreatedAt.IsZero() {
+		link.CreatedAt = s.now()
+	}
+	query := `INSERT INTO ` + s.cfg.LinkTable + ` (journal_entry_id, event_type, source_id, event_id, workspace_id, amount, stage, created_at)
+		VALUES (` + s.cfg.Placeholder(1) + `, ` + s.cfg.Placeholder(2) + `, ` + s.cfg.Placeholder(3) + `, ` +
+		s.cfg.Placeholder(4) + `, ` + s.cfg.Placeholder(5) + `, ` + s.cfg.Placeholder(6) + `, ` +
+		s.cfg.Placeholder(7) + `, ` + s.cfg.Placeholder(8) + `)`
+	_, err := s.db.ExecContext(ctx, query, link.JournalEntryID, link.EventType, link.SourceID,
+		link.EventID, link.WorkspaceID, link.Amount, string(link.Stage), link.CreatedAt.UnixNano())
+	if err == nil {
+		return nil
+	}
+
+	var n int
+	exists := `SELECT COUNT(*) FROM ` + s.cfg.LinkTable + ` WHERE journal_entry_id = ` + s.cfg.Placeholder(1)
+	if checkErr := s.db.QueryRowContext(ctx, exists, link.JournalEntryID).Scan(&n); checkErr == nil && n > 0 {
+		return nil
+	}
+	return fmt.Errorf("eventbus: saving journal link for %s: %w", link.JournalEntryID, err)
+}
+
+// LinksForSource returns the links recorded for sourceID, oldest first.
+func (s *SQLProcessedStore) LinksForSource(ctx context.Context, sourceID string) ([]JournalEntryLink, error) {
+	query := `SELECT journal_entry_id, event_type, source_id, event_id, workspace_id, amount, stage, created_at, reversed_at
+		FROM ` + s.cfg.LinkTable + ` WHERE source_id = ` + s.cfg.Placeholder(1) + `
+		ORDER BY created_at, journal_entry_id`
+	rows, err := s.db.QueryContext(ctx, query, sourceID)
+	if err != nil {
+		return nil, fmt.Errorf("eventbus: querying journal links for %s: %w", sourceID, err)
+	}
+	defer rows.Close()
+
+	var links []JournalEntryLink
+	for rows.Next() {
+		var (
+			link       JournalEntryLink
+			stage      string
+			createdAt  int64
+			reversedAt sql.NullInt64
+		)
+		if err := rows.Scan(&link.JournalEntryID, &link.EventType, &link.SourceID,
+			&link.EventID, &link.WorkspaceID, &link.Amount, &stage, &createdAt, &reversedAt); err != nil {
+			return nil, fmt.Errorf("eventbus: scanning journal link: %w", err)
+		}
+		link.CreatedAt = time.Unix(0, createdAt).UTC()
+		link.Reversed = reversedAt.Valid
+		link.Stage = LinkStage(stage)
+		links = append(links, link)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("eventbus: reading journal links for %s: %w", sourceID, err)
+	}
+	return links, nil
+}
+
+// MarkReversed flags the link for journalEntryID as reversed.
+func (s *SQLProcessedStore) MarkReversed(ctx context.Context, journalEntryID string) error {
+	query := `UPDATE ` + s.cfg.LinkTable + ` SET reversed_at = ` + s.cfg.Placeholder(1) + `
+		WHERE journal_entry_id = ` + s.cfg.Placeholder(2)
+	res, err := s.db.ExecContext(ctx, query, s.now().UnixNano(), journalEntryID)
+	if err != nil {
+		return fmt.Errorf("eventbus: marking journal link %s reversed: %w", journalEntryID, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("eventbus: no journal link for entry %s", journalEntryID)
+	}
+	return nil
+}
+
+// SetLinkStage records how far the entry for journalEntryID has got.
+func (s *SQLProcessedStore) SetLinkStage(ctx context.Context, journalEntryID string, stage LinkStage) error {
+	query := `UPDATE ` + s.cfg.LinkTable + ` SET stage = ` + s.cfg.Placeholder(1) + `
+		WHERE journal_entry_id = ` + s.cfg.Placeholder(2)
+	res, err := s.db.ExecContext(ctx, query, string(stage), journalEntryID)
+	if err != nil {
+		return fmt.Errorf("eventbus: recording stage of journal link %s: %w", journalEntryID, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("eventbus: no journal link for entry %s", journalEntryID)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package eventbus
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSQLProcessedStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("processed keys", func(t *testing.T) {
+		t.Parallel()
+
+		store := NewSQLProcessedStore(openTestSQLite(t), SQLProcessedStoreConfig{})
+		if err := store.CreateTables(ctx); err != nil {
+			t.Fatalf("CreateTables: %v", err)
+		}
+
+		key := ProcessedKey{Consumer: JournalPosterConsumer, EventType: "revenue.completed", SourceID: "rev-1", EventID: "evt-1"}
+		if done, err := store.IsProcessed(ctx, key); err != nil || done {
+			t.Fatalf("IsProcessed before mark = %v, %v", done, err)
+		}
+		for i := 0; i < 2; i++ {
+			if err := store.MarkProcessed(ctx, key); err != nil {
+				t.Fatalf("MarkProcessed %d: %v", i+1, err)
+			}
+		}
+		if done, err := store.IsProcessed(ctx, key); err != nil || !done {
+			t.Errorf("IsProcessed after mark = %v, %v", done, err)
+		}
+
+		other := key
+		other.EventID = "evt-2"
+		if done, _ := store.IsProcessed(ctx, other); done {
+			t.Error("different event ID reported as processed")
+		}
+	})
+
+	t.Run("journal links", func(t *testing.T) {
+		t.Parallel()
+
+		store := NewSQLProcessedStore(openTestSQLite(t), SQLProcessedStoreConfig{})
+		if err := store.CreateTables(ctx); err != nil {
+			t.Fatalf("CreateTables: %v", err)
+		}
+
+		base := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+		links := []JournalEntryLink{
+			{EventType: "loan.payment", SourceID: "loan-1", EventID: "e2", JournalEntryID: "je-2", Stage: LinkHeaderCreated, CreatedAt: base.Add(time.Hour)},
+			{EventType: "loan.received", SourceID: "loan-1", EventID: "e1", WorkspaceID: "ws-1", JournalEntryID: "je-1", CreatedAt: base},
+			{EventType: "loan.received", SourceID: "loan-2", EventID: "e3", JournalEntryID: "je-3", CreatedAt: base},
+		}
+		for _, link := range append(links, links[0]) {
+			if err := store.SaveLink(ctx, link); err != nil {
+				t.Fatalf("SaveLink %s: %v", link.JournalEntryID, err)
+			}
+		}
+
+		got, err := store.LinksForSource(ctx, "loan-1")
+		if err != nil {
+			t.Fatalf("LinksForSource: %v", err)
+		}
+		if len(got) != 2 {
+			t.Fatalf("links = %d, want 2", len(got))
+		}
+		if got[0].JournalEntryID != "je-1" || got[1].JournalEntryID != "je-2" {
+			t.Errorf("order = %s, %s, want je-1, je-2", got[0].JournalEntryID, got[1].JournalEntryID)
+		}
+		if got[0].WorkspaceID != "ws-1" || !got[0].CreatedAt.Equal(base) {
+			t.Errorf("link = %+v", got[0])
+		}
+		if got[0].Stage != LinkComplete || got[1].Stage != LinkHeaderCreated {
+			t.Errorf("stages = %q, %q, want complete, header", got[0].Stage, got[1].Stage)
+		}
+
+		if err := store.SetLinkStage(ctx, "je-2", LinkLinesCreated); err != nil {
+			t.Fatalf("SetLinkStage: %v", err)
+		}
+		if got, _ := store.LinksForSource(ctx, "loan-1"); got[1].Stage != LinkLinesCreated {
+			t.Errorf("stage after SetLinkStage = %q, want lines", got[1].Stage)
+		}
+		if err := store.SetLinkStage(ctx, "je-missing", LinkComplete); err == nil {
+			t.Error("SetLinkStage on an unknown entry succeeded")
+		}
+	})
+}
+
+// TestJournalPoster_OutboxDuplicatePublish publishes the same revenue twice
+// through the outbox, which assigns each row its own Event.ID, and checks
+// that only one journal entry is posted.
+func TestJournalPoster_OutboxDuplicatePublish(t *testing.T) {
+	t.Parallel()
+
+	bus, db, _ := newTestOutbox(t, OutboxConfig{})
+	ctx := context.Background()
+	store := NewSQLProcessedStore(db, SQLProcessedStoreConfig{})
+	if err := store.CreateTables(ctx); err != nil {
+		t.Fatalf("CreateTables: %v", err)
+	}
+
+	rec := &recordingDeps{}
+	deps := rec.deps()
+	deps.Processed = store
+	deps.Links = store
+	NewJournalPoster(deps).RegisterAll(bus)
+
+	evt := Event{
+		Type:     EventTypeRevenueCompleted,
+		SourceID: "rev-1",
+		Payload:  map[string]any{"amount": 500.0, "account_ar": "ar", "account_rev": "rev"},
+	}
+	for i := 0; i < 2; i++ {
+		if err := bus.Publish(ctx, evt); err != nil {
+			t.Fatalf("Publish %d: %v", i+1, err)
+		}
+	}
+	if n, err := bus.DispatchPending(ctx); err != nil || n != 2 {
+		t.Fatalf("DispatchPending = %d, %v, want 2 delivered", n, err)
+	}
+
+	if len(rec.created) != 1 {
+		t.Errorf("created entries = %d, want 1", len(rec.created))
+	}
+	links, err := store.LinksForSource(ctx, "rev-1")
+	if err != nil {
+		t.Fatalf("LinksForSource: %v", err)
+	}
+	if len(links) != 1 {
+		t.Errorf("links = %d, want 1", len(links))
+	}
+}
@@ -0,0 +1,334 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	jepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/ledger/journal_entry"
+)
+
+func TestIdempotent(t *testing.T) {
+	t.Parallel()
+
+	t.Run("duplicate event is skipped", func(t *testing.T) {
+		t.Parallel()
+
+		store := NewMemoryProcessedStore()
+		calls := 0
+		handler := Idempotent(store, "test")(func(ctx context.Context, e Event) error {
+			calls++
+			return nil
+		})
+
+		evt := Event{ID: "evt-1", Type: "revenue.completed", SourceID: "rev-1"}
+		for i := 0; i < 3; i++ {
+			if err := handler(context.Background(), evt); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if calls != 1 {
+			t.Errorf("calls = %d, want 1", calls)
+		}
+	})
+
+	t.Run("same source published twice is handled once", func(t *testing.T) {
+		t.Parallel()
+
+		store := NewMemoryProcessedStore()
+		calls := 0
+		handler := Idempotent(store, "test")(func(ctx context.Context, e Event) error {
+			calls++
+			return nil
+		})
+
+		_ = handler(context.Background(), Event{ID: "evt-1", Type: "revenue.completed", SourceID: "rev-1"})
+		_ = handler(context.Background(), Event{ID: "evt-2", Type: "revenue.completed", SourceID: "rev-1"})
+		if calls != 1 {
+			t.Errorf("calls = %d, want 1", calls)
+		}
+	})
+
+	t.Run("per-event: distinct event IDs for the same source are both handled", func(t *testing.T) {
+		t.Parallel()
+
+		store := NewMemoryProcessedStore()
+		calls := 0
+		handler := IdempotentPerEvent(store, "test")(func(ctx context.Context, e Event) error {
+			calls++
+			return nil
+		})
+
+		_ = handler(context.Background(), Event{ID: "dep-2026-03", Type: "asset.deprecated", SourceID: "asset-1"})
+		_ = handler(context.Background(), Event{ID: "dep-2026-04", Type: "asset.deprecated", SourceID: "asset-1"})
+		_ = handler(context.Background(), Event{ID: "dep-2026-04", Type: "asset.deprecated", SourceID: "asset-1"})
+		if calls != 2 {
+			t.Errorf("calls = %d, want 2", calls)
+		}
+	})
+
+	t.Run("per-event: an event without an ID is an error", func(t *testing.T) {
+		t.Parallel()
+
+		store := NewMemoryProcessedStore()
+		calls := 0
+		handler := IdempotentPerEvent(store, "test")(func(ctx context.Context, e Event) error {
+			calls++
+			return nil
+		})
+
+		err := handler(context.Background(), Event{Type: "asset.depreciated", SourceID: "asset-1"})
+		if !errors.Is(err, ErrMissingEventID) || calls != 0 {
+			t.Errorf("error = %v, calls = %d; want ErrMissingEventID and no call", err, calls)
+		}
+	})
+
+	t.Run("failed handling is not recorded", func(t *testing.T) {
+		t.Parallel()
+
+		store := NewMemoryProcessedStore()
+		calls := 0
+		handler := Idempotent(store, "test")(func(ctx context.Context, e Event) error {
+			calls++
+			if calls == 1 {
+				return errors.New("temporary failure")
+			}
+			return nil
+		})
+
+		evt := Event{Type: "loan.received", SourceID: "loan-1"}
+		if err := handler(context.Background(), evt); err == nil {
+			t.Fatal("expected first call to fail")
+		}
+		if err := handler(context.Background(), evt); err != nil {
+			t.Fatalf("retry failed: %v", err)
+		}
+		if calls != 2 {
+			t.Errorf("calls = %d, want 2", calls)
+		}
+	})
+
+	t.Run("consumers are tracked independently", func(t *testing.T) {
+		t.Parallel()
+
+		store := NewMemoryProcessedStore()
+		var a, b int
+		handlerA := Idempotent(store, "a")(func(ctx context.Context, e Event) error { a++; return nil })
+		handlerB := Idempotent(store, "b")(func(ctx context.Context, e Event) error { b++; return nil })
+
+		evt := Event{ID: "evt-1", Type: "payroll.posted", SourceID: "pr-1"}
+		_ = handlerA(context.Background(), evt)
+		_ = handlerB(context.Background(), evt)
+		if a != 1 || b != 1 {
+			t.Errorf("calls = (%d, %d), want (1, 1)", a, b)
+		}
+	})
+}
+
+func TestJournalPoster_Idempotency(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryProcessedStore()
+	rec := &recordingDeps{}
+	deps := rec.deps()
+	deps.Processed = store
+	deps.Links = store
+
+	bus := NewMemoryEventBus()
+	NewJournalPoster(deps).RegisterAll(bus)
+
+	evt := Event{
+		ID:          "evt-rev-1",
+		Type:        EventTypeRevenueCompleted,
+		SourceID:    "rev-1",
+		WorkspaceID: "ws-1",
+		Payload:     map[string]any{"amount": 500.0, "account_ar": "ar", "account_rev": "rev"},
+	}
+	for i := 0; i < 2; i++ {
+		if err := bus.Publish(context.Background(), evt); err != nil {
+			t.Fatalf("publish %d: %v", i+1, err)
+		}
+	}
+
+	if len(rec.created) != 1 {
+		t.Fatalf("created entries = %d, want 1 (redelivery must not double-post)", len(rec.created))
+	}
+
+	links, err := store.LinksForSource(context.Background(), "rev-1")
+	if err != nil {
+		t.Fatalf("LinksForSource: %v", err)
+	}
+	if len(links) != 1 {
+		t.Fatalf("links = %d, want 1", len(links))
+	}
+	link := links[0]
+	if link.JournalEntryID != rec.created[0].GetId() || link.EventID != "evt-rev-1" || link.WorkspaceID != "ws-1" {
+		t.Errorf("link = %+v", link)
+	}
+}
+
+// TestJournalPoster_DuplicatePublish publishes the same source twice through
+// StoringEventBus, which gives each publish a new Event.ID. Single-shot event
+// types must still post once; recurring ones post per event.
+func TestJournalPoster_DuplicatePublish(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryProcessedStore()
+	rec := &recordingDeps{}
+	deps := rec.deps()
+	deps.Processed = store
+	deps.Links = store
+
+	bus := NewStoringEventBus(NewMemoryEventBus(), NewMemoryEventStore())
+	NewJournalPoster(deps).RegisterAll(bus)
+
+	ctx := context.Background()
+	revenue := Event{
+		Type:     EventTypeRevenueCompleted,
+		SourceID: "rev-1",
+		Payload:  map[string]any{"amount": 500.0, "account_ar": "ar", "account_rev": "rev"},
+	}
+	depreciation := Event{
+		Type:     EventTypeAssetDepreciated,
+		SourceID: "asset-1",
+		Payload:  map[string]any{"amount": 100.0, "account_depreciation_exp": "dep", "account_accum_dep": "accum"},
+	}
+	for i := 0; i < 2; i++ {
+		if err := bus.Publish(ctx, revenue); err != nil {
+			t.Fatalf("publish revenue %d: %v", i+1, err)
+		}
+		if err := bus.Publish(ctx, depreciation); err != nil {
+			t.Fatalf("publish depreciation %d: %v", i+1, err)
+		}
+	}
+
+	if links, _ := store.LinksForSource(ctx, "rev-1"); len(links) != 1 {
+		t.Errorf("revenue entries = %d, want 1", len(links))
+	}
+	if links, _ := store.LinksForSource(ctx, "asset-1"); len(links) != 2 {
+		t.Errorf("depreciation entries = %d, want 2 (one per run)", len(links))
+	}
+	if len(rec.created) != 3 {
+		t.Errorf("created entries = %d, want 3", len(rec.created))
+	}
+}
+
+// TestJournalPoster_RecurringWithoutIDs publishes recurring events with no
+// Event.ID on a plain MemoryEventBus: each must post, not be taken for a
+// redelivery of the first.
+func TestJournalPoster_RecurringWithoutIDs(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryProcessedStore()
+	rec := &recordingDeps{}
+	deps := rec.deps()
+	deps.Processed = store
+	deps.Links = store
+
+	bus := NewMemoryEventBus()
+	NewJournalPoster(deps).RegisterAll(bus)
+
+	depreciation := Event{
+		Type:     EventTypeAssetDepreciated,
+		SourceID: "asset-1",
+		Payload:  map[string]any{"amount": 100.0, "account_depreciation_exp": "dep", "account_accum_dep": "accum"},
+	}
+	for i := 0; i < 2; i++ {
+		if err := bus.Publish(context.Background(), depreciation); err != nil {
+			t.Fatalf("publish %d: %v", i+1, err)
+		}
+	}
+
+	if len(rec.created) != 2 {
+		t.Errorf("created entries = %d, want 2 (one per depreciation run)", len(rec.created))
+	}
+}
+
+func TestJournalPoster_ResumesUnfinishedEntry(t *testing.T) {
+	t.Parallel()
+
+	revenue := Event{
+		ID:       "evt-1",
+		Type:     EventTypeRevenueCompleted,
+		SourceID: "rev-1",
+		Payload:  map[string]any{"amount": 100.0, "account_ar": "ar", "account_cash": "cash", "account_rev": "rev"},
+	}
+
+	t.Run("lines fail once", func(t *testing.T) {
+		t.Parallel()
+
+		store := NewMemoryProcessedStore()
+		rec := &recordingDeps{}
+		deps := rec.deps()
+		deps.Processed = store
+		deps.Links = store
+		createLines, failed := deps.CreateLines, false
+		deps.CreateLines = func(ctx context.Context, journalEntryID string, lines []JournalLine) error {
+			if !failed {
+				failed = true
+				return errors.New("connection reset")
+			}
+			return createLines(ctx, journalEntryID, lines)
+		}
+
+		bus := NewMemoryEventBus()
+		NewJournalPoster(deps).RegisterAll(bus)
+
+		if err := bus.Publish(context.Background(), revenue); err == nil {
+			t.Fatal("first delivery succeeded, want the CreateLines error")
+		}
+		if err := bus.Publish(context.Background(), revenue); err != nil {
+			t.Fatalf("retry: %v", err)
+		}
+
+		if len(rec.created) != 1 {
+			t.Fatalf("created entries = %d, want 1", len(rec.created))
+		}
+		if got := len(rec.lines["je-1"]); got != 2 {
+			t.Errorf("lines on je-1 = %d, want 2", got)
+		}
+		links, _ := store.LinksForSource(context.Background(), "rev-1")
+		if len(links) != 1 || links[0].Stage != LinkComplete {
+			t.Errorf("links = %+v, want one complete link", links)
+		}
+	})
+
+	t.Run("posting fails once", func(t *testing.T) {
+		t.Parallel()
+
+		store := NewMemoryProcessedStore()
+		rec := &recordingDeps{}
+		deps := rec.deps()
+		deps.Processed = store
+		deps.Links = store
+		createLines, lineCalls := deps.CreateLines, 0
+		deps.CreateLines = func(ctx context.Context, journalEntryID string, lines []JournalLine) error {
+			lineCalls++
+			return createLines(ctx, journalEntryID, lines)
+		}
+		deps.PostJournalEntry = func(ctx context.Context, req *jepb.PostJournalEntryRequest) (*jepb.PostJournalEntryResponse, error) {
+			rec.posted = append(rec.posted, req.JournalEntryId)
+			if len(rec.posted) == 1 {
+				return &jepb.PostJournalEntryResponse{Success: false}, nil
+			}
+			return &jepb.PostJournalEntryResponse{Success: true}, nil
+		}
+
+		bus := NewMemoryEventBus()
+		NewJournalPoster(deps).RegisterAll(bus)
+
+		if err := bus.Publish(context.Background(), revenue); err == nil {
+			t.Fatal("first delivery succeeded, want the posting error")
+		}
+		if err := bus.Publish(context.Background(), revenue); err != nil {
+			t.Fatalf("retry: %v", err)
+		}
+
+		if len(rec.created) != 1 || lineCalls != 1 {
+			t.Errorf("created entries = %d, CreateLines calls = %d; want 1, 1", len(rec.created), lineCalls)
+		}
+		if len(rec.posted) != 2 || rec.posted[1] != "je-1" {
+			t.Errorf("posted = %v, want je-1 twice", rec.posted)
+		}
+	})
+}
@@ -1,14 +1,16 @@
 package eventbus
 
-// journal_poster.go — Phase 9 auto-posting skeleton.
+// journal_poster.go — Phase 9 auto-posting.
 //
 // JournalPoster registers handlers for all known operational event types and
-// maps each event to the correct double-entry accounting rule. In Phase 9 all
-// handlers are stubs (they log and return nil). Full implementation wires the
-// CreateJournalEntry use case once the JournalEntry repository is available in
-// the operational context.
+// maps each event to the correct double-entry accounting rule. The rules are
+// declarative (see posting_rules.go and default_posting_rules.yaml) and can be
+// overridden per workspace. Each handler evaluates the rule into a balanced
+// PostingEntry and persists it via the CreateJournalEntry use case injected
+// through JournalPosterDeps. Cancellations and voids are handled separately
+// (see journal_reversal.go).
 //
-// Accounting rules documented per event type:
+// Default accounting rules per event type:
 //
 //   "revenue.completed"
 //     DR  Accounts Receivable (or Cash if collected immediately)
@@ -70,7 +72,10 @@ package eventbus
 
 import (
 	"context"
+	"fmt"
 	"log"
+
+	jepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/ledger/journal_entry"
 )
 
 // EventTypeRevenuCompleted fires when a revenue record is marked completed/collected.
@@ -115,325 +120,355 @@ const EventTypePayrollPosted = "payroll.posted"
 // EventTypePettyCashReplenished fires when a petty cash fund is replenished.
 const EventTypePettyCashReplenished = "petty_cash.replenished"
 
-// JournalPoster handles all accounting event types and auto-posts journal entries.
-//
-// In Phase 9 all handlers are stubs. Implement CreateJournalEntry in each
-// handler once the use case is injected via JournalPosterDeps.
-type JournalPoster struct {
-	// TODO Phase 9 full implementation: inject CreateJournalEntry use case.
-	// CreateJournalEntry func(ctx context.Context, req *journalentrypb.CreateJournalEntryRequest) (*journalentrypb.CreateJournalEntryResponse, error)
+// JournalPosterDeps holds the use cases JournalPoster calls to persist
+// auto-posted entries. Consumer apps wire these in their composition root.
+type JournalPosterDeps struct {
+	// CreateJournalEntry persists the entry header. Required.
+	CreateJournalEntry func(ctx context.Context, req *jepb.CreateJournalEntryRequest) (*jepb.CreateJournalEntryResponse, error)
+
+	// PostJournalEntry transitions the created draft to posted. Optional —
+	// when nil, auto-posted entries are left as drafts for review.
+	PostJournalEntry func(ctx context.Context, req *jepb.PostJournalEntryRequest) (*jepb.PostJournalEntryResponse, error)
+
+	// ReverseJournalEntry reverses a posted entry. Required for full
+	// reversal events (see journal_reversal.go).
+	ReverseJournalEntry func(ctx context.Context, req *jepb.ReverseJournalEntryRequest) (*jepb.ReverseJournalEntryResponse, error)
+
+	// CreateLines persists the journal lines for a newly created entry.
+	// Consumer apps wire this to CreateJournalLine for each JournalLine.
+	// Required — without it no entry is created, so a header is never
+	// posted without its lines.
+	CreateLines func(ctx context.Context, journalEntryID string, lines []JournalLine) error
+
+	// ReadLines returns the stored lines of a journal entry. Optional — when
+	// wired, Replay compares entries account by account and leaves entries
+	// the current rules would reproduce unchanged.
+	ReadLines func(ctx context.Context, journalEntryID string) ([]JournalLine, error)
+
+	// PostedBy is recorded as the poster of auto-posted entries (e.g. "system").
+	PostedBy string
+
+	// Rules is the base posting rule set. Defaults to DefaultPostingRules().
+	Rules *PostingRuleSet
+
+	// WorkspaceRules returns per-workspace rule overrides, merged over Rules.
+	// Optional — return nil for workspaces that use the base rules.
+	WorkspaceRules func(ctx context.Context, workspaceID string) (*PostingRuleSet, error)
+
+	// ResolveAccount maps account codes in rules to workspace account IDs.
+	// Required only when rules use account_code.
+	ResolveAccount AccountResolver
+
+	// FunctionalCurrency is the ledger currency. Defaults to
+	// DefaultFunctionalCurrency ("PHP").
+	FunctionalCurrency string
+
+	// FXRates converts foreign-currency events (see fx.go). Optional —
+	// without it, such events must carry their own "fx_rate".
+	FXRates FXRateProvider
+
+	// Processed makes RegisterAll skip events the poster has already handled
+	// (see Idempotent). Optional — without it, redelivered events post again.
+	Processed ProcessedEventStore
+
+	// RecurringEventTypes are the event types one source raises more than
+	// once, such as a depreciation run per period for the same asset. Processed
+	// tracks them per Event.ID (see IdempotentPerEvent); every other type posts
+	// once per SourceID. Defaults to DefaultRecurringEventTypes(). Reversal
+	// events are always tracked per Event.ID.
+	RecurringEventTypes []string
+
+	// Links records which journal entry was created for each source entity.
+	// Required for reversal events; optional otherwise.
+	Links JournalLinkStore
 }
 
-// NewJournalPoster creates a JournalPoster.
-// In Phase 9 it carries no dependencies; wiring the use case is future work.
-func NewJournalPoster() *JournalPoster {
-	return &JournalPoster{}
-}
+// JournalPosterConsumer is the consumer name JournalPoster records in the
+// ProcessedEventStore.
+const JournalPosterConsumer = "journal_poster"
 
-// RegisterAll subscribes all 14 handler stubs to the provided EventBus.
-func (p *JournalPoster) RegisterAll(bus EventBus) {
-	bus.Subscribe(EventTypeRevenueCompleted, p.handleRevenueCompleted)
-	bus.Subscribe(EventTypeCollectionReceived, p.handleCollectionReceived)
-	bus.Subscribe(EventTypeExpenditureApproved, p.handleExpenditureApproved)
-	bus.Subscribe(EventTypeDisbursementPaid, p.handleDisbursementPaid)
-	bus.Subscribe(EventTypeAssetAcquired, p.handleAssetAcquired)
-	bus.Subscribe(EventTypeAssetDepreciated, p.handleAssetDepreciated)
-	bus.Subscribe(EventTypePrepaymentCreated, p.handlePrepaymentCreated)
-	bus.Subscribe(EventTypePrepaymentAmortized, p.handlePrepaymentAmortized)
-	bus.Subscribe(EventTypeLoanReceived, p.handleLoanReceived)
-	bus.Subscribe(EventTypeLoanPayment, p.handleLoanPayment)
-	bus.Subscribe(EventTypeEquityContribution, p.handleEquityContribution)
-	bus.Subscribe(EventTypeEquityWithdrawal, p.handleEquityWithdrawal)
-	bus.Subscribe(EventTypePayrollPosted, p.handlePayrollPosted)
-	bus.Subscribe(EventTypePettyCashReplenished, p.handlePettyCashReplenished)
+// JournalPoster handles all accounting event types and auto-posts journal entries.
+type JournalPoster struct {
+	deps *JournalPosterDeps
 }
 
-// handleRevenueCompleted auto-posts:
-//
-//	DR  Accounts Receivable (or Cash if payload["collected"] == true)
-//	CR  Revenue
-//
-// Expected payload keys:
-//
-//	"amount"         float64   — gross revenue amount
-//	"revenue_id"     string    — source revenue ID (for journal source link)
-//	"collected"      bool      — true if cash was collected immediately
-//	"account_ar"     string    — account ID for Accounts Receivable
-//	"account_cash"   string    — account ID for Cash (used when collected == true)
-//	"account_rev"    string    — account ID for Revenue
-func (p *JournalPoster) handleRevenueCompleted(_ context.Context, event Event) error {
-	log.Printf("[eventbus] stub: revenue.completed source=%s — DR AR/Cash, CR Revenue", event.SourceID)
-	// TODO: build CreateJournalEntryRequest with two lines:
-	//   line 1: debit account_ar (or account_cash if collected), amount
-	//   line 2: credit account_rev, amount
-	// then call p.CreateJournalEntry(ctx, req)
-	return nil
+// NewJournalPoster creates a JournalPoster that persists entries through deps.
+func NewJournalPoster(deps *JournalPosterDeps) *JournalPoster {
+	if deps == nil {
+		deps = &JournalPosterDeps{}
+	}
+	if deps.Rules == nil {
+		deps.Rules = DefaultPostingRules()
+	}
+	if deps.RecurringEventTypes == nil {
+		deps.RecurringEventTypes = DefaultRecurringEventTypes()
+	}
+	return &JournalPoster{deps: deps}
 }
 
-// handleCollectionReceived auto-posts:
-//
-//	DR  Cash / Bank
-//	CR  Accounts Receivable
-//
-// Expected payload keys:
-//
-//	"amount"         float64   — collection amount
-//	"collection_id"  string    — source collection ID
-//	"account_cash"   string    — account ID for Cash / Bank
-//	"account_ar"     string    — account ID for Accounts Receivable
-func (p *JournalPoster) handleCollectionReceived(_ context.Context, event Event) error {
-	log.Printf("[eventbus] stub: collection.received source=%s — DR Cash, CR AR", event.SourceID)
-	// TODO: build CreateJournalEntryRequest with two lines:
-	//   line 1: debit account_cash, amount
-	//   line 2: credit account_ar, amount
-	return nil
+// DefaultRecurringEventTypes returns the recognized event types whose source
+// entity raises them repeatedly: per-period depreciation and amortization,
+// petty cash replenishments of one fund and payments on one loan.
+func DefaultRecurringEventTypes() []string {
+	return []string{
+		EventTypeAssetDepreciated,
+		EventTypePrepaymentAmortized,
+		EventTypeLoanPayment,
+		EventTypePettyCashReplenished,
+	}
 }
 
-// handleExpenditureApproved auto-posts:
-//
-//	DR  Expense Account (by category/type)
-//	CR  Accounts Payable (or Cash if paid immediately)
-//
-// Expected payload keys:
-//
-//	"amount"             float64   — expenditure amount
-//	"expenditure_id"     string    — source expenditure ID
-//	"paid_immediately"   bool      — true if cash was disbursed immediately
-//	"account_expense"    string    — account ID for the expense category
-//	"account_ap"         string    — account ID for Accounts Payable
-//	"account_cash"       string    — account ID for Cash (when paid_immediately)
-func (p *JournalPoster) handleExpenditureApproved(_ context.Context, event Event) error {
-	log.Printf("[eventbus] stub: expenditure.approved source=%s — DR Expense, CR AP/Cash", event.SourceID)
-	// TODO: build CreateJournalEntryRequest with two lines:
-	//   line 1: debit account_expense, amount
-	//   line 2: credit account_ap (or account_cash if paid_immediately), amount
-	return nil
+// EventTypes returns the event types JournalPoster recognizes, in the order
+// they are documented above.
+func EventTypes() []string {
+	return []string{
+		EventTypeRevenueCompleted,
+		EventTypeCollectionReceived,
+		EventTypeExpenditureApproved,
+		EventTypeDisbursementPaid,
+		EventTypeAssetAcquired,
+		EventTypeAssetDepreciated,
+		EventTypePrepaymentCreated,
+		EventTypePrepaymentAmortized,
+		EventTypeLoanReceived,
+		EventTypeLoanPayment,
+		EventTypeEquityContribution,
+		EventTypeEquityWithdrawal,
+		EventTypePayrollPosted,
+		EventTypePettyCashReplenished,
+	}
 }
 
-// handleDisbursementPaid auto-posts:
-//
-//	DR  Accounts Payable
-//	CR  Cash / Bank
-//
-// Expected payload keys:
-//
-//	"amount"            float64   — disbursement amount
-//	"disbursement_id"   string    — source disbursement ID
-//	"account_ap"        string    — account ID for Accounts Payable
-//	"account_cash"      string    — account ID for Cash / Bank
-func (p *JournalPoster) handleDisbursementPaid(_ context.Context, event Event) error {
-	log.Printf("[eventbus] stub: disbursement.paid source=%s — DR AP, CR Cash", event.SourceID)
-	// TODO: build CreateJournalEntryRequest with two lines:
-	//   line 1: debit account_ap, amount
-	//   line 2: credit account_cash, amount
-	return nil
+// RegisterAll subscribes the poster to every event type covered by its base
+// rule set (all 14 recognized types with the default rules), plus the
+// reversal event types whose original type has a rule.
+func (p *JournalPoster) RegisterAll(bus EventBus) {
+	for _, eventType := range p.deps.Rules.EventTypes() {
+		bus.Subscribe(eventType, p.idempotent(p.handle, p.recurring(eventType)))
+	}
+	for _, eventType := range ReversalEventTypes() {
+		if original, _ := OriginalEventType(eventType); p.deps.Rules.Rule(original) != nil {
+			bus.Subscribe(eventType, p.idempotent(p.handleReversal, true))
+		}
+	}
 }
 
-// handleAssetAcquired auto-posts:
-//
-//	DR  Fixed Asset Account
-//	CR  Cash / Accounts Payable
-//
-// Expected payload keys:
-//
-//	"amount"          float64   — acquisition cost
-//	"asset_id"        string    — source asset ID
-//	"paid_in_cash"    bool      — true if paid in cash, false if financed (AP)
-//	"account_asset"   string    — account ID for the Fixed Asset
-//	"account_cash"    string    — account ID for Cash (when paid_in_cash)
-//	"account_ap"      string    — account ID for Accounts Payable (when financed)
-func (p *JournalPoster) handleAssetAcquired(_ context.Context, event Event) error {
-	log.Printf("[eventbus] stub: asset.acquired source=%s — DR Fixed Asset, CR Cash/AP", event.SourceID)
-	// TODO: build CreateJournalEntryRequest with two lines:
-	//   line 1: debit account_asset, amount
-	//   line 2: credit account_cash (or account_ap if financed), amount
-	return nil
+// recurring reports whether eventType is one of deps.RecurringEventTypes.
+func (p *JournalPoster) recurring(eventType string) bool {
+	for _, t := range p.deps.RecurringEventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
 }
 
-// handleAssetDepreciated auto-posts:
-//
-//	DR  Depreciation Expense
-//	CR  Accumulated Depreciation
-//
-// Expected payload keys:
-//
-//	"amount"                    float64   — depreciation amount for the period
-//	"asset_id"                  string    — source asset ID
-//	"account_depreciation_exp"  string    — account ID for Depreciation Expense
-//	"account_accum_dep"         string    — account ID for Accumulated Depreciation
-func (p *JournalPoster) handleAssetDepreciated(_ context.Context, event Event) error {
-	log.Printf("[eventbus] stub: asset.deprecated source=%s — DR Depreciation Exp, CR Accum Dep", event.SourceID)
-	// TODO: build CreateJournalEntryRequest with two lines:
-	//   line 1: debit account_depreciation_exp, amount
-	//   line 2: credit account_accum_dep, amount
-	return nil
+// idempotent wraps h with the Idempotent middleware, or IdempotentPerEvent
+// when perEvent is set, if a ProcessedEventStore is wired.
+func (p *JournalPoster) idempotent(h Handler, perEvent bool) Handler {
+	if p.deps.Processed == nil {
+		return h
+	}
+	if perEvent {
+		return IdempotentPerEvent(p.deps.Processed, JournalPosterConsumer)(h)
+	}
+	return Idempotent(p.deps.Processed, JournalPosterConsumer)(h)
 }
 
-// handlePrepaymentCreated auto-posts:
-//
-//	DR  Prepaid Expense (asset)
-//	CR  Cash / Accounts Payable
-//
-// Expected payload keys:
-//
-//	"amount"           float64   — prepayment amount
-//	"prepayment_id"    string    — source prepayment ID
-//	"paid_in_cash"     bool      — true if paid in cash, false if on account
-//	"account_prepaid"  string    — account ID for Prepaid Expense (asset)
-//	"account_cash"     string    — account ID for Cash
-//	"account_ap"       string    — account ID for Accounts Payable
-func (p *JournalPoster) handlePrepaymentCreated(_ context.Context, event Event) error {
-	log.Printf("[eventbus] stub: prepayment.created source=%s — DR Prepaid Expense, CR Cash/AP", event.SourceID)
-	// TODO: build CreateJournalEntryRequest with two lines:
-	//   line 1: debit account_prepaid, amount
-	//   line 2: credit account_cash (or account_ap), amount
-	return nil
+// BuildEntry evaluates the posting rule for an event without persisting it.
+// Returns an error for event types without a rule, invalid payloads and
+// unbalanced entries.
+func (p *JournalPoster) BuildEntry(ctx context.Context, event Event) (*PostingEntry, error) {
+	entry, err := p.evaluate(ctx, event)
+	if err != nil {
+		return nil, err
+	}
+	if err := entry.Validate(); err != nil {
+		return nil, fmt.Errorf("eventbus: %s source=%s: %w", event.Type, event.SourceID, err)
+	}
+	return entry, nil
 }
 
-// handlePrepaymentAmortized auto-posts:
-//
-//	DR  Expense Account (matching prepayment category)
-//	CR  Prepaid Expense (asset)
-//
-// Expected payload keys:
-//
-//	"amount"           float64   — amortization amount for the period
-//	"prepayment_id"    string    — source prepayment ID
-//	"account_expense"  string    — account ID for the appropriate Expense
-//	"account_prepaid"  string    — account ID for Prepaid Expense (asset)
-func (p *JournalPoster) handlePrepaymentAmortized(_ context.Context, event Event) error {
-	log.Printf("[eventbus] stub: prepayment.amortized source=%s — DR Expense, CR Prepaid Expense", event.SourceID)
-	// TODO: build CreateJournalEntryRequest with two lines:
-	//   line 1: debit account_expense, amount
-	//   line 2: credit account_prepaid, amount
-	return nil
+// evaluate applies the event's posting rule without validating the result,
+// so previews can show unbalanced entries.
+func (p *JournalPoster) evaluate(ctx context.Context, event Event) (*PostingEntry, error) {
+	rules, err := p.rulesFor(ctx, event.WorkspaceID)
+	if err != nil {
+		return nil, err
+	}
+	rule := rules.Rule(event.Type)
+	if rule == nil {
+		return nil, fmt.Errorf("eventbus: no posting rule for event type %q", event.Type)
+	}
+	entry, err := rule.Evaluate(ctx, event, p.deps.ResolveAccount)
+	if err != nil {
+		return nil, fmt.Errorf("eventbus: %s source=%s: %w", event.Type, event.SourceID, err)
+	}
+	if err := p.convert(ctx, event, entry); err != nil {
+		return nil, fmt.Errorf("eventbus: %s source=%s: %w", event.Type, event.SourceID, err)
+	}
+	return entry, nil
 }
 
-// handleLoanReceived auto-posts:
-//
-//	DR  Cash / Bank
-//	CR  Loan Payable (liability)
-//
-// Expected payload keys:
-//
-//	"amount"           float64   — loan principal received
-//	"loan_id"          string    — source loan ID
-//	"account_cash"     string    — account ID for Cash / Bank
-//	"account_loan"     string    — account ID for Loan Payable
-func (p *JournalPoster) handleLoanReceived(_ context.Context, event Event) error {
-	log.Printf("[eventbus] stub: loan.received source=%s — DR Cash, CR Loan Payable", event.SourceID)
-	// TODO: build CreateJournalEntryRequest with two lines:
-	//   line 1: debit account_cash, amount
-	//   line 2: credit account_loan, amount
-	return nil
+// rulesFor returns the base rules merged with any workspace overrides.
+func (p *JournalPoster) rulesFor(ctx context.Context, workspaceID string) (*PostingRuleSet, error) {
+	if p.deps.WorkspaceRules == nil || workspaceID == "" {
+		return p.deps.Rules, nil
+	}
+	override, err := p.deps.WorkspaceRules(ctx, workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("eventbus: loading posting rules for workspace %s: %w", workspaceID, err)
+	}
+	if override == nil {
+		return p.deps.Rules, nil
+	}
+	return p.deps.Rules.Merge(override), nil
 }
 
-// handleLoanPayment auto-posts:
-//
-//	DR  Loan Payable (principal portion)
-//	DR  Interest Expense (interest portion)
-//	CR  Cash / Bank (total payment)
-//
-// Expected payload keys:
-//
-//	"total_amount"        float64   — total payment made
-//	"principal_amount"    float64   — principal portion of the payment
-//	"interest_amount"     float64   — interest portion of the payment
-//	"loan_payment_id"     string    — source loan payment ID
-//	"account_loan"        string    — account ID for Loan Payable
-//	"account_interest"    string    — account ID for Interest Expense
-//	"account_cash"        string    — account ID for Cash / Bank
-func (p *JournalPoster) handleLoanPayment(_ context.Context, event Event) error {
-	log.Printf("[eventbus] stub: loan.payment source=%s — DR Loan Payable + DR Interest Exp, CR Cash", event.SourceID)
-	// TODO: build CreateJournalEntryRequest with three lines:
-	//   line 1: debit account_loan, principal_amount
-	//   line 2: debit account_interest, interest_amount
-	//   line 3: credit account_cash, total_amount
-	return nil
+// handle builds the entry for an event, persists it and links it to the
+// event's source entity.
+func (p *JournalPoster) handle(ctx context.Context, event Event) error {
+	entry, err := p.BuildEntry(ctx, event)
+	if err != nil {
+		return err
+	}
+	_, err = p.createAndLink(ctx, event, entry, true)
+	return err
 }
 
-// handleEquityContribution auto-posts:
-//
-//	DR  Cash / Bank
-//	CR  Owner's Capital / Paid-In Capital
-//
-// Expected payload keys:
-//
-//	"amount"                float64   — equity contribution amount
-//	"equity_transaction_id" string    — source equity transaction ID
-//	"account_cash"          string    — account ID for Cash / Bank
-//	"account_capital"       string    — account ID for Owner's Capital
-func (p *JournalPoster) handleEquityContribution(_ context.Context, event Event) error {
-	log.Printf("[eventbus] stub: equity.contribution source=%s — DR Cash, CR Owner's Capital", event.SourceID)
-	// TODO: build CreateJournalEntryRequest with two lines:
-	//   line 1: debit account_cash, amount
-	//   line 2: credit account_capital, amount
-	return nil
+// createAndLink persists entry and links it to the event's source entity.
+// The link is saved, at LinkHeaderCreated, as soon as the header exists, and
+// advanced as the lines are stored and the entry posted. When an earlier
+// delivery of the event stopped part way, createAndLink resumes that entry
+// rather than creating another header. Without Links a failure after the
+// header is created leaves it behind.
+func (p *JournalPoster) createAndLink(ctx context.Context, event Event, entry *PostingEntry, post bool) (string, error) {
+	if p.deps.CreateJournalEntry == nil {
+		return "", fmt.Errorf("eventbus: CreateJournalEntry use case not wired")
+	}
+	if p.deps.CreateLines == nil {
+		return "", fmt.Errorf("eventbus: CreateLines use case not wired")
+	}
+	// A handler abandoned by the Timeout middleware must not go on to
+	// create an entry the retry will create again.
+	if err := ctx.Err(); err != nil {
+		return "", fmt.Errorf("eventbus: %s source=%s: %w", event.Type, event.SourceID, err)
+	}
+
+	entryID, stage, err := p.unfinishedEntry(ctx, event)
+	if err != nil {
+		return "", err
+	}
+	if entryID != "" {
+		log.Printf("[eventbus] %s source=%s: resuming journal entry %s", event.Type, event.SourceID, entryID)
+	} else {
+		entryID, err = p.createHeader(ctx, entry)
+		if err != nil {
+			return "", err
+		}
+		stage = LinkHeaderCreated
+		if p.deps.Links != nil {
+			debit, _ := entry.Totals()
+			err := p.deps.Links.SaveLink(ctx, JournalEntryLink{
+				EventType:      event.Type,
+				SourceID:       event.SourceID,
+				EventID:        event.ID,
+				WorkspaceID:    event.WorkspaceID,
+				JournalEntryID: entryID,
+				Amount:         debit,
+				Stage:          stage,
+			})
+			if err != nil {
+				return entryID, fmt.Errorf("eventbus: %s source=%s: linking journal entry %s: %w", event.Type, event.SourceID, entryID, err)
+			}
+		}
+	}
+
+	if stage == LinkHeaderCreated {
+		if err := p.deps.CreateLines(ctx, entryID, entry.Lines); err != nil {
+			return entryID, fmt.Errorf("eventbus: %s source=%s: creating lines for %s: %w", entry.EventType, entry.SourceID, entryID, err)
+		}
+		if err := p.setStage(ctx, event, entryID, LinkLinesCreated); err != nil {
+			return entryID, err
+		}
+	}
+
+	if post && p.deps.PostJournalEntry != nil {
+		postResp, err := p.deps.PostJournalEntry(ctx, &jepb.PostJournalEntryRequest{
+			JournalEntryId: entryID,
+			PostedBy:       p.deps.PostedBy,
+		})
+		if err != nil {
+			return entryID, fmt.Errorf("eventbus: %s source=%s: posting %s: %w", entry.EventType, entry.SourceID, entryID, err)
+		}
+		if postResp == nil || !postResp.GetSuccess() {
+			msg := "unknown error"
+			if postResp.GetError() != nil {
+				msg = postResp.GetError().GetMessage()
+			}
+			return entryID, fmt.Errorf("eventbus: %s source=%s: posting %s: %s", entry.EventType, entry.SourceID, entryID, msg)
+		}
+	}
+	if err := p.setStage(ctx, event, entryID, LinkComplete); err != nil {
+		return entryID, err
+	}
+
+	log.Printf("[eventbus] %s source=%s → journal entry %s", entry.EventType, entry.SourceID, entryID)
+	return entryID, nil
 }
 
-// handleEquityWithdrawal auto-posts:
-//
-//	DR  Owner's Draw / Drawings
-//	CR  Cash / Bank
-//
-// Expected payload keys:
-//
-//	"amount"                float64   — withdrawal amount
-//	"equity_transaction_id" string    — source equity transaction ID
-//	"account_drawings"      string    — account ID for Owner's Draw/Drawings
-//	"account_cash"          string    — account ID for Cash / Bank
-func (p *JournalPoster) handleEquityWithdrawal(_ context.Context, event Event) error {
-	log.Printf("[eventbus] stub: equity.withdrawal source=%s — DR Owner's Draw, CR Cash", event.SourceID)
-	// TODO: build CreateJournalEntryRequest with two lines:
-	//   line 1: debit account_drawings, amount
-	//   line 2: credit account_cash, amount
-	return nil
+// unfinishedEntry returns the entry an earlier delivery of event left part
+// way, and how far it got. Returns an empty ID when there is none or Links is
+// not wired.
+func (p *JournalPoster) unfinishedEntry(ctx context.Context, event Event) (string, LinkStage, error) {
+	if p.deps.Links == nil {
+		return "", LinkComplete, nil
+	}
+	links, err := p.deps.Links.LinksForSource(ctx, event.SourceID)
+	if err != nil {
+		return "", LinkComplete, fmt.Errorf("eventbus: %s source=%s: %w", event.Type, event.SourceID, err)
+	}
+	perEvent := p.recurring(event.Type) || isReversalType(event.Type)
+	for i := len(links) - 1; i >= 0; i-- {
+		l := links[i]
+		if l.EventType == event.Type && (!perEvent || l.EventID == event.ID) && !l.Reversed && l.Stage != LinkComplete {
+			return l.JournalEntryID, l.Stage, nil
+		}
+	}
+	return "", LinkComplete, nil
 }
 
-// handlePayrollPosted auto-posts:
-//
-//	DR  Salary Expense (gross pay)
-//	CR  Cash / Bank (net pay disbursed)
-//	CR  Government Payables (SSS, PhilHealth, Pag-IBIG, withholding tax)
-//
-// Expected payload keys:
-//
-//	"gross_pay"             float64   — total gross payroll amount
-//	"net_pay"               float64   — net pay disbursed to employees
-//	"gov_contributions"     float64   — total government contribution withholdings
-//	"payroll_run_id"        string    — source payroll run ID
-//	"account_salary_exp"    string    — account ID for Salary Expense
-//	"account_cash"          string    — account ID for Cash / Bank
-//	"account_gov_payables"  string    — account ID for Government Payables
-func (p *JournalPoster) handlePayrollPosted(_ context.Context, event Event) error {
-	log.Printf("[eventbus] stub: payroll.posted source=%s — DR Salary Exp, CR Cash + CR Gov Payables", event.SourceID)
-	// TODO: build CreateJournalEntryRequest with three lines:
-	//   line 1: debit account_salary_exp, gross_pay
-	//   line 2: credit account_cash, net_pay
-	//   line 3: credit account_gov_payables, gov_contributions
-	// Note: gross_pay == net_pay + gov_contributions (entries must balance)
+// setStage records the entry's progress on its link, if Links is wired.
+func (p *JournalPoster) setStage(ctx context.Context, event Event, entryID string, stage LinkStage) error {
+	if p.deps.Links == nil {
+		return nil
+	}
+	if err := p.deps.Links.SetLinkStage(ctx, entryID, stage); err != nil {
+		return fmt.Errorf("eventbus: %s source=%s: %w", event.Type, event.SourceID, err)
+	}
 	return nil
 }
 
-// handlePettyCashReplenished auto-posts:
-//
-//	DR  Petty Cash Expense accounts (per voucher categories)
-//	CR  Cash / Bank (replenishment amount)
-//
-// Expected payload keys:
-//
-//	"replenishment_amount"   float64          — total replenishment disbursed
-//	"petty_cash_fund_id"     string           — source petty cash fund ID
-//	"expense_lines"          []map[string]any — per-category expense breakdown:
-//	                           "account_id" string  — expense account ID
-//	                           "amount"     float64 — amount for this category
-//	"account_cash"           string           — account ID for Cash / Bank
-func (p *JournalPoster) handlePettyCashReplenished(_ context.Context, event Event) error {
-	log.Printf("[eventbus] stub: petty_cash.replenished source=%s — DR Expense accounts, CR Cash", event.SourceID)
-	// TODO: build CreateJournalEntryRequest with N+1 lines:
-	//   for each entry in expense_lines:
-	//     line N: debit account_id, amount
-	//   last line: credit account_cash, replenishment_amount
-	// Note: sum of expense_lines amounts must equal replenishment_amount
-	return nil
+// createHeader creates the journal entry header and returns its ID.
+func (p *JournalPoster) createHeader(ctx context.Context, entry *PostingEntry) (string, error) {
+	resp, err := p.deps.CreateJournalEntry(ctx, &jepb.CreateJournalEntryRequest{Data: entry.toProto()})
+	if err != nil {
+		return "", fmt.Errorf("eventbus: %s source=%s: creating journal entry: %w", entry.EventType, entry.SourceID, err)
+	}
+	if resp == nil || !resp.GetSuccess() {
+		msg := "unknown error"
+		if resp.GetError() != nil {
+			msg = resp.GetError().GetMessage()
+		}
+		return "", fmt.Errorf("eventbus: %s source=%s: creating journal entry: %s", entry.EventType, entry.SourceID, msg)
+	}
+
+	entryID := ""
+	if len(resp.GetData()) > 0 {
+		entryID = resp.GetData()[0].GetId()
+	}
+	if entryID == "" {
+		return "", fmt.Errorf("eventbus: %s source=%s: CreateJournalEntry returned no entry ID", entry.EventType, entry.SourceID)
+	}
+	return entryID, nil
 }
@@ -0,0 +1,368 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	jepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/ledger/journal_entry"
+)
+
+// recordingDeps captures every use case call made by JournalPoster.
+type recordingDeps struct {
+	created []*jepb.JournalEntry
+	lines   map[string][]JournalLine
+	posted  []string
+	nextID  int
+}
+
+func (r *recordingDeps) deps() *JournalPosterDeps {
+	r.lines = make(map[string][]JournalLine)
+	return &JournalPosterDeps{
+		CreateJournalEntry: func(ctx context.Context, req *jepb.CreateJournalEntryRequest) (*jepb.CreateJournalEntryResponse, error) {
+			r.nextID++
+			id := fmt.Sprintf("je-%d", r.nextID)
+			entry := req.GetData()
+			entry.Id = id
+			r.created = append(r.created, entry)
+			return &jepb.CreateJournalEntryResponse{Success: true, Data: []*jepb.JournalEntry{entry}}, nil
+		},
+		CreateLines: func(ctx context.Context, journalEntryID string, lines []JournalLine) error {
+			r.lines[journalEntryID] = lines
+			return nil
+		},
+	}
+}
+
+func TestJournalPoster_BuildEntry(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		event      Event
+		wantLines  int
+		wantTotal  int64
+		wantSource jepb.JournalSourceType
+	}{
+		{
+			name: "revenue completed on account",
+			event: Event{Type: EventTypeRevenueCompleted, SourceID: "rev-1", Payload: map[string]any{
+				"amount": 1500.50, "account_ar": "ar", "account_cash": "cash", "account_rev": "rev",
+			}},
+			wantLines: 2, wantTotal: 150050, wantSource: jepb.JournalSourceType_JOURNAL_SOURCE_TYPE_REVENUE,
+		},
+		{
+			name: "collection received",
+			event: Event{Type: EventTypeCollectionReceived, SourceID: "col-1", Payload: map[string]any{
+				"amount": 100, "account_cash": "cash", "account_ar": "ar",
+			}},
+			wantLines: 2, wantTotal: 10000, wantSource: jepb.JournalSourceType_JOURNAL_SOURCE_TYPE_COLLECTION,
+		},
+		{
+			name: "loan payment splits principal and interest",
+			event: Event{Type: EventTypeLoanPayment, SourceID: "lp-1", Payload: map[string]any{
+				"total_amount": 1100.0, "principal_amount": 1000.0, "interest_amount": 100.0,
+				"account_loan": "loan", "account_interest": "int", "account_cash": "cash",
+			}},
+			wantLines: 3, wantTotal: 110000, wantSource: jepb.JournalSourceType_JOURNAL_SOURCE_TYPE_LOAN_PAYMENT,
+		},
+		{
+			name: "payroll posted",
+			event: Event{Type: EventTypePayrollPosted, SourceID: "pr-1", Payload: map[string]any{
+				"gross_pay": 50000.0, "net_pay": 42000.0, "gov_contributions": 8000.0,
+				"account_salary_exp": "sal", "account_cash": "cash", "account_gov_payables": "gov",
+			}},
+			wantLines: 3, wantTotal: 5000000, wantSource: jepb.JournalSourceType_JOURNAL_SOURCE_TYPE_PAYROLL,
+		},
+		{
+			name: "petty cash replenished",
+			event: Event{Type: EventTypePettyCashReplenished, SourceID: "pcf-1", Payload: map[string]any{
+				"replenishment_amount": 750.0,
+				"account_cash":         "cash",
+				"expense_lines": []any{
+					map[string]any{"account_id": "supplies", "amount": 500.0},
+					map[string]any{"account_id": "transport", "amount": 250.0},
+				},
+			}},
+			wantLines: 3, wantTotal: 75000, wantSource: jepb.JournalSourceType_JOURNAL_SOURCE_TYPE_PETTY_CASH_REPLENISHMENT,
+		},
+	}
+
+	poster := NewJournalPoster(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			entry, err := poster.BuildEntry(context.Background(), tt.event)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(entry.Lines) != tt.wantLines {
+				t.Errorf("lines = %d, want %d", len(entry.Lines), tt.wantLines)
+			}
+			debit, credit := entry.Totals()
+			if debit != tt.wantTotal || credit != tt.wantTotal {
+				t.Errorf("totals = %d/%d, want %d", debit, credit, tt.wantTotal)
+			}
+			if entry.SourceType != tt.wantSource {
+				t.Errorf("SourceType = %v, want %v", entry.SourceType, tt.wantSource)
+			}
+			for i, l := range entry.Lines {
+				if l.Order != int32(i+1) {
+					t.Errorf("line %d Order = %d, want %d", i, l.Order, i+1)
+				}
+			}
+		})
+	}
+}
+
+func TestJournalPoster_BuildEntry_Rejects(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		event   Event
+		wantErr error
+	}{
+		{
+			name: "payroll that does not balance",
+			event: Event{Type: EventTypePayrollPosted, SourceID: "pr-1", Payload: map[string]any{
+				"gross_pay": 50000.0, "net_pay": 42000.0, "gov_contributions": 7000.0,
+				"account_salary_exp": "sal", "account_cash": "cash", "account_gov_payables": "gov",
+			}},
+			wantErr: ErrUnbalancedEntry,
+		},
+		{
+			name: "petty cash lines that do not sum to replenishment",
+			event: Event{Type: EventTypePettyCashReplenished, SourceID: "pcf-1", Payload: map[string]any{
+				"replenishment_amount": 800.0,
+				"account_cash":         "cash",
+				"expense_lines":        []map[string]any{{"account_id": "supplies", "amount": 500.0}},
+			}},
+			wantErr: ErrUnbalancedEntry,
+		},
+		{
+			name: "amount sent as a string",
+			event: Event{Type: EventTypeCollectionReceived, SourceID: "col-1", Payload: map[string]any{
+				"amount": "100.00", "account_cash": "cash", "account_ar": "ar",
+			}},
+			wantErr: ErrInvalidPayload,
+		},
+		{
+			name: "missing account",
+			event: Event{Type: EventTypeDisbursementPaid, SourceID: "d-1", Payload: map[string]any{
+				"amount": 100.0, "account_ap": "ap",
+			}},
+			wantErr: ErrInvalidPayload,
+		},
+		{
+			name: "zero amount",
+			event: Event{Type: EventTypeLoanReceived, SourceID: "l-1", Payload: map[string]any{
+				"amount": 0.0, "account_cash": "cash", "account_loan": "loan",
+			}},
+			wantErr: ErrInvalidPayload,
+		},
+	}
+
+	poster := NewJournalPoster(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := poster.BuildEntry(context.Background(), tt.event)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("unknown event type", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := poster.BuildEntry(context.Background(), Event{Type: "unknown.event"})
+		if err == nil || !strings.Contains(err.Error(), "unknown.event") {
+			t.Errorf("error = %v, want mention of event type", err)
+		}
+	})
+}
+
+func TestJournalPoster_RegisterAll(t *testing.T) {
+	t.Parallel()
+
+	t.Run("published event creates and posts a journal entry", func(t *testing.T) {
+		t.Parallel()
+
+		rec := &recordingDeps{}
+		deps := rec.deps()
+		deps.PostedBy = "system"
+		deps.PostJournalEntry = func(ctx context.Context, req *jepb.PostJournalEntryRequest) (*jepb.PostJournalEntryResponse, error) {
+			if req.PostedBy != "system" {
+				t.Errorf("PostedBy = %q, want %q", req.PostedBy, "system")
+			}
+			rec.posted = append(rec.posted, req.JournalEntryId)
+			return &jepb.PostJournalEntryResponse{Success: true}, nil
+		}
+
+		bus := NewMemoryEventBus()
+		NewJournalPoster(deps).RegisterAll(bus)
+
+		err := bus.Publish(context.Background(), Event{
+			Type:      EventTypeExpenditureApproved,
+			SourceID:  "exp-9",
+			Timestamp: time.Date(2026, 3, 31, 9, 0, 0, 0, time.UTC),
+			Payload: map[string]any{
+				"amount": 2500.0, "paid_immediately": true,
+				"account_expense": "utilities", "account_ap": "ap", "account_cash": "cash",
+			},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(rec.created) != 1 {
+			t.Fatalf("created entries = %d, want 1", len(rec.created))
+		}
+		entry := rec.created[0]
+		if entry.GetTotalDebit() != 250000 || entry.GetTotalCredit() != 250000 {
+			t.Errorf("totals = %d/%d, want 250000", entry.GetTotalDebit(), entry.GetTotalCredit())
+		}
+		if entry.GetEntryDateString() != "2026-03-31" {
+			t.Errorf("EntryDateString = %q, want %q", entry.GetEntryDateString(), "2026-03-31")
+		}
+		if entry.GetSourceId() != "exp-9" {
+			t.Errorf("SourceId = %q, want %q", entry.GetSourceId(), "exp-9")
+		}
+
+		lines := rec.lines[entry.GetId()]
+		if len(lines) != 2 {
+			t.Fatalf("lines = %d, want 2", len(lines))
+		}
+		if lines[1].AccountID != "cash" {
+			t.Errorf("credit account = %q, want cash (paid_immediately)", lines[1].AccountID)
+		}
+		if len(rec.posted) != 1 || rec.posted[0] != entry.GetId() {
+			t.Errorf("posted = %v, want [%s]", rec.posted, entry.GetId())
+		}
+	})
+
+	t.Run("all recognized event types are subscribed", func(t *testing.T) {
+		t.Parallel()
+
+		bus := NewMemoryEventBus()
+		NewJournalPoster(nil).RegisterAll(bus)
+
+		for _, eventType := range EventTypes() {
+			if len(bus.handlers[eventType]) != 1 {
+				t.Errorf("handlers for %q = %d, want 1", eventType, len(bus.handlers[eventType]))
+			}
+		}
+	})
+
+	t.Run("unwired CreateJournalEntry returns an error", func(t *testing.T) {
+		t.Parallel()
+
+		bus := NewMemoryEventBus()
+		NewJournalPoster(nil).RegisterAll(bus)
+
+		err := bus.Publish(context.Background(), Event{
+			Type:     EventTypeLoanReceived,
+			SourceID: "loan-1",
+			Payload:  map[string]any{"amount": 1000.0, "account_cash": "cash", "account_loan": "loan"},
+		})
+		if err == nil || !strings.Contains(err.Error(), "not wired") {
+			t.Errorf("error = %v, want not wired error", err)
+		}
+	})
+
+	t.Run("unwired CreateLines creates nothing", func(t *testing.T) {
+		t.Parallel()
+
+		rec := &recordingDeps{}
+		deps := rec.deps()
+		deps.CreateLines = nil
+		deps.PostJournalEntry = func(ctx context.Context, req *jepb.PostJournalEntryRequest) (*jepb.PostJournalEntryResponse, error) {
+			rec.posted = append(rec.posted, req.JournalEntryId)
+			return &jepb.PostJournalEntryResponse{Success: true}, nil
+		}
+		bus := NewMemoryEventBus()
+		NewJournalPoster(deps).RegisterAll(bus)
+
+		err := bus.Publish(context.Background(), Event{
+			Type:     EventTypeLoanReceived,
+			SourceID: "loan-1",
+			Payload:  map[string]any{"amount": 1000.0, "account_cash": "cash", "account_loan": "loan"},
+		})
+		if err == nil || !strings.Contains(err.Error(), "CreateLines use case not wired") {
+			t.Errorf("error = %v, want CreateLines not wired", err)
+		}
+		if len(rec.created) != 0 || len(rec.posted) != 0 {
+			t.Errorf("created = %d posted = %d, want no header-only entry", len(rec.created), len(rec.posted))
+		}
+	})
+
+	t.Run("create error is surfaced", func(t *testing.T) {
+		t.Parallel()
+
+		bus := NewMemoryEventBus()
+		NewJournalPoster(&JournalPosterDeps{
+			CreateJournalEntry: func(ctx context.Context, req *jepb.CreateJournalEntryRequest) (*jepb.CreateJournalEntryResponse, error) {
+				return nil, errors.New("fiscal period closed")
+			},
+			CreateLines: func(ctx context.Context, journalEntryID string, lines []JournalLine) error { return nil },
+		}).RegisterAll(bus)
+
+		err := bus.Publish(context.Background(), Event{
+			Type:     EventTypeLoanReceived,
+			SourceID: "loan-1",
+			Payload:  map[string]any{"amount": 1000.0, "account_cash": "cash", "account_loan": "loan"},
+		})
+		if err == nil || !strings.Contains(err.Error(), "fiscal period closed") {
+			t.Errorf("error = %v, want create error", err)
+		}
+	})
+}
+
+func TestJournalPoster_AbandonedByTimeout(t *testing.T) {
+	t.Parallel()
+
+	rec := &recordingDeps{}
+	deps := rec.deps()
+	release := make(chan struct{})
+	deps.WorkspaceRules = func(ctx context.Context, workspaceID string) (*PostingRuleSet, error) {
+		<-ctx.Done()
+		<-release
+		return nil, nil
+	}
+
+	finished := make(chan error, 1)
+	bus := NewMemoryEventBus()
+	bus.Use(Timeout(10*time.Millisecond), func(next Handler) Handler {
+		return func(ctx context.Context, e Event) error {
+			err := next(ctx, e)
+			finished <- err
+			return err
+		}
+	})
+	NewJournalPoster(deps).RegisterAll(bus)
+
+	err := bus.Publish(context.Background(), Event{
+		Type:        EventTypeCollectionReceived,
+		SourceID:    "col-1",
+		WorkspaceID: "ws-1",
+		Payload:     map[string]any{"amount": 100, "account_cash": "cash", "account_ar": "ar"},
+	})
+	if !errors.Is(err, ErrHandlerTimeout) {
+		t.Fatalf("publish error = %v, want ErrHandlerTimeout", err)
+	}
+
+	close(release)
+	if err := <-finished; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("abandoned handler error = %v, want DeadlineExceeded", err)
+	}
+	if len(rec.created) != 0 {
+		t.Errorf("created entries = %d after the timeout, want 0", len(rec.created))
+	}
+}
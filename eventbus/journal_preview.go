@@ -0,0 +1,213 @@
+package eventbus
+
+// Dry-run preview: JournalPoster can evaluate a batch of events against the
+// current posting rules without persisting anything, so an accountant can
+// review the resulting entries before they hit the ledger. Approved events
+// are then created as draft entries with CreateDraft.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrAlreadyPosted is returned by CreateDraft for an event whose source
+// already has a journal entry, created by the live bus or an earlier approval.
+var ErrAlreadyPosted = errors.New("journal entry already created for source")
+
+// PostingPreview is the dry-run result for one event.
+type PostingPreview struct {
+	Event Event
+
+	// Entry is the journal entry the event would create. Nil when the rule
+	// could not be evaluated (see Err) or for full reversals, which do not
+	// create an entry of their own.
+	Entry *PostingEntry
+
+	// Debit and Credit are the entry totals, in centavos.
+	Debit  int64
+	Credit int64
+
+	// Balanced reports whether Entry passes PostingEntry.Validate.
+	Balanced bool
+
+	// Note describes what a full reversal would unwind, or names the entry
+	// already created for the event.
+	Note string
+
+	// AlreadyPosted is set when the event's source already has a journal
+	// entry (see CreateDraft); ExistingEntryID names it when a
+	// JournalLinkStore is wired.
+	AlreadyPosted   bool
+	ExistingEntryID string
+
+	// Err is the evaluation or validation error, if any. An unbalanced
+	// entry still carries Entry so its lines can be shown.
+	Err error
+}
+
+// Key identifies the previewed event within a batch: its Event.ID when set,
+// otherwise type, source and timestamp.
+func (pv PostingPreview) Key() string {
+	if pv.Event.ID != "" {
+		return pv.Event.ID
+	}
+	return strings.Join([]string{pv.Event.Type, pv.Event.SourceID, pv.Event.Timestamp.UTC().Format("20060102T150405.000000000")}, "|")
+}
+
+// OK reports whether the event can be approved as a draft entry.
+func (pv PostingPreview) OK() bool {
+	return pv.Err == nil && pv.Entry != nil && pv.Balanced && !pv.AlreadyPosted
+}
+
+// Preview evaluates events against the current posting rules, including
+// workspace overrides, without calling any use case. Every event gets a
+// result in input order; failures are reported per event rather than
+// aborting the batch.
+func (p *JournalPoster) Preview(ctx context.Context, events []Event) []PostingPreview {
+	previews := make([]PostingPreview, 0, len(events))
+	for _, event := range events {
+		previews = append(previews, p.previewOne(ctx, event))
+	}
+	return previews
+}
+
+func (p *JournalPoster) previewOne(ctx context.Context, event Event) PostingPreview {
+	pv := PostingPreview{Event: event}
+
+	var entry *PostingEntry
+	if original, ok := OriginalEventType(event.Type); ok {
+		if partial, ok := event.Payload["partial"]; !truthy(partial, ok) {
+			pv.Note, pv.Err = p.describeFullReversal(ctx, event, original)
+			return pv
+		}
+		asOriginal := event
+		asOriginal.Type = original
+		evaluated, err := p.evaluate(ctx, asOriginal)
+		if err != nil {
+			pv.Err = err
+			return pv
+		}
+		entry = counterEntry(evaluated, event.Type)
+	} else {
+		evaluated, err := p.evaluate(ctx, event)
+		if err != nil {
+			pv.Err = err
+			return pv
+		}
+		entry = evaluated
+	}
+
+	pv.Entry = entry
+	pv.Debit, pv.Credit = entry.Totals()
+	if err := entry.Validate(); err != nil {
+		pv.Err = fmt.Errorf("eventbus: %s source=%s: %w", event.Type, event.SourceID, err)
+		return pv
+	}
+	pv.Balanced = true
+
+	if !isReversalType(event.Type) {
+		pv.ExistingEntryID, pv.AlreadyPosted, pv.Err = p.existingEntry(ctx, event)
+		if pv.AlreadyPosted {
+			pv.Note = "Already posted"
+			if pv.ExistingEntryID != "" {
+				pv.Note = fmt.Sprintf("Already posted as journal entry %s", pv.ExistingEntryID)
+			}
+		}
+	}
+	return pv
+}
+
+func isReversalType(eventType string) bool {
+	_, ok := OriginalEventType(eventType)
+	return ok
+}
+
+// existingEntry reports whether the poster has already handled event: a
+// complete link for the same source and event type (for recurring types, the
+// same Event.ID), or a ProcessedEventStore record under the key the live bus
+// uses. The entry ID is known only from a link. An unfinished entry does not
+// count; CreateDraft resumes it.
+func (p *JournalPoster) existingEntry(ctx context.Context, event Event) (string, bool, error) {
+	recurring := p.recurring(event.Type)
+	if p.deps.Links != nil {
+		links, err := p.deps.Links.LinksForSource(ctx, event.SourceID)
+		if err != nil {
+			return "", false, fmt.Errorf("eventbus: %s source=%s: %w", event.Type, event.SourceID, err)
+		}
+		for _, l := range links {
+			if l.EventType == event.Type && (!recurring || l.EventID == event.ID) && l.Stage == LinkComplete {
+				return l.JournalEntryID, true, nil
+			}
+		}
+	}
+	if p.deps.Processed != nil {
+		done, err := p.deps.Processed.IsProcessed(ctx, processedKeyFor(JournalPosterConsumer, event, recurring))
+		if err != nil {
+			return "", false, fmt.Errorf("eventbus: %s source=%s: %w", event.Type, event.SourceID, err)
+		}
+		return "", done, nil
+	}
+	return "", false, nil
+}
+
+// describeFullReversal summarizes the linked entries a full reversal would
+// unwind. Without a link store the preview only names the original type.
+func (p *JournalPoster) describeFullReversal(ctx context.Context, event Event, original string) (string, error) {
+	if p.deps.Links == nil {
+		return fmt.Sprintf("Reverses the %s entries for source %s", original, event.SourceID), nil
+	}
+	links, err := p.deps.Links.LinksForSource(ctx, event.SourceID)
+	if err != nil {
+		return "", fmt.Errorf("eventbus: %s source=%s: %w", event.Type, event.SourceID, err)
+	}
+	var ids []string
+	for _, l := range links {
+		if !l.Reversed && (l.EventType == original || l.EventType == event.Type) {
+			ids = append(ids, l.JournalEntryID)
+		}
+	}
+	if len(ids) == 0 {
+		return "", fmt.Errorf("eventbus: %s source=%s: %w", event.Type, event.SourceID, ErrNoLinkedEntry)
+	}
+	return fmt.Sprintf("Reverses journal entries %s", strings.Join(ids, ", ")), nil
+}
+
+// CreateDraft persists the entry for an approved event as a draft — it is
+// never posted, even when PostJournalEntry is wired — and links it to the
+// event's source. Reversal events are not drafted; publish them on the bus.
+// Returns the new journal entry ID.
+//
+// An event the poster has already handled is not drafted again: CreateDraft
+// returns the existing entry ID, when known, with ErrAlreadyPosted. A new
+// draft is recorded in the ProcessedEventStore, so the live bus skips the
+// event if it is delivered later.
+func (p *JournalPoster) CreateDraft(ctx context.Context, event Event) (string, error) {
+	if isReversalType(event.Type) {
+		return "", fmt.Errorf("eventbus: %s source=%s: reversal events cannot be created as drafts", event.Type, event.SourceID)
+	}
+	existingID, done, err := p.existingEntry(ctx, event)
+	if err != nil {
+		return "", err
+	}
+	if done {
+		return existingID, fmt.Errorf("eventbus: %s source=%s: %w", event.Type, event.SourceID, ErrAlreadyPosted)
+	}
+
+	entry, err := p.BuildEntry(ctx, event)
+	if err != nil {
+		return "", err
+	}
+	id, err := p.createAndLink(ctx, event, entry, false)
+	if err != nil {
+		return id, err
+	}
+	if p.deps.Processed != nil {
+		key := processedKeyFor(JournalPosterConsumer, event, p.recurring(event.Type))
+		if err := p.deps.Processed.MarkProcessed(ctx, key); err != nil {
+			return id, fmt.Errorf("eventbus: %s source=%s: recording draft %s: %w", event.Type, event.SourceID, id, err)
+		}
+	}
+	return id, nil
+}
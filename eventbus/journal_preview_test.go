@@ -0,0 +1,165 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	jepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/ledger/journal_entry"
+)
+
+func TestJournalPoster_Preview(t *testing.T) {
+	t.Parallel()
+
+	rec := &recordingDeps{}
+	store := NewMemoryProcessedStore()
+	deps := rec.deps()
+	deps.Links = store
+	poster := NewJournalPoster(deps)
+	ctx := context.Background()
+
+	if err := store.SaveLink(ctx, JournalEntryLink{EventType: EventTypeCollectionReceived, SourceID: "col-9", JournalEntryID: "je-old", Amount: 5000}); err != nil {
+		t.Fatalf("SaveLink: %v", err)
+	}
+
+	events := []Event{
+		{ID: "e1", Type: EventTypeRevenueCompleted, SourceID: "rev-1", Payload: revenuePayload},
+		{ID: "e2", Type: EventTypePayrollPosted, SourceID: "pr-1", Payload: map[string]any{
+			"gross_pay": 500.0, "net_pay": 420.0, "gov_contributions": 70.0,
+			"account_salary_exp": "sal", "account_cash": "cash", "account_gov_payables": "gov",
+		}},
+		{ID: "e3", Type: EventTypeLoanReceived, SourceID: "loan-1", Payload: map[string]any{}},
+		{ID: "e4", Type: EventTypeRevenueCancelled, SourceID: "rev-1", Payload: map[string]any{
+			"partial": true, "amount": 200.0, "account_ar": "ar", "account_rev": "rev",
+		}},
+		{ID: "e5", Type: EventTypeCollectionBounced, SourceID: "col-9"},
+	}
+	previews := poster.Preview(ctx, events)
+
+	if len(previews) != len(events) {
+		t.Fatalf("previews = %d, want %d", len(previews), len(events))
+	}
+	if len(rec.created) != 0 {
+		t.Fatalf("Preview created %d entries", len(rec.created))
+	}
+
+	t.Run("balanced entry", func(t *testing.T) {
+		pv := previews[0]
+		if !pv.OK() || pv.Debit != 100000 || pv.Credit != 100000 || pv.Key() != "e1" {
+			t.Errorf("preview = %+v", pv)
+		}
+	})
+
+	t.Run("unbalanced entry keeps its lines", func(t *testing.T) {
+		pv := previews[1]
+		if pv.Balanced || pv.OK() || !errors.Is(pv.Err, ErrUnbalancedEntry) {
+			t.Errorf("preview = %+v, want unbalanced", pv)
+		}
+		if pv.Entry == nil || len(pv.Entry.Lines) != 3 || pv.Debit != 50000 || pv.Credit != 49000 {
+			t.Errorf("entry = %+v debit=%d credit=%d", pv.Entry, pv.Debit, pv.Credit)
+		}
+	})
+
+	t.Run("invalid payload", func(t *testing.T) {
+		pv := previews[2]
+		if pv.Entry != nil || !errors.Is(pv.Err, ErrInvalidPayload) {
+			t.Errorf("preview = %+v, want invalid payload", pv)
+		}
+	})
+
+	t.Run("partial reversal previews the counter-entry", func(t *testing.T) {
+		pv := previews[3]
+		if !pv.OK() || pv.Debit != 20000 || !strings.HasPrefix(pv.Entry.Description, "Reversal: ") {
+			t.Errorf("preview = %+v", pv)
+		}
+	})
+
+	t.Run("full reversal names the linked entries", func(t *testing.T) {
+		pv := previews[4]
+		if pv.Err != nil || pv.Entry != nil || !strings.Contains(pv.Note, "je-old") {
+			t.Errorf("preview = %+v", pv)
+		}
+	})
+}
+
+func TestJournalPoster_CreateDraft(t *testing.T) {
+	t.Parallel()
+
+	rec := &recordingDeps{}
+	store := NewMemoryProcessedStore()
+	deps := rec.deps()
+	deps.Links = store
+	posted := 0
+	deps.PostJournalEntry = func(ctx context.Context, req *jepb.PostJournalEntryRequest) (*jepb.PostJournalEntryResponse, error) {
+		posted++
+		return &jepb.PostJournalEntryResponse{Success: true}, nil
+	}
+	poster := NewJournalPoster(deps)
+	ctx := context.Background()
+
+	id, err := poster.CreateDraft(ctx, Event{ID: "e1", Type: EventTypeRevenueCompleted, SourceID: "rev-1", Payload: revenuePayload})
+	if err != nil {
+		t.Fatalf("CreateDraft: %v", err)
+	}
+	if id != "je-1" || len(rec.lines[id]) != 2 || posted != 0 {
+		t.Errorf("id = %q lines = %v posted = %d, want an unposted draft", id, rec.lines[id], posted)
+	}
+	links, _ := store.LinksForSource(ctx, "rev-1")
+	if len(links) != 1 || links[0].JournalEntryID != id {
+		t.Errorf("links = %+v", links)
+	}
+
+	t.Run("repeat approval returns the existing entry", func(t *testing.T) {
+		id2, err := poster.CreateDraft(ctx, Event{ID: "e2", Type: EventTypeRevenueCompleted, SourceID: "rev-1", Payload: revenuePayload})
+		if !errors.Is(err, ErrAlreadyPosted) || id2 != id {
+			t.Errorf("CreateDraft = %q, %v, want %q, ErrAlreadyPosted", id2, err, id)
+		}
+		if len(rec.created) != 1 {
+			t.Errorf("created entries = %d, want 1", len(rec.created))
+		}
+		pv := poster.Preview(ctx, []Event{{ID: "e3", Type: EventTypeRevenueCompleted, SourceID: "rev-1", Payload: revenuePayload}})[0]
+		if pv.OK() || !pv.AlreadyPosted || pv.ExistingEntryID != id || !strings.Contains(pv.Note, id) {
+			t.Errorf("preview = %+v, want already posted as %s", pv, id)
+		}
+	})
+
+	t.Run("reversal events are rejected", func(t *testing.T) {
+		_, err := poster.CreateDraft(ctx, Event{Type: EventTypeRevenueCancelled, SourceID: "rev-1"})
+		if err == nil || !strings.Contains(err.Error(), "cannot be created as drafts") {
+			t.Errorf("error = %v", err)
+		}
+	})
+}
+
+func TestJournalPoster_CreateDraftSkipsPostedEvents(t *testing.T) {
+	t.Parallel()
+
+	rec := &recordingDeps{}
+	store := NewMemoryProcessedStore()
+	deps := rec.deps()
+	deps.Processed = store
+	poster := NewJournalPoster(deps)
+	bus := NewMemoryEventBus()
+	poster.RegisterAll(bus)
+	ctx := context.Background()
+
+	posted := Event{ID: "e1", Type: EventTypeRevenueCompleted, SourceID: "rev-1", Payload: revenuePayload}
+	if err := bus.Publish(ctx, posted); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if _, err := poster.CreateDraft(ctx, posted); !errors.Is(err, ErrAlreadyPosted) {
+		t.Errorf("CreateDraft of a bus-posted event: err = %v, want ErrAlreadyPosted", err)
+	}
+
+	drafted := Event{ID: "e2", Type: EventTypeRevenueCompleted, SourceID: "rev-2", Payload: revenuePayload}
+	if _, err := poster.CreateDraft(ctx, drafted); err != nil {
+		t.Fatalf("CreateDraft: %v", err)
+	}
+	if err := bus.Publish(ctx, drafted); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if len(rec.created) != 2 {
+		t.Errorf("created entries = %d, want 2 (the bus must skip the drafted event)", len(rec.created))
+	}
+}
@@ -0,0 +1,264 @@
+package eventbus
+
+// Replay regenerates auto-posted journal entries from the event log after a
+// posting rule has been corrected. For each stored event that matches the
+// filter, the entries JournalPoster previously created for it are reversed,
+// and the event is posted again with the current rules. Prior entries are
+// found through JournalPosterDeps.Links by source and event type, and for
+// recurring types by Event.ID as well, the way live posting deduplicates: a
+// single-shot event stored twice for one source replays once. The ReconciliationReport
+// records the before/after amounts and, when ReadLines is wired, the change
+// per account.
+//
+// Reversal events in the log are not replayed, and events whose entries have
+// all been reversed already (e.g. by a "revenue.cancelled") are skipped.
+// Counter-entries from partial reversals are left in place.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+)
+
+// ReplayOptions controls a Replay run.
+type ReplayOptions struct {
+	// DryRun builds the report without reversing or creating any entry.
+	DryRun bool
+}
+
+// ReplayStatus is the outcome of replaying one event.
+type ReplayStatus string
+
+const (
+	// ReplayReplayed: prior entries were reversed and a new entry created
+	// (or, in a dry run, would be).
+	ReplayReplayed ReplayStatus = "replayed"
+
+	// ReplayUnchanged: the current rules reproduce the existing entry
+	// account for account, so it was left alone. Requires ReadLines.
+	ReplayUnchanged ReplayStatus = "unchanged"
+
+	// ReplaySkipped: the event is a reversal event, its entries have
+	// already been reversed, or it repeats a single-shot event replayed
+	// earlier in the run.
+	ReplaySkipped ReplayStatus = "skipped"
+
+	// ReplayFailed: see ReplayItem.Err.
+	ReplayFailed ReplayStatus = "failed"
+)
+
+// AccountDiff is the net debit (debits minus credits, in centavos) posted to
+// one account before and after a replay.
+type AccountDiff struct {
+	AccountID string
+	Before    int64
+	After     int64
+}
+
+// Delta returns After minus Before.
+func (d AccountDiff) Delta() int64 {
+	return d.After - d.Before
+}
+
+// ReplayItem reports the replay of one stored event.
+type ReplayItem struct {
+	Event  Event
+	Status ReplayStatus
+
+	// BeforeEntryIDs are the unreversed entries previously created for the
+	// event; BeforeAmount is their total debit.
+	BeforeEntryIDs []string
+	BeforeAmount   int64
+
+	// AfterEntryID is the entry created by the replay (empty in a dry run);
+	// AfterAmount is its total debit under the current rules.
+	AfterEntryID string
+	AfterAmount  int64
+
+	// AccountDiffs lists the accounts whose net debit changes, sorted by
+	// account ID. Populated only when JournalPosterDeps.ReadLines is wired.
+	AccountDiffs []AccountDiff
+
+	Note string
+	Err  error
+}
+
+// ReconciliationReport summarizes a Replay run.
+type ReconciliationReport struct {
+	Filter EventFilter
+	DryRun bool
+	Items  []ReplayItem
+
+	Replayed  int
+	Unchanged int
+	Skipped   int
+	Failed    int
+
+	// BeforeTotal and AfterTotal sum BeforeAmount and AfterAmount over the
+	// replayed and unchanged items, in centavos.
+	BeforeTotal int64
+	AfterTotal  int64
+}
+
+// Replay re-posts the events in store that match filter with the current
+// posting rules (see the top of this file). A failure on one event is
+// recorded in the report and does not stop the run; the returned error is
+// reserved for problems that prevent the run altogether.
+func (p *JournalPoster) Replay(ctx context.Context, store EventStore, filter EventFilter, opts ReplayOptions) (*ReconciliationReport, error) {
+	if p.deps.Links == nil {
+		return nil, errors.New("eventbus: replay requires a JournalLinkStore")
+	}
+	events, err := store.Query(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ReconciliationReport{Filter: filter, DryRun: opts.DryRun}
+	replayed := make(map[ProcessedKey]string)
+	for _, event := range events {
+		var item ReplayItem
+		key := processedKeyFor(JournalPosterConsumer, event, p.recurring(event.Type))
+		if first, dup := replayed[key]; dup {
+			item = ReplayItem{Event: event, Status: ReplaySkipped, Note: fmt.Sprintf("duplicate of event %s for the same source", first)}
+		} else {
+			item = p.replayOne(ctx, event, opts)
+			replayed[key] = event.ID
+		}
+		switch item.Status {
+		case ReplayReplayed:
+			report.Replayed++
+		case ReplayUnchanged:
+			report.Unchanged++
+		case ReplaySkipped:
+			report.Skipped++
+		case ReplayFailed:
+			report.Failed++
+		}
+		if item.Status == ReplayReplayed || item.Status == ReplayUnchanged {
+			report.BeforeTotal += item.BeforeAmount
+			report.AfterTotal += item.AfterAmount
+		}
+		report.Items = append(report.Items, item)
+	}
+
+	log.Printf("[eventbus] replay of %d events (dry run: %t): %d replayed, %d unchanged, %d skipped, %d failed",
+		len(events), opts.DryRun, report.Replayed, report.Unchanged, report.Skipped, report.Failed)
+	return report, nil
+}
+
+func (p *JournalPoster) replayOne(ctx context.Context, event Event, opts ReplayOptions) ReplayItem {
+	item := ReplayItem{Event: event}
+	fail := func(err error) ReplayItem {
+		item.Status = ReplayFailed
+		item.Err = err
+		return item
+	}
+
+	if _, ok := OriginalEventType(event.Type); ok {
+		item.Status = ReplaySkipped
+		item.Note = "reversal events are not replayed"
+		return item
+	}
+
+	links, err := p.deps.Links.LinksForSource(ctx, event.SourceID)
+	if err != nil {
+		return fail(fmt.Errorf("eventbus: %s source=%s: %w", event.Type, event.SourceID, err))
+	}
+	recurring := p.recurring(event.Type)
+	var prior, active []JournalEntryLink
+	for _, l := range links {
+		if l.EventType == event.Type && (!recurring || l.EventID == event.ID) {
+			prior = append(prior, l)
+			if !l.Reversed {
+				active = append(active, l)
+			}
+		}
+	}
+	if len(prior) > 0 && len(active) == 0 {
+		item.Status = ReplaySkipped
+		item.Note = "entries already reversed"
+		return item
+	}
+	for _, l := range active {
+		item.BeforeEntryIDs = append(item.BeforeEntryIDs, l.JournalEntryID)
+		item.BeforeAmount += l.Amount
+	}
+
+	entry, err := p.BuildEntry(ctx, event)
+	if err != nil {
+		return fail(err)
+	}
+	item.AfterAmount, _ = entry.Totals()
+
+	if p.deps.ReadLines != nil {
+		var before []JournalLine
+		for _, l := range active {
+			lines, err := p.deps.ReadLines(ctx, l.JournalEntryID)
+			if err != nil {
+				return fail(fmt.Errorf("eventbus: %s source=%s: reading lines of %s: %w", event.Type, event.SourceID, l.JournalEntryID, err))
+			}
+			before = append(before, lines...)
+		}
+		item.AccountDiffs = diffAccounts(before, entry.Lines)
+		if len(active) > 0 && len(item.AccountDiffs) == 0 {
+			item.Status = ReplayUnchanged
+			return item
+		}
+	}
+	if len(active) == 0 {
+		item.Note = "no prior entry"
+	}
+
+	item.Status = ReplayReplayed
+	if opts.DryRun {
+		return item
+	}
+
+	for i := len(active) - 1; i >= 0; i-- {
+		id := active[i].JournalEntryID
+		if err := p.reverseEntry(ctx, id); err != nil {
+			return fail(fmt.Errorf("eventbus: %s source=%s: %w", event.Type, event.SourceID, err))
+		}
+		if err := p.deps.Links.MarkReversed(ctx, id); err != nil {
+			return fail(fmt.Errorf("eventbus: %s source=%s: %w", event.Type, event.SourceID, err))
+		}
+	}
+	item.AfterEntryID, err = p.createAndLink(ctx, event, entry, true)
+	if err != nil {
+		return fail(err)
+	}
+	log.Printf("[eventbus] replayed %s source=%s: reversed %v → journal entry %s",
+		event.Type, event.SourceID, item.BeforeEntryIDs, item.AfterEntryID)
+	return item
+}
+
+// diffAccounts compares the net debit per account of two line sets and
+// returns the accounts that differ, sorted by account ID.
+func diffAccounts(before, after []JournalLine) []AccountDiff {
+	net := make(map[string]*AccountDiff)
+	get := func(accountID string) *AccountDiff {
+		d, ok := net[accountID]
+		if !ok {
+			d = &AccountDiff{AccountID: accountID}
+			net[accountID] = d
+		}
+		return d
+	}
+	for _, l := range before {
+		get(l.AccountID).Before += l.Debit - l.Credit
+	}
+	for _, l := range after {
+		get(l.AccountID).After += l.Debit - l.Credit
+	}
+
+	var diffs []AccountDiff
+	for _, d := range net {
+		if d.Delta() != 0 {
+			diffs = append(diffs, *d)
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].AccountID < diffs[j].AccountID })
+	return diffs
+}
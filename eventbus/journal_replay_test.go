@@ -0,0 +1,240 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	jepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/ledger/journal_entry"
+)
+
+// replayFixture publishes collections through a StoringEventBus whose poster
+// uses a rule that credits the wrong account, and returns deps that Replay
+// can be run against with corrected rules.
+type replayFixture struct {
+	rec   *recordingDeps
+	deps  *JournalPosterDeps
+	links *MemoryProcessedStore
+	store *MemoryEventStore
+	bus   *StoringEventBus
+	base  time.Time
+	wrong *PostingRuleSet
+	fixed *PostingRuleSet
+}
+
+func newReplayFixture(t *testing.T) *replayFixture {
+	t.Helper()
+
+	parse := func(credit string) *PostingRuleSet {
+		rules, err := ParsePostingRulesYAML([]byte(`
+rules:
+  - event_type: collection.received
+    source_type: collection
+    description: Collection received
+    lines:
+      - {side: debit, account_key: account_cash, amount: amount}
+      - {side: credit, account_key: ` + credit + `, amount: amount}
+`))
+		if err != nil {
+			t.Fatalf("parsing rules: %v", err)
+		}
+		return rules
+	}
+
+	f := &replayFixture{
+		rec:   &recordingDeps{},
+		links: NewMemoryProcessedStore(),
+		store: NewMemoryEventStore(),
+		base:  time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		wrong: parse("account_rev"),
+		fixed: parse("account_ar"),
+	}
+	f.deps = f.rec.deps()
+	f.deps.Processed = f.links
+	f.deps.Links = f.links
+	f.deps.Rules = f.wrong
+	f.deps.ReverseJournalEntry = func(ctx context.Context, req *jepb.ReverseJournalEntryRequest) (*jepb.ReverseJournalEntryResponse, error) {
+		f.rec.posted = append(f.rec.posted, "reverse:"+req.JournalEntryId)
+		return &jepb.ReverseJournalEntryResponse{Success: true}, nil
+	}
+
+	inner := NewMemoryEventBus()
+	NewJournalPoster(f.deps).RegisterAll(inner)
+	f.bus = NewStoringEventBus(inner, f.store)
+	return f
+}
+
+func (f *replayFixture) publish(t *testing.T, sourceID string, amount float64, at time.Time) {
+	t.Helper()
+	err := f.bus.Publish(context.Background(), Event{
+		Type: EventTypeCollectionReceived, SourceID: sourceID, Timestamp: at,
+		Payload: map[string]any{"amount": amount, "account_cash": "cash", "account_ar": "ar", "account_rev": "rev"},
+	})
+	if err != nil {
+		t.Fatalf("publish %s: %v", sourceID, err)
+	}
+}
+
+// poster returns a JournalPoster sharing the fixture's use cases with rules.
+func (f *replayFixture) poster(rules *PostingRuleSet, readLines bool) *JournalPoster {
+	deps := *f.deps
+	deps.Rules = rules
+	if readLines {
+		deps.ReadLines = func(ctx context.Context, id string) ([]JournalLine, error) {
+			return f.rec.lines[id], nil
+		}
+	}
+	return NewJournalPoster(&deps)
+}
+
+func TestJournalPoster_Replay(t *testing.T) {
+	t.Parallel()
+
+	f := newReplayFixture(t)
+	f.publish(t, "col-1", 100, f.base.Add(24*time.Hour))
+	f.publish(t, "col-2", 250, f.base.Add(48*time.Hour))
+	f.publish(t, "col-3", 999, f.base.AddDate(0, 1, 0)) // outside the replayed month
+	ctx := context.Background()
+	march := EventFilter{Types: []string{EventTypeCollectionReceived}, Since: f.base, Until: f.base.AddDate(0, 1, 0)}
+
+	t.Run("dry run reports without touching the ledger", func(t *testing.T) {
+		report, err := f.poster(f.fixed, true).Replay(ctx, f.store, march, ReplayOptions{DryRun: true})
+		if err != nil {
+			t.Fatalf("Replay: %v", err)
+		}
+		if report.Replayed != 2 || len(f.rec.created) != 3 || len(f.rec.posted) != 0 {
+			t.Fatalf("report = %+v, created = %d, posted = %v", report, len(f.rec.created), f.rec.posted)
+		}
+		diffs := report.Items[0].AccountDiffs
+		want := []AccountDiff{{AccountID: "ar", After: -10000}, {AccountID: "rev", Before: -10000}}
+		if len(diffs) != 2 || diffs[0] != want[0] || diffs[1] != want[1] {
+			t.Errorf("diffs = %+v, want %+v", diffs, want)
+		}
+	})
+
+	t.Run("replay reverses and re-posts with current rules", func(t *testing.T) {
+		report, err := f.poster(f.fixed, true).Replay(ctx, f.store, march, ReplayOptions{})
+		if err != nil {
+			t.Fatalf("Replay: %v", err)
+		}
+		if report.Replayed != 2 || report.Failed != 0 || report.BeforeTotal != 35000 || report.AfterTotal != 35000 {
+			t.Fatalf("report = %+v", report)
+		}
+		if len(f.rec.posted) != 2 || f.rec.posted[0] != "reverse:je-1" || f.rec.posted[1] != "reverse:je-2" {
+			t.Errorf("reversals = %v", f.rec.posted)
+		}
+		item := report.Items[0]
+		if item.AfterEntryID != "je-4" || f.rec.lines["je-4"][1].AccountID != "ar" {
+			t.Errorf("item = %+v, lines = %+v", item, f.rec.lines["je-4"])
+		}
+		links, _ := f.links.LinksForSource(ctx, "col-1")
+		if len(links) != 2 || !links[0].Reversed || links[1].Reversed || links[1].JournalEntryID != "je-4" {
+			t.Errorf("links = %+v", links)
+		}
+	})
+
+	t.Run("second replay leaves matching entries unchanged", func(t *testing.T) {
+		report, err := f.poster(f.fixed, true).Replay(ctx, f.store, march, ReplayOptions{})
+		if err != nil {
+			t.Fatalf("Replay: %v", err)
+		}
+		if report.Unchanged != 2 || report.Replayed != 0 || len(f.rec.created) != 5 {
+			t.Errorf("report = %+v, created = %d", report, len(f.rec.created))
+		}
+	})
+
+	t.Run("without ReadLines every event is replayed", func(t *testing.T) {
+		report, err := f.poster(f.fixed, false).Replay(ctx, f.store, EventFilter{SourceID: "col-3"}, ReplayOptions{DryRun: true})
+		if err != nil {
+			t.Fatalf("Replay: %v", err)
+		}
+		if report.Replayed != 1 || report.Items[0].AccountDiffs != nil || report.Items[0].BeforeAmount != 99900 {
+			t.Errorf("report = %+v", report)
+		}
+	})
+}
+
+func TestJournalPoster_ReplaySkipsAndFailures(t *testing.T) {
+	t.Parallel()
+
+	f := newReplayFixture(t)
+	ctx := context.Background()
+	f.publish(t, "col-1", 100, f.base)
+	f.publish(t, "col-2", 100, f.base)
+	if err := f.bus.Publish(ctx, Event{Type: EventTypeCollectionBounced, SourceID: "col-1", Timestamp: f.base.Add(time.Hour)}); err != nil {
+		t.Fatalf("publish bounce: %v", err)
+	}
+	_ = f.store.Append(ctx, Event{ID: "broken", Type: EventTypeCollectionReceived, SourceID: "col-9", Timestamp: f.base.Add(2 * time.Hour)})
+
+	report, err := f.poster(f.fixed, false).Replay(ctx, f.store, EventFilter{}, ReplayOptions{})
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	statuses := make(map[string]ReplayStatus)
+	for _, item := range report.Items {
+		statuses[item.Event.SourceID+"/"+item.Event.Type] = item.Status
+	}
+	want := map[string]ReplayStatus{
+		"col-1/" + EventTypeCollectionReceived: ReplaySkipped,
+		"col-2/" + EventTypeCollectionReceived: ReplayReplayed,
+		"col-1/" + EventTypeCollectionBounced:  ReplaySkipped,
+		"col-9/" + EventTypeCollectionReceived: ReplayFailed,
+	}
+	for key, status := range want {
+		if statuses[key] != status {
+			t.Errorf("%s = %q, want %q", key, statuses[key], status)
+		}
+	}
+	if report.Failed != 1 || !errors.Is(report.Items[3].Err, ErrInvalidPayload) {
+		t.Errorf("failed item = %+v", report.Items[3])
+	}
+
+	t.Run("link store is required", func(t *testing.T) {
+		_, err := NewJournalPoster(nil).Replay(ctx, f.store, EventFilter{}, ReplayOptions{})
+		if err == nil {
+			t.Error("Replay without a JournalLinkStore succeeded")
+		}
+	})
+}
+
+// TestJournalPoster_ReplayDuplicateSingleShot stores a single-shot event
+// twice for one source under different IDs. Live posting skips the second
+// copy, so it has no link of its own; replay must match it to the source's
+// entry rather than post it again.
+func TestJournalPoster_ReplayDuplicateSingleShot(t *testing.T) {
+	t.Parallel()
+
+	f := newReplayFixture(t)
+	ctx := context.Background()
+	f.publish(t, "col-1", 100, f.base)
+	f.publish(t, "col-1", 100, f.base.Add(time.Hour))
+	if len(f.rec.created) != 1 {
+		t.Fatalf("created entries = %d, want 1 after duplicate publish", len(f.rec.created))
+	}
+
+	report, err := f.poster(f.fixed, false).Replay(ctx, f.store, EventFilter{SourceID: "col-1"}, ReplayOptions{})
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if len(report.Items) != 2 || report.Replayed != 1 || report.Skipped != 1 {
+		t.Fatalf("report = %+v", report)
+	}
+	if first := report.Items[0]; len(first.BeforeEntryIDs) != 1 || first.BeforeEntryIDs[0] != "je-1" {
+		t.Errorf("first copy = %+v, want it to replace je-1", first)
+	}
+	if len(f.rec.created) != 2 || len(f.rec.posted) != 1 || f.rec.posted[0] != "reverse:je-1" {
+		t.Errorf("created = %d, posted = %v; want one reversal and one new entry", len(f.rec.created), f.rec.posted)
+	}
+
+	t.Run("second copy alone finds the source's entry", func(t *testing.T) {
+		second := report.Items[1].Event
+		report, err := f.poster(f.fixed, false).Replay(ctx, f.store, EventFilter{SourceID: "col-1", Since: second.Timestamp}, ReplayOptions{DryRun: true})
+		if err != nil {
+			t.Fatalf("Replay: %v", err)
+		}
+		if len(report.Items) != 1 || report.Items[0].Event.ID != second.ID || len(report.Items[0].BeforeEntryIDs) != 1 {
+			t.Errorf("report = %+v, want the replayed entry as prior", report)
+		}
+	})
+}
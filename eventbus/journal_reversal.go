@@ -0,0 +1,206 @@
+package eventbus
+
+// Reversal events unwind journal entries JournalPoster created earlier for
+// the same SourceID. They rely on JournalPosterDeps.Links to find those
+// entries.
+//
+//   "revenue.cancelled"    unwinds "revenue.completed"
+//   "disbursement.voided"  unwinds "disbursement.paid"
+//   "collection.bounced"   unwinds "collection.received"
+//   "payroll.reversed"     unwinds "payroll.posted"
+//
+// Full reversal (default): every linked entry for the source that has not
+// been reversed yet — the original and any earlier partial reversals — is
+// passed to ReverseJournalEntry.
+//
+// Partial reversal (payload "partial": true): the payload carries the same
+// keys as the original event, with amounts for the reversed portion only
+// (e.g. a partial refund). The original event's posting rule is evaluated
+// against it and the resulting lines are swapped debit-for-credit into a new
+// counter-entry. The counter-entries for a source may not exceed the original
+// amount. When JournalPosterDeps.Processed is wired, reversal events are
+// tracked per Event.ID: give each partial reversal its own Event.ID so
+// repeated refunds are not skipped as redeliveries.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	jepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/ledger/journal_entry"
+)
+
+// EventTypeRevenueCancelled fires when a completed revenue record is cancelled or refunded.
+const EventTypeRevenueCancelled = "revenue.cancelled"
+
+// EventTypeDisbursementVoided fires when a paid disbursement is voided.
+const EventTypeDisbursementVoided = "disbursement.voided"
+
+// EventTypeCollectionBounced fires when a recorded collection bounces (e.g. a returned cheque).
+const EventTypeCollectionBounced = "collection.bounced"
+
+// EventTypePayrollReversed fires when a posted payroll run is reversed.
+const EventTypePayrollReversed = "payroll.reversed"
+
+// ErrNoLinkedEntry is returned when a reversal event arrives for a source
+// that has no auto-posted journal entry to reverse.
+var ErrNoLinkedEntry = errors.New("no auto-posted journal entry for source")
+
+// reversalOf maps each reversal event type to the event type it unwinds.
+var reversalOf = map[string]string{
+	EventTypeRevenueCancelled:   EventTypeRevenueCompleted,
+	EventTypeDisbursementVoided: EventTypeDisbursementPaid,
+	EventTypeCollectionBounced:  EventTypeCollectionReceived,
+	EventTypePayrollReversed:    EventTypePayrollPosted,
+}
+
+// ReversalEventTypes returns the reversal event types JournalPoster recognizes.
+func ReversalEventTypes() []string {
+	return []string{
+		EventTypeRevenueCancelled,
+		EventTypeDisbursementVoided,
+		EventTypeCollectionBounced,
+		EventTypePayrollReversed,
+	}
+}
+
+// OriginalEventType returns the event type a reversal event unwinds.
+func OriginalEventType(reversalType string) (string, bool) {
+	original, ok := reversalOf[reversalType]
+	return original, ok
+}
+
+// handleReversal reverses, fully or partially, the entries linked to the
+// event's source.
+func (p *JournalPoster) handleReversal(ctx context.Context, event Event) error {
+	original, ok := OriginalEventType(event.Type)
+	if !ok {
+		return fmt.Errorf("eventbus: %q is not a reversal event type", event.Type)
+	}
+	if p.deps.Links == nil {
+		return fmt.Errorf("eventbus: %s source=%s: JournalLinkStore not wired", event.Type, event.SourceID)
+	}
+
+	links, err := p.deps.Links.LinksForSource(ctx, event.SourceID)
+	if err != nil {
+		return fmt.Errorf("eventbus: %s source=%s: %w", event.Type, event.SourceID, err)
+	}
+
+	var originals, counters []JournalEntryLink
+	for _, l := range links {
+		switch l.EventType {
+		case original:
+			originals = append(originals, l)
+		case event.Type:
+			counters = append(counters, l)
+		}
+	}
+	if len(originals) == 0 {
+		return fmt.Errorf("eventbus: %s source=%s: %w", event.Type, event.SourceID, ErrNoLinkedEntry)
+	}
+
+	if partial, ok := event.Payload["partial"]; truthy(partial, ok) {
+		return p.reversePartially(ctx, event, original, originals, counters)
+	}
+	return p.reverseFully(ctx, event, append(originals, counters...))
+}
+
+// reverseFully reverses every not-yet-reversed linked entry, newest first.
+func (p *JournalPoster) reverseFully(ctx context.Context, event Event, links []JournalEntryLink) error {
+	reversed := 0
+	for i := len(links) - 1; i >= 0; i-- {
+		link := links[i]
+		if link.Reversed {
+			continue
+		}
+		if err := p.reverseEntry(ctx, link.JournalEntryID); err != nil {
+			return fmt.Errorf("eventbus: %s source=%s: %w", event.Type, event.SourceID, err)
+		}
+		if err := p.deps.Links.MarkReversed(ctx, link.JournalEntryID); err != nil {
+			return fmt.Errorf("eventbus: %s source=%s: %w", event.Type, event.SourceID, err)
+		}
+		log.Printf("[eventbus] %s source=%s → reversed journal entry %s", event.Type, event.SourceID, link.JournalEntryID)
+		reversed++
+	}
+	if reversed == 0 {
+		log.Printf("[eventbus] %s source=%s: entries already reversed, nothing to do", event.Type, event.SourceID)
+	}
+	return nil
+}
+
+// reversePartially posts a counter-entry for the reversed portion.
+func (p *JournalPoster) reversePartially(ctx context.Context, event Event, original string, originals, counters []JournalEntryLink) error {
+	entry, err := p.BuildReversalEntry(ctx, event)
+	if err != nil {
+		return err
+	}
+
+	var remaining int64
+	for _, l := range originals {
+		if !l.Reversed {
+			remaining += l.Amount
+		}
+	}
+	for _, l := range counters {
+		if !l.Reversed {
+			remaining -= l.Amount
+		}
+	}
+	if debit, _ := entry.Totals(); debit > remaining {
+		return fmt.Errorf("eventbus: %s source=%s: %w: reversal of %s exceeds the %s remaining on %s",
+			event.Type, event.SourceID, ErrInvalidPayload, formatCentavos(debit), formatCentavos(remaining), original)
+	}
+	_, err = p.createAndLink(ctx, event, entry, true)
+	return err
+}
+
+// BuildReversalEntry evaluates the counter-entry for a partial reversal event
+// without persisting it: the original event type's rule is applied to the
+// event payload and every line is swapped debit-for-credit.
+func (p *JournalPoster) BuildReversalEntry(ctx context.Context, event Event) (*PostingEntry, error) {
+	original, ok := OriginalEventType(event.Type)
+	if !ok {
+		return nil, fmt.Errorf("eventbus: %q is not a reversal event type", event.Type)
+	}
+
+	asOriginal := event
+	asOriginal.Type = original
+	entry, err := p.BuildEntry(ctx, asOriginal)
+	if err != nil {
+		return nil, err
+	}
+	return counterEntry(entry, event.Type), nil
+}
+
+// counterEntry turns an entry evaluated with the original event type's rule
+// into the counter-entry for reversalType.
+func counterEntry(entry *PostingEntry, reversalType string) *PostingEntry {
+	entry.EventType = reversalType
+	entry.Description = "Reversal: " + entry.Description
+	for i := range entry.Lines {
+		l := &entry.Lines[i]
+		l.Debit, l.Credit = l.Credit, l.Debit
+		l.TransactionDebit, l.TransactionCredit = l.TransactionCredit, l.TransactionDebit
+	}
+	return entry
+}
+
+// reverseEntry calls the ReverseJournalEntry use case for one entry.
+func (p *JournalPoster) reverseEntry(ctx context.Context, journalEntryID string) error {
+	if p.deps.ReverseJournalEntry == nil {
+		return fmt.Errorf("ReverseJournalEntry use case not wired")
+	}
+	resp, err := p.deps.ReverseJournalEntry(ctx, &jepb.ReverseJournalEntryRequest{JournalEntryId: journalEntryID})
+	if err != nil {
+		return fmt.Errorf("reversing %s: %w", journalEntryID, err)
+	}
+	if resp == nil || !resp.GetSuccess() {
+		msg := "unknown error"
+		if resp.GetError() != nil {
+			msg = resp.GetError().GetMessage()
+		}
+		return fmt.Errorf("reversing %s: %s", journalEntryID, msg)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	jepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/ledger/journal_entry"
+)
+
+// newReversalPoster wires a JournalPoster with recording use cases and an
+// in-memory link store, registered on a fresh MemoryEventBus.
+func newReversalPoster(t *testing.T) (*MemoryEventBus, *recordingDeps, *MemoryProcessedStore) {
+	t.Helper()
+
+	rec := &recordingDeps{}
+	store := NewMemoryProcessedStore()
+	deps := rec.deps()
+	deps.Links = store
+	deps.ReverseJournalEntry = func(ctx context.Context, req *jepb.ReverseJournalEntryRequest) (*jepb.ReverseJournalEntryResponse, error) {
+		rec.posted = append(rec.posted, "reverse:"+req.JournalEntryId)
+		return &jepb.ReverseJournalEntryResponse{Success: true}, nil
+	}
+
+	bus := NewMemoryEventBus()
+	NewJournalPoster(deps).RegisterAll(bus)
+	return bus, rec, store
+}
+
+var revenuePayload = map[string]any{"amount": 1000.0, "account_ar": "ar", "account_rev": "rev"}
+
+func TestJournalPoster_FullReversal(t *testing.T) {
+	t.Parallel()
+
+	bus, rec, store := newReversalPoster(t)
+	ctx := context.Background()
+
+	if err := bus.Publish(ctx, Event{Type: EventTypeRevenueCompleted, SourceID: "rev-1", Payload: revenuePayload}); err != nil {
+		t.Fatalf("publish original: %v", err)
+	}
+	if err := bus.Publish(ctx, Event{Type: EventTypeRevenueCancelled, SourceID: "rev-1"}); err != nil {
+		t.Fatalf("publish cancellation: %v", err)
+	}
+
+	originalID := rec.created[0].GetId()
+	if len(rec.posted) != 1 || rec.posted[0] != "reverse:"+originalID {
+		t.Fatalf("reversals = %v, want [reverse:%s]", rec.posted, originalID)
+	}
+	links, _ := store.LinksForSource(ctx, "rev-1")
+	if len(links) != 1 || !links[0].Reversed {
+		t.Errorf("links = %+v, want original marked reversed", links)
+	}
+
+	t.Run("second cancellation is a no-op", func(t *testing.T) {
+		if err := bus.Publish(ctx, Event{Type: EventTypeRevenueCancelled, SourceID: "rev-1"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(rec.posted) != 1 {
+			t.Errorf("reversals = %v, want 1", rec.posted)
+		}
+	})
+}
+
+func TestJournalPoster_PartialReversal(t *testing.T) {
+	t.Parallel()
+
+	bus, rec, store := newReversalPoster(t)
+	ctx := context.Background()
+
+	if err := bus.Publish(ctx, Event{Type: EventTypeRevenueCompleted, SourceID: "rev-1", Payload: revenuePayload}); err != nil {
+		t.Fatalf("publish original: %v", err)
+	}
+
+	refund := map[string]any{"partial": true, "amount": 300.0, "account_ar": "ar", "account_rev": "rev"}
+	if err := bus.Publish(ctx, Event{Type: EventTypeRevenueCancelled, SourceID: "rev-1", Payload: refund}); err != nil {
+		t.Fatalf("publish partial refund: %v", err)
+	}
+
+	if len(rec.created) != 2 {
+		t.Fatalf("created entries = %d, want 2", len(rec.created))
+	}
+	counter := rec.created[1]
+	if counter.GetTotalDebit() != 30000 || !strings.HasPrefix(counter.GetDescription(), "Reversal: ") {
+		t.Errorf("counter-entry = %d %q", counter.GetTotalDebit(), counter.GetDescription())
+	}
+	lines := rec.lines[counter.GetId()]
+	if len(lines) != 2 || lines[0].AccountID != "ar" || lines[0].Credit != 30000 || lines[1].AccountID != "rev" || lines[1].Debit != 30000 {
+		t.Errorf("counter lines = %+v, want CR ar / DR rev", lines)
+	}
+	if len(rec.posted) != 0 {
+		t.Errorf("ReverseJournalEntry called for a partial reversal: %v", rec.posted)
+	}
+
+	t.Run("reversal beyond the remaining amount is rejected", func(t *testing.T) {
+		tooMuch := map[string]any{"partial": true, "amount": 800.0, "account_ar": "ar", "account_rev": "rev"}
+		err := bus.Publish(ctx, Event{Type: EventTypeRevenueCancelled, SourceID: "rev-1", Payload: tooMuch})
+		if !errors.Is(err, ErrInvalidPayload) || !strings.Contains(err.Error(), "700.00 remaining") {
+			t.Errorf("error = %v, want remaining-amount error", err)
+		}
+	})
+
+	t.Run("full reversal after partial unwinds both entries", func(t *testing.T) {
+		if err := bus.Publish(ctx, Event{Type: EventTypeRevenueCancelled, SourceID: "rev-1"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []string{"reverse:" + counter.GetId(), "reverse:" + rec.created[0].GetId()}
+		if strings.Join(rec.posted, ",") != strings.Join(want, ",") {
+			t.Errorf("reversals = %v, want %v", rec.posted, want)
+		}
+		links, _ := store.LinksForSource(ctx, "rev-1")
+		for _, l := range links {
+			if !l.Reversed {
+				t.Errorf("link %s not marked reversed", l.JournalEntryID)
+			}
+		}
+	})
+}
+
+func TestJournalPoster_ReversalErrors(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no linked entry", func(t *testing.T) {
+		t.Parallel()
+
+		bus, _, _ := newReversalPoster(t)
+		err := bus.Publish(context.Background(), Event{Type: EventTypePayrollReversed, SourceID: "pr-unknown"})
+		if !errors.Is(err, ErrNoLinkedEntry) {
+			t.Errorf("error = %v, want ErrNoLinkedEntry", err)
+		}
+	})
+
+	t.Run("link store not wired", func(t *testing.T) {
+		t.Parallel()
+
+		bus := NewMemoryEventBus()
+		NewJournalPoster(nil).RegisterAll(bus)
+		err := bus.Publish(context.Background(), Event{Type: EventTypeDisbursementVoided, SourceID: "d-1"})
+		if err == nil || !strings.Contains(err.Error(), "JournalLinkStore not wired") {
+			t.Errorf("error = %v, want not wired error", err)
+		}
+	})
+
+	t.Run("reverse use case failure is surfaced", func(t *testing.T) {
+		t.Parallel()
+
+		rec := &recordingDeps{}
+		store := NewMemoryProcessedStore()
+		deps := rec.deps()
+		deps.Links = store
+		deps.ReverseJournalEntry = func(ctx context.Context, req *jepb.ReverseJournalEntryRequest) (*jepb.ReverseJournalEntryResponse, error) {
+			return nil, errors.New("period closed")
+		}
+		bus := NewMemoryEventBus()
+		NewJournalPoster(deps).RegisterAll(bus)
+
+		payload := map[string]any{"amount": 50.0, "account_cash": "cash", "account_ar": "ar"}
+		if err := bus.Publish(context.Background(), Event{Type: EventTypeCollectionReceived, SourceID: "col-1", Payload: payload}); err != nil {
+			t.Fatalf("publish original: %v", err)
+		}
+		err := bus.Publish(context.Background(), Event{Type: EventTypeCollectionBounced, SourceID: "col-1"})
+		if err == nil || !strings.Contains(err.Error(), "period closed") {
+			t.Errorf("error = %v, want use case error", err)
+		}
+		links, _ := store.LinksForSource(context.Background(), "col-1")
+		if links[0].Reversed {
+			t.Error("link marked reversed after a failed reversal")
+		}
+	})
+}
@@ -8,12 +8,15 @@ import (
 )
 
 // MemoryEventBus is an in-process, synchronous event bus implementation.
-// All handlers for an event type are invoked in registration order.
-// This is the Phase 9 default; replace with a durable bus (e.g. outbox pattern)
-// for production use cases that need delivery guarantees.
+// Handlers for the exact event type are invoked in registration order,
+// followed by matching pattern handlers.
+// Use OutboxEventBus when events must survive a crash or commit atomically
+// with the business change that raised them.
 type MemoryEventBus struct {
-	handlers map[string][]Handler
-	mu       sync.RWMutex
+	handlers   map[string][]Handler
+	patterns   patternSubscriptions
+	mu         sync.RWMutex
+	middleware middlewareStack
 }
 
 // NewMemoryEventBus creates a new MemoryEventBus with no registered handlers.
@@ -23,30 +26,46 @@ func NewMemoryEventBus() *MemoryEventBus {
 	}
 }
 
-// Subscribe registers a handler for a specific event type.
+// Subscribe registers a handler for an event type or a pattern such as
+// "revenue.*" (see pattern.go).
 // Thread-safe; multiple handlers per event type are supported.
 func (b *MemoryEventBus) Subscribe(eventType string, handler Handler) {
+	if IsEventPattern(eventType) {
+		b.patterns.add(eventType, handler)
+		return
+	}
 	b.mu.Lock()
 	defer b.mu.Unlock()
 	b.handlers[eventType] = append(b.handlers[eventType], handler)
 }
 
+// Use adds middleware around every handler. Handlers are called directly on
+// the publishing goroutine, so add Recover to keep a panicking handler from
+// taking down the caller.
+func (b *MemoryEventBus) Use(middleware ...Middleware) {
+	b.middleware.use(middleware...)
+}
+
 // Publish dispatches an event synchronously to all registered handlers.
-// If Event.Timestamp is zero, it is set to the current time before dispatch.
+// An empty Event.ID is set with NewEventID and a zero Event.Timestamp to the
+// current time before dispatch.
 // Returns a combined error if any handler fails; all handlers are invoked
 // regardless of individual failures.
 func (b *MemoryEventBus) Publish(ctx context.Context, event Event) error {
+	if event.ID == "" {
+		event.ID = NewEventID()
+	}
 	if event.Timestamp.IsZero() {
 		event.Timestamp = time.Now()
 	}
 
 	b.mu.RLock()
-	handlers := b.handlers[event.Type]
+	handlers := b.patterns.appendMatches(b.handlers[event.Type], event.Type)
 	b.mu.RUnlock()
 
 	var errs []error
 	for _, h := range handlers {
-		if err := h(ctx, event); err != nil {
+		if err := b.middleware.wrap(h)(ctx, event); err != nil {
 			errs = append(errs, err)
 		}
 	}
@@ -47,7 +47,7 @@ func TestMemoryEventBus_SubscribeAndPublish(t *testing.T) {
 		}
 	})
 
-	t.Run("timestamp defaults to now when zero", func(t *testing.T) {
+	t.Run("ID and timestamp default when empty", func(t *testing.T) {
 		t.Parallel()
 
 		bus := NewMemoryEventBus()
@@ -69,6 +69,9 @@ func TestMemoryEventBus_SubscribeAndPublish(t *testing.T) {
 			t.Fatalf("unexpected error: %v", err)
 		}
 
+		if received.ID == "" {
+			t.Error("ID should be assigned")
+		}
 		if received.Timestamp.IsZero() {
 			t.Fatal("Timestamp should not be zero")
 		}
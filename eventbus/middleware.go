@@ -0,0 +1,240 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware wraps a Handler with cross-cutting behaviour such as logging,
+// tracing or retries. Every bus accepts middleware through its Use method;
+// it applies to all handlers, including those subscribed before Use.
+type Middleware func(Handler) Handler
+
+// Chain composes middleware into one. The first middleware is the outermost:
+// Chain(a, b)(h) runs a, then b, then h.
+func Chain(middleware ...Middleware) Middleware {
+	return func(h Handler) Handler {
+		for i := len(middleware) - 1; i >= 0; i-- {
+			h = middleware[i](h)
+		}
+		return h
+	}
+}
+
+// middlewareStack holds the middleware registered on a bus.
+type middlewareStack struct {
+	mu    sync.RWMutex
+	stack []Middleware
+}
+
+func (s *middlewareStack) use(middleware ...Middleware) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stack = append(s.stack, middleware...)
+}
+
+// wrap applies the registered middleware to h.
+func (s *middlewareStack) wrap(h Handler) Handler {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.stack) == 0 {
+		return h
+	}
+	return Chain(s.stack...)(h)
+}
+
+// ---------------------------------------------------------------------------
+// Built-in middleware
+// ---------------------------------------------------------------------------
+
+// Recover converts a handler panic into an error, so one misbehaving handler
+// cannot take down the publishing request. AsyncEventBus and OutboxEventBus
+// already recover on their own; MemoryEventBus needs this middleware.
+func Recover() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, event Event) error {
+			return callHandler(ctx, next, event)
+		}
+	}
+}
+
+// ErrHandlerTimeout is returned by the Timeout middleware when a handler
+// does not finish in time.
+var ErrHandlerTimeout = errors.New("eventbus: handler timed out")
+
+// Timeout bounds each handler call to d. The handler receives a context with
+// that deadline; if it has not returned when the deadline passes, the call
+// fails with an error wrapping ErrHandlerTimeout.
+//
+// Go cannot stop a goroutine, so a handler that ignores ctx keeps running in
+// the background after the timeout error, and its side effects still land.
+// A bus that retries the failed delivery may then run the handler again
+// while the first call is in flight. Handlers should check ctx before each
+// side effect; JournalPoster does so before creating an entry.
+func Timeout(d time.Duration) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, event Event) error {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+
+			done := make(chan error, 1)
+			go func() { done <- callHandler(ctx, next, event) }()
+
+			select {
+			case err := <-done:
+				return err
+			case <-ctx.Done():
+				return fmt.Errorf("%w: %s source=%s after %s: %w", ErrHandlerTimeout, event.Type, event.SourceID, d, ctx.Err())
+			}
+		}
+	}
+}
+
+// Logging logs every handler call to logger (slog.Default() when nil):
+// failures at Error level, successes at Debug level, each with the event
+// attributes and the call duration.
+func Logging(logger *slog.Logger) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, event Event) error {
+			l := logger
+			if l == nil {
+				l = slog.Default()
+			}
+			start := time.Now()
+			err := next(ctx, event)
+
+			attrs := []slog.Attr{
+				slog.String("event_type", event.Type),
+				slog.String("source_id", event.SourceID),
+				slog.String("event_id", event.ID),
+				slog.Duration("duration", time.Since(start)),
+			}
+			if event.WorkspaceID != "" {
+				attrs = append(attrs, slog.String("workspace_id", event.WorkspaceID))
+			}
+			if err != nil {
+				l.LogAttrs(ctx, slog.LevelError, "eventbus handler failed", append(attrs, slog.Any("error", err))...)
+			} else {
+				l.LogAttrs(ctx, slog.LevelDebug, "eventbus handler done", attrs...)
+			}
+			return err
+		}
+	}
+}
+
+// tracerName is the instrumentation scope used by Tracing.
+const tracerName = "github.com/erniealice/fycha-golang/eventbus"
+
+// Tracing starts an OpenTelemetry span around each handler call, named
+// "eventbus.handle <event type>" and parented to the span in ctx, so a
+// handler's work shows up under the request that published the event. That
+// holds for MemoryEventBus and AsyncEventBus; OutboxEventBus delivers from
+// its own dispatcher, so its handler spans start new traces. A nil provider
+// uses the global one. Handler errors are recorded on the span.
+func Tracing(provider trace.TracerProvider) Middleware {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	tracer := provider.Tracer(tracerName)
+	return func(next Handler) Handler {
+		return func(ctx context.Context, event Event) error {
+			ctx, span := tracer.Start(ctx, "eventbus.handle "+event.Type,
+				trace.WithSpanKind(trace.SpanKindConsumer),
+				trace.WithAttributes(eventAttributes(event)...))
+			defer span.End()
+
+			err := next(ctx, event)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			return err
+		}
+	}
+}
+
+// eventAttributes describes event for spans and metrics.
+func eventAttributes(event Event) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		attribute.String("eventbus.event_type", event.Type),
+		attribute.String("eventbus.source_id", event.SourceID),
+	}
+	if event.ID != "" {
+		attrs = append(attrs, attribute.String("eventbus.event_id", event.ID))
+	}
+	if event.WorkspaceID != "" {
+		attrs = append(attrs, attribute.String("eventbus.workspace_id", event.WorkspaceID))
+	}
+	return attrs
+}
+
+// HandlerMetrics receives one observation per handler call. AsyncMetrics
+// implementations satisfy it.
+type HandlerMetrics interface {
+	HandlerLatency(eventType string, d time.Duration, err error)
+}
+
+// Metrics reports the duration and outcome of each handler call to m.
+func Metrics(m HandlerMetrics) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, event Event) error {
+			start := time.Now()
+			err := next(ctx, event)
+			m.HandlerLatency(event.Type, time.Since(start), err)
+			return err
+		}
+	}
+}
+
+// OTelHandlerMetrics is a HandlerMetrics that records to OpenTelemetry
+// instruments: the "eventbus.handler.duration" histogram (seconds) and the
+// "eventbus.handler.calls" counter, both with event_type and outcome
+// ("ok" or "error") attributes.
+type OTelHandlerMetrics struct {
+	duration metric.Float64Histogram
+	calls    metric.Int64Counter
+}
+
+// NewOTelHandlerMetrics creates the instruments on meter. A nil meter uses
+// the global meter provider.
+func NewOTelHandlerMetrics(meter metric.Meter) (*OTelHandlerMetrics, error) {
+	if meter == nil {
+		meter = otel.GetMeterProvider().Meter(tracerName)
+	}
+	duration, err := meter.Float64Histogram("eventbus.handler.duration",
+		metric.WithDescription("Duration of event handler calls"), metric.WithUnit("s"))
+	if err != nil {
+		return nil, fmt.Errorf("eventbus: creating handler duration histogram: %w", err)
+	}
+	calls, err := meter.Int64Counter("eventbus.handler.calls",
+		metric.WithDescription("Number of event handler calls"))
+	if err != nil {
+		return nil, fmt.Errorf("eventbus: creating handler call counter: %w", err)
+	}
+	return &OTelHandlerMetrics{duration: duration, calls: calls}, nil
+}
+
+// HandlerLatency records one handler call.
+func (m *OTelHandlerMetrics) HandlerLatency(eventType string, d time.Duration, err error) {
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	attrs := metric.WithAttributes(
+		attribute.String("eventbus.event_type", eventType),
+		attribute.String("eventbus.outcome", outcome),
+	)
+	ctx := context.Background()
+	m.duration.Record(ctx, d.Seconds(), attrs)
+	m.calls.Add(ctx, 1, attrs)
+}
@@ -0,0 +1,328 @@
+package eventbus
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestChain(t *testing.T) {
+	t.Parallel()
+
+	var calls []string
+	tag := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx context.Context, e Event) error {
+				calls = append(calls, name+">")
+				err := next(ctx, e)
+				calls = append(calls, "<"+name)
+				return err
+			}
+		}
+	}
+	h := Chain(tag("a"), tag("b"))(func(ctx context.Context, e Event) error {
+		calls = append(calls, "h")
+		return nil
+	})
+	_ = h(context.Background(), Event{})
+
+	if got := strings.Join(calls, " "); got != "a> b> h <b <a" {
+		t.Errorf("calls = %q, want a outermost", got)
+	}
+}
+
+func TestMemoryEventBus_Use(t *testing.T) {
+	t.Parallel()
+
+	t.Run("recover turns a panic into an error", func(t *testing.T) {
+		t.Parallel()
+
+		bus := NewMemoryEventBus()
+		bus.Use(Recover())
+		ran := false
+		bus.Subscribe("payroll.posted", func(ctx context.Context, e Event) error { panic("boom") })
+		bus.Subscribe("payroll.posted", func(ctx context.Context, e Event) error { ran = true; return nil })
+
+		err := bus.Publish(context.Background(), Event{Type: "payroll.posted"})
+		if err == nil || !strings.Contains(err.Error(), "panicked: boom") {
+			t.Errorf("error = %v, want panic error", err)
+		}
+		if !ran {
+			t.Error("second handler skipped after a panic")
+		}
+	})
+
+	t.Run("middleware added after subscribe still applies", func(t *testing.T) {
+		t.Parallel()
+
+		bus := NewMemoryEventBus()
+		bus.Subscribe("x", func(ctx context.Context, e Event) error { return nil })
+		var seen int
+		bus.Use(func(next Handler) Handler {
+			return func(ctx context.Context, e Event) error { seen++; return next(ctx, e) }
+		})
+		_ = bus.Publish(context.Background(), Event{Type: "x"})
+		if seen != 1 {
+			t.Errorf("middleware calls = %d, want 1", seen)
+		}
+	})
+}
+
+func TestTimeout(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		handler Handler
+		wantErr bool
+	}{
+		{"fast handler", func(ctx context.Context, e Event) error { return nil }, false},
+		{"handler honouring ctx", func(ctx context.Context, e Event) error { <-ctx.Done(); return ctx.Err() }, true},
+		{"handler ignoring ctx", func(ctx context.Context, e Event) error { time.Sleep(200 * time.Millisecond); return nil }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			start := time.Now()
+			err := Timeout(20*time.Millisecond)(tt.handler)(context.Background(), Event{Type: "slow"})
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if tt.wantErr && (!errors.Is(err, ErrHandlerTimeout) || !errors.Is(err, context.DeadlineExceeded)) {
+				t.Errorf("error = %v, want ErrHandlerTimeout wrapping DeadlineExceeded", err)
+			}
+			if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+				t.Errorf("returned after %s, want about 20ms", elapsed)
+			}
+		})
+	}
+}
+
+func TestLogging(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	h := Logging(logger)(func(ctx context.Context, e Event) error {
+		if e.SourceID == "bad" {
+			return errors.New("account not found")
+		}
+		return nil
+	})
+
+	_ = h(context.Background(), Event{Type: "revenue.completed", SourceID: "ok", ID: "e1"})
+	_ = h(context.Background(), Event{Type: "revenue.completed", SourceID: "bad"})
+
+	out := buf.String()
+	for _, want := range []string{
+		`level=DEBUG msg="eventbus handler done" event_type=revenue.completed source_id=ok event_id=e1`,
+		`level=ERROR msg="eventbus handler failed" event_type=revenue.completed source_id=bad`,
+		`error="account not found"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+// recordingTracer is a TracerProvider that keeps every span it starts, so
+// the tests need only the OpenTelemetry API, not the SDK.
+type recordingTracer struct {
+	embedded.TracerProvider
+
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+func (r *recordingTracer) Tracer(string, ...trace.TracerOption) trace.Tracer {
+	return spanRecorder{r: r}
+}
+
+type spanRecorder struct {
+	embedded.Tracer
+	r *recordingTracer
+}
+
+func (t spanRecorder) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	cfg := trace.NewSpanStartConfig(opts...)
+	t.r.mu.Lock()
+	defer t.r.mu.Unlock()
+	span := &recordedSpan{
+		name:   name,
+		parent: trace.SpanContextFromContext(ctx),
+		kind:   cfg.SpanKind(),
+		attrs:  cfg.Attributes(),
+		sc: trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID: trace.TraceID{1},
+			SpanID:  trace.SpanID{byte(len(t.r.spans) + 1)},
+		}),
+	}
+	t.r.spans = append(t.r.spans, span)
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+type recordedSpan struct {
+	tracenoop.Span
+
+	name   string
+	sc     trace.SpanContext
+	parent trace.SpanContext
+	kind   trace.SpanKind
+	attrs  []attribute.KeyValue
+	code   codes.Code
+	desc   string
+	ended  bool
+}
+
+func (s *recordedSpan) SpanContext() trace.SpanContext { return s.sc }
+func (s *recordedSpan) IsRecording() bool              { return !s.ended }
+func (s *recordedSpan) End(...trace.SpanEndOption)     { s.ended = true }
+
+func (s *recordedSpan) SetStatus(code codes.Code, description string) {
+	s.code, s.desc = code, description
+}
+
+func TestTracing(t *testing.T) {
+	t.Parallel()
+
+	provider := &recordingTracer{}
+	bus := NewMemoryEventBus()
+	bus.Use(Tracing(provider))
+	bus.Subscribe("loan.payment", func(ctx context.Context, e Event) error { return errors.New("period closed") })
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "POST /loans/pay")
+	_ = bus.Publish(ctx, Event{Type: "loan.payment", SourceID: "loan-1", ID: "e1"})
+	parent.End()
+
+	if len(provider.spans) != 2 {
+		t.Fatalf("spans = %d, want 2", len(provider.spans))
+	}
+	handled := provider.spans[1]
+	if handled.name != "eventbus.handle loan.payment" || handled.kind != trace.SpanKindConsumer {
+		t.Errorf("span = %q (%s), want eventbus.handle loan.payment (consumer)", handled.name, handled.kind)
+	}
+	if !handled.ended {
+		t.Error("handler span not ended")
+	}
+	if handled.parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Error("handler span is not a child of the publishing span")
+	}
+	if handled.code != codes.Error || handled.desc != "period closed" {
+		t.Errorf("status = %v %q, want error", handled.code, handled.desc)
+	}
+	var source string
+	for _, a := range handled.attrs {
+		if a.Key == "eventbus.source_id" {
+			source = a.Value.AsString()
+		}
+	}
+	if source != "loan-1" {
+		t.Errorf("eventbus.source_id = %q, want loan-1", source)
+	}
+}
+
+// countingMeter is a Meter whose Int64Counters add up into counts; every
+// other instrument is a no-op.
+type countingMeter struct {
+	metricnoop.Meter
+
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+func (m *countingMeter) Int64Counter(name string, _ ...metric.Int64CounterOption) (metric.Int64Counter, error) {
+	return &countingCounter{meter: m, name: name}, nil
+}
+
+type countingCounter struct {
+	metricnoop.Int64Counter
+
+	meter *countingMeter
+	name  string
+}
+
+func (c *countingCounter) Add(_ context.Context, incr int64, _ ...metric.AddOption) {
+	c.meter.mu.Lock()
+	defer c.meter.mu.Unlock()
+	if c.meter.counts == nil {
+		c.meter.counts = make(map[string]int64)
+	}
+	c.meter.counts[c.name] += incr
+}
+
+type recordingMetrics struct {
+	mu    sync.Mutex
+	calls map[string]int
+	fails int
+}
+
+func (m *recordingMetrics) HandlerLatency(eventType string, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.calls == nil {
+		m.calls = make(map[string]int)
+	}
+	m.calls[eventType]++
+	if err != nil {
+		m.fails++
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	t.Run("custom sink on AsyncEventBus", func(t *testing.T) {
+		t.Parallel()
+
+		m := &recordingMetrics{}
+		bus := NewAsyncEventBus(AsyncConfig{Workers: 2})
+		bus.Use(Metrics(m))
+		bus.Subscribe("x", func(ctx context.Context, e Event) error {
+			if e.SourceID == "bad" {
+				return errors.New("fail")
+			}
+			return nil
+		})
+		for _, id := range []string{"a", "b", "bad"} {
+			_ = bus.Publish(context.Background(), Event{Type: "x", SourceID: id})
+		}
+		_ = bus.Shutdown(context.Background())
+
+		if m.calls["x"] != 3 || m.fails != 1 {
+			t.Errorf("calls = %v, fails = %d", m.calls, m.fails)
+		}
+	})
+
+	t.Run("OpenTelemetry instruments on StoringEventBus", func(t *testing.T) {
+		t.Parallel()
+
+		meter := &countingMeter{}
+		otelMetrics, err := NewOTelHandlerMetrics(meter)
+		if err != nil {
+			t.Fatalf("NewOTelHandlerMetrics: %v", err)
+		}
+		bus := NewStoringEventBus(NewMemoryEventBus(), NewMemoryEventStore())
+		bus.Use(Metrics(otelMetrics))
+		bus.Subscribe("x", func(ctx context.Context, e Event) error { return nil })
+		_ = bus.Publish(context.Background(), Event{Type: "x"})
+		_ = bus.Publish(context.Background(), Event{Type: "x"})
+
+		if calls := meter.counts["eventbus.handler.calls"]; calls != 2 {
+			t.Errorf("eventbus.handler.calls = %d, want 2", calls)
+		}
+	})
+}
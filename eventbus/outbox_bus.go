@@ -0,0 +1,502 @@
+package eventbus
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OutboxEventBus is a durable EventBus built on the transactional outbox
+// pattern. Publish writes the event to an outbox table — inside the caller's
+// database transaction when one is attached to the context — so the event is
+// recorded if and only if the business change commits. A dispatcher (Run or
+// DispatchPending) later delivers outbox rows to the subscribed handlers.
+//
+// Delivery is at-least-once: a row is marked delivered only after every
+// handler for its type succeeds, so handlers must tolerate redelivery. Failed
+// rows are retried with exponential backoff and moved to the dead-letter
+// table after OutboxConfig.MaxAttempts failures.
+//
+// Only database/sql is used. The DDL in CreateTables stores timestamps as
+// BIGINT Unix nanoseconds and runs on SQLite and PostgreSQL; set
+// OutboxConfig.Placeholder to DollarPlaceholder for PostgreSQL. MySQL has no
+// CREATE INDEX IF NOT EXISTS, so create the tables there with a migration.
+type OutboxEventBus struct {
+	db  *sql.DB
+	cfg OutboxConfig
+	now func() time.Time
+
+	handlers   map[string][]Handler
+	patterns   patternSubscriptions
+	mu         sync.RWMutex
+	middleware middlewareStack
+}
+
+// OutboxConfig configures an OutboxEventBus. Zero values fall back to the
+// defaults noted on each field.
+type OutboxConfig struct {
+	// Table is the outbox table name. Default "eventbus_outbox".
+	Table string
+
+	// DeadLetterTable receives events that exhausted their retries.
+	// Default "eventbus_dead_letter".
+	DeadLetterTable string
+
+	// Placeholder renders the n-th (1-based) bind parameter.
+	// Default QuestionPlaceholder.
+	Placeholder func(n int) string
+
+	// PollInterval is how often Run checks for pending rows. Default 1s.
+	PollInterval time.Duration
+
+	// BatchSize is the maximum number of rows claimed per dispatch. Default 50.
+	BatchSize int
+
+	// MaxAttempts is the number of failed deliveries after which an event is
+	// dead-lettered. Default 10.
+	MaxAttempts int
+
+	// BaseBackoff is the delay before the first retry; each later retry
+	// doubles it. Default 1s.
+	BaseBackoff time.Duration
+
+	// MaxBackoff caps the retry delay. Default 5m.
+	MaxBackoff time.Duration
+
+	// Lease is how long a dispatcher holds a claimed row before another
+	// dispatcher may take it over. It must exceed the slowest handler.
+	// Default 1m.
+	Lease time.Duration
+}
+
+// QuestionPlaceholder renders "?" bind parameters (SQLite).
+func QuestionPlaceholder(int) string { return "?" }
+
+// DollarPlaceholder renders "$n" bind parameters (PostgreSQL).
+func DollarPlaceholder(n int) string { return "$" + strconv.Itoa(n) }
+
+func (c OutboxConfig) withDefaults() OutboxConfig {
+	if c.Table == "" {
+		c.Table = "eventbus_outbox"
+	}
+	if c.DeadLetterTable == "" {
+		c.DeadLetterTable = "eventbus_dead_letter"
+	}
+	if c.Placeholder == nil {
+		c.Placeholder = QuestionPlaceholder
+	}
+	if c.PollInterval <= 0 {
+		c.PollInterval = time.Second
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 50
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = 10
+	}
+	if c.BaseBackoff <= 0 {
+		c.BaseBackoff = time.Second
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = 5 * time.Minute
+	}
+	if c.Lease <= 0 {
+		c.Lease = time.Minute
+	}
+	return c
+}
+
+// NewOutboxEventBus creates an OutboxEventBus backed by db.
+// Call CreateTables (or apply equivalent migrations) before publishing.
+func NewOutboxEventBus(db *sql.DB, cfg OutboxConfig) *OutboxEventBus {
+	return &OutboxEventBus{
+		db:       db,
+		cfg:      cfg.withDefaults(),
+		now:      time.Now,
+		handlers: make(map[string][]Handler),
+	}
+}
+
+// CreateTables creates the outbox and dead-letter tables if they do not exist.
+// It runs on SQLite and PostgreSQL; elsewhere apply equivalent migrations.
+func (b *OutboxEventBus) CreateTables(ctx context.Context) error {
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS ` + b.cfg.Table + ` (
+			id              VARCHAR(64) PRIMARY KEY,
+			event_type      VARCHAR(255) NOT NULL,
+			source_id       VARCHAR(255) NOT NULL,
+			workspace_id    VARCHAR(255) NOT NULL,
+			payload         TEXT NOT NULL,
+			occurred_at     BIGINT NOT NULL,
+			created_at      BIGINT NOT NULL,
+			attempts        INTEGER NOT NULL DEFAULT 0,
+			next_attempt_at BIGINT NOT NULL,
+			locked_until    BIGINT NOT NULL DEFAULT 0,
+			last_error      TEXT,
+			delivered_at    BIGINT
+		)`,
+		`CREATE INDEX IF NOT EXISTS ` + b.cfg.Table + `_pending_idx
+			ON ` + b.cfg.Table + ` (delivered_at, next_attempt_at)`,
+		`CREATE TABLE IF NOT EXISTS ` + b.cfg.DeadLetterTable + ` (
+			id           VARCHAR(64) PRIMARY KEY,
+			event_type   VARCHAR(255) NOT NULL,
+			source_id    VARCHAR(255) NOT NULL,
+			workspace_id VARCHAR(255) NOT NULL,
+			payload      TEXT NOT NULL,
+			occurred_at  BIGINT NOT NULL,
+			created_at   BIGINT NOT NULL,
+			attempts     INTEGER NOT NULL,
+			last_error   TEXT,
+			failed_at    BIGINT NOT NULL
+		)`,
+	}
+	for _, stmt := range stmts {
+		if _, err := b.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("eventbus: creating outbox tables: %w", err)
+		}
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// Publishing
+// ---------------------------------------------------------------------------
+
+type txContextKey struct{}
+
+// ContextWithTx attaches a database transaction to ctx. OutboxEventBus.Publish
+// writes events through the attached transaction, so they commit or roll back
+// together with the caller's business changes.
+func ContextWithTx(ctx context.Context, tx *sql.Tx) context.Context {
+	return context.WithValue(ctx, txContextKey{}, tx)
+}
+
+// TxFromContext returns the transaction attached with ContextWithTx, if any.
+func TxFromContext(ctx context.Context) (*sql.Tx, bool) {
+	tx, ok := ctx.Value(txContextKey{}).(*sql.Tx)
+	return tx, ok && tx != nil
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Use adds middleware around every handler. Middleware runs in the
+// dispatcher, once per delivery attempt.
+func (b *OutboxEventBus) Use(middleware ...Middleware) {
+	b.middleware.use(middleware...)
+}
+
+// Subscribe registers a handler for an event type or a pattern such as
+// "revenue.*" (see pattern.go).
+// Handlers run when the dispatcher delivers the event, not during Publish.
+func (b *OutboxEventBus) Subscribe(eventType string, handler Handler) {
+	if IsEventPattern(eventType) {
+		b.patterns.add(eventType, handler)
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[eventType] = append(b.handlers[eventType], handler)
+}
+
+// Publish records the event in the outbox. If ctx carries a transaction (see
+// ContextWithTx) the row is written inside it; otherwise it is written
+// directly to the database. Handlers are not invoked here.
+// Empty Event.ID and zero Event.Timestamp are filled in before writing.
+func (b *OutboxEventBus) Publish(ctx context.Context, event Event) error {
+	if tx, ok := TxFromContext(ctx); ok {
+		return b.insert(ctx, tx, event)
+	}
+	return b.insert(ctx, b.db, event)
+}
+
+// PublishTx records the event in the outbox inside tx.
+func (b *OutboxEventBus) PublishTx(ctx context.Context, tx *sql.Tx, event Event) error {
+	return b.insert(ctx, tx, event)
+}
+
+func (b *OutboxEventBus) insert(ctx context.Context, exec execer, event Event) error {
+	if event.Type == "" {
+		return errors.New("eventbus: event type is required")
+	}
+	if event.ID == "" {
+		event.ID = NewEventID()
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = b.now()
+	}
+	payload, err := json.Marshal(event.Payload)
+	if err != nil {
+		return fmt.Errorf("eventbus: encoding %s payload: %w", event.Type, err)
+	}
+
+	now := b.now().UnixNano()
+	query := `INSERT INTO ` + b.cfg.Table + ` (id, event_type, source_id, workspace_id, payload,
+		occurred_at, created_at, attempts, next_attempt_at, locked_until)
+		VALUES (` + b.placeholders(1, 7) + `, 0, ` + b.cfg.Placeholder(8) + `, 0)`
+	_, err = exec.ExecContext(ctx, query,
+		event.ID, event.Type, event.SourceID, event.WorkspaceID, string(payload),
+		event.Timestamp.UnixNano(), now, now)
+	if err != nil {
+		return fmt.Errorf("eventbus: writing %s to outbox: %w", event.Type, err)
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// Dispatching
+// ---------------------------------------------------------------------------
+
+// Run dispatches pending events every PollInterval until ctx is cancelled.
+// Dispatch errors are logged and retried on the next tick.
+func (b *OutboxEventBus) Run(ctx context.Context) error {
+	ticker := time.NewTicker(b.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := b.DispatchPending(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("[eventbus] outbox dispatch failed: %v", err)
+			}
+			if err != nil || n < b.cfg.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchPending claims up to BatchSize due events, delivers each to its
+// handlers and records the outcome. It returns the number of events claimed.
+// Several dispatchers may run concurrently; each row is claimed by at most
+// one of them per lease.
+func (b *OutboxEventBus) DispatchPending(ctx context.Context) (int, error) {
+	rows, err := b.claim(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, row := range rows {
+		deliverErr := b.deliver(ctx, row.event)
+		if deliverErr == nil {
+			err = b.markDelivered(ctx, row.event.ID)
+		} else {
+			err = b.markFailed(ctx, row, deliverErr)
+		}
+		if err != nil {
+			return len(rows), err
+		}
+	}
+	return len(rows), nil
+}
+
+type outboxRow struct {
+	event     Event
+	attempts  int
+	createdAt int64
+}
+
+// claim selects due rows and leases each with a conditional UPDATE, so a row
+// another dispatcher leased in the meantime is skipped.
+func (b *OutboxEventBus) claim(ctx context.Context) ([]outboxRow, error) {
+	now := b.now().UnixNano()
+	query := `SELECT id, event_type, source_id, workspace_id, payload, occurred_at, created_at, attempts
+		FROM ` + b.cfg.Table + `
+		WHERE delivered_at IS NULL AND next_attempt_at <= ` + b.cfg.Placeholder(1) + ` AND locked_until <= ` + b.cfg.Placeholder(2) + `
+		ORDER BY created_at, id
+		LIMIT ` + strconv.Itoa(b.cfg.BatchSize)
+	rs, err := b.db.QueryContext(ctx, query, now, now)
+	if err != nil {
+		return nil, fmt.Errorf("eventbus: selecting pending outbox rows: %w", err)
+	}
+
+	var candidates []outboxRow
+	for rs.Next() {
+		var (
+			row        outboxRow
+			payload    string
+			occurredAt int64
+		)
+		if err := rs.Scan(&row.event.ID, &row.event.Type, &row.event.SourceID, &row.event.WorkspaceID,
+			&payload, &occurredAt, &row.createdAt, &row.attempts); err != nil {
+			rs.Close()
+			return nil, fmt.Errorf("eventbus: scanning outbox row: %w", err)
+		}
+		if err := json.Unmarshal([]byte(payload), &row.event.Payload); err != nil {
+			rs.Close()
+			return nil, fmt.Errorf("eventbus: decoding outbox payload %s: %w", row.event.ID, err)
+		}
+		row.event.Timestamp = time.Unix(0, occurredAt).UTC()
+		candidates = append(candidates, row)
+	}
+	rs.Close()
+	if err := rs.Err(); err != nil {
+		return nil, fmt.Errorf("eventbus: reading outbox rows: %w", err)
+	}
+
+	lease := `UPDATE ` + b.cfg.Table + ` SET locked_until = ` + b.cfg.Placeholder(1) + `
+		WHERE id = ` + b.cfg.Placeholder(2) + ` AND delivered_at IS NULL AND locked_until <= ` + b.cfg.Placeholder(3)
+	until := b.now().Add(b.cfg.Lease).UnixNano()
+
+	claimed := candidates[:0]
+	for _, row := range candidates {
+		res, err := b.db.ExecContext(ctx, lease, until, row.event.ID, now)
+		if err != nil {
+			return nil, fmt.Errorf("eventbus: leasing outbox row %s: %w", row.event.ID, err)
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 1 {
+			claimed = append(claimed, row)
+		}
+	}
+	return claimed, nil
+}
+
+// deliver runs every handler for the event. Like MemoryEventBus, all handlers
+// are invoked even if one fails; a panicking handler counts as a failure so
+// the dispatcher keeps running.
+func (b *OutboxEventBus) deliver(ctx context.Context, event Event) error {
+	b.mu.RLock()
+	handlers := b.patterns.appendMatches(b.handlers[event.Type], event.Type)
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, h := range handlers {
+		if err := callHandler(ctx, b.middleware.wrap(h), event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return fmt.Errorf("eventbus: %d handler(s) failed for %q: first error: %w", len(errs), event.Type, errs[0])
+}
+
+func (b *OutboxEventBus) markDelivered(ctx context.Context, id string) error {
+	query := `UPDATE ` + b.cfg.Table + ` SET delivered_at = ` + b.cfg.Placeholder(1) + `, locked_until = 0, last_error = NULL
+		WHERE id = ` + b.cfg.Placeholder(2)
+	if _, err := b.db.ExecContext(ctx, query, b.now().UnixNano(), id); err != nil {
+		return fmt.Errorf("eventbus: marking outbox row %s delivered: %w", id, err)
+	}
+	return nil
+}
+
+// markFailed schedules the next attempt, or dead-letters the event once
+// MaxAttempts is reached.
+func (b *OutboxEventBus) markFailed(ctx context.Context, row outboxRow, deliverErr error) error {
+	attempts := row.attempts + 1
+	if attempts >= b.cfg.MaxAttempts {
+		log.Printf("[eventbus] %s id=%s dead-lettered after %d attempts: %v",
+			row.event.Type, row.event.ID, attempts, deliverErr)
+		return b.deadLetter(ctx, row, attempts, deliverErr)
+	}
+
+	next := b.now().Add(b.backoff(attempts)).UnixNano()
+	query := `UPDATE ` + b.cfg.Table + ` SET attempts = ` + b.cfg.Placeholder(1) + `, next_attempt_at = ` + b.cfg.Placeholder(2) + `,
+		locked_until = 0, last_error = ` + b.cfg.Placeholder(3) + `
+		WHERE id = ` + b.cfg.Placeholder(4)
+	if _, err := b.db.ExecContext(ctx, query, attempts, next, deliverErr.Error(), row.event.ID); err != nil {
+		return fmt.Errorf("eventbus: recording outbox failure for %s: %w", row.event.ID, err)
+	}
+	return nil
+}
+
+// backoff returns BaseBackoff * 2^(attempts-1), capped at MaxBackoff.
+func (b *OutboxEventBus) backoff(attempts int) time.Duration {
+	d := b.cfg.BaseBackoff
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= b.cfg.MaxBackoff {
+			return b.cfg.MaxBackoff
+		}
+	}
+	if d > b.cfg.MaxBackoff {
+		return b.cfg.MaxBackoff
+	}
+	return d
+}
+
+func (b *OutboxEventBus) deadLetter(ctx context.Context, row outboxRow, attempts int, deliverErr error) error {
+	payload, err := json.Marshal(row.event.Payload)
+	if err != nil {
+		return fmt.Errorf("eventbus: encoding %s payload: %w", row.event.Type, err)
+	}
+
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("eventbus: dead-lettering %s: %w", row.event.ID, err)
+	}
+	defer tx.Rollback()
+
+	insert := `INSERT INTO ` + b.cfg.DeadLetterTable + ` (id, event_type, source_id, workspace_id, payload,
+		occurred_at, created_at, attempts, last_error, failed_at)
+		VALUES (` + b.placeholders(1, 10) + `)`
+	if _, err := tx.ExecContext(ctx, insert,
+		row.event.ID, row.event.Type, row.event.SourceID, row.event.WorkspaceID, string(payload),
+		row.event.Timestamp.UnixNano(), row.createdAt, attempts, deliverErr.Error(), b.now().UnixNano()); err != nil {
+		return fmt.Errorf("eventbus: dead-lettering %s: %w", row.event.ID, err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM `+b.cfg.Table+` WHERE id = `+b.cfg.Placeholder(1), row.event.ID); err != nil {
+		return fmt.Errorf("eventbus: dead-lettering %s: %w", row.event.ID, err)
+	}
+	return tx.Commit()
+}
+
+// RequeueDeadLetter moves a dead-lettered event back into the outbox with a
+// fresh attempt budget, typically after the handler bug has been fixed.
+func (b *OutboxEventBus) RequeueDeadLetter(ctx context.Context, id string) error {
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("eventbus: requeueing %s: %w", id, err)
+	}
+	defer tx.Rollback()
+
+	now := b.now().UnixNano()
+	insert := `INSERT INTO ` + b.cfg.Table + ` (id, event_type, source_id, workspace_id, payload,
+		occurred_at, created_at, attempts, next_attempt_at, locked_until)
+		SELECT id, event_type, source_id, workspace_id, payload, occurred_at, created_at, 0, ` + b.cfg.Placeholder(1) + `, 0
+		FROM ` + b.cfg.DeadLetterTable + ` WHERE id = ` + b.cfg.Placeholder(2)
+	res, err := tx.ExecContext(ctx, insert, now, id)
+	if err != nil {
+		return fmt.Errorf("eventbus: requeueing %s: %w", id, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("eventbus: dead-lettered event %s not found", id)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM `+b.cfg.DeadLetterTable+` WHERE id = `+b.cfg.Placeholder(1), id); err != nil {
+		return fmt.Errorf("eventbus: requeueing %s: %w", id, err)
+	}
+	return tx.Commit()
+}
+
+// placeholders renders bind parameters from..to (inclusive), comma-separated.
+func (b *OutboxEventBus) placeholders(from, to int) string {
+	return joinPlaceholders(b.cfg.Placeholder, from, to)
+}
+
+// joinPlaceholders renders bind parameters from..to with render, comma separated.
+func joinPlaceholders(render func(n int) string, from, to int) string {
+	parts := make([]string, 0, to-from+1)
+	for n := from; n <= to; n++ {
+		parts = append(parts, render(n))
+	}
+	return strings.Join(parts, ", ")
+}
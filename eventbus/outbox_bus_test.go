@@ -0,0 +1,375 @@
+package eventbus
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// openTestSQLite opens an in-memory SQLite database private to the test.
+func openTestSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", "file:"+strings.ReplaceAll(t.Name(), "/", "_")+"?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatalf("opening sqlite: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// newTestOutbox opens a private in-memory SQLite database with the outbox
+// tables created. The clock is fixed and advanced manually through *now.
+func newTestOutbox(t *testing.T, cfg OutboxConfig) (*OutboxEventBus, *sql.DB, *time.Time) {
+	t.Helper()
+
+	db := openTestSQLite(t)
+	now := time.Date(2026, 4, 1, 8, 0, 0, 0, time.UTC)
+	bus := NewOutboxEventBus(db, cfg)
+	bus.now = func() time.Time { return now }
+
+	if err := bus.CreateTables(context.Background()); err != nil {
+		t.Fatalf("CreateTables: %v", err)
+	}
+	return bus, db, &now
+}
+
+func countRows(t *testing.T, db *sql.DB, query string) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(query).Scan(&n); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return n
+}
+
+func TestOutboxEventBus_Publish(t *testing.T) {
+	t.Parallel()
+
+	t.Run("event is written in the caller's transaction", func(t *testing.T) {
+		t.Parallel()
+
+		bus, db, _ := newTestOutbox(t, OutboxConfig{})
+		ctx := context.Background()
+
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			t.Fatalf("BeginTx: %v", err)
+		}
+		err = bus.Publish(ContextWithTx(ctx, tx), Event{Type: "revenue.completed", SourceID: "rev-1"})
+		if err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+		if err := tx.Rollback(); err != nil {
+			t.Fatalf("Rollback: %v", err)
+		}
+		if n := countRows(t, db, "SELECT COUNT(*) FROM eventbus_outbox"); n != 0 {
+			t.Errorf("outbox rows after rollback = %d, want 0", n)
+		}
+
+		tx, err = db.BeginTx(ctx, nil)
+		if err != nil {
+			t.Fatalf("BeginTx: %v", err)
+		}
+		if err := bus.PublishTx(ctx, tx, Event{Type: "revenue.completed", SourceID: "rev-2"}); err != nil {
+			t.Fatalf("PublishTx: %v", err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("Commit: %v", err)
+		}
+		if n := countRows(t, db, "SELECT COUNT(*) FROM eventbus_outbox"); n != 1 {
+			t.Errorf("outbox rows after commit = %d, want 1", n)
+		}
+	})
+
+	t.Run("handlers do not run until dispatch", func(t *testing.T) {
+		t.Parallel()
+
+		bus, _, _ := newTestOutbox(t, OutboxConfig{})
+		var calls int32
+		bus.Subscribe("loan.received", func(ctx context.Context, e Event) error {
+			atomic.AddInt32(&calls, 1)
+			return nil
+		})
+
+		if err := bus.Publish(context.Background(), Event{Type: "loan.received"}); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+		if atomic.LoadInt32(&calls) != 0 {
+			t.Error("handler ran during Publish")
+		}
+	})
+
+	t.Run("empty event type is rejected", func(t *testing.T) {
+		t.Parallel()
+
+		bus, _, _ := newTestOutbox(t, OutboxConfig{})
+		if err := bus.Publish(context.Background(), Event{SourceID: "x"}); err == nil {
+			t.Error("expected error for empty event type")
+		}
+	})
+}
+
+func TestOutboxEventBus_DispatchPending(t *testing.T) {
+	t.Parallel()
+
+	t.Run("delivers event with payload and metadata", func(t *testing.T) {
+		t.Parallel()
+
+		bus, db, _ := newTestOutbox(t, OutboxConfig{})
+		var received Event
+		bus.Subscribe("collection.received", func(ctx context.Context, e Event) error {
+			received = e
+			return nil
+		})
+
+		occurred := time.Date(2026, 3, 31, 17, 30, 0, 0, time.UTC)
+		err := bus.Publish(context.Background(), Event{
+			ID:          "evt-1",
+			Type:        "collection.received",
+			SourceID:    "col-7",
+			WorkspaceID: "ws-1",
+			Timestamp:   occurred,
+			Payload:     map[string]any{"amount": 150.25, "account_cash": "cash"},
+		})
+		if err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+
+		n, err := bus.DispatchPending(context.Background())
+		if err != nil {
+			t.Fatalf("DispatchPending: %v", err)
+		}
+		if n != 1 {
+			t.Fatalf("dispatched = %d, want 1", n)
+		}
+
+		if received.ID != "evt-1" || received.SourceID != "col-7" || received.WorkspaceID != "ws-1" {
+			t.Errorf("received = %+v", received)
+		}
+		if !received.Timestamp.Equal(occurred) {
+			t.Errorf("Timestamp = %v, want %v", received.Timestamp, occurred)
+		}
+		if received.Payload["amount"] != 150.25 {
+			t.Errorf("Payload[amount] = %v, want 150.25", received.Payload["amount"])
+		}
+
+		if n := countRows(t, db, "SELECT COUNT(*) FROM eventbus_outbox WHERE delivered_at IS NOT NULL"); n != 1 {
+			t.Errorf("delivered rows = %d, want 1", n)
+		}
+		if n, _ := bus.DispatchPending(context.Background()); n != 0 {
+			t.Errorf("redispatched = %d, want 0", n)
+		}
+	})
+
+	t.Run("failed delivery is retried with backoff", func(t *testing.T) {
+		t.Parallel()
+
+		bus, db, now := newTestOutbox(t, OutboxConfig{BaseBackoff: time.Second, MaxBackoff: 3 * time.Second})
+		var calls int32
+		bus.Subscribe("asset.deprecated", func(ctx context.Context, e Event) error {
+			if atomic.AddInt32(&calls, 1) < 4 {
+				return errors.New("ledger locked")
+			}
+			return nil
+		})
+
+		if err := bus.Publish(context.Background(), Event{Type: "asset.deprecated"}); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+
+		// Delays after each failure: 1s, 2s, then capped at 3s.
+		for i, delay := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second} {
+			if n, err := bus.DispatchPending(context.Background()); err != nil || n != 1 {
+				t.Fatalf("attempt %d: dispatched = %d, err = %v", i+1, n, err)
+			}
+			*now = now.Add(delay - time.Millisecond)
+			if n, _ := bus.DispatchPending(context.Background()); n != 0 {
+				t.Fatalf("attempt %d: retried before backoff elapsed", i+1)
+			}
+			*now = now.Add(time.Millisecond)
+		}
+
+		if n, err := bus.DispatchPending(context.Background()); err != nil || n != 1 {
+			t.Fatalf("final attempt: dispatched = %d, err = %v", n, err)
+		}
+		if got := atomic.LoadInt32(&calls); got != 4 {
+			t.Errorf("handler calls = %d, want 4", got)
+		}
+		if n := countRows(t, db, "SELECT COUNT(*) FROM eventbus_outbox WHERE delivered_at IS NOT NULL AND attempts = 3"); n != 1 {
+			t.Errorf("delivered rows with 3 failed attempts = %d, want 1", n)
+		}
+	})
+
+	t.Run("event is dead-lettered after max attempts and can be requeued", func(t *testing.T) {
+		t.Parallel()
+
+		bus, db, now := newTestOutbox(t, OutboxConfig{MaxAttempts: 2, BaseBackoff: time.Second})
+		var fail atomic.Bool
+		fail.Store(true)
+		bus.Subscribe("payroll.posted", func(ctx context.Context, e Event) error {
+			if fail.Load() {
+				return errors.New("account not found")
+			}
+			return nil
+		})
+
+		if err := bus.Publish(context.Background(), Event{ID: "evt-dl", Type: "payroll.posted"}); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+		for i := 0; i < 2; i++ {
+			if _, err := bus.DispatchPending(context.Background()); err != nil {
+				t.Fatalf("DispatchPending: %v", err)
+			}
+			*now = now.Add(time.Minute)
+		}
+
+		if n := countRows(t, db, "SELECT COUNT(*) FROM eventbus_outbox"); n != 0 {
+			t.Errorf("outbox rows = %d, want 0", n)
+		}
+		var lastErr string
+		var attempts int
+		if err := db.QueryRow("SELECT last_error, attempts FROM eventbus_dead_letter WHERE id = 'evt-dl'").Scan(&lastErr, &attempts); err != nil {
+			t.Fatalf("dead letter row: %v", err)
+		}
+		if lastErr != "account not found" || attempts != 2 {
+			t.Errorf("dead letter = (%q, %d), want (account not found, 2)", lastErr, attempts)
+		}
+
+		fail.Store(false)
+		if err := bus.RequeueDeadLetter(context.Background(), "evt-dl"); err != nil {
+			t.Fatalf("RequeueDeadLetter: %v", err)
+		}
+		if n, err := bus.DispatchPending(context.Background()); err != nil || n != 1 {
+			t.Fatalf("dispatched = %d, err = %v", n, err)
+		}
+		if n := countRows(t, db, "SELECT COUNT(*) FROM eventbus_dead_letter"); n != 0 {
+			t.Errorf("dead letter rows = %d, want 0", n)
+		}
+		if err := bus.RequeueDeadLetter(context.Background(), "evt-dl"); err == nil {
+			t.Error("expected error requeueing a missing dead letter")
+		}
+	})
+
+	t.Run("handler panic counts as a failed attempt", func(t *testing.T) {
+		t.Parallel()
+
+		bus, db, _ := newTestOutbox(t, OutboxConfig{})
+		bus.Subscribe("equity.withdrawal", func(ctx context.Context, e Event) error {
+			panic("boom")
+		})
+
+		if err := bus.Publish(context.Background(), Event{Type: "equity.withdrawal"}); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+		if _, err := bus.DispatchPending(context.Background()); err != nil {
+			t.Fatalf("DispatchPending: %v", err)
+		}
+		if n := countRows(t, db, "SELECT COUNT(*) FROM eventbus_outbox WHERE attempts = 1 AND last_error LIKE '%boom%'"); n != 1 {
+			t.Errorf("failed rows = %d, want 1", n)
+		}
+	})
+
+	t.Run("leased rows are not claimed twice", func(t *testing.T) {
+		t.Parallel()
+
+		bus, _, _ := newTestOutbox(t, OutboxConfig{})
+		if err := bus.Publish(context.Background(), Event{Type: "loan.received"}); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+
+		first, err := bus.claim(context.Background())
+		if err != nil || len(first) != 1 {
+			t.Fatalf("first claim = %d rows, err = %v", len(first), err)
+		}
+		second, err := bus.claim(context.Background())
+		if err != nil || len(second) != 0 {
+			t.Fatalf("second claim = %d rows, err = %v, want 0", len(second), err)
+		}
+	})
+}
+
+func TestOutboxEventBus_PatternSubscribe(t *testing.T) {
+	t.Parallel()
+
+	bus, _, _ := newTestOutbox(t, OutboxConfig{})
+	var got []string
+	bus.Subscribe("*.bounced", func(ctx context.Context, e Event) error {
+		got = append(got, e.SourceID)
+		return nil
+	})
+
+	ctx := context.Background()
+	for _, e := range []Event{
+		{Type: EventTypeCollectionBounced, SourceID: "col-1"},
+		{Type: EventTypeCollectionReceived, SourceID: "col-2"},
+	} {
+		if err := bus.Publish(ctx, e); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+	if n, err := bus.DispatchPending(ctx); err != nil || n != 2 {
+		t.Fatalf("DispatchPending = %d, %v; want 2", n, err)
+	}
+	if len(got) != 1 || got[0] != "col-1" {
+		t.Errorf("pattern handler received %v, want [col-1]", got)
+	}
+}
+
+func TestOutboxEventBus_Run(t *testing.T) {
+	t.Parallel()
+
+	bus, _, _ := newTestOutbox(t, OutboxConfig{PollInterval: 5 * time.Millisecond})
+	bus.now = time.Now
+
+	var (
+		mu  sync.Mutex
+		got []string
+	)
+	done := make(chan struct{})
+	bus.Subscribe("disbursement.paid", func(ctx context.Context, e Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, e.SourceID)
+		if len(got) == 3 {
+			close(done)
+		}
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() { stopped <- bus.Run(ctx) }()
+
+	for _, id := range []string{"d-1", "d-2", "d-3"} {
+		if err := bus.Publish(context.Background(), Event{Type: "disbursement.paid", SourceID: id}); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for dispatch")
+	}
+	cancel()
+	if err := <-stopped; err != nil {
+		t.Errorf("Run returned %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	sort.Strings(got)
+	if strings.Join(got, ",") != "d-1,d-2,d-3" {
+		t.Errorf("delivered = %v, want d-1,d-2,d-3", got)
+	}
+}
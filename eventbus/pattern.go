@@ -0,0 +1,87 @@
+package eventbus
+
+// Pattern subscriptions let one handler receive a family of event types,
+// e.g. an audit trail subscribing to "*" or a notifier to "revenue.*".
+//
+// A pattern is an event type with one or more "*" segments (segments are
+// separated by "."). A "*" segment matches exactly one segment, except as the
+// last segment, where it matches one or more: "revenue.*" matches
+// "revenue.completed" and "revenue.completed.v2", "*.cancelled" matches
+// "revenue.cancelled", and "*" alone matches every event type. A "*" inside
+// a segment ("rev*") has no special meaning.
+//
+// Every bus invokes the handlers subscribed to the exact event type first,
+// in registration order, then the matching pattern handlers, in registration
+// order.
+
+import (
+	"strings"
+	"sync"
+)
+
+// IsEventPattern reports whether s contains a "*" segment and so subscribes
+// to a pattern rather than a single event type.
+func IsEventPattern(s string) bool {
+	for _, seg := range strings.Split(s, ".") {
+		if seg == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchEventType reports whether eventType matches pattern. A pattern without
+// "*" segments matches only itself.
+func MatchEventType(pattern, eventType string) bool {
+	if pattern == "*" || pattern == eventType {
+		return true
+	}
+	pat := strings.Split(pattern, ".")
+	typ := strings.Split(eventType, ".")
+	for i, seg := range pat {
+		last := i == len(pat)-1
+		if i >= len(typ) {
+			return false
+		}
+		switch {
+		case seg == "*" && last:
+			return true
+		case seg == "*":
+		case seg != typ[i]:
+			return false
+		}
+	}
+	return len(pat) == len(typ)
+}
+
+// patternSubscription is one handler subscribed to a pattern.
+type patternSubscription struct {
+	pattern string
+	handler Handler
+}
+
+// patternSubscriptions holds the pattern handlers registered on a bus.
+type patternSubscriptions struct {
+	mu   sync.RWMutex
+	subs []patternSubscription
+}
+
+func (s *patternSubscriptions) add(pattern string, handler Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subs = append(s.subs, patternSubscription{pattern: pattern, handler: handler})
+}
+
+// appendMatches returns exact followed by the handlers whose pattern matches
+// eventType. exact is never modified.
+func (s *patternSubscriptions) appendMatches(exact []Handler, eventType string) []Handler {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	handlers := exact[:len(exact):len(exact)]
+	for _, sub := range s.subs {
+		if MatchEventType(sub.pattern, eventType) {
+			handlers = append(handlers, sub.handler)
+		}
+	}
+	return handlers
+}
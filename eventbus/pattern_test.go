@@ -0,0 +1,205 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMatchEventType(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pattern   string
+		eventType string
+		want      bool
+	}{
+		{"*", "revenue.completed", true},
+		{"*", "", true},
+		{"revenue.*", "revenue.completed", true},
+		{"revenue.*", "revenue.completed.v2", true},
+		{"revenue.*", "revenue", false},
+		{"revenue.*", "revenues.completed", false},
+		{"*.cancelled", "revenue.cancelled", true},
+		{"*.cancelled", "revenue.completed", false},
+		{"*.cancelled", "a.b.cancelled", false},
+		{"petty_cash.*.v1", "petty_cash.replenished.v1", true},
+		{"rev*", "revenue.completed", false},
+		{"rev*", "rev*", true},
+		{"revenue.completed", "revenue.completed", true},
+	}
+	for _, tt := range tests {
+		if got := MatchEventType(tt.pattern, tt.eventType); got != tt.want {
+			t.Errorf("MatchEventType(%q, %q) = %t, want %t", tt.pattern, tt.eventType, got, tt.want)
+		}
+	}
+
+	for s, want := range map[string]bool{"*": true, "revenue.*": true, "*.cancelled": true, "revenue.completed": false, "rev*": false} {
+		if got := IsEventPattern(s); got != want {
+			t.Errorf("IsEventPattern(%q) = %t, want %t", s, got, want)
+		}
+	}
+}
+
+func TestMemoryEventBus_PatternSubscribe(t *testing.T) {
+	t.Parallel()
+
+	t.Run("wildcard receives every event type", func(t *testing.T) {
+		t.Parallel()
+
+		bus := NewMemoryEventBus()
+		var received []string
+
+		bus.Subscribe("*", func(ctx context.Context, e Event) error {
+			received = append(received, e.Type)
+			return nil
+		})
+
+		for _, eventType := range []string{EventTypeRevenueCompleted, EventTypeLoanPayment, "inventory.adjusted"} {
+			if err := bus.Publish(context.Background(), Event{Type: eventType, SourceID: "src-1"}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+
+		if got := strings.Join(received, ","); got != "revenue.completed,loan.payment,inventory.adjusted" {
+			t.Errorf("received = %s", got)
+		}
+	})
+
+	t.Run("prefix pattern only receives its family", func(t *testing.T) {
+		t.Parallel()
+
+		bus := NewMemoryEventBus()
+		var received []string
+
+		bus.Subscribe("revenue.*", func(ctx context.Context, e Event) error {
+			received = append(received, e.Type)
+			return nil
+		})
+
+		for _, eventType := range []string{EventTypeRevenueCompleted, EventTypeCollectionReceived, EventTypeRevenueCancelled} {
+			if err := bus.Publish(context.Background(), Event{Type: eventType, SourceID: "src-1"}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+
+		if got := strings.Join(received, ","); got != "revenue.completed,revenue.cancelled" {
+			t.Errorf("received = %s", got)
+		}
+	})
+
+	t.Run("exact handlers run before pattern handlers", func(t *testing.T) {
+		t.Parallel()
+
+		bus := NewMemoryEventBus()
+		var order []string
+		record := func(name string) Handler {
+			return func(ctx context.Context, e Event) error {
+				order = append(order, name)
+				return nil
+			}
+		}
+
+		bus.Subscribe("*", record("all"))
+		bus.Subscribe("revenue.*", record("revenue"))
+		bus.Subscribe("revenue.completed", record("exact-1"))
+		bus.Subscribe("*.completed", record("completed"))
+		bus.Subscribe("revenue.completed", record("exact-2"))
+
+		if err := bus.Publish(context.Background(), Event{Type: "revenue.completed", SourceID: "src-1"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got := strings.Join(order, ","); got != "exact-1,exact-2,all,revenue,completed" {
+			t.Errorf("order = %s", got)
+		}
+	})
+
+	t.Run("pattern handler errors are aggregated", func(t *testing.T) {
+		t.Parallel()
+
+		bus := NewMemoryEventBus()
+		handlerErr := errors.New("audit store down")
+
+		bus.Subscribe("fail.event", func(ctx context.Context, e Event) error {
+			return errors.New("exact failed")
+		})
+		bus.Subscribe("*", func(ctx context.Context, e Event) error {
+			return handlerErr
+		})
+
+		err := bus.Publish(context.Background(), Event{Type: "fail.event", SourceID: "src-1"})
+		if err == nil || !strings.Contains(err.Error(), "2 handler(s) failed") {
+			t.Errorf("error = %v, want both failures counted", err)
+		}
+	})
+
+	t.Run("exact subscriptions are not affected", func(t *testing.T) {
+		t.Parallel()
+
+		bus := NewMemoryEventBus()
+		bus.Subscribe("revenue.*", func(ctx context.Context, e Event) error { return nil })
+
+		if n := len(bus.handlers["revenue.*"]); n != 0 {
+			t.Errorf("pattern stored as exact subscription (%d handlers)", n)
+		}
+		calls := 0
+		bus.Subscribe("revenue.completed", func(ctx context.Context, e Event) error { calls++; return nil })
+		_ = bus.Publish(context.Background(), Event{Type: "revenue.completed"})
+		_ = bus.Publish(context.Background(), Event{Type: "revenue.completed"})
+		if calls != 2 {
+			t.Errorf("exact handler calls = %d, want 2", calls)
+		}
+	})
+}
+
+func TestAsyncEventBus_PatternSubscribe(t *testing.T) {
+	t.Parallel()
+
+	bus := NewAsyncEventBus(AsyncConfig{Workers: 1})
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+	record := func(name string) Handler {
+		return func(ctx context.Context, e Event) error {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, name+":"+e.Type)
+			return nil
+		}
+	}
+	bus.Subscribe("*", record("all"))
+	bus.Subscribe(EventTypeLoanPayment, record("exact"))
+
+	_ = bus.Publish(context.Background(), Event{Type: EventTypeLoanPayment, SourceID: "loan-1"})
+	_ = bus.Publish(context.Background(), Event{Type: EventTypeLoanReceived, SourceID: "loan-1"})
+	if err := bus.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	if got := strings.Join(order, ","); got != "exact:loan.payment,all:loan.payment,all:loan.received" {
+		t.Errorf("order = %s", got)
+	}
+}
+
+func TestStoringEventBus_PatternSubscribe(t *testing.T) {
+	t.Parallel()
+
+	bus := NewStoringEventBus(NewMemoryEventBus(), NewMemoryEventStore())
+	var received []string
+	bus.Subscribe("payroll.*", func(ctx context.Context, e Event) error {
+		received = append(received, e.Type)
+		return nil
+	})
+
+	_ = bus.Publish(context.Background(), Event{Type: EventTypePayrollPosted})
+	_ = bus.Publish(context.Background(), Event{Type: EventTypePayrollReversed})
+	_ = bus.Publish(context.Background(), Event{Type: EventTypeRevenueCompleted})
+
+	if got := strings.Join(received, ","); got != "payroll.posted,payroll.reversed" {
+		t.Errorf("received = %s", got)
+	}
+}
@@ -0,0 +1,436 @@
+package eventbus
+
+// Payload schemas give Event.Payload a typed, versioned shape.
+//
+// A typed payload is a struct implementing Payload whose fields carry a
+// `payload:"key"` tag (add ",required" for keys that must be present).
+// Supported field types are string, bool, int64 (an amount in centavos,
+// carried in the map as a decimal number) and slices of such structs
+// (carried as a list of objects). EncodePayload turns a struct into the map
+// that travels on the bus, adding the schema version under "schema_version";
+// DecodePayload and PayloadRegistry.Decode turn it back, rejecting unknown
+// keys and values of the wrong type.
+//
+// Besides the struct's keys, any payload may carry the envelope keys
+// "schema_version", "currency" and "fx_rate" (see fx.go).
+//
+// Versioning: a payload without "schema_version" is version 1. To change a
+// payload incompatibly, add a new struct with a higher SchemaVersion, keep
+// the old one registered, and give it an Upgrade method returning the new
+// form so handlers only deal with the latest version.
+//
+// ValidatingEventBus checks every published event against a PayloadRegistry
+// and fails Publish before any handler runs. Event types without a
+// registered schema (including reversal events) pass through unchecked.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SchemaVersionKey is the payload key holding the schema version.
+const SchemaVersionKey = "schema_version"
+
+// Payload is a typed event payload.
+type Payload interface {
+	// EventType is the event type the payload belongs to.
+	EventType() string
+
+	// SchemaVersion is the version of the payload's shape, starting at 1.
+	SchemaVersion() int
+
+	// Validate checks the decoded values, e.g. that amounts add up. Missing
+	// required keys and type mismatches are caught before it is called.
+	Validate() error
+}
+
+// PayloadUpgrader is implemented by superseded payload versions.
+// PayloadRegistry.Decode calls Upgrade until it reaches a payload that does
+// not implement it.
+type PayloadUpgrader interface {
+	Upgrade() (Payload, error)
+}
+
+// NewEvent returns an event of p's type for sourceID with p encoded as its
+// payload.
+func NewEvent(sourceID string, p Payload) (Event, error) {
+	payload, err := EncodePayload(p)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{Type: p.EventType(), SourceID: sourceID, Payload: payload}, nil
+}
+
+// EncodePayload validates p and converts it to an Event.Payload map.
+func EncodePayload(p Payload) (map[string]any, error) {
+	if err := p.Validate(); err != nil {
+		return nil, fmt.Errorf("eventbus: %w: %s (schema v%d): %v", ErrInvalidPayload, p.EventType(), p.SchemaVersion(), err)
+	}
+	v := reflect.Indirect(reflect.ValueOf(p))
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("eventbus: payload %T is not a struct", p)
+	}
+	m := encodeStruct(v)
+	m[SchemaVersionKey] = p.SchemaVersion()
+	return m, nil
+}
+
+// DecodePayload decodes event.Payload into dst, which must be a pointer to a
+// struct of the event's type and schema version, and validates the result.
+func DecodePayload(event Event, dst Payload) error {
+	if event.Type != dst.EventType() {
+		return fmt.Errorf("eventbus: cannot decode %s payload into %T (for %s)", event.Type, dst, dst.EventType())
+	}
+	version, err := payloadVersion(event.Payload)
+	if err != nil {
+		return payloadError(event, version, []string{err.Error()})
+	}
+	if version != dst.SchemaVersion() {
+		return payloadError(event, version, []string{fmt.Sprintf("%T decodes schema v%d", dst, dst.SchemaVersion())})
+	}
+	return decodeInto(event, version, dst, false)
+}
+
+// decodeInto decodes event.Payload into dst and validates it.
+func decodeInto(event Event, version int, dst Payload, allowUnknownKeys bool) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("eventbus: decoding payload into %T: need a pointer to a struct", dst)
+	}
+	problems := decodeStruct(event.Payload, v.Elem(), "", allowUnknownKeys)
+	if _, _, err := payloadCurrency(event.Payload); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if len(problems) == 0 {
+		if err := dst.Validate(); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if len(problems) > 0 {
+		return payloadError(event, version, problems)
+	}
+	return nil
+}
+
+// payloadError builds the descriptive error returned for a bad payload.
+func payloadError(event Event, version int, problems []string) error {
+	return fmt.Errorf("eventbus: %w: %s source=%s (schema v%d): %s",
+		ErrInvalidPayload, event.Type, event.SourceID, version, strings.Join(problems, "; "))
+}
+
+// payloadVersion reads SchemaVersionKey, defaulting to 1.
+func payloadVersion(payload map[string]any) (int, error) {
+	raw, ok := payload[SchemaVersionKey]
+	if !ok || raw == nil {
+		return 1, nil
+	}
+	f, err := toFloat(raw)
+	if err != nil || f < 1 || f != math.Trunc(f) {
+		return 1, fmt.Errorf("%q must be a positive integer, got %s", SchemaVersionKey, describeValue(raw))
+	}
+	return int(f), nil
+}
+
+// ---------------------------------------------------------------------------
+// Registry
+// ---------------------------------------------------------------------------
+
+// PayloadRegistry maps event types and schema versions to payload structs.
+type PayloadRegistry struct {
+	mu      sync.RWMutex
+	schemas map[string]map[int]func() Payload
+}
+
+// NewPayloadRegistry creates an empty registry.
+func NewPayloadRegistry() *PayloadRegistry {
+	return &PayloadRegistry{schemas: make(map[string]map[int]func() Payload)}
+}
+
+// DefaultPayloadRegistry returns a new registry holding the payloads in
+// payloads.go. Register more on it as modules add event types.
+func DefaultPayloadRegistry() *PayloadRegistry {
+	r := NewPayloadRegistry()
+	registerDefaultPayloads(r)
+	return r
+}
+
+// Register adds a payload schema. newPayload must return a pointer to a new
+// zero struct; its EventType and SchemaVersion key the registration, and a
+// later registration for the same pair replaces the earlier one.
+func (r *PayloadRegistry) Register(newPayload func() Payload) {
+	p := newPayload()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	versions := r.schemas[p.EventType()]
+	if versions == nil {
+		versions = make(map[int]func() Payload)
+		r.schemas[p.EventType()] = versions
+	}
+	versions[p.SchemaVersion()] = newPayload
+}
+
+// Versions returns the registered schema versions for eventType, ascending.
+func (r *PayloadRegistry) Versions(eventType string) []int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var versions []int
+	for v := range r.schemas[eventType] {
+		versions = append(versions, v)
+	}
+	sort.Ints(versions)
+	return versions
+}
+
+// Decode decodes and validates event.Payload with the schema registered for
+// its type and version, then upgrades it to the newest form. It returns
+// (nil, nil) when no schema is registered for the event type.
+func (r *PayloadRegistry) Decode(event Event) (Payload, error) {
+	return r.decode(event, false)
+}
+
+func (r *PayloadRegistry) decode(event Event, allowUnknownKeys bool) (Payload, error) {
+	r.mu.RLock()
+	versions := r.schemas[event.Type]
+	r.mu.RUnlock()
+	if versions == nil {
+		return nil, nil
+	}
+
+	version, err := payloadVersion(event.Payload)
+	if err != nil {
+		return nil, payloadError(event, version, []string{err.Error()})
+	}
+	r.mu.RLock()
+	newPayload, ok := versions[version]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, payloadError(event, version, []string{
+			fmt.Sprintf("unsupported schema version (registered: %v)", r.Versions(event.Type)),
+		})
+	}
+
+	p := newPayload()
+	if err := decodeInto(event, version, p, allowUnknownKeys); err != nil {
+		return nil, err
+	}
+	for {
+		u, ok := p.(PayloadUpgrader)
+		if !ok {
+			return p, nil
+		}
+		next, err := u.Upgrade()
+		if err != nil {
+			return nil, payloadError(event, version, []string{fmt.Sprintf("upgrading %T: %v", p, err)})
+		}
+		p = next
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Validating bus
+// ---------------------------------------------------------------------------
+
+// ValidatingConfig configures a ValidatingEventBus.
+type ValidatingConfig struct {
+	// Registry holds the schemas to check against.
+	// Default DefaultPayloadRegistry().
+	Registry *PayloadRegistry
+
+	// AllowUnknownKeys accepts payload keys the schema does not declare, for
+	// workspaces whose posting rules read extra keys (e.g. "vat"). Wrong
+	// types and missing required keys are still rejected.
+	AllowUnknownKeys bool
+}
+
+// ValidatingEventBus checks each event's payload against its registered
+// schema before handing it to the wrapped bus. Put it outermost, e.g. around
+// a StoringEventBus, so invalid events are neither stored nor delivered.
+type ValidatingEventBus struct {
+	bus        EventBus
+	cfg        ValidatingConfig
+	middleware middlewareStack
+}
+
+// NewValidatingEventBus wraps bus so every published payload is validated.
+func NewValidatingEventBus(bus EventBus, cfg ValidatingConfig) *ValidatingEventBus {
+	if cfg.Registry == nil {
+		cfg.Registry = DefaultPayloadRegistry()
+	}
+	return &ValidatingEventBus{bus: bus, cfg: cfg}
+}
+
+// Publish validates the payload and publishes the event on the wrapped bus.
+// An invalid payload fails with an error wrapping ErrInvalidPayload that
+// lists every problem found; no handler runs.
+func (b *ValidatingEventBus) Publish(ctx context.Context, event Event) error {
+	if event.Type == "" {
+		return errors.New("eventbus: event type is required")
+	}
+	if _, err := b.cfg.Registry.decode(event, b.cfg.AllowUnknownKeys); err != nil {
+		return err
+	}
+	return b.bus.Publish(ctx, event)
+}
+
+// Subscribe registers handler on the wrapped bus.
+func (b *ValidatingEventBus) Subscribe(eventType string, handler Handler) {
+	b.bus.Subscribe(eventType, func(ctx context.Context, event Event) error {
+		return b.middleware.wrap(handler)(ctx, event)
+	})
+}
+
+// Use adds middleware around every handler subscribed through this bus. It
+// runs inside any middleware registered on the wrapped bus.
+func (b *ValidatingEventBus) Use(middleware ...Middleware) {
+	b.middleware.use(middleware...)
+}
+
+// ---------------------------------------------------------------------------
+// Struct encoding
+// ---------------------------------------------------------------------------
+
+// envelopeKeys may appear at the top level of any payload.
+var envelopeKeys = map[string]bool{SchemaVersionKey: true, CurrencyKey: true, FXRateKey: true}
+
+// payloadField is one tagged struct field.
+type payloadField struct {
+	key      string
+	index    int
+	required bool
+}
+
+var payloadFieldCache sync.Map // reflect.Type → []payloadField
+
+// payloadFields returns the tagged fields of struct type t.
+func payloadFields(t reflect.Type) []payloadField {
+	if cached, ok := payloadFieldCache.Load(t); ok {
+		return cached.([]payloadField)
+	}
+	var fields []payloadField
+	for i := 0; i < t.NumField(); i++ {
+		tag, ok := t.Field(i).Tag.Lookup("payload")
+		if !ok || tag == "-" {
+			continue
+		}
+		key, opts, _ := strings.Cut(tag, ",")
+		fields = append(fields, payloadField{key: key, index: i, required: opts == "required"})
+	}
+	payloadFieldCache.Store(t, fields)
+	return fields
+}
+
+// encodeStruct converts a tagged struct to a payload map. Zero values of
+// optional fields are omitted.
+func encodeStruct(v reflect.Value) map[string]any {
+	m := make(map[string]any)
+	for _, f := range payloadFields(v.Type()) {
+		fv := v.Field(f.index)
+		if fv.IsZero() && !f.required {
+			continue
+		}
+		switch fv.Kind() {
+		case reflect.Int64:
+			m[f.key] = float64(fv.Int()) / 100
+		case reflect.Slice:
+			items := make([]any, fv.Len())
+			for i := range items {
+				items[i] = encodeStruct(fv.Index(i))
+			}
+			m[f.key] = items
+		default:
+			m[f.key] = fv.Interface()
+		}
+	}
+	return m
+}
+
+// decodeStruct fills v from m and returns a description of every problem
+// found. path prefixes keys inside lists, e.g. "expense_lines[1].".
+func decodeStruct(m map[string]any, v reflect.Value, path string, allowUnknownKeys bool) []string {
+	var problems []string
+	fields := payloadFields(v.Type())
+
+	if !allowUnknownKeys {
+		known := make(map[string]bool, len(fields))
+		for _, f := range fields {
+			known[f.key] = true
+		}
+		var unknown []string
+		for k := range m {
+			if !known[k] && !(path == "" && envelopeKeys[k]) {
+				unknown = append(unknown, k)
+			}
+		}
+		sort.Strings(unknown)
+		for _, k := range unknown {
+			problems = append(problems, fmt.Sprintf("unknown key %q", path+k))
+		}
+	}
+
+	for _, f := range fields {
+		key := path + f.key
+		raw, ok := m[f.key]
+		if !ok || raw == nil {
+			if f.required {
+				problems = append(problems, fmt.Sprintf("missing %q", key))
+			}
+			continue
+		}
+		fv := v.Field(f.index)
+		switch fv.Kind() {
+		case reflect.String:
+			s, ok := raw.(string)
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%q must be a string, got %s", key, describeValue(raw)))
+				continue
+			}
+			fv.SetString(s)
+		case reflect.Bool:
+			b, ok := raw.(bool)
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%q must be true or false, got %s", key, describeValue(raw)))
+				continue
+			}
+			fv.SetBool(b)
+		case reflect.Int64:
+			amount, err := toFloat(raw)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%q must be a number, got %s", key, describeValue(raw)))
+				continue
+			}
+			if amount < 0 {
+				problems = append(problems, fmt.Sprintf("%q must not be negative", key))
+				continue
+			}
+			fv.SetInt(toCentavos(amount))
+		case reflect.Slice:
+			items, err := payloadMaps(m, f.key)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%q must be a list of objects, got %s", key, describeValue(raw)))
+				continue
+			}
+			slice := reflect.MakeSlice(fv.Type(), len(items), len(items))
+			for i, item := range items {
+				problems = append(problems, decodeStruct(item, slice.Index(i), fmt.Sprintf("%s[%d].", key, i), allowUnknownKeys)...)
+			}
+			fv.Set(slice)
+		default:
+			problems = append(problems, fmt.Sprintf("%q: unsupported field type %s", key, fv.Type()))
+		}
+	}
+	return problems
+}
+
+// describeValue renders a payload value for an error message.
+func describeValue(v any) string {
+	if s, ok := v.(string); ok {
+		return fmt.Sprintf("string %q", s)
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,278 @@
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEncodeDecodePayload(t *testing.T) {
+	t.Parallel()
+
+	payment := LoanPaymentPayload{
+		TotalAmount: 1250000, PrincipalAmount: 1000000, InterestAmount: 250000,
+		LoanPaymentID: "lp-1", AccountLoan: "loan", AccountInterest: "interest", AccountCash: "cash",
+	}
+	event, err := NewEvent("lp-1", payment)
+	if err != nil {
+		t.Fatalf("NewEvent: %v", err)
+	}
+	if event.Type != EventTypeLoanPayment || event.Payload["total_amount"] != 12500.0 || event.Payload[SchemaVersionKey] != 1 {
+		t.Fatalf("event = %+v", event)
+	}
+
+	t.Run("round trip through JSON", func(t *testing.T) {
+		t.Parallel()
+
+		data, _ := json.Marshal(event.Payload)
+		stored := event
+		stored.Payload = nil
+		if err := json.Unmarshal(data, &stored.Payload); err != nil {
+			t.Fatal(err)
+		}
+		var got LoanPaymentPayload
+		if err := DecodePayload(stored, &got); err != nil {
+			t.Fatalf("DecodePayload: %v", err)
+		}
+		if got != payment {
+			t.Errorf("decoded %+v, want %+v", got, payment)
+		}
+	})
+
+	t.Run("encoded payload posts with the default rules", func(t *testing.T) {
+		t.Parallel()
+
+		entry, err := NewJournalPoster(nil).BuildEntry(context.Background(), event)
+		if err != nil {
+			t.Fatalf("BuildEntry: %v", err)
+		}
+		if debit, _ := entry.Totals(); debit != 1250000 || len(entry.Lines) != 3 {
+			t.Errorf("entry debit = %d with %d lines, want 1250000 with 3", debit, len(entry.Lines))
+		}
+	})
+
+	t.Run("petty cash expense lines", func(t *testing.T) {
+		t.Parallel()
+
+		in := PettyCashReplenishedPayload{
+			ReplenishmentAmount: 80000, AccountCash: "cash",
+			ExpenseLines: []PettyCashExpenseLine{
+				{AccountID: "supplies", Amount: 50000, Memo: "Office supplies"},
+				{AccountID: "fares", Amount: 30000},
+			},
+		}
+		event, err := NewEvent("pc-1", in)
+		if err != nil {
+			t.Fatalf("NewEvent: %v", err)
+		}
+		var out PettyCashReplenishedPayload
+		if err := DecodePayload(event, &out); err != nil {
+			t.Fatalf("DecodePayload: %v", err)
+		}
+		if len(out.ExpenseLines) != 2 || out.ExpenseLines[0] != in.ExpenseLines[0] || out.ExpenseLines[1] != in.ExpenseLines[1] {
+			t.Errorf("expense lines = %+v", out.ExpenseLines)
+		}
+	})
+
+	t.Run("encoding rejects inconsistent amounts", func(t *testing.T) {
+		t.Parallel()
+
+		bad := payment
+		bad.InterestAmount = 0
+		_, err := NewEvent("lp-1", bad)
+		if !errors.Is(err, ErrInvalidPayload) || !strings.Contains(err.Error(), "must equal total_amount 12500.00") {
+			t.Errorf("error = %v", err)
+		}
+	})
+
+	t.Run("decoding into the wrong type", func(t *testing.T) {
+		t.Parallel()
+
+		if err := DecodePayload(event, &PayrollPostedPayload{}); err == nil {
+			t.Error("loan.payment decoded into a payroll payload")
+		}
+	})
+}
+
+func TestPayloadRegistry_Decode(t *testing.T) {
+	t.Parallel()
+
+	registry := DefaultPayloadRegistry()
+	tests := []struct {
+		name    string
+		event   Event
+		wantErr []string
+	}{
+		{
+			name: "legacy payload without schema version",
+			event: Event{Type: EventTypeRevenueCompleted, Payload: map[string]any{
+				"amount": 1500.50, "account_ar": "ar", "account_rev": "rev",
+			}},
+		},
+		{
+			name: "amount sent as a string",
+			event: Event{Type: EventTypeRevenueCompleted, SourceID: "rev-1", Payload: map[string]any{
+				"amount": "1500.50", "account_ar": "ar",
+			}},
+			wantErr: []string{`revenue.completed source=rev-1 (schema v1): "amount" must be a number, got string "1500.50"`},
+		},
+		{
+			name: "misspelled key",
+			event: Event{Type: EventTypeCollectionReceived, Payload: map[string]any{
+				"amt": 100, "account_cash": "cash",
+			}},
+			wantErr: []string{`unknown key "amt"`, `missing "amount"`},
+		},
+		{
+			name: "payroll that does not add up",
+			event: Event{Type: EventTypePayrollPosted, Payload: map[string]any{
+				"gross_pay": 50000, "net_pay": 42000, "gov_contributions": 5000,
+			}},
+			wantErr: []string{"net_pay 42000.00 + gov_contributions 5000.00 must equal gross_pay 50000.00"},
+		},
+		{
+			name: "bad expense line",
+			event: Event{Type: EventTypePettyCashReplenished, Payload: map[string]any{
+				"replenishment_amount": 500,
+				"expense_lines":        []any{map[string]any{"account_id": 7, "amount": 500}},
+			}},
+			wantErr: []string{`"expense_lines[0].account_id" must be a string, got int`},
+		},
+		{
+			name: "unsupported schema version",
+			event: Event{Type: EventTypeLoanReceived, Payload: map[string]any{
+				"amount": 100, SchemaVersionKey: 9,
+			}},
+			wantErr: []string{"(schema v9): unsupported schema version (registered: [1])"},
+		},
+		{
+			name:  "unregistered event type",
+			event: Event{Type: "inventory.adjusted", Payload: map[string]any{"anything": "goes"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := registry.Decode(tt.event)
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("Decode: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidPayload) {
+				t.Fatalf("error = %v, want ErrInvalidPayload", err)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+// loanPaymentV2 is a hypothetical successor to LoanPaymentPayload used to
+// exercise upgrades.
+type loanPaymentV2 struct {
+	TotalAmount int64  `payload:"total_amount,required"`
+	Principal   int64  `payload:"principal"`
+	Interest    int64  `payload:"interest"`
+	Penalty     int64  `payload:"penalty"`
+	AccountLoan string `payload:"account_loan"`
+}
+
+func (loanPaymentV2) EventType() string  { return EventTypeLoanPayment }
+func (loanPaymentV2) SchemaVersion() int { return 2 }
+func (loanPaymentV2) Validate() error    { return nil }
+
+type loanPaymentV1 struct {
+	TotalAmount     int64  `payload:"total_amount,required"`
+	PrincipalAmount int64  `payload:"principal_amount"`
+	InterestAmount  int64  `payload:"interest_amount"`
+	AccountLoan     string `payload:"account_loan"`
+}
+
+func (loanPaymentV1) EventType() string  { return EventTypeLoanPayment }
+func (loanPaymentV1) SchemaVersion() int { return 1 }
+func (loanPaymentV1) Validate() error    { return nil }
+
+func (p *loanPaymentV1) Upgrade() (Payload, error) {
+	return &loanPaymentV2{
+		TotalAmount: p.TotalAmount, Principal: p.PrincipalAmount, Interest: p.InterestAmount, AccountLoan: p.AccountLoan,
+	}, nil
+}
+
+func TestPayloadRegistry_Upgrade(t *testing.T) {
+	t.Parallel()
+
+	registry := NewPayloadRegistry()
+	registry.Register(func() Payload { return &loanPaymentV1{} })
+	registry.Register(func() Payload { return &loanPaymentV2{} })
+	if got := registry.Versions(EventTypeLoanPayment); len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Fatalf("versions = %v, want [1 2]", got)
+	}
+
+	legacy := Event{Type: EventTypeLoanPayment, Payload: map[string]any{
+		"total_amount": 110, "principal_amount": 100, "interest_amount": 10, "account_loan": "loan",
+	}}
+	p, err := registry.Decode(legacy)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	v2, ok := p.(*loanPaymentV2)
+	if !ok || v2.Principal != 10000 || v2.Interest != 1000 || v2.AccountLoan != "loan" {
+		t.Errorf("decoded %#v, want upgraded v2", p)
+	}
+}
+
+func TestValidatingEventBus(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	valid := map[string]any{"amount": 100, "account_cash": "cash", "account_ar": "ar"}
+	withVAT := map[string]any{"amount": 100, "vat": 12, "account_cash": "cash"}
+	invalid := map[string]any{"amount": "100"}
+
+	t.Run("rejects before any handler runs", func(t *testing.T) {
+		t.Parallel()
+
+		store := NewMemoryEventStore()
+		bus := NewValidatingEventBus(NewStoringEventBus(NewMemoryEventBus(), store), ValidatingConfig{})
+		handled := 0
+		bus.Subscribe(EventTypeCollectionReceived, func(ctx context.Context, e Event) error { handled++; return nil })
+
+		if err := bus.Publish(ctx, Event{Type: EventTypeCollectionReceived, Payload: valid}); err != nil {
+			t.Fatalf("valid payload: %v", err)
+		}
+		for _, payload := range []map[string]any{invalid, withVAT} {
+			if err := bus.Publish(ctx, Event{Type: EventTypeCollectionReceived, Payload: payload}); !errors.Is(err, ErrInvalidPayload) {
+				t.Errorf("payload %v: error = %v, want ErrInvalidPayload", payload, err)
+			}
+		}
+		if err := bus.Publish(ctx, Event{Type: EventTypeRevenueCancelled, SourceID: "rev-1"}); err != nil {
+			t.Errorf("unregistered type: %v", err)
+		}
+
+		stored, _ := store.Query(ctx, EventFilter{})
+		if handled != 1 || len(stored) != 2 {
+			t.Errorf("handled = %d, stored = %d, want 1 and 2", handled, len(stored))
+		}
+	})
+
+	t.Run("allow unknown keys", func(t *testing.T) {
+		t.Parallel()
+
+		bus := NewValidatingEventBus(NewMemoryEventBus(), ValidatingConfig{AllowUnknownKeys: true})
+		if err := bus.Publish(ctx, Event{Type: EventTypeCollectionReceived, Payload: withVAT}); err != nil {
+			t.Errorf("extra key rejected: %v", err)
+		}
+		if err := bus.Publish(ctx, Event{Type: EventTypeCollectionReceived, Payload: invalid}); err == nil {
+			t.Error("wrong type accepted")
+		}
+	})
+}
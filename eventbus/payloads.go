@@ -0,0 +1,299 @@
+package eventbus
+
+// Typed payloads for the operational event types JournalPoster recognizes.
+// Each struct mirrors the payload keys documented in
+// default_posting_rules.yaml; publish them with NewEvent (or EncodePayload)
+// and read them back in handlers with DecodePayload. See payload_schema.go for
+// the encoding rules and versioning.
+//
+// Amounts are int64 centavos and travel in Event.Payload as decimal numbers,
+// the form posting rules expect. The default rules read the accounts from the
+// Account fields; leave them empty only for workspaces whose posting rule
+// gives the line an account_code to resolve instead. For a foreign-currency
+// event, amounts are in the transaction currency; set Event.Payload[CurrencyKey]
+// on the event NewEvent returns.
+
+import (
+	"errors"
+	"fmt"
+)
+
+// RevenueCompletedPayload is the payload of "revenue.completed".
+type RevenueCompletedPayload struct {
+	Amount      int64  `payload:"amount,required"`
+	RevenueID   string `payload:"revenue_id"`
+	Collected   bool   `payload:"collected"`
+	AccountAR   string `payload:"account_ar"`
+	AccountCash string `payload:"account_cash"`
+	AccountRev  string `payload:"account_rev"`
+}
+
+func (RevenueCompletedPayload) EventType() string  { return EventTypeRevenueCompleted }
+func (RevenueCompletedPayload) SchemaVersion() int { return 1 }
+
+// Validate checks the amount.
+func (p RevenueCompletedPayload) Validate() error { return positive("amount", p.Amount) }
+
+// CollectionReceivedPayload is the payload of "collection.received".
+type CollectionReceivedPayload struct {
+	Amount       int64  `payload:"amount,required"`
+	CollectionID string `payload:"collection_id"`
+	AccountCash  string `payload:"account_cash"`
+	AccountAR    string `payload:"account_ar"`
+}
+
+func (CollectionReceivedPayload) EventType() string  { return EventTypeCollectionReceived }
+func (CollectionReceivedPayload) SchemaVersion() int { return 1 }
+
+// Validate checks the amount.
+func (p CollectionReceivedPayload) Validate() error { return positive("amount", p.Amount) }
+
+// ExpenditureApprovedPayload is the payload of "expenditure.approved".
+type ExpenditureApprovedPayload struct {
+	Amount          int64  `payload:"amount,required"`
+	ExpenditureID   string `payload:"expenditure_id"`
+	PaidImmediately bool   `payload:"paid_immediately"`
+	AccountExpense  string `payload:"account_expense"`
+	AccountAP       string `payload:"account_ap"`
+	AccountCash     string `payload:"account_cash"`
+}
+
+func (ExpenditureApprovedPayload) EventType() string  { return EventTypeExpenditureApproved }
+func (ExpenditureApprovedPayload) SchemaVersion() int { return 1 }
+
+// Validate checks the amount.
+func (p ExpenditureApprovedPayload) Validate() error { return positive("amount", p.Amount) }
+
+// DisbursementPaidPayload is the payload of "disbursement.paid".
+type DisbursementPaidPayload struct {
+	Amount         int64  `payload:"amount,required"`
+	DisbursementID string `payload:"disbursement_id"`
+	AccountAP      string `payload:"account_ap"`
+	AccountCash    string `payload:"account_cash"`
+}
+
+func (DisbursementPaidPayload) EventType() string  { return EventTypeDisbursementPaid }
+func (DisbursementPaidPayload) SchemaVersion() int { return 1 }
+
+// Validate checks the amount.
+func (p DisbursementPaidPayload) Validate() error { return positive("amount", p.Amount) }
+
+// AssetAcquiredPayload is the payload of "asset.acquired".
+type AssetAcquiredPayload struct {
+	Amount       int64  `payload:"amount,required"`
+	AssetID      string `payload:"asset_id"`
+	PaidInCash   bool   `payload:"paid_in_cash"`
+	AccountAsset string `payload:"account_asset"`
+	AccountCash  string `payload:"account_cash"`
+	AccountAP    string `payload:"account_ap"`
+}
+
+func (AssetAcquiredPayload) EventType() string  { return EventTypeAssetAcquired }
+func (AssetAcquiredPayload) SchemaVersion() int { return 1 }
+
+// Validate checks the amount.
+func (p AssetAcquiredPayload) Validate() error { return positive("amount", p.Amount) }
+
+// AssetDepreciatedPayload is the payload of "asset.deprecated".
+type AssetDepreciatedPayload struct {
+	Amount                 int64  `payload:"amount,required"`
+	AssetID                string `payload:"asset_id"`
+	AccountDepreciationExp string `payload:"account_depreciation_exp"`
+	AccountAccumDep        string `payload:"account_accum_dep"`
+}
+
+func (AssetDepreciatedPayload) EventType() string  { return EventTypeAssetDepreciated }
+func (AssetDepreciatedPayload) SchemaVersion() int { return 1 }
+
+// Validate checks the amount.
+func (p AssetDepreciatedPayload) Validate() error { return positive("amount", p.Amount) }
+
+// PrepaymentCreatedPayload is the payload of "prepayment.created".
+type PrepaymentCreatedPayload struct {
+	Amount         int64  `payload:"amount,required"`
+	PrepaymentID   string `payload:"prepayment_id"`
+	PaidInCash     bool   `payload:"paid_in_cash"`
+	AccountPrepaid string `payload:"account_prepaid"`
+	AccountCash    string `payload:"account_cash"`
+	AccountAP      string `payload:"account_ap"`
+}
+
+func (PrepaymentCreatedPayload) EventType() string  { return EventTypePrepaymentCreated }
+func (PrepaymentCreatedPayload) SchemaVersion() int { return 1 }
+
+// Validate checks the amount.
+func (p PrepaymentCreatedPayload) Validate() error { return positive("amount", p.Amount) }
+
+// PrepaymentAmortizedPayload is the payload of "prepayment.amortized".
+type PrepaymentAmortizedPayload struct {
+	Amount         int64  `payload:"amount,required"`
+	PrepaymentID   string `payload:"prepayment_id"`
+	AccountExpense string `payload:"account_expense"`
+	AccountPrepaid string `payload:"account_prepaid"`
+}
+
+func (PrepaymentAmortizedPayload) EventType() string  { return EventTypePrepaymentAmortized }
+func (PrepaymentAmortizedPayload) SchemaVersion() int { return 1 }
+
+// Validate checks the amount.
+func (p PrepaymentAmortizedPayload) Validate() error { return positive("amount", p.Amount) }
+
+// LoanReceivedPayload is the payload of "loan.received".
+type LoanReceivedPayload struct {
+	Amount      int64  `payload:"amount,required"`
+	LoanID      string `payload:"loan_id"`
+	AccountCash string `payload:"account_cash"`
+	AccountLoan string `payload:"account_loan"`
+}
+
+func (LoanReceivedPayload) EventType() string  { return EventTypeLoanReceived }
+func (LoanReceivedPayload) SchemaVersion() int { return 1 }
+
+// Validate checks the amount.
+func (p LoanReceivedPayload) Validate() error { return positive("amount", p.Amount) }
+
+// LoanPaymentPayload is the payload of "loan.payment". The principal and
+// interest portions must add up to the total payment.
+type LoanPaymentPayload struct {
+	TotalAmount     int64  `payload:"total_amount,required"`
+	PrincipalAmount int64  `payload:"principal_amount"`
+	InterestAmount  int64  `payload:"interest_amount"`
+	LoanPaymentID   string `payload:"loan_payment_id"`
+	AccountLoan     string `payload:"account_loan"`
+	AccountInterest string `payload:"account_interest"`
+	AccountCash     string `payload:"account_cash"`
+}
+
+func (LoanPaymentPayload) EventType() string  { return EventTypeLoanPayment }
+func (LoanPaymentPayload) SchemaVersion() int { return 1 }
+
+// Validate checks that principal plus interest equals the total.
+func (p LoanPaymentPayload) Validate() error {
+	if err := positive("total_amount", p.TotalAmount); err != nil {
+		return err
+	}
+	if p.PrincipalAmount+p.InterestAmount != p.TotalAmount {
+		return fmt.Errorf("principal_amount %s + interest_amount %s must equal total_amount %s",
+			formatCentavos(p.PrincipalAmount), formatCentavos(p.InterestAmount), formatCentavos(p.TotalAmount))
+	}
+	return nil
+}
+
+// EquityContributionPayload is the payload of "equity.contribution".
+type EquityContributionPayload struct {
+	Amount              int64  `payload:"amount,required"`
+	EquityTransactionID string `payload:"equity_transaction_id"`
+	AccountCash         string `payload:"account_cash"`
+	AccountCapital      string `payload:"account_capital"`
+}
+
+func (EquityContributionPayload) EventType() string  { return EventTypeEquityContribution }
+func (EquityContributionPayload) SchemaVersion() int { return 1 }
+
+// Validate checks the amount.
+func (p EquityContributionPayload) Validate() error { return positive("amount", p.Amount) }
+
+// EquityWithdrawalPayload is the payload of "equity.withdrawal".
+type EquityWithdrawalPayload struct {
+	Amount              int64  `payload:"amount,required"`
+	EquityTransactionID string `payload:"equity_transaction_id"`
+	AccountDrawings     string `payload:"account_drawings"`
+	AccountCash         string `payload:"account_cash"`
+}
+
+func (EquityWithdrawalPayload) EventType() string  { return EventTypeEquityWithdrawal }
+func (EquityWithdrawalPayload) SchemaVersion() int { return 1 }
+
+// Validate checks the amount.
+func (p EquityWithdrawalPayload) Validate() error { return positive("amount", p.Amount) }
+
+// PayrollPostedPayload is the payload of "payroll.posted". Gross pay must
+// equal net pay plus government contributions.
+type PayrollPostedPayload struct {
+	GrossPay           int64  `payload:"gross_pay,required"`
+	NetPay             int64  `payload:"net_pay,required"`
+	GovContributions   int64  `payload:"gov_contributions"`
+	PayrollRunID       string `payload:"payroll_run_id"`
+	AccountSalaryExp   string `payload:"account_salary_exp"`
+	AccountCash        string `payload:"account_cash"`
+	AccountGovPayables string `payload:"account_gov_payables"`
+}
+
+func (PayrollPostedPayload) EventType() string  { return EventTypePayrollPosted }
+func (PayrollPostedPayload) SchemaVersion() int { return 1 }
+
+// Validate checks that gross pay equals net pay plus contributions.
+func (p PayrollPostedPayload) Validate() error {
+	if err := positive("gross_pay", p.GrossPay); err != nil {
+		return err
+	}
+	if p.NetPay+p.GovContributions != p.GrossPay {
+		return fmt.Errorf("net_pay %s + gov_contributions %s must equal gross_pay %s",
+			formatCentavos(p.NetPay), formatCentavos(p.GovContributions), formatCentavos(p.GrossPay))
+	}
+	return nil
+}
+
+// PettyCashReplenishedPayload is the payload of "petty_cash.replenished".
+// The expense lines must add up to the replenishment amount.
+type PettyCashReplenishedPayload struct {
+	ReplenishmentAmount int64                  `payload:"replenishment_amount,required"`
+	PettyCashFundID     string                 `payload:"petty_cash_fund_id"`
+	AccountCash         string                 `payload:"account_cash"`
+	ExpenseLines        []PettyCashExpenseLine `payload:"expense_lines,required"`
+}
+
+// PettyCashExpenseLine is one voucher category of a petty cash replenishment.
+type PettyCashExpenseLine struct {
+	AccountID string `payload:"account_id,required"`
+	Amount    int64  `payload:"amount,required"`
+	Memo      string `payload:"memo"`
+}
+
+func (PettyCashReplenishedPayload) EventType() string  { return EventTypePettyCashReplenished }
+func (PettyCashReplenishedPayload) SchemaVersion() int { return 1 }
+
+// Validate checks that the expense lines add up to the replenishment amount.
+func (p PettyCashReplenishedPayload) Validate() error {
+	if err := positive("replenishment_amount", p.ReplenishmentAmount); err != nil {
+		return err
+	}
+	if len(p.ExpenseLines) == 0 {
+		return errors.New("expense_lines must not be empty")
+	}
+	var sum int64
+	for _, l := range p.ExpenseLines {
+		sum += l.Amount
+	}
+	if sum != p.ReplenishmentAmount {
+		return fmt.Errorf("expense_lines total %s must equal replenishment_amount %s",
+			formatCentavos(sum), formatCentavos(p.ReplenishmentAmount))
+	}
+	return nil
+}
+
+// registerDefaultPayloads adds the payloads above to r.
+func registerDefaultPayloads(r *PayloadRegistry) {
+	r.Register(func() Payload { return &RevenueCompletedPayload{} })
+	r.Register(func() Payload { return &CollectionReceivedPayload{} })
+	r.Register(func() Payload { return &ExpenditureApprovedPayload{} })
+	r.Register(func() Payload { return &DisbursementPaidPayload{} })
+	r.Register(func() Payload { return &AssetAcquiredPayload{} })
+	r.Register(func() Payload { return &AssetDepreciatedPayload{} })
+	r.Register(func() Payload { return &PrepaymentCreatedPayload{} })
+	r.Register(func() Payload { return &PrepaymentAmortizedPayload{} })
+	r.Register(func() Payload { return &LoanReceivedPayload{} })
+	r.Register(func() Payload { return &LoanPaymentPayload{} })
+	r.Register(func() Payload { return &EquityContributionPayload{} })
+	r.Register(func() Payload { return &EquityWithdrawalPayload{} })
+	r.Register(func() Payload { return &PayrollPostedPayload{} })
+	r.Register(func() Payload { return &PettyCashReplenishedPayload{} })
+}
+
+// positive returns an error unless amount is greater than zero.
+func positive(key string, amount int64) error {
+	if amount <= 0 {
+		return fmt.Errorf("%s must be greater than zero", key)
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package eventbus
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	jepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/ledger/journal_entry"
+)
+
+// ErrUnbalancedEntry is returned when the debits and credits derived from an
+// event payload do not balance. Retrying such an event can never succeed.
+var ErrUnbalancedEntry = errors.New("journal entry is unbalanced")
+
+// ErrInvalidPayload is returned when an event payload is missing a required
+// key or carries a value of the wrong type.
+var ErrInvalidPayload = errors.New("invalid event payload")
+
+// JournalLine is one debit or credit line of an auto-posted journal entry.
+// Amounts are in centavos, matching JournalEntry.TotalDebit/TotalCredit.
+type JournalLine struct {
+	AccountID string
+	Debit     int64
+	Credit    int64
+	Memo      string
+	Order     int32
+
+	// Currency is the transaction currency of a foreign-currency event, in
+	// which case Debit and Credit hold the functional-currency amounts,
+	// TransactionDebit and TransactionCredit the original amounts in the
+	// currency's minor units, and FXRate the rate used (see fx.go). All four
+	// are zero for entries in the functional currency.
+	Currency          string
+	TransactionDebit  int64
+	TransactionCredit int64
+	FXRate            float64
+}
+
+// PostingEntry is the journal entry JournalPoster derives from an event,
+// before it is persisted through the CreateJournalEntry use case.
+type PostingEntry struct {
+	EventType   string
+	SourceID    string
+	SourceType  jepb.JournalSourceType
+	Description string
+	EntryDate   time.Time
+	Lines       []JournalLine
+
+	// Currency and FXRate are set when the event was in a foreign currency.
+	Currency string
+	FXRate   float64
+}
+
+// Totals returns the sum of debit and credit amounts across all lines, in centavos.
+func (e *PostingEntry) Totals() (debit, credit int64) {
+	for _, l := range e.Lines {
+		debit += l.Debit
+		credit += l.Credit
+	}
+	return debit, credit
+}
+
+// Validate checks that the entry has at least two lines, that every line
+// names an account and carries exactly one positive amount, and that total
+// debits equal total credits.
+func (e *PostingEntry) Validate() error {
+	if len(e.Lines) < 2 {
+		return fmt.Errorf("%w: at least 2 non-zero journal lines are required, got %d", ErrInvalidPayload, len(e.Lines))
+	}
+	for i, l := range e.Lines {
+		if l.AccountID == "" {
+			return fmt.Errorf("%w: line %d has no account", ErrInvalidPayload, i+1)
+		}
+		if l.Debit < 0 || l.Credit < 0 {
+			return fmt.Errorf("%w: line %d has a negative amount", ErrInvalidPayload, i+1)
+		}
+		if (l.Debit == 0) == (l.Credit == 0) {
+			return fmt.Errorf("%w: line %d must carry either a debit or a credit", ErrInvalidPayload, i+1)
+		}
+	}
+	debit, credit := e.Totals()
+	if debit != credit {
+		return fmt.Errorf("%w: total debits %s != total credits %s",
+			ErrUnbalancedEntry, formatCentavos(debit), formatCentavos(credit))
+	}
+	return nil
+}
+
+// toProto converts the entry into the JournalEntry proto sent to CreateJournalEntry.
+// Lines are persisted separately through JournalPosterDeps.CreateLines.
+func (e *PostingEntry) toProto() *jepb.JournalEntry {
+	debit, credit := e.Totals()
+	dateStr := e.EntryDate.Format("2006-01-02")
+	sourceID := e.SourceID
+	notes := fmt.Sprintf("Auto-posted from %s event", e.EventType)
+	if e.Currency != "" {
+		var txDebit int64
+		for _, l := range e.Lines {
+			txDebit += l.TransactionDebit
+		}
+		notes += fmt.Sprintf(" (%s %s at %s)", e.Currency, formatMinorUnits(txDebit, minorUnitExponent(e.Currency)), strconv.FormatFloat(e.FXRate, 'f', -1, 64))
+	}
+
+	entry := &jepb.JournalEntry{
+		Description:     e.Description,
+		EntryDateString: &dateStr,
+		TotalDebit:      debit,
+		TotalCredit:     credit,
+		Status:          jepb.JournalEntryStatus_JOURNAL_ENTRY_STATUS_DRAFT,
+		SourceType:      e.SourceType,
+		Notes:           &notes,
+	}
+	if sourceID != "" {
+		entry.SourceId = &sourceID
+	}
+	return entry
+}
+
+// debitLine and creditLine build single-sided journal lines.
+func debitLine(accountID string, amount int64, memo string) JournalLine {
+	return JournalLine{AccountID: accountID, Debit: amount, Memo: memo}
+}
+
+func creditLine(accountID string, amount int64, memo string) JournalLine {
+	return JournalLine{AccountID: accountID, Credit: amount, Memo: memo}
+}
+
+// numberLines assigns 1-based line order in slice order.
+func numberLines(lines []JournalLine) []JournalLine {
+	for i := range lines {
+		lines[i].Order = int32(i + 1)
+	}
+	return lines
+}
+
+// ---------------------------------------------------------------------------
+// Payload helpers
+// ---------------------------------------------------------------------------
+
+// payloadMaps reads a list of objects such as petty cash expense_lines.
+// Both []map[string]any and []any of map[string]any are accepted.
+func payloadMaps(payload map[string]any, key string) ([]map[string]any, error) {
+	raw, ok := payload[key]
+	if !ok || raw == nil {
+		return nil, fmt.Errorf("%w: missing %q", ErrInvalidPayload, key)
+	}
+	switch v := raw.(type) {
+	case []map[string]any:
+		return v, nil
+	case []any:
+		result := make([]map[string]any, 0, len(v))
+		for i, item := range v {
+			m, ok := item.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%w: %q[%d] must be an object, got %T", ErrInvalidPayload, key, i, item)
+			}
+			result = append(result, m)
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("%w: %q must be a list, got %T", ErrInvalidPayload, key, raw)
+	}
+}
+
+// toFloat converts the numeric types publishers commonly send into float64.
+func toFloat(v any) (float64, error) {
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case float32:
+		return float64(n), nil
+	case int:
+		return float64(n), nil
+	case int32:
+		return float64(n), nil
+	case int64:
+		return float64(n), nil
+	case json.Number:
+		return n.Float64()
+	default:
+		return 0, fmt.Errorf("expected a number, got %T", v)
+	}
+}
+
+// toCentavos converts a decimal amount to integer centavos, rounding half away from zero.
+func toCentavos(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// formatCentavos formats a centavo integer as a plain decimal string (e.g. "15000.50").
+func formatCentavos(centavos int64) string {
+	return fmt.Sprintf("%.2f", float64(centavos)/100.0)
+}
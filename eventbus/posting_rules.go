@@ -0,0 +1,557 @@
+package eventbus
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	jepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/ledger/journal_entry"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed default_posting_rules.yaml
+var defaultPostingRulesYAML []byte
+
+// PostingRuleSet is a declarative mapping from event types to journal entries.
+// It is loaded from JSON or YAML so finance can change mappings without a redeploy.
+type PostingRuleSet struct {
+	Rules []PostingRule `json:"rules" yaml:"rules"`
+}
+
+// PostingRule describes the journal entry produced for one event type.
+type PostingRule struct {
+	EventType string `json:"event_type" yaml:"event_type"`
+
+	// SourceType is the journal source type name, e.g. "revenue" or "loan_payment".
+	SourceType string `json:"source_type" yaml:"source_type"`
+
+	// Description is the entry description template ({{key}} expands payload keys).
+	Description string `json:"description" yaml:"description"`
+
+	Lines []PostingRuleLine `json:"lines" yaml:"lines"`
+}
+
+// PostingRuleLine describes one debit or credit line of a PostingRule.
+type PostingRuleLine struct {
+	// Side is "debit" or "credit".
+	Side string `json:"side" yaml:"side"`
+
+	// AccountKey names the payload key holding the account ID. Takes precedence
+	// over AccountCode when the key is present in the payload.
+	AccountKey string `json:"account_key,omitempty" yaml:"account_key,omitempty"`
+
+	// AccountCode is a chart-of-accounts code resolved through AccountResolver.
+	AccountCode string `json:"account_code,omitempty" yaml:"account_code,omitempty"`
+
+	// Amount is an expression of payload keys and numbers joined by + and -.
+	Amount string `json:"amount" yaml:"amount"`
+
+	// Memo is the line memo template.
+	Memo string `json:"memo,omitempty" yaml:"memo,omitempty"`
+
+	// When is an optional condition; the line is skipped when it evaluates false.
+	When string `json:"when,omitempty" yaml:"when,omitempty"`
+
+	// Optional treats missing amount keys as zero instead of failing.
+	Optional bool `json:"optional,omitempty" yaml:"optional,omitempty"`
+
+	// ForEach names a payload list; the line is repeated once per item.
+	ForEach string `json:"for_each,omitempty" yaml:"for_each,omitempty"`
+}
+
+// AccountResolver maps a chart-of-accounts code to the account ID used in the
+// given workspace. Consumer apps wire this to their account lookup use case.
+type AccountResolver func(ctx context.Context, workspaceID, code string) (string, error)
+
+// sourceTypesByName maps rule source_type names to journal source types.
+var sourceTypesByName = map[string]jepb.JournalSourceType{
+	"manual":                   jepb.JournalSourceType_JOURNAL_SOURCE_TYPE_MANUAL,
+	"revenue":                  jepb.JournalSourceType_JOURNAL_SOURCE_TYPE_REVENUE,
+	"expenditure":              jepb.JournalSourceType_JOURNAL_SOURCE_TYPE_EXPENDITURE,
+	"collection":               jepb.JournalSourceType_JOURNAL_SOURCE_TYPE_COLLECTION,
+	"disbursement":             jepb.JournalSourceType_JOURNAL_SOURCE_TYPE_DISBURSEMENT,
+	"depreciation":             jepb.JournalSourceType_JOURNAL_SOURCE_TYPE_DEPRECIATION,
+	"asset_acquisition":        jepb.JournalSourceType_JOURNAL_SOURCE_TYPE_ASSET_ACQUISITION,
+	"asset_disposal":           jepb.JournalSourceType_JOURNAL_SOURCE_TYPE_ASSET_DISPOSAL,
+	"prepayment":               jepb.JournalSourceType_JOURNAL_SOURCE_TYPE_PREPAYMENT,
+	"prepayment_amortization":  jepb.JournalSourceType_JOURNAL_SOURCE_TYPE_PREPAYMENT_AMORTIZATION,
+	"loan_receipt":             jepb.JournalSourceType_JOURNAL_SOURCE_TYPE_LOAN_RECEIPT,
+	"loan_payment":             jepb.JournalSourceType_JOURNAL_SOURCE_TYPE_LOAN_PAYMENT,
+	"petty_cash_replenishment": jepb.JournalSourceType_JOURNAL_SOURCE_TYPE_PETTY_CASH_REPLENISHMENT,
+	"bad_debt_provision":       jepb.JournalSourceType_JOURNAL_SOURCE_TYPE_BAD_DEBT_PROVISION,
+	"deferred_revenue":         jepb.JournalSourceType_JOURNAL_SOURCE_TYPE_DEFERRED_REVENUE,
+	"equity_contribution":      jepb.JournalSourceType_JOURNAL_SOURCE_TYPE_EQUITY_CONTRIBUTION,
+	"equity_withdrawal":        jepb.JournalSourceType_JOURNAL_SOURCE_TYPE_EQUITY_WITHDRAWAL,
+	"equity_distribution":      jepb.JournalSourceType_JOURNAL_SOURCE_TYPE_EQUITY_DISTRIBUTION,
+	"year_end_close":           jepb.JournalSourceType_JOURNAL_SOURCE_TYPE_YEAR_END_CLOSE,
+	"recurring":                jepb.JournalSourceType_JOURNAL_SOURCE_TYPE_RECURRING,
+	"payroll":                  jepb.JournalSourceType_JOURNAL_SOURCE_TYPE_PAYROLL,
+}
+
+// DefaultPostingRules returns the built-in rule set covering all 14 recognized
+// event types. Account IDs are read from the payload keys documented in
+// default_posting_rules.yaml.
+func DefaultPostingRules() *PostingRuleSet {
+	rules, err := ParsePostingRulesYAML(defaultPostingRulesYAML)
+	if err != nil {
+		panic(fmt.Sprintf("eventbus: invalid default posting rules: %v", err))
+	}
+	return rules
+}
+
+// ParsePostingRulesJSON parses and validates a JSON rule set.
+func ParsePostingRulesJSON(data []byte) (*PostingRuleSet, error) {
+	var set PostingRuleSet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("eventbus: parsing posting rules JSON: %w", err)
+	}
+	if err := set.Validate(); err != nil {
+		return nil, err
+	}
+	return &set, nil
+}
+
+// ParsePostingRulesYAML parses and validates a YAML rule set.
+func ParsePostingRulesYAML(data []byte) (*PostingRuleSet, error) {
+	var set PostingRuleSet
+	if err := yaml.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("eventbus: parsing posting rules YAML: %w", err)
+	}
+	if err := set.Validate(); err != nil {
+		return nil, err
+	}
+	return &set, nil
+}
+
+// Validate checks every rule for structural errors so a bad rule file is
+// rejected at load time rather than when the first event arrives.
+func (s *PostingRuleSet) Validate() error {
+	seen := make(map[string]bool, len(s.Rules))
+	for i, r := range s.Rules {
+		if r.EventType == "" {
+			return fmt.Errorf("eventbus: posting rule %d: event_type is required", i+1)
+		}
+		if seen[r.EventType] {
+			return fmt.Errorf("eventbus: posting rule %q is defined more than once", r.EventType)
+		}
+		seen[r.EventType] = true
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("eventbus: posting rule %q: %w", r.EventType, err)
+		}
+	}
+	return nil
+}
+
+func (r *PostingRule) validate() error {
+	if _, ok := sourceTypesByName[r.SourceType]; !ok {
+		return fmt.Errorf("unknown source_type %q", r.SourceType)
+	}
+	if len(r.Lines) < 2 {
+		return fmt.Errorf("at least 2 lines are required")
+	}
+	var hasDebit, hasCredit bool
+	for i, l := range r.Lines {
+		switch l.Side {
+		case "debit":
+			hasDebit = true
+		case "credit":
+			hasCredit = true
+		default:
+			return fmt.Errorf("line %d: side must be debit or credit, got %q", i+1, l.Side)
+		}
+		if l.AccountKey == "" && l.AccountCode == "" {
+			return fmt.Errorf("line %d: account_key or account_code is required", i+1)
+		}
+		if _, err := parseAmountExpr(l.Amount); err != nil {
+			return fmt.Errorf("line %d: %w", i+1, err)
+		}
+		if _, err := parseCondition(l.When); err != nil {
+			return fmt.Errorf("line %d: %w", i+1, err)
+		}
+	}
+	if !hasDebit || !hasCredit {
+		return fmt.Errorf("a rule needs at least one debit and one credit line")
+	}
+	return nil
+}
+
+// Rule returns the rule for an event type, or nil if none is defined.
+func (s *PostingRuleSet) Rule(eventType string) *PostingRule {
+	if s == nil {
+		return nil
+	}
+	for i := range s.Rules {
+		if s.Rules[i].EventType == eventType {
+			return &s.Rules[i]
+		}
+	}
+	return nil
+}
+
+// EventTypes returns the event types covered by the set, in rule order.
+func (s *PostingRuleSet) EventTypes() []string {
+	if s == nil {
+		return nil
+	}
+	types := make([]string, 0, len(s.Rules))
+	for _, r := range s.Rules {
+		types = append(types, r.EventType)
+	}
+	return types
+}
+
+// Merge returns a new set where rules in override replace rules of the same
+// event type in s, and rules for new event types are appended.
+func (s *PostingRuleSet) Merge(override *PostingRuleSet) *PostingRuleSet {
+	merged := &PostingRuleSet{}
+	if s != nil {
+		merged.Rules = append(merged.Rules, s.Rules...)
+	}
+	if override == nil {
+		return merged
+	}
+	for _, r := range override.Rules {
+		replaced := false
+		for i := range merged.Rules {
+			if merged.Rules[i].EventType == r.EventType {
+				merged.Rules[i] = r
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			merged.Rules = append(merged.Rules, r)
+		}
+	}
+	return merged
+}
+
+// ---------------------------------------------------------------------------
+// Evaluation
+// ---------------------------------------------------------------------------
+
+// Evaluate applies the rule to an event and returns the resulting entry.
+// The entry is not validated; callers run PostingEntry.Validate.
+func (r *PostingRule) Evaluate(ctx context.Context, event Event, resolve AccountResolver) (*PostingEntry, error) {
+	entry := &PostingEntry{
+		EventType:   event.Type,
+		SourceID:    event.SourceID,
+		SourceType:  sourceTypesByName[r.SourceType],
+		Description: expandTemplate(r.Description, event, nil),
+		EntryDate:   event.Timestamp,
+	}
+
+	for i, rl := range r.Lines {
+		scopes := []map[string]any{nil}
+		if rl.ForEach != "" {
+			items, err := payloadMaps(event.Payload, rl.ForEach)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			scopes = items
+		}
+
+		for j, item := range scopes {
+			line, ok, err := rl.evaluate(ctx, event, item, resolve)
+			if err != nil {
+				if rl.ForEach != "" {
+					return nil, fmt.Errorf("line %d: %s[%d]: %w", i+1, rl.ForEach, j, err)
+				}
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			if ok {
+				entry.Lines = append(entry.Lines, line)
+			}
+		}
+	}
+
+	entry.Lines = numberLines(entry.Lines)
+	return entry, nil
+}
+
+// evaluate builds one journal line. Returns ok=false when the line's condition
+// is false or its amount is zero.
+func (l *PostingRuleLine) evaluate(ctx context.Context, event Event, item map[string]any, resolve AccountResolver) (JournalLine, bool, error) {
+	lookup := scopedLookup(event, item)
+
+	cond, err := parseCondition(l.When)
+	if err != nil {
+		return JournalLine{}, false, err
+	}
+	if !cond.eval(lookup) {
+		return JournalLine{}, false, nil
+	}
+
+	expr, err := parseAmountExpr(l.Amount)
+	if err != nil {
+		return JournalLine{}, false, err
+	}
+	amount, err := expr.eval(lookup, l.Optional)
+	if err != nil {
+		return JournalLine{}, false, err
+	}
+	if amount == 0 {
+		return JournalLine{}, false, nil
+	}
+	if amount < 0 {
+		return JournalLine{}, false, fmt.Errorf("%w: amount %q evaluates to %s", ErrInvalidPayload, l.Amount, formatCentavos(amount))
+	}
+
+	accountID, err := l.resolveAccount(ctx, event, lookup, resolve)
+	if err != nil {
+		return JournalLine{}, false, err
+	}
+
+	memo := expandTemplate(l.Memo, event, item)
+	if l.Side == "debit" {
+		return debitLine(accountID, amount, memo), true, nil
+	}
+	return creditLine(accountID, amount, memo), true, nil
+}
+
+// resolveAccount returns the payload account ID when AccountKey is present,
+// otherwise resolves AccountCode for the event's workspace.
+func (l *PostingRuleLine) resolveAccount(ctx context.Context, event Event, lookup func(string) (any, bool), resolve AccountResolver) (string, error) {
+	if l.AccountKey != "" {
+		if raw, ok := lookup(l.AccountKey); ok && raw != nil {
+			id, isString := raw.(string)
+			if !isString {
+				return "", fmt.Errorf("%w: %q must be a string, got %T", ErrInvalidPayload, l.AccountKey, raw)
+			}
+			if strings.TrimSpace(id) != "" {
+				return id, nil
+			}
+		}
+		if l.AccountCode == "" {
+			return "", fmt.Errorf("%w: missing %q", ErrInvalidPayload, l.AccountKey)
+		}
+	}
+
+	if resolve == nil {
+		return "", fmt.Errorf("eventbus: no AccountResolver configured for account code %q", l.AccountCode)
+	}
+	id, err := resolve(ctx, event.WorkspaceID, l.AccountCode)
+	if err != nil {
+		return "", fmt.Errorf("resolving account code %q: %w", l.AccountCode, err)
+	}
+	if id == "" {
+		return "", fmt.Errorf("account code %q not found in workspace %q", l.AccountCode, event.WorkspaceID)
+	}
+	return id, nil
+}
+
+// scopedLookup resolves keys against the for_each item first, then the event
+// payload. Dotted keys traverse nested maps.
+func scopedLookup(event Event, item map[string]any) func(string) (any, bool) {
+	return func(key string) (any, bool) {
+		if item != nil {
+			if v, ok := lookupPath(item, key); ok {
+				return v, true
+			}
+		}
+		return lookupPath(event.Payload, key)
+	}
+}
+
+// lookupPath retrieves a nested value using a dot-separated path.
+func lookupPath(data map[string]any, path string) (any, bool) {
+	var current any = data
+	for _, part := range strings.Split(path, ".") {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		current, ok = m[part]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// templateVarRegex matches {{key}} variables in description and memo templates.
+var templateVarRegex = regexp.MustCompile(`{{\s*([\w.]+)\s*}}`)
+
+// expandTemplate replaces {{key}} with payload values. source_id, event_type
+// and workspace_id are always available. Unknown keys expand to "".
+func expandTemplate(tmpl string, event Event, item map[string]any) string {
+	if !strings.Contains(tmpl, "{{") {
+		return tmpl
+	}
+	lookup := scopedLookup(event, item)
+	return templateVarRegex.ReplaceAllStringFunc(tmpl, func(match string) string {
+		key := templateVarRegex.FindStringSubmatch(match)[1]
+		switch key {
+		case "source_id":
+			return event.SourceID
+		case "event_type":
+			return event.Type
+		case "workspace_id":
+			return event.WorkspaceID
+		}
+		if v, ok := lookup(key); ok && v != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return ""
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Amount expressions
+// ---------------------------------------------------------------------------
+
+// amountTerm is one signed operand of an amount expression.
+type amountTerm struct {
+	negative bool
+	key      string  // payload key, empty for literals
+	literal  float64 // used when key is empty
+}
+
+type amountExpr []amountTerm
+
+// parseAmountExpr parses expressions such as "gross_pay - gov_contributions".
+func parseAmountExpr(s string) (amountExpr, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, fmt.Errorf("amount is required")
+	}
+
+	var expr amountExpr
+	negative := false
+	expectOperand := true
+	for _, tok := range strings.Fields(strings.NewReplacer("+", " + ", "-", " - ").Replace(s)) {
+		switch {
+		case tok == "+" || tok == "-":
+			if expectOperand {
+				return nil, fmt.Errorf("amount %q: unexpected %q", s, tok)
+			}
+			negative = tok == "-"
+			expectOperand = true
+		default:
+			if !expectOperand {
+				return nil, fmt.Errorf("amount %q: missing operator before %q", s, tok)
+			}
+			term := amountTerm{negative: negative}
+			if f, err := strconv.ParseFloat(tok, 64); err == nil {
+				term.literal = f
+			} else {
+				term.key = tok
+			}
+			expr = append(expr, term)
+			expectOperand = false
+		}
+	}
+	if expectOperand {
+		return nil, fmt.Errorf("amount %q: trailing operator", s)
+	}
+	return expr, nil
+}
+
+// eval sums the expression in centavos.
+func (e amountExpr) eval(lookup func(string) (any, bool), optional bool) (int64, error) {
+	var total int64
+	for _, term := range e {
+		var value int64
+		if term.key == "" {
+			value = toCentavos(term.literal)
+		} else {
+			raw, ok := lookup(term.key)
+			if !ok || raw == nil {
+				if optional {
+					continue
+				}
+				return 0, fmt.Errorf("%w: missing %q", ErrInvalidPayload, term.key)
+			}
+			f, err := toFloat(raw)
+			if err != nil {
+				return 0, fmt.Errorf("%w: %q: %v", ErrInvalidPayload, term.key, err)
+			}
+			if f < 0 {
+				return 0, fmt.Errorf("%w: %q must not be negative", ErrInvalidPayload, term.key)
+			}
+			value = toCentavos(f)
+		}
+		if term.negative {
+			total -= value
+		} else {
+			total += value
+		}
+	}
+	return total, nil
+}
+
+// ---------------------------------------------------------------------------
+// Conditions
+// ---------------------------------------------------------------------------
+
+// condition is a parsed "when" clause.
+type condition struct {
+	key     string
+	negate  bool
+	op      string // "", "==" or "!="
+	literal string
+}
+
+// conditionCompareRegex matches `key == "value"` and `key != value`.
+var conditionCompareRegex = regexp.MustCompile(`^([\w.]+)\s*(==|!=)\s*(.+)$`)
+
+// parseCondition parses "", "flag", "!flag", `key == "value"` and `key != "value"`.
+func parseCondition(s string) (condition, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return condition{}, nil
+	}
+	if m := conditionCompareRegex.FindStringSubmatch(s); m != nil {
+		literal := strings.TrimSpace(m[3])
+		if unquoted, err := strconv.Unquote(literal); err == nil {
+			literal = unquoted
+		}
+		return condition{key: m[1], op: m[2], literal: literal}, nil
+	}
+	c := condition{key: s}
+	if strings.HasPrefix(s, "!") {
+		c.negate = true
+		c.key = strings.TrimSpace(s[1:])
+	}
+	if c.key == "" || strings.ContainsAny(c.key, " =!\"") {
+		return condition{}, fmt.Errorf("invalid condition %q", s)
+	}
+	return c, nil
+}
+
+// eval reports whether the condition holds. An empty condition is always true.
+func (c condition) eval(lookup func(string) (any, bool)) bool {
+	if c.key == "" {
+		return true
+	}
+	raw, ok := lookup(c.key)
+	switch c.op {
+	case "==":
+		return ok && fmt.Sprintf("%v", raw) == c.literal
+	case "!=":
+		return !ok || fmt.Sprintf("%v", raw) != c.literal
+	}
+	return truthy(raw, ok) != c.negate
+}
+
+// truthy reports whether a payload value counts as set.
+func truthy(v any, ok bool) bool {
+	if !ok || v == nil {
+		return false
+	}
+	switch t := v.(type) {
+	case bool:
+		return t
+	case string:
+		return t != "" && t != "false" && t != "0"
+	default:
+		if f, err := toFloat(v); err == nil {
+			return f != 0
+		}
+		return true
+	}
+}
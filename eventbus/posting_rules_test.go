@@ -0,0 +1,336 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDefaultPostingRules(t *testing.T) {
+	t.Parallel()
+
+	rules := DefaultPostingRules()
+	for _, eventType := range EventTypes() {
+		if rules.Rule(eventType) == nil {
+			t.Errorf("default rules missing %q", eventType)
+		}
+	}
+	if got, want := len(rules.Rules), len(EventTypes()); got != want {
+		t.Errorf("default rule count = %d, want %d", got, want)
+	}
+}
+
+func TestParsePostingRules(t *testing.T) {
+	t.Parallel()
+
+	t.Run("JSON rule set", func(t *testing.T) {
+		t.Parallel()
+
+		rules, err := ParsePostingRulesJSON([]byte(`{"rules": [{
+			"event_type": "revenue.completed",
+			"source_type": "revenue",
+			"description": "Sale {{invoice_no}}",
+			"lines": [
+				{"side": "debit", "account_code": "1110", "amount": "amount"},
+				{"side": "credit", "account_code": "4010", "amount": "amount", "memo": "Revenue for {{source_id}}"}
+			]
+		}]}`))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if rules.Rule("revenue.completed") == nil {
+			t.Fatal("rule not loaded")
+		}
+	})
+
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{
+			name: "unknown side",
+			yaml: `
+rules:
+  - event_type: a.b
+    source_type: manual
+    lines:
+      - {side: left, account_key: x, amount: amount}
+      - {side: credit, account_key: y, amount: amount}`,
+			wantErr: "side must be debit or credit",
+		},
+		{
+			name: "missing account",
+			yaml: `
+rules:
+  - event_type: a.b
+    source_type: manual
+    lines:
+      - {side: debit, amount: amount}
+      - {side: credit, account_key: y, amount: amount}`,
+			wantErr: "account_key or account_code is required",
+		},
+		{
+			name: "bad amount expression",
+			yaml: `
+rules:
+  - event_type: a.b
+    source_type: manual
+    lines:
+      - {side: debit, account_key: x, amount: "gross_pay -"}
+      - {side: credit, account_key: y, amount: amount}`,
+			wantErr: "trailing operator",
+		},
+		{
+			name: "duplicate event type",
+			yaml: `
+rules:
+  - event_type: a.b
+    source_type: manual
+    lines:
+      - {side: debit, account_key: x, amount: amount}
+      - {side: credit, account_key: y, amount: amount}
+  - event_type: a.b
+    source_type: manual
+    lines:
+      - {side: debit, account_key: x, amount: amount}
+      - {side: credit, account_key: y, amount: amount}`,
+			wantErr: "more than once",
+		},
+		{
+			name: "unknown source type",
+			yaml: `
+rules:
+  - event_type: a.b
+    source_type: sales
+    lines:
+      - {side: debit, account_key: x, amount: amount}
+      - {side: credit, account_key: y, amount: amount}`,
+			wantErr: "unknown source_type",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := ParsePostingRulesYAML([]byte(tt.yaml))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPostingRule_Evaluate(t *testing.T) {
+	t.Parallel()
+
+	rules, err := ParsePostingRulesYAML([]byte(`
+rules:
+  - event_type: expenditure.approved
+    source_type: expenditure
+    description: "Bill {{bill_no}} from {{supplier.name}}"
+    lines:
+      - side: debit
+        account_code: "6100"
+        amount: amount - discount
+        memo: "{{category}}"
+      - side: debit
+        account_code: "1150"
+        amount: vat
+        optional: true
+      - side: credit
+        when: 'status == "paid"'
+        account_code: "1110"
+        amount: amount - discount + vat
+      - side: credit
+        when: 'status != "paid"'
+        account_code: "2010"
+        amount: amount - discount + vat
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resolver := func(ctx context.Context, workspaceID, code string) (string, error) {
+		if workspaceID != "ws-1" {
+			return "", errors.New("unexpected workspace " + workspaceID)
+		}
+		return "acct-" + code, nil
+	}
+
+	event := Event{
+		Type:        "expenditure.approved",
+		SourceID:    "exp-1",
+		WorkspaceID: "ws-1",
+		Payload: map[string]any{
+			"bill_no":  "B-77",
+			"supplier": map[string]any{"name": "Meralco"},
+			"category": "Utilities",
+			"amount":   1000.0,
+			"discount": 100.0,
+			"vat":      108.0,
+			"status":   "paid",
+		},
+	}
+
+	entry, err := rules.Rule("expenditure.approved").Evaluate(context.Background(), event, resolver)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := entry.Validate(); err != nil {
+		t.Fatalf("entry should balance: %v", err)
+	}
+
+	if entry.Description != "Bill B-77 from Meralco" {
+		t.Errorf("Description = %q", entry.Description)
+	}
+	if len(entry.Lines) != 3 {
+		t.Fatalf("lines = %d, want 3", len(entry.Lines))
+	}
+	if entry.Lines[0].Debit != 90000 || entry.Lines[0].Memo != "Utilities" {
+		t.Errorf("expense line = %+v", entry.Lines[0])
+	}
+	if entry.Lines[2].AccountID != "acct-1110" || entry.Lines[2].Credit != 100800 {
+		t.Errorf("credit line = %+v, want cash for paid status", entry.Lines[2])
+	}
+
+	t.Run("condition selects the alternative account", func(t *testing.T) {
+		unpaid := event
+		unpaid.Payload = map[string]any{"amount": 500.0, "discount": 0.0, "vat": 0.0, "status": "open"}
+
+		entry, err := rules.Rule("expenditure.approved").Evaluate(context.Background(), unpaid, resolver)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(entry.Lines) != 2 || entry.Lines[1].AccountID != "acct-2010" {
+			t.Errorf("lines = %+v, want AP credit", entry.Lines)
+		}
+	})
+
+	t.Run("account code without resolver fails", func(t *testing.T) {
+		_, err := rules.Rule("expenditure.approved").Evaluate(context.Background(), event, nil)
+		if err == nil || !strings.Contains(err.Error(), "AccountResolver") {
+			t.Errorf("error = %v, want resolver error", err)
+		}
+	})
+}
+
+func TestJournalPoster_WorkspaceRules(t *testing.T) {
+	t.Parallel()
+
+	override, err := ParsePostingRulesYAML([]byte(`
+rules:
+  - event_type: collection.received
+    source_type: collection
+    description: Collection
+    lines:
+      - {side: debit, account_code: "1030", amount: amount}
+      - {side: credit, account_code: "1210", amount: amount}
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	poster := NewJournalPoster(&JournalPosterDeps{
+		WorkspaceRules: func(ctx context.Context, workspaceID string) (*PostingRuleSet, error) {
+			if workspaceID == "clinic" {
+				return override, nil
+			}
+			return nil, nil
+		},
+		ResolveAccount: func(ctx context.Context, workspaceID, code string) (string, error) {
+			return workspaceID + ":" + code, nil
+		},
+	})
+
+	payload := map[string]any{"amount": 250.0, "account_cash": "cash", "account_ar": "ar"}
+
+	entry, err := poster.BuildEntry(context.Background(), Event{
+		Type: EventTypeCollectionReceived, SourceID: "c-1", WorkspaceID: "clinic", Payload: payload,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if entry.Lines[0].AccountID != "clinic:1030" {
+		t.Errorf("override debit account = %q, want clinic:1030", entry.Lines[0].AccountID)
+	}
+
+	entry, err = poster.BuildEntry(context.Background(), Event{
+		Type: EventTypeCollectionReceived, SourceID: "c-2", WorkspaceID: "salon", Payload: payload,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if entry.Lines[0].AccountID != "cash" {
+		t.Errorf("default debit account = %q, want cash", entry.Lines[0].AccountID)
+	}
+}
+
+func TestJournalPoster_AccountCodeFallback(t *testing.T) {
+	t.Parallel()
+
+	override, err := ParsePostingRulesYAML([]byte(`
+rules:
+  - event_type: collection.received
+    source_type: collection
+    description: Collection
+    lines:
+      - {side: debit, account_key: account_cash, account_code: "1030", amount: amount}
+      - {side: credit, account_key: account_ar, account_code: "1210", amount: amount}
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	poster := NewJournalPoster(&JournalPosterDeps{
+		WorkspaceRules: func(ctx context.Context, workspaceID string) (*PostingRuleSet, error) {
+			if workspaceID == "clinic" {
+				return override, nil
+			}
+			return nil, nil
+		},
+		ResolveAccount: func(ctx context.Context, workspaceID, code string) (string, error) {
+			return workspaceID + ":" + code, nil
+		},
+	})
+
+	// The typed payload leaves the account fields empty; they are omitted
+	// from Event.Payload and the rule resolves its account codes instead.
+	event, err := NewEvent("c-1", CollectionReceivedPayload{Amount: 25000})
+	if err != nil {
+		t.Fatalf("NewEvent: %v", err)
+	}
+	event.WorkspaceID = "clinic"
+
+	entry, err := poster.BuildEntry(context.Background(), event)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if entry.Lines[0].AccountID != "clinic:1030" || entry.Lines[1].AccountID != "clinic:1210" {
+		t.Errorf("accounts = %q, %q; want clinic:1030, clinic:1210", entry.Lines[0].AccountID, entry.Lines[1].AccountID)
+	}
+
+	t.Run("payload account wins over the code", func(t *testing.T) {
+		event, err := NewEvent("c-2", CollectionReceivedPayload{Amount: 25000, AccountCash: "cash"})
+		if err != nil {
+			t.Fatalf("NewEvent: %v", err)
+		}
+		event.WorkspaceID = "clinic"
+
+		entry, err := poster.BuildEntry(context.Background(), event)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if entry.Lines[0].AccountID != "cash" || entry.Lines[1].AccountID != "clinic:1210" {
+			t.Errorf("accounts = %q, %q; want cash, clinic:1210", entry.Lines[0].AccountID, entry.Lines[1].AccountID)
+		}
+	})
+
+	t.Run("default rules need the account fields", func(t *testing.T) {
+		event.WorkspaceID = "salon"
+		if _, err := poster.BuildEntry(context.Background(), event); !errors.Is(err, ErrInvalidPayload) {
+			t.Errorf("error = %v, want ErrInvalidPayload", err)
+		}
+	})
+}
@@ -77,8 +77,10 @@ type IncomeStatementLabels struct {
 
 // BalanceSheetLabels holds translatable strings for the Balance Sheet page.
 type BalanceSheetLabels struct {
-	Title    string `json:"title"`
-	Subtitle string `json:"subtitle"`
+	Title          string `json:"title"`
+	Subtitle       string `json:"subtitle"`
+	AsOf           string `json:"asOf"`
+	AsOfDateFormat string `json:"asOfDateFormat"`
 }
 
 // CashFlowLabels holds translatable strings for the Cash Flow Statement page.
@@ -555,6 +557,7 @@ type AccountLabels struct {
 	Detail        AccountDetailLabels        `json:"detail"`
 	Templates     AccountTemplatesLabels     `json:"templates"`
 	GeneralLedger AccountGeneralLedgerLabels `json:"generalLedger"`
+	TrialBalance  AccountTrialBalanceLabels  `json:"trialBalance"`
 }
 
 // AccountTemplatesLabels holds translatable strings for the Account Templates settings page.
@@ -597,6 +600,7 @@ type AccountGeneralLedgerLabels struct {
 	Apply                 string `json:"apply"`
 	Clear                 string `json:"clear"`
 	Print                 string `json:"print"`
+	DownloadPDF           string `json:"downloadPdf"`
 	SelectAccountMessage  string `json:"selectAccountMessage"`
 	NoTransactionsMessage string `json:"noTransactionsMessage"`
 	DateRangeSeparator    string `json:"dateRangeSeparator"`
@@ -608,6 +612,13 @@ type AccountGeneralLedgerLabels struct {
 	NoTransactionsDetail  string `json:"noTransactionsDetail"`
 }
 
+// AccountTrialBalanceLabels holds translatable strings for the Trial Balance report.
+type AccountTrialBalanceLabels struct {
+	Title          string `json:"title"`
+	AsOf           string `json:"asOf"`
+	AsOfDateFormat string `json:"asOfDateFormat"`
+}
+
 type AccountPageLabels struct {
 	Heading string `json:"heading"`
 	Caption string `json:"caption"`
@@ -907,6 +918,7 @@ func DefaultAccountLabels() AccountLabels {
 			Apply:                 "Apply",
 			Clear:                 "Clear",
 			Print:                 "Print",
+			DownloadPDF:           "PDF",
 			SelectAccountMessage:  "Select an account above to view its detailed transaction history.",
 			NoTransactionsMessage: "No transactions found for the selected account and date range.",
 			DateRangeSeparator:    "to",
@@ -917,6 +929,11 @@ func DefaultAccountLabels() AccountLabels {
 			NoTransactionsTitle:   "No transactions",
 			NoTransactionsDetail:  "No journal entries found for this account in the selected date range.",
 		},
+		TrialBalance: AccountTrialBalanceLabels{
+			Title:          "Trial Balance",
+			AsOf:           "As of %s",
+			AsOfDateFormat: "January 2, 2006",
+		},
 	}
 }
 
@@ -936,6 +953,35 @@ type JournalLabels struct {
 	Form    JournalFormLabels    `json:"form"`
 	Detail  JournalDetailLabels  `json:"detail"`
 	Confirm JournalConfirmLabels `json:"confirm"`
+	Preview JournalPreviewLabels `json:"preview"`
+}
+
+// JournalPreviewLabels holds translatable strings for the posting preview page,
+// which dry-runs auto-posting rules against recent events.
+type JournalPreviewLabels struct {
+	Heading        string `json:"heading"`
+	Subtitle       string `json:"subtitle"`
+	StartDate      string `json:"startDate"`
+	EndDate        string `json:"endDate"`
+	Refresh        string `json:"refresh"`
+	SelectAll      string `json:"selectAll"`
+	ApproveAll     string `json:"approveAll"`
+	ApproveChecked string `json:"approveChecked"`
+	ConfirmApprove string `json:"confirmApprove"`
+	Event          string `json:"event"`
+	Source         string `json:"source"`
+	Balanced       string `json:"balanced"`
+	Unbalanced     string `json:"unbalanced"`
+	Failed         string `json:"failed"`
+	Reversal       string `json:"reversal"`
+	EmptyTitle     string `json:"emptyTitle"`
+	EmptyMessage   string `json:"emptyMessage"`
+	NothingChosen  string `json:"nothingChosen"`
+	ApproveError   string `json:"approveError"`
+	NotWired       string `json:"notWired"`
+	AlreadyPosted  string `json:"alreadyPosted"`
+	AllPosted      string `json:"allPosted"`
+	InvalidRequest string `json:"invalidRequest"`
 }
 
 // JournalConfirmLabels holds confirmation dialog strings for journal actions.
@@ -1178,6 +1224,31 @@ func DefaultJournalLabels() JournalLabels {
 			Delete:  "Are you sure you want to delete this journal entry? This action cannot be undone.",
 			Reverse: "Are you sure you want to reverse this journal entry? A reversing entry will be created.",
 		},
+		Preview: JournalPreviewLabels{
+			Heading:        "Posting Preview",
+			Subtitle:       "Review the journal entries recent events would create before they reach the ledger",
+			StartDate:      "From",
+			EndDate:        "To",
+			Refresh:        "Preview",
+			SelectAll:      "Select all",
+			ApproveAll:     "Approve All",
+			ApproveChecked: "Approve Selected",
+			ConfirmApprove: "Create draft journal entries for the approved events?",
+			Event:          "Event",
+			Source:         "Source",
+			Balanced:       "Balanced",
+			Unbalanced:     "Unbalanced",
+			Failed:         "Cannot post",
+			Reversal:       "Reversal",
+			EmptyTitle:     "No events to preview",
+			EmptyMessage:   "No accounting events were recorded in this date range.",
+			NothingChosen:  "Select at least one balanced entry to approve",
+			ApproveError:   "Failed to create draft journal entries",
+			NotWired:       "Posting preview is not configured",
+			AlreadyPosted:  "Already posted",
+			AllPosted:      "The selected entries have already been posted",
+			InvalidRequest: "Invalid request",
+		},
 	}
 }
 
@@ -2941,6 +3012,8 @@ type ReceivablesAgingReportLabels struct {
 	ExportFilename     string `json:"export_filename"`
 	FilterAsOfDate     string `json:"filter_as_of_date"`
 	FilterRowDimension string `json:"filter_row_dimension"`
+	AsOf               string `json:"as_of"`
+	AsOfDateFormat     string `json:"as_of_date_format"`
 	DimensionClient         string `json:"dimension_client"`
 	DimensionClientCategory string `json:"dimension_client_category"`
 	DimensionLocation       string `json:"dimension_location"`
@@ -2982,6 +3055,8 @@ type PayablesAgingReportLabels struct {
 	ExportFilename     string `json:"export_filename"`
 	FilterAsOfDate     string `json:"filter_as_of_date"`
 	FilterRowDimension string `json:"filter_row_dimension"`
+	AsOf               string `json:"as_of"`
+	AsOfDateFormat     string `json:"as_of_date_format"`
 	DimensionSupplier             string `json:"dimension_supplier"`
 	DimensionSupplierCategory     string `json:"dimension_supplier_category"`
 	DimensionLocation             string `json:"dimension_location"`
@@ -16,8 +16,10 @@ const (
 	ReportsDisbursementReportExportURL = "/app/reports/disbursement-report/export"
 	ReportsReceivablesAgingReportURL       = "/app/reports/receivables-aging"
 	ReportsReceivablesAgingReportExportURL = "/app/reports/receivables-aging/export"
+	ReportsReceivablesAgingReportPDFURL    = "/app/reports/receivables-aging/export.pdf"
 	ReportsPayablesAgingReportURL          = "/app/suppliers/reports/payables-aging"
 	ReportsPayablesAgingReportExportURL    = "/app/suppliers/reports/payables-aging/export"
+	ReportsPayablesAgingReportPDFURL       = "/app/suppliers/reports/payables-aging/export.pdf"
 	ReportsCollectionSummaryReportURL       = "/app/reports/collection-summary"
 	ReportsCollectionSummaryReportExportURL = "/app/reports/collection-summary/export"
 
@@ -66,9 +68,15 @@ const (
 	JournalReverseURL = "/action/ledger/journals/reverse/{id}"
 	JournalDeleteURL  = "/action/ledger/journals/delete"
 
+	// Ledger — Journal Posting Preview (dry-run of auto-posting rules)
+	JournalPreviewURL        = "/app/ledger/journals/preview"
+	JournalPreviewApproveURL = "/action/ledger/journals/preview/approve"
+
 	// Ledger — Accounting Statements (internal tools)
-	LedgerGeneralLedgerURL = "/app/ledger/reports/general-ledger"
-	LedgerTrialBalanceURL  = "/app/ledger/reports/trial-balance"
+	LedgerGeneralLedgerURL    = "/app/ledger/reports/general-ledger"
+	LedgerGeneralLedgerPDFURL = "/app/ledger/reports/general-ledger/export.pdf"
+	LedgerTrialBalanceURL     = "/app/ledger/reports/trial-balance"
+	LedgerTrialBalancePDFURL  = "/app/ledger/reports/trial-balance/export.pdf"
 
 	// Ledger — Fiscal Periods / Settings
 	FiscalPeriodListURL   = "/app/ledger/settings/fiscal-periods"
@@ -83,10 +91,12 @@ const (
 	RecurringTemplatesURL = "/app/ledger/settings/recurring"
 
 	// Reports — Financial Statements (business-stakeholder output)
-	ReportsIncomeStatementURL = "/app/reports/income-statement"
-	ReportsBalanceSheetURL    = "/app/reports/balance-sheet"
-	ReportsCashFlowURL        = "/app/reports/cash-flow"
-	ReportsEquityChangesURL   = "/app/reports/equity-changes"
+	ReportsIncomeStatementURL    = "/app/reports/income-statement"
+	ReportsIncomeStatementPDFURL = "/app/reports/income-statement/export.pdf"
+	ReportsBalanceSheetURL       = "/app/reports/balance-sheet"
+	ReportsBalanceSheetPDFURL    = "/app/reports/balance-sheet/export.pdf"
+	ReportsCashFlowURL           = "/app/reports/cash-flow"
+	ReportsEquityChangesURL      = "/app/reports/equity-changes"
 
 	// Funding — Loans
 	LoanListURL         = "/app/funding/loans/list/{status}"
@@ -25,10 +25,12 @@ type ReportsRoutes struct {
 	ExpensesURL    string `json:"expenses_url"`
 	NetProfitURL   string `json:"net_profit_url"`
 	// Financial Statements (NEW — derived from ledger, exposed to business stakeholders)
-	IncomeStatementURL string `json:"income_statement_url"`
-	BalanceSheetURL    string `json:"balance_sheet_url"`
-	CashFlowURL        string `json:"cash_flow_url"`
-	EquityChangesURL   string `json:"equity_changes_url"`
+	IncomeStatementURL    string `json:"income_statement_url"`
+	IncomeStatementPDFURL string `json:"income_statement_pdf_url"`
+	BalanceSheetURL       string `json:"balance_sheet_url"`
+	BalanceSheetPDFURL    string `json:"balance_sheet_pdf_url"`
+	CashFlowURL           string `json:"cash_flow_url"`
+	EquityChangesURL      string `json:"equity_changes_url"`
 	// Revenue Report pivot table
 	RevenueReportURL       string `json:"revenue_report_url"`
 	RevenueReportExportURL string `json:"revenue_report_export_url"`
@@ -41,9 +43,11 @@ type ReportsRoutes struct {
 	// Receivables Aging Report
 	ReceivablesAgingReportURL       string `json:"receivables_aging_report_url"`
 	ReceivablesAgingReportExportURL string `json:"receivables_aging_report_export_url"`
+	ReceivablesAgingReportPDFURL    string `json:"receivables_aging_report_pdf_url"`
 	// Payables Aging Report
 	PayablesAgingReportURL       string `json:"payables_aging_report_url"`
 	PayablesAgingReportExportURL string `json:"payables_aging_report_export_url"`
+	PayablesAgingReportPDFURL    string `json:"payables_aging_report_pdf_url"`
 	// Collection Summary Report pivot table
 	CollectionSummaryReportURL       string `json:"collection_summary_report_url"`
 	CollectionSummaryReportExportURL string `json:"collection_summary_report_export_url"`
@@ -59,7 +63,9 @@ func DefaultReportsRoutes() ReportsRoutes {
 		ExpensesURL:            ReportsExpensesURL,
 		NetProfitURL:           ReportsNetProfitURL,
 		IncomeStatementURL:     ReportsIncomeStatementURL,
+		IncomeStatementPDFURL:  ReportsIncomeStatementPDFURL,
 		BalanceSheetURL:        ReportsBalanceSheetURL,
+		BalanceSheetPDFURL:     ReportsBalanceSheetPDFURL,
 		CashFlowURL:            ReportsCashFlowURL,
 		EquityChangesURL:       ReportsEquityChangesURL,
 		RevenueReportURL:       ReportsRevenueReportURL,
@@ -70,8 +76,10 @@ func DefaultReportsRoutes() ReportsRoutes {
 		DisbursementReportExportURL: ReportsDisbursementReportExportURL,
 		ReceivablesAgingReportURL:       ReportsReceivablesAgingReportURL,
 		ReceivablesAgingReportExportURL: ReportsReceivablesAgingReportExportURL,
+		ReceivablesAgingReportPDFURL:    ReportsReceivablesAgingReportPDFURL,
 		PayablesAgingReportURL:          ReportsPayablesAgingReportURL,
 		PayablesAgingReportExportURL:    ReportsPayablesAgingReportExportURL,
+		PayablesAgingReportPDFURL:       ReportsPayablesAgingReportPDFURL,
 		CollectionSummaryReportURL:       ReportsCollectionSummaryReportURL,
 		CollectionSummaryReportExportURL: ReportsCollectionSummaryReportExportURL,
 	}
@@ -87,7 +95,9 @@ func (r ReportsRoutes) RouteMap() map[string]string {
 		"reports.expenses":              r.ExpensesURL,
 		"reports.net_profit":            r.NetProfitURL,
 		"reports.income_statement":      r.IncomeStatementURL,
+		"reports.income_statement_pdf":  r.IncomeStatementPDFURL,
 		"reports.balance_sheet":         r.BalanceSheetURL,
+		"reports.balance_sheet_pdf":     r.BalanceSheetPDFURL,
 		"reports.cash_flow":             r.CashFlowURL,
 		"reports.equity_changes":        r.EquityChangesURL,
 		"reports.revenue_report":        r.RevenueReportURL,
@@ -98,8 +108,10 @@ func (r ReportsRoutes) RouteMap() map[string]string {
 		"reports.disbursement_report_export": r.DisbursementReportExportURL,
 		"reports.receivables_aging_report":        r.ReceivablesAgingReportURL,
 		"reports.receivables_aging_report_export": r.ReceivablesAgingReportExportURL,
+		"reports.receivables_aging_report_pdf":    r.ReceivablesAgingReportPDFURL,
 		"reports.payables_aging_report":           r.PayablesAgingReportURL,
 		"reports.payables_aging_report_export":    r.PayablesAgingReportExportURL,
+		"reports.payables_aging_report_pdf":       r.PayablesAgingReportPDFURL,
 		"reports.collection_summary_report":        r.CollectionSummaryReportURL,
 		"reports.collection_summary_report_export": r.CollectionSummaryReportExportURL,
 	}
@@ -229,40 +241,46 @@ func (r AccountRoutes) RouteMap() map[string]string {
 
 // JournalRoutes holds route paths for Journal Entry views.
 type JournalRoutes struct {
-	ActiveNav    string `json:"active_nav"`
-	ActiveSubNav string `json:"active_sub_nav"`
-	ListURL      string `json:"list_url"`
-	DetailURL    string `json:"detail_url"`
-	AddURL       string `json:"add_url"`
-	EditURL      string `json:"edit_url"`
-	PostURL      string `json:"post_url"`
-	ReverseURL   string `json:"reverse_url"`
-	DeleteURL    string `json:"delete_url"`
+	ActiveNav         string `json:"active_nav"`
+	ActiveSubNav      string `json:"active_sub_nav"`
+	ListURL           string `json:"list_url"`
+	DetailURL         string `json:"detail_url"`
+	AddURL            string `json:"add_url"`
+	EditURL           string `json:"edit_url"`
+	PostURL           string `json:"post_url"`
+	ReverseURL        string `json:"reverse_url"`
+	DeleteURL         string `json:"delete_url"`
+	PreviewURL        string `json:"preview_url"`
+	PreviewApproveURL string `json:"preview_approve_url"`
 }
 
 func DefaultJournalRoutes() JournalRoutes {
 	return JournalRoutes{
-		ActiveNav:    "ledger",
-		ActiveSubNav: "journals-draft",
-		ListURL:      JournalListURL,
-		DetailURL:    JournalDetailURL,
-		AddURL:       JournalAddURL,
-		EditURL:      JournalEditURL,
-		PostURL:      JournalPostURL,
-		ReverseURL:   JournalReverseURL,
-		DeleteURL:    JournalDeleteURL,
+		ActiveNav:         "ledger",
+		ActiveSubNav:      "journals-draft",
+		ListURL:           JournalListURL,
+		DetailURL:         JournalDetailURL,
+		AddURL:            JournalAddURL,
+		EditURL:           JournalEditURL,
+		PostURL:           JournalPostURL,
+		ReverseURL:        JournalReverseURL,
+		DeleteURL:         JournalDeleteURL,
+		PreviewURL:        JournalPreviewURL,
+		PreviewApproveURL: JournalPreviewApproveURL,
 	}
 }
 
 func (r JournalRoutes) RouteMap() map[string]string {
 	return map[string]string{
-		"ledger.journal.list":    r.ListURL,
-		"ledger.journal.detail":  r.DetailURL,
-		"ledger.journal.add":     r.AddURL,
-		"ledger.journal.edit":    r.EditURL,
-		"ledger.journal.post":    r.PostURL,
-		"ledger.journal.reverse": r.ReverseURL,
-		"ledger.journal.delete":  r.DeleteURL,
+		"ledger.journal.list":            r.ListURL,
+		"ledger.journal.detail":          r.DetailURL,
+		"ledger.journal.add":             r.AddURL,
+		"ledger.journal.edit":            r.EditURL,
+		"ledger.journal.post":            r.PostURL,
+		"ledger.journal.reverse":         r.ReverseURL,
+		"ledger.journal.delete":          r.DeleteURL,
+		"ledger.journal.preview":         r.PreviewURL,
+		"ledger.journal.preview_approve": r.PreviewApproveURL,
 	}
 }
 
@@ -273,23 +291,29 @@ func (r JournalRoutes) RouteMap() map[string]string {
 // LedgerStatementRoutes holds route paths for accounting statement views
 // (General Ledger, Trial Balance — internal accounting tools, not business reports).
 type LedgerStatementRoutes struct {
-	ActiveNav        string `json:"active_nav"`
-	GeneralLedgerURL string `json:"general_ledger_url"`
-	TrialBalanceURL  string `json:"trial_balance_url"`
+	ActiveNav           string `json:"active_nav"`
+	GeneralLedgerURL    string `json:"general_ledger_url"`
+	GeneralLedgerPDFURL string `json:"general_ledger_pdf_url"`
+	TrialBalanceURL     string `json:"trial_balance_url"`
+	TrialBalancePDFURL  string `json:"trial_balance_pdf_url"`
 }
 
 func DefaultLedgerStatementRoutes() LedgerStatementRoutes {
 	return LedgerStatementRoutes{
-		ActiveNav:        "ledger",
-		GeneralLedgerURL: LedgerGeneralLedgerURL,
-		TrialBalanceURL:  LedgerTrialBalanceURL,
+		ActiveNav:           "ledger",
+		GeneralLedgerURL:    LedgerGeneralLedgerURL,
+		GeneralLedgerPDFURL: LedgerGeneralLedgerPDFURL,
+		TrialBalanceURL:     LedgerTrialBalanceURL,
+		TrialBalancePDFURL:  LedgerTrialBalancePDFURL,
 	}
 }
 
 func (r LedgerStatementRoutes) RouteMap() map[string]string {
 	return map[string]string{
-		"ledger.statement.general_ledger": r.GeneralLedgerURL,
-		"ledger.statement.trial_balance":  r.TrialBalanceURL,
+		"ledger.statement.general_ledger":     r.GeneralLedgerURL,
+		"ledger.statement.general_ledger_pdf": r.GeneralLedgerPDFURL,
+		"ledger.statement.trial_balance":      r.TrialBalanceURL,
+		"ledger.statement.trial_balance_pdf":  r.TrialBalancePDFURL,
 	}
 }
 
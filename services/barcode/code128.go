@@ -0,0 +1,152 @@
+package barcode
+
+import (
+	"errors"
+	"fmt"
+)
+
+// code128Patterns are the bar and space widths, in modules, of the Code 128
+// symbols 0 to 106, starting with a bar. 103 to 105 are the start codes A,
+// B and C; 106 is the stop code, which ends with a final bar.
+var code128Patterns = [107]string{
+	"212222", "222122", "222221", "121223", "121322", "131222", "122213", "122312", "132212", "221213",
+	"221312", "231212", "112232", "122132", "122231", "113222", "123122", "123221", "223211", "221132",
+	"221231", "213212", "223112", "312131", "311222", "321122", "321221", "312212", "322112", "322211",
+	"212123", "212321", "232121", "111323", "131123", "131321", "112313", "132113", "132311", "211313",
+	"231113", "231311", "112133", "112331", "132131", "113123", "113321", "133121", "313121", "211331",
+	"231131", "213113", "213311", "213131", "311123", "311321", "331121", "312113", "312311", "332111",
+	"314111", "221411", "431111", "111224", "111422", "121124", "121421", "141122", "141221", "112214",
+	"112412", "122114", "122411", "142112", "142211", "241211", "221114", "413111", "241112", "134111",
+	"111242", "121142", "121241", "114212", "124112", "124211", "411212", "421112", "421211", "212141",
+	"214121", "412121", "111143", "111341", "131141", "114113", "114311", "411113", "411311", "113141",
+	"114131", "311141", "411131", "211412", "211214", "211232", "2331112",
+}
+
+// Code 128 symbol values with a special meaning.
+const (
+	code128CodeC  = 99
+	code128CodeB  = 100
+	code128CodeA  = 101
+	code128StartA = 103
+	code128StartB = 104
+	code128StartC = 105
+	code128Stop   = 106
+)
+
+// Code128QuietZone is the light margin, in modules, that scanners need on
+// either side of a Code 128 barcode. PNG includes it.
+const Code128QuietZone = 10
+
+// Barcode is an encoded one-dimensional barcode.
+type Barcode struct {
+	// Content is the encoded text.
+	Content string
+	// Symbols are the symbol values, from the start code to the stop code.
+	Symbols []int
+
+	bars []bool // one per module; true is a bar
+}
+
+// Width is the width of the barcode in modules, without the quiet zone.
+func (b *Barcode) Width() int { return len(b.bars) }
+
+// Bar reports whether module x is a bar. Modules outside the barcode are
+// spaces.
+func (b *Barcode) Bar(x int) bool { return x >= 0 && x < len(b.bars) && b.bars[x] }
+
+// EncodeCode128 encodes ASCII content as a Code 128 barcode. Runs of
+// digits are packed two to a symbol (code set C), which keeps document
+// numbers such as OR-000123 short; letters use code set B and control
+// characters code set A.
+func EncodeCode128(content string) (*Barcode, error) {
+	if content == "" {
+		return nil, errors.New("barcode: empty Code 128 content")
+	}
+	for i := 0; i < len(content); i++ {
+		if content[i] > 127 {
+			return nil, fmt.Errorf("barcode: Code 128 encodes ASCII only, found %q", content[i:])
+		}
+	}
+
+	var symbols []int
+	set := 0 // code set in use: 'A', 'B' or 'C'
+	for i := 0; i < len(content); {
+		if run := digitRun(content[i:]); run >= 4 || run >= 2 && run == len(content)-i && set != 'A' && set != 'B' {
+			if run%2 == 1 {
+				// Encode the odd digit in the current set so the rest pairs up.
+				if set == 0 {
+					set = 'B'
+					symbols = append(symbols, code128StartB)
+				}
+				symbols = append(symbols, code128Value(set, content[i]))
+				i++
+				run--
+			}
+			symbols = appendSwitch(symbols, &set, 'C')
+			for ; run > 0; run -= 2 {
+				symbols = append(symbols, int(content[i]-'0')*10+int(content[i+1]-'0'))
+				i += 2
+			}
+			continue
+		}
+
+		c := content[i]
+		switch {
+		case c < 32:
+			symbols = appendSwitch(symbols, &set, 'A')
+		case c >= 96 || set != 'A':
+			symbols = appendSwitch(symbols, &set, 'B')
+		}
+		symbols = append(symbols, code128Value(set, c))
+		i++
+	}
+
+	checksum := symbols[0]
+	for i, s := range symbols[1:] {
+		checksum += (i + 1) * s
+	}
+	symbols = append(symbols, checksum%103, code128Stop)
+
+	b := &Barcode{Content: content, Symbols: symbols}
+	for _, s := range symbols {
+		for i, w := range code128Patterns[s] {
+			for range int(w - '0') {
+				b.bars = append(b.bars, i%2 == 0)
+			}
+		}
+	}
+	return b, nil
+}
+
+// digitRun returns the number of leading ASCII digits of s.
+func digitRun(s string) int {
+	n := 0
+	for n < len(s) && s[n] >= '0' && s[n] <= '9' {
+		n++
+	}
+	return n
+}
+
+// appendSwitch starts or switches to code set to, if needed.
+func appendSwitch(symbols []int, set *int, to int) []int {
+	if *set == to {
+		return symbols
+	}
+	start := map[int]int{'A': code128StartA, 'B': code128StartB, 'C': code128StartC}
+	code := map[int]int{'A': code128CodeA, 'B': code128CodeB, 'C': code128CodeC}
+	if *set == 0 {
+		symbols = append(symbols, start[to])
+	} else {
+		symbols = append(symbols, code[to])
+	}
+	*set = to
+	return symbols
+}
+
+// code128Value returns the value of an ASCII character in code set A or B.
+func code128Value(set int, c byte) int {
+	if set == 'A' && c < 32 {
+		return int(c) + 64
+	}
+	return int(c) - 32
+}
@@ -0,0 +1,105 @@
+package barcode
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"testing"
+)
+
+func TestCode128Patterns(t *testing.T) {
+	seen := make(map[string]int)
+	for i, p := range code128Patterns {
+		sum := 0
+		for _, w := range p {
+			sum += int(w - '0')
+		}
+		want := 11
+		if i == code128Stop {
+			want = 13
+		}
+		if sum != want {
+			t.Errorf("pattern %d (%s) is %d modules, want %d", i, p, sum, want)
+		}
+		if j, dup := seen[p]; dup {
+			t.Errorf("patterns %d and %d are both %s", j, i, p)
+		}
+		seen[p] = i
+	}
+}
+
+func TestEncodeCode128(t *testing.T) {
+	tests := []struct {
+		content string
+		symbols string // without the checksum and stop code
+	}{
+		// Code set B throughout.
+		{"PJJ123C", "[104 48 42 42 17 18 19 35]"},
+		// A long digit run switches to code set C.
+		{"OR-000123", "[104 47 50 13 99 0 1 23]"},
+		// An odd run leaves its first digit in code set B.
+		{"AST-12345", "[104 33 51 52 13 17 99 23 45]"},
+		// Digits only start in code set C.
+		{"2026", "[105 20 26]"},
+		{"12", "[105 12]"},
+		// Back to code set B after digits, and code set A for controls.
+		{"1234ab\t", "[105 12 34 100 65 66 101 73]"},
+	}
+	for _, tt := range tests {
+		b, err := EncodeCode128(tt.content)
+		if err != nil {
+			t.Fatalf("EncodeCode128(%q): %v", tt.content, err)
+		}
+		n := len(b.Symbols)
+		if got := fmt.Sprint(b.Symbols[:n-2]); got != tt.symbols {
+			t.Errorf("EncodeCode128(%q) = %s, want %s", tt.content, got, tt.symbols)
+		}
+		sum := b.Symbols[0]
+		for i, s := range b.Symbols[1 : n-2] {
+			sum += (i + 1) * s
+		}
+		if b.Symbols[n-2] != sum%103 || b.Symbols[n-1] != code128Stop {
+			t.Errorf("EncodeCode128(%q) ends %v, want checksum %d and stop", tt.content, b.Symbols[n-2:], sum%103)
+		}
+		if want := 11*n + 2; b.Width() != want {
+			t.Errorf("EncodeCode128(%q) is %d modules, want %d", tt.content, b.Width(), want)
+		}
+		if !b.Bar(0) || !b.Bar(b.Width()-1) || b.Bar(-1) || b.Bar(b.Width()) {
+			t.Errorf("EncodeCode128(%q) does not start and end with a bar", tt.content)
+		}
+	}
+
+	for _, bad := range []string{"", "café"} {
+		if _, err := EncodeCode128(bad); err == nil {
+			t.Errorf("EncodeCode128(%q) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestCode128_PNG(t *testing.T) {
+	data, err := Code128("AST-2026-0042")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decoding PNG: %v", err)
+	}
+	b, _ := EncodeCode128("AST-2026-0042")
+	if want := (b.Width() + 2*Code128QuietZone) * code128ModuleWidth; img.Bounds().Dx() != want || img.Bounds().Dy() != code128Height {
+		t.Errorf("PNG is %v, want %dx%d", img.Bounds(), want, code128Height)
+	}
+	// Reading the middle row back gives the module widths of the symbols.
+	x := Code128QuietZone * code128ModuleWidth
+	for i, s := range b.Symbols {
+		for j, w := range code128Patterns[s] {
+			for range int(w-'0') * code128ModuleWidth {
+				r, _, _, _ := img.At(x, code128Height/2).RGBA()
+				if bar := r == 0; bar != (j%2 == 0) {
+					t.Fatalf("symbol %d: pixel %d has the wrong colour", i, x)
+				}
+				x++
+			}
+		}
+	}
+}
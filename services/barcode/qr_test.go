@@ -0,0 +1,286 @@
+package barcode
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// readFormat reads the first copy of the format information of q and
+// returns the level and mask it encodes.
+func readFormat(t *testing.T, q *QRCode) (Level, int) {
+	t.Helper()
+	var bits int
+	pos := [][2]int{{8, 0}, {8, 1}, {8, 2}, {8, 3}, {8, 4}, {8, 5}, {8, 7}, {8, 8}, {7, 8}, {5, 8}, {4, 8}, {3, 8}, {2, 8}, {1, 8}, {0, 8}}
+	for i, p := range pos {
+		if q.Dark(p[0], p[1]) {
+			bits |= 1 << i
+		}
+	}
+	// The second copy must match.
+	for i := range 15 {
+		x, y := q.Size-1-i, 8
+		if i >= 8 {
+			x, y = 8, q.Size-15+i
+		}
+		if q.Dark(x, y) != bit(bits, i) {
+			t.Fatalf("format copies differ at bit %d", i)
+		}
+	}
+	bits ^= 0x5412
+	for level, fb := range formatBits {
+		for mask := range 8 {
+			data := fb<<3 | mask
+			rem := data
+			for range 10 {
+				rem = rem<<1 ^ (rem>>9)*0x537
+			}
+			if data<<10|rem == bits {
+				return Level(level), mask
+			}
+		}
+	}
+	t.Fatalf("invalid format bits %015b", bits)
+	return 0, 0
+}
+
+var maskFuncs = [8]func(x, y int) bool{
+	func(x, y int) bool { return (x+y)%2 == 0 },
+	func(x, y int) bool { return y%2 == 0 },
+	func(x, y int) bool { return x%3 == 0 },
+	func(x, y int) bool { return (x+y)%3 == 0 },
+	func(x, y int) bool { return (x/3+y/2)%2 == 0 },
+	func(x, y int) bool { return x*y%2+x*y%3 == 0 },
+	func(x, y int) bool { return (x*y%2+x*y%3)%2 == 0 },
+	func(x, y int) bool { return ((x+y)%2+x*y%3)%2 == 0 },
+}
+
+// decodeQR reads the content of q back: it unmasks the data modules,
+// collects the codewords in placement order, de-interleaves the blocks,
+// checks their error correction and decodes the byte-mode data.
+func decodeQR(t *testing.T, q *QRCode) string {
+	t.Helper()
+	level, mask := readFormat(t, q)
+	if level != q.Level {
+		t.Fatalf("format level = %s, want %s", level, q.Level)
+	}
+
+	var bits []bool
+	for right := q.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := range q.Size {
+			for j := range 2 {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = q.Size - 1 - vert
+				}
+				if q.isFunction[y*q.Size+x] {
+					continue
+				}
+				bits = append(bits, q.Dark(x, y) != maskFuncs[mask](x, y))
+			}
+		}
+	}
+	raw := make([]byte, len(bits)/8)
+	for i := range raw {
+		for j := range 8 {
+			if bits[i*8+j] {
+				raw[i] |= 0x80 >> j
+			}
+		}
+	}
+
+	numBlocks := eccBlocks[level][q.Version]
+	eccLen := eccCodewordsPerBlock[level][q.Version]
+	numShort := numBlocks - len(raw)%numBlocks
+	shortData := len(raw)/numBlocks - eccLen
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := range shortData + 1 {
+		for j := range blocks {
+			if i < shortData || j >= numShort {
+				blocks[j] = append(blocks[j], raw[k])
+				k++
+			}
+		}
+	}
+	for range eccLen {
+		for j := range blocks {
+			blocks[j] = append(blocks[j], raw[k])
+			k++
+		}
+	}
+
+	var data []byte
+	for j, block := range blocks {
+		// Every codeword polynomial is divisible by the generator, so it
+		// vanishes at its roots 2^0 ... 2^(eccLen-1).
+		root := byte(1)
+		for i := range eccLen {
+			var sum byte
+			for _, c := range block {
+				sum = gfMultiply(sum, root) ^ c
+			}
+			if sum != 0 {
+				t.Fatalf("block %d: syndrome %d is %d", j, i, sum)
+			}
+			root = gfMultiply(root, 2)
+		}
+		data = append(data, block[:len(block)-eccLen]...)
+	}
+
+	var buf bitBuffer
+	for _, b := range data {
+		buf.append(int(b), 8)
+	}
+	read := func(n int) int {
+		v := 0
+		for range n {
+			v <<= 1
+			if buf[0] {
+				v |= 1
+			}
+			buf = buf[1:]
+		}
+		return v
+	}
+	if mode := read(4); mode != 0x4 {
+		t.Fatalf("mode = %x, want byte mode", mode)
+	}
+	out := make([]byte, read(countBits(q.Version)))
+	for i := range out {
+		out[i] = byte(read(8))
+	}
+	return string(out)
+}
+
+func TestEncodeQR_RoundTrip(t *testing.T) {
+	tests := []struct {
+		content string
+		level   Level
+		version int
+	}{
+		{"", Low, 1},
+		{"OR-000123", Medium, 1},
+		{strings.Repeat("x", 14), Medium, 1},
+		{strings.Repeat("x", 15), Medium, 2},
+		{"https://verify.example.com/or/OR-000123?tin=123-456-789-000", Quartile, 5},
+		{"Résumé ✓", High, 2},
+		{strings.Repeat("BIR receipt ", 12), Medium, 8},
+		{strings.Repeat("z", 500), High, 24},
+		{strings.Repeat("0123456789", 295) + "012", Low, 40},
+	}
+	for _, tt := range tests {
+		q, err := EncodeQR(tt.content, tt.level)
+		if err != nil {
+			t.Fatalf("EncodeQR(%d bytes, %s): %v", len(tt.content), tt.level, err)
+		}
+		if q.Version != tt.version || q.Size != 17+4*tt.version {
+			t.Errorf("%d bytes at %s: version %d size %d, want version %d", len(tt.content), tt.level, q.Version, q.Size, tt.version)
+		}
+		if got := decodeQR(t, q); got != tt.content {
+			t.Errorf("decoded %q, want %q", got, tt.content)
+		}
+	}
+
+	if _, err := EncodeQR(strings.Repeat("x", 2954), Low); err == nil {
+		t.Error("2954 bytes encoded, want an error")
+	}
+	if _, err := EncodeQR("x", Level(7)); err == nil {
+		t.Error("invalid level accepted")
+	}
+}
+
+func TestEncodeQR_FunctionPatterns(t *testing.T) {
+	q, err := EncodeQR(strings.Repeat("asset ", 20), Medium)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Version < 7 {
+		t.Fatalf("version %d has no version information", q.Version)
+	}
+
+	// Finders in three corners: a dark ring, a light ring and a 3x3 core.
+	for _, c := range [][2]int{{3, 3}, {q.Size - 4, 3}, {3, q.Size - 4}} {
+		for dy := -3; dy <= 3; dy++ {
+			for dx := -3; dx <= 3; dx++ {
+				want := max(abs(dx), abs(dy)) != 2
+				if q.Dark(c[0]+dx, c[1]+dy) != want {
+					t.Fatalf("finder at %v wrong at (%d,%d)", c, dx, dy)
+				}
+			}
+		}
+	}
+	for i := 8; i < q.Size-8; i++ {
+		if q.Dark(i, 6) != (i%2 == 0) || q.Dark(6, i) != (i%2 == 0) {
+			t.Fatalf("timing pattern wrong at %d", i)
+		}
+	}
+
+	// Version information, bottom-left copy (ISO/IEC 18004 annex D).
+	var bits int
+	for i := range 18 {
+		if q.Dark(i/3, q.Size-11+i%3) {
+			bits |= 1 << i
+		}
+	}
+	known := map[int]int{7: 0x07C94, 8: 0x085BC, 9: 0x09A99, 10: 0x0A4D3}
+	if want, ok := known[q.Version]; ok && bits != want {
+		t.Errorf("version %d information = %05X, want %05X", q.Version, bits, want)
+	}
+}
+
+func TestQRTables(t *testing.T) {
+	// Data capacities from ISO/IEC 18004, table 7.
+	capacities := map[[2]int]int{
+		{1, int(Low)}: 19, {1, int(Medium)}: 16, {1, int(Quartile)}: 13, {1, int(High)}: 9,
+		{10, int(Medium)}: 216,
+		{40, int(Low)}:    2956, {40, int(Medium)}: 2334, {40, int(Quartile)}: 1666, {40, int(High)}: 1276,
+	}
+	for k, want := range capacities {
+		if got := dataCodewords(k[0], Level(k[1])); got != want {
+			t.Errorf("version %d level %s: %d data codewords, want %d", k[0], Level(k[1]), got, want)
+		}
+	}
+	if got := fmt.Sprint(alignmentPositions(32)); got != "[6 34 60 86 112 138]" {
+		t.Errorf("alignment positions of version 32 = %s", got)
+	}
+}
+
+func TestQR_PNG(t *testing.T) {
+	data, err := QR("OR-000123", Medium)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decoding PNG: %v", err)
+	}
+	side := (21 + 2*QuietZone) * qrScale
+	if b := img.Bounds(); b.Dx() != side || b.Dy() != side {
+		t.Errorf("PNG is %v, want %dx%d", b, side, side)
+	}
+	// The quiet zone is white, the finder's corner black.
+	if r, _, _, _ := img.At(0, 0).RGBA(); r == 0 {
+		t.Error("quiet zone is not white")
+	}
+	corner := QuietZone*qrScale + qrScale/2
+	if r, _, _, _ := img.At(corner, corner).RGBA(); r != 0 {
+		t.Error("finder corner is not black")
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for _, l := range []Level{Low, Medium, Quartile, High} {
+		if got, err := ParseLevel(strings.ToLower(l.String())); err != nil || got != l {
+			t.Errorf("ParseLevel(%q) = %v, %v", l, got, err)
+		}
+	}
+	if _, err := ParseLevel("X"); err == nil {
+		t.Error("ParseLevel(X) succeeded")
+	}
+}
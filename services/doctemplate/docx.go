@@ -3,8 +3,17 @@ package doctemplate
 import (
 	"archive/zip"
 	"bytes"
+	"crypto/sha256"
+	"fmt"
 	"io"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+
+	"github.com/beevik/etree"
 )
 
 // DocxArchive holds the content and structure of a DOCX file.
@@ -13,9 +22,41 @@ type DocxArchive struct {
 	Headers map[string]string
 	Footers map[string]string
 	Images  map[string][]byte
-	files   []*zip.File
+	// Parts holds the other parts with text ProcessTemplate renders, by
+	// name: footnotes, endnotes and comments (word/footnotes.xml, ...) and
+	// the document properties (docProps/core.xml, docProps/custom.xml).
+	// WriteDocx writes them as they are here.
+	Parts map[string]string
+	files []*zip.File
+
+	// added holds parts created or rewritten since the archive was read
+	// (media, relationships, content types). WriteDocx writes them in place
+	// of the originals and appends the new ones.
+	added map[string][]byte
+	// media maps the hash of an image to the media part holding it, so an
+	// image placed many times is stored once.
+	media map[[sha256.Size]byte]string
+	// drawingID is the last drawing id (wp:docPr id) handed out.
+	drawingID int
 }
 
+// noteParts and propertyParts are the parts kept in DocxArchive.Parts.
+var (
+	noteParts     = []string{"word/footnotes.xml", "word/endnotes.xml", "word/comments.xml"}
+	propertyParts = []string{"docProps/core.xml", "docProps/custom.xml"}
+)
+
+// Relationship types used by the template engine.
+const (
+	relTypeImage     = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/image"
+	relTypeNumbering = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/numbering"
+	relTypeHyperlink = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink"
+
+	// relNamespace is the namespace of r:id, r:embed and the other
+	// attributes that refer to relationships.
+	relNamespace = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+)
+
 // ReadDocxBytes reads a DOCX file from a byte slice and extracts its main components.
 func ReadDocxBytes(data []byte) (*DocxArchive, error) {
 	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
@@ -27,6 +68,7 @@ func ReadDocxBytes(data []byte) (*DocxArchive, error) {
 		Headers: make(map[string]string),
 		Footers: make(map[string]string),
 		Images:  make(map[string][]byte),
+		Parts:   make(map[string]string),
 		files:   reader.File,
 	}
 
@@ -45,6 +87,8 @@ func ReadDocxBytes(data []byte) (*DocxArchive, error) {
 			archive.Footers[file.Name] = string(contentBytes)
 		case strings.HasPrefix(file.Name, "word/media/"):
 			archive.Images[file.Name] = contentBytes
+		case slices.Contains(noteParts, file.Name), slices.Contains(propertyParts, file.Name):
+			archive.Parts[file.Name] = string(contentBytes)
 		}
 	}
 
@@ -89,6 +133,12 @@ func (archive *DocxArchive) WriteDocx(
 		} else if content, ok := modifiedFooters[file.Name]; ok {
 			contentToWrite = []byte(content)
 			found = true
+		} else if content, ok := archive.added[file.Name]; ok {
+			contentToWrite = content
+			found = true
+		} else if content, ok := archive.Parts[file.Name]; ok {
+			contentToWrite = []byte(content)
+			found = true
 		}
 
 		if found {
@@ -106,9 +156,331 @@ func (archive *DocxArchive) WriteDocx(
 		}
 	}
 
+	// Append the parts that did not exist in the original archive.
+	existing := make(map[string]bool, len(archive.files))
+	for _, file := range archive.files {
+		existing[file.Name] = true
+	}
+	var newParts []string
+	for name := range archive.added {
+		if !existing[name] {
+			newParts = append(newParts, name)
+		}
+	}
+	sort.Strings(newParts)
+	for _, name := range newParts {
+		writer, err := zipWriter.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := writer.Write(archive.added[name]); err != nil {
+			return nil, err
+		}
+	}
+
 	if err := zipWriter.Close(); err != nil {
 		return nil, err
 	}
 
 	return buf.Bytes(), nil
 }
+
+// AddImage stores an image (PNG, JPEG or GIF) as a media part, relates it to
+// the given part (e.g. "word/document.xml" or "word/header1.xml") and
+// registers its content type. It returns the relationship id to reference
+// from a:blip r:embed in that part. Adding the same bytes again reuses the
+// stored media part and relationship.
+func (archive *DocxArchive) AddImage(part string, data []byte) (relID string, err error) {
+	info, err := decodeImageInfo(data)
+	if err != nil {
+		return "", err
+	}
+
+	if archive.media == nil {
+		archive.media = make(map[[sha256.Size]byte]string)
+		for name, stored := range archive.Images {
+			archive.media[sha256.Sum256(stored)] = name
+		}
+	}
+	sum := sha256.Sum256(data)
+	media, ok := archive.media[sum]
+	if !ok {
+		for n := len(archive.Images) + 1; ; n++ {
+			media = fmt.Sprintf("word/media/image%d.%s", n, info.ext)
+			if _, taken := archive.Images[media]; !taken && !archive.hasPart(media) {
+				break
+			}
+		}
+		if err := archive.ensureContentType(info.ext, info.contentType); err != nil {
+			return "", err
+		}
+		archive.setPart(media, data)
+		if archive.Images == nil {
+			archive.Images = make(map[string][]byte)
+		}
+		archive.Images[media] = data
+		archive.media[sum] = media
+	}
+
+	target, err := relativeTarget(part, media)
+	if err != nil {
+		return "", err
+	}
+	return archive.addRelationship(part, relTypeImage, target)
+}
+
+// hasPart reports whether the archive contains a part, original or added.
+func (archive *DocxArchive) hasPart(name string) bool {
+	if _, ok := archive.added[name]; ok {
+		return true
+	}
+	for _, file := range archive.files {
+		if file.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// readPart returns the current bytes of a part. ok is false if the part
+// does not exist.
+func (archive *DocxArchive) readPart(name string) (data []byte, ok bool, err error) {
+	if data, ok := archive.added[name]; ok {
+		return data, true, nil
+	}
+	for _, file := range archive.files {
+		if file.Name == name {
+			data, err := readZipFile(file)
+			return data, err == nil, err
+		}
+	}
+	return nil, false, nil
+}
+
+func (archive *DocxArchive) setPart(name string, data []byte) {
+	if archive.added == nil {
+		archive.added = make(map[string][]byte)
+	}
+	archive.added[name] = data
+}
+
+// readXMLPart parses a part, or returns fallback parsed when it does not
+// exist.
+func (archive *DocxArchive) readXMLPart(name, fallback string) (*etree.Document, error) {
+	data, ok, err := archive.readPart(name)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		data = []byte(fallback)
+	}
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(data); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", name, err)
+	}
+	return doc, nil
+}
+
+func (archive *DocxArchive) writeXMLPart(name string, doc *etree.Document) error {
+	data, err := doc.WriteToBytes()
+	if err != nil {
+		return fmt.Errorf("serializing %s: %w", name, err)
+	}
+	archive.setPart(name, data)
+	return nil
+}
+
+// relationshipsPart returns the name of the relationships part of a part:
+// word/document.xml → word/_rels/document.xml.rels.
+func relationshipsPart(part string) string {
+	dir, file := path.Split(part)
+	return dir + "_rels/" + file + ".rels"
+}
+
+// relativeTarget expresses target relative to the folder of source, as
+// relationship targets are: word/document.xml + word/media/a.png → media/a.png.
+func relativeTarget(source, target string) (string, error) {
+	dir := path.Dir(source)
+	if rel, ok := strings.CutPrefix(target, dir+"/"); ok {
+		return rel, nil
+	}
+	return "", fmt.Errorf("cannot relate %s to %s", target, source)
+}
+
+const emptyRelationships = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"></Relationships>`
+
+// addRelationship adds a relationship from part to target, or returns the
+// id of an identical one.
+func (archive *DocxArchive) addRelationship(part, relType, target string) (string, error) {
+	return archive.addRelationshipMode(part, relType, target, "")
+}
+
+// addRelationshipMode is addRelationship with a target mode: "External"
+// for targets outside the package, such as hyperlinks, or "".
+func (archive *DocxArchive) addRelationshipMode(part, relType, target, mode string) (string, error) {
+	relsName := relationshipsPart(part)
+	doc, err := archive.readXMLPart(relsName, emptyRelationships)
+	if err != nil {
+		return "", err
+	}
+	root := doc.Root()
+	if root == nil {
+		return "", fmt.Errorf("%s has no root element", relsName)
+	}
+
+	ids := make(map[string]bool)
+	for _, rel := range root.SelectElements("Relationship") {
+		id := rel.SelectAttrValue("Id", "")
+		if rel.SelectAttrValue("Type", "") == relType && rel.SelectAttrValue("Target", "") == target &&
+			rel.SelectAttrValue("TargetMode", "") == mode {
+			return id, nil
+		}
+		ids[id] = true
+	}
+	id := ""
+	for n := len(ids) + 1; ; n++ {
+		if id = "rId" + strconv.Itoa(n); !ids[id] {
+			break
+		}
+	}
+
+	rel := root.CreateElement("Relationship")
+	rel.CreateAttr("Id", id)
+	rel.CreateAttr("Type", relType)
+	rel.CreateAttr("Target", target)
+	if mode != "" {
+		rel.CreateAttr("TargetMode", mode)
+	}
+	return id, archive.writeXMLPart(relsName, doc)
+}
+
+// relationship is an entry of a relationships part.
+type relationship struct {
+	relType string
+	// target is the part name for internal targets
+	// (word/media/image1.png), or the target as written for external ones.
+	target string
+	mode   string
+}
+
+// lookupRelationship finds the relationship id of part. ok is false when
+// there is no such relationship.
+func (archive *DocxArchive) lookupRelationship(part, id string) (rel relationship, ok bool, err error) {
+	doc, err := archive.readXMLPart(relationshipsPart(part), emptyRelationships)
+	if err != nil || doc.Root() == nil {
+		return relationship{}, false, err
+	}
+	for _, el := range doc.Root().SelectElements("Relationship") {
+		if el.SelectAttrValue("Id", "") != id {
+			continue
+		}
+		rel = relationship{
+			relType: el.SelectAttrValue("Type", ""),
+			target:  el.SelectAttrValue("Target", ""),
+			mode:    el.SelectAttrValue("TargetMode", ""),
+		}
+		if rel.mode != "External" {
+			if strings.HasPrefix(rel.target, "/") {
+				rel.target = strings.TrimPrefix(rel.target, "/")
+			} else {
+				rel.target = path.Join(path.Dir(part), rel.target)
+			}
+		}
+		return rel, true, nil
+	}
+	return relationship{}, false, nil
+}
+
+// ensureContentType registers a default content type for a file extension
+// in [Content_Types].xml.
+func (archive *DocxArchive) ensureContentType(ext, contentType string) error {
+	const name = "[Content_Types].xml"
+	doc, err := archive.readXMLPart(name, "")
+	if err != nil {
+		return err
+	}
+	root := doc.Root()
+	if root == nil {
+		return fmt.Errorf("%s has no root element", name)
+	}
+	for _, def := range root.SelectElements("Default") {
+		if strings.EqualFold(def.SelectAttrValue("Extension", ""), ext) {
+			return nil
+		}
+	}
+	def := etree.NewElement("Default")
+	def.CreateAttr("Extension", ext)
+	def.CreateAttr("ContentType", contentType)
+	root.InsertChildAt(0, def)
+	return archive.writeXMLPart(name, doc)
+}
+
+// contentType returns the content type of a part from [Content_Types].xml,
+// and whether it is registered as an override rather than by extension.
+func (archive *DocxArchive) contentType(name string) (contentType string, override bool, err error) {
+	doc, err := archive.readXMLPart("[Content_Types].xml", "")
+	if err != nil || doc.Root() == nil {
+		return "", false, err
+	}
+	for _, o := range doc.Root().SelectElements("Override") {
+		if o.SelectAttrValue("PartName", "") == "/"+name {
+			return o.SelectAttrValue("ContentType", ""), true, nil
+		}
+	}
+	ext := strings.TrimPrefix(path.Ext(name), ".")
+	for _, def := range doc.Root().SelectElements("Default") {
+		if strings.EqualFold(def.SelectAttrValue("Extension", ""), ext) {
+			return def.SelectAttrValue("ContentType", ""), false, nil
+		}
+	}
+	return "", false, nil
+}
+
+// ensureOverride registers the content type of a single part in
+// [Content_Types].xml.
+func (archive *DocxArchive) ensureOverride(name, contentType string) error {
+	const typesPart = "[Content_Types].xml"
+	doc, err := archive.readXMLPart(typesPart, "")
+	if err != nil {
+		return err
+	}
+	root := doc.Root()
+	if root == nil {
+		return fmt.Errorf("%s has no root element", typesPart)
+	}
+	for _, o := range root.SelectElements("Override") {
+		if o.SelectAttrValue("PartName", "") == "/"+name {
+			return nil
+		}
+	}
+	o := root.CreateElement("Override")
+	o.CreateAttr("PartName", "/"+name)
+	o.CreateAttr("ContentType", contentType)
+	return archive.writeXMLPart(typesPart, doc)
+}
+
+var drawingIDRegex = regexp.MustCompile(`docPr\b[^>]*?\sid="(\d+)"`)
+
+// nextDrawingID returns an id for a new wp:docPr that does not collide with
+// the drawings already in the document, headers and footers.
+func (archive *DocxArchive) nextDrawingID() int {
+	if archive.drawingID == 0 {
+		parts := []string{archive.Content}
+		for _, h := range archive.Headers {
+			parts = append(parts, h)
+		}
+		for _, f := range archive.Footers {
+			parts = append(parts, f)
+		}
+		for _, xml := range parts {
+			for _, m := range drawingIDRegex.FindAllStringSubmatch(xml, -1) {
+				if id, err := strconv.Atoi(m[1]); err == nil && id > archive.drawingID {
+					archive.drawingID = id
+				}
+			}
+		}
+	}
+	archive.drawingID++
+	return archive.drawingID
+}
@@ -2,29 +2,49 @@ package doctemplate
 
 import (
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 
 	"github.com/beevik/etree"
 )
 
 // ProcessTemplate takes a DOCX template as bytes and a data map,
 // performs placeholder replacement, and returns the processed DOCX as bytes.
+// The body, headers, footers, footnotes, endnotes and comments are rendered,
+// text boxes included, as are the document properties (title, subject, ...).
+// Placeholders whose value is missing are left in the document as written;
+// use ProcessTemplateWithOptions to blank them or to fail instead.
 func ProcessTemplate(templateData []byte, data map[string]any) ([]byte, error) {
+	return ProcessTemplateWithOptions(templateData, data, Options{})
+}
+
+// ProcessTemplateWithOptions is ProcessTemplate with per-call options. With
+// opts.Missing set to MissingError, the whole template is rendered and the
+// error is a *MissingValuesError listing every value that was missing.
+func ProcessTemplateWithOptions(templateData []byte, data map[string]any, opts Options) ([]byte, error) {
 	// Step 1: Read the DOCX archive
 	archive, err := ReadDocxBytes(templateData)
 	if err != nil {
 		return nil, fmt.Errorf("reading docx: %w", err)
 	}
 
+	missing := &missingValues{}
+	newRenderer := func(part string) *renderer {
+		return &renderer{archive: archive, part: part, opts: opts, missing: missing}
+	}
+
 	// Step 2: Process the main document content
-	processedContent, err := processXMLContent(archive.Content, data)
+	processedContent, err := processXMLContent(newRenderer("word/document.xml"), archive.Content, data)
 	if err != nil {
 		return nil, fmt.Errorf("processing document.xml: %w", err)
 	}
 
-	// Step 3: Process headers
+	// Step 3: Process headers (in name order, so strict-mode errors list
+	// missing values in a stable order)
 	processedHeaders := make(map[string]string, len(archive.Headers))
-	for name, content := range archive.Headers {
-		processed, err := processXMLContent(content, data)
+	for _, name := range slices.Sorted(maps.Keys(archive.Headers)) {
+		processed, err := processXMLContent(newRenderer(name), archive.Headers[name], data)
 		if err != nil {
 			return nil, fmt.Errorf("processing header %s: %w", name, err)
 		}
@@ -33,21 +53,39 @@ func ProcessTemplate(templateData []byte, data map[string]any) ([]byte, error) {
 
 	// Step 4: Process footers
 	processedFooters := make(map[string]string, len(archive.Footers))
-	for name, content := range archive.Footers {
-		processed, err := processXMLContent(content, data)
+	for _, name := range slices.Sorted(maps.Keys(archive.Footers)) {
+		processed, err := processXMLContent(newRenderer(name), archive.Footers[name], data)
 		if err != nil {
 			return nil, fmt.Errorf("processing footer %s: %w", name, err)
 		}
 		processedFooters[name] = processed
 	}
 
-	// Step 5: Write the modified DOCX
+	// Step 5: Process footnotes, endnotes, comments and document properties
+	for _, name := range slices.Sorted(maps.Keys(archive.Parts)) {
+		process := processXMLContent
+		if slices.Contains(propertyParts, name) {
+			process = processProperties
+		}
+		processed, err := process(newRenderer(name), archive.Parts[name], data)
+		if err != nil {
+			return nil, fmt.Errorf("processing %s: %w", name, err)
+		}
+		archive.Parts[name] = processed
+	}
+
+	if err := missing.err(); err != nil {
+		return nil, err
+	}
+
+	// Step 6: Write the modified DOCX
 	return archive.WriteDocx(processedContent, processedHeaders, processedFooters)
 }
 
 // processXMLContent parses an OOXML string, processes placeholders using
-// the etree-based XML processor, and serializes back to string.
-func processXMLContent(xmlContent string, data map[string]any) (string, error) {
+// the etree-based XML processor, and serializes back to string. r is set up
+// for the part being processed.
+func processXMLContent(r *renderer, xmlContent string, data map[string]any) (string, error) {
 	doc := etree.NewDocument()
 	doc.ReadSettings.PreserveCData = true
 
@@ -62,10 +100,13 @@ func processXMLContent(xmlContent string, data map[string]any) (string, error) {
 		// Try alternative: process all paragraphs/tables at document level
 		root := doc.Root()
 		if root != nil {
-			processAllElements(root, data)
+			r.processAllElements(root, data)
 		}
 	} else {
-		ProcessBody(body, data)
+		r.processSiblings(body, body.ChildElements(), data)
+	}
+	if r.err != nil {
+		return "", r.err
 	}
 
 	doc.WriteSettings.CanonicalEndTags = false
@@ -80,18 +121,33 @@ func processXMLContent(xmlContent string, data map[string]any) (string, error) {
 	return result, nil
 }
 
-// processAllElements walks all child elements recursively, processing
-// paragraphs and tables it finds. Used for headers/footers where the
-// structure differs from the main document body.
-func processAllElements(el *etree.Element, data map[string]any) {
-	for _, child := range el.ChildElements() {
-		switch child.Tag {
-		case "p":
-			processParagraph(child, data)
-		case "tbl":
-			processTable(child, data)
-		default:
-			processAllElements(child, data)
+// processProperties fills the placeholders in a document properties part
+// (docProps/core.xml or docProps/custom.xml): the title, subject, keywords,
+// custom property values and so on. Each value is plain text, so sections
+// and images do not apply there.
+func processProperties(r *renderer, xmlContent string, data map[string]any) (string, error) {
+	doc := etree.NewDocument()
+	if err := doc.ReadFromString(xmlContent); err != nil {
+		return "", fmt.Errorf("parsing XML: %w", err)
+	}
+	if root := doc.Root(); root != nil {
+		for _, el := range root.FindElements(".//*") {
+			if len(el.ChildElements()) == 0 && strings.Contains(el.Text(), "{{") {
+				el.SetText(r.replaceInText(el.Text(), data))
+			}
 		}
 	}
+
+	result, err := doc.WriteToString()
+	if err != nil {
+		return "", fmt.Errorf("serializing XML: %w", err)
+	}
+	return result, nil
+}
+
+// processAllElements renders the content of a root element whose structure
+// differs from the main document body, such as a header, footer or the
+// footnotes part.
+func (r *renderer) processAllElements(el *etree.Element, data map[string]any) {
+	r.processSiblings(el, el.ChildElements(), data)
 }
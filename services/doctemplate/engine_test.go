@@ -274,15 +274,20 @@ func createTestDocx(t *testing.T, documentXML string) []byte {
 	documentRelsXML := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
 <Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"></Relationships>`
 
-	buf := new(bytes.Buffer)
-	w := zip.NewWriter(buf)
-
-	files := map[string]string{
+	return createTestPackage(t, map[string]string{
 		"[Content_Types].xml":          contentTypesXML,
 		"_rels/.rels":                  relsXML,
 		"word/document.xml":            documentXML,
 		"word/_rels/document.xml.rels": documentRelsXML,
-	}
+	})
+}
+
+// createTestPackage zips the given parts, by name.
+func createTestPackage(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	buf := new(bytes.Buffer)
+	w := zip.NewWriter(buf)
 
 	for name, content := range files {
 		writer, err := w.Create(name)
@@ -495,3 +500,93 @@ func TestReplacePlaceholders(t *testing.T) {
 		t.Errorf("loop end marker should be preserved, got: %s", result)
 	}
 }
+
+func TestProcessTemplate_TextBoxesNotesAndProperties(t *testing.T) {
+	const ns = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:mc="http://schemas.openxmlformats.org/markup-compatibility/2006" xmlns:wps="http://schemas.microsoft.com/office/word/2010/wordprocessingShape" xmlns:v="urn:schemas-microsoft-com:vml"`
+	// Word writes a text box twice: as a shape and as a VML fallback.
+	textBox := `<w:txbxContent>` + para("{{#if client.vip}}") + para("VIP: {{client.name}}") + para("{{/if}}") + `</w:txbxContent>`
+	sidebar := `<w:p><w:r><w:t>Contract {{number}}</w:t></w:r><w:r><mc:AlternateContent>` +
+		`<mc:Choice Requires="wps"><w:drawing><wps:wsp><wps:txbx>` + textBox + `</wps:txbx></wps:wsp></w:drawing></mc:Choice>` +
+		`<mc:Fallback><w:pict><v:shape><v:textbox>` + textBox + `</v:textbox></v:shape></w:pict></mc:Fallback>` +
+		`</mc:AlternateContent></w:r></w:p>`
+
+	template := createTestPackage(t, map[string]string{
+		"[Content_Types].xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/></Types>`,
+		"word/document.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document ` + ns + `><w:body>` + sidebar + `</w:body></w:document>`,
+		"word/footnotes.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:footnotes ` + ns + `><w:footnote w:id="1">` + para("Signed for {{client.name}}") + `</w:footnote></w:footnotes>`,
+		"word/endnotes.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:endnotes ` + ns + `><w:endnote w:id="1">` + para("{{#terms}}") + para("{{@number}}. {{text}}") + para("{{/terms}}") + `</w:endnote></w:endnotes>`,
+		"word/comments.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:comments ` + ns + `><w:comment w:id="0" w:author="Legal">` + para("Check the TIN of {{client.name}}") + `</w:comment></w:comments>`,
+		"docProps/core.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>Contract with {{client.name}}</dc:title><dc:creator>Legal</dc:creator></cp:coreProperties>`,
+		"docProps/custom.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Properties xmlns="http://schemas.openxmlformats.org/officeDocument/2006/custom-properties" xmlns:vt="http://schemas.openxmlformats.org/officeDocument/2006/docPropsVTypes"><property fmtid="{D5CDD505-2E9C-101B-9397-08002B2CF9AE}" pid="2" name="Client"><vt:lpwstr>{{client.name}} &amp; Co</vt:lpwstr></property></Properties>`,
+	})
+
+	result, err := ProcessTemplate(template, map[string]any{
+		"number": "C-12",
+		"client": map[string]any{"name": "Acme", "vip": true},
+		"terms":  []any{map[string]any{"text": "Net 30"}, map[string]any{"text": "No refunds"}},
+	})
+	if err != nil {
+		t.Fatalf("ProcessTemplate failed: %v", err)
+	}
+	archive, err := ReadDocxBytes(result)
+	if err != nil {
+		t.Fatalf("failed to read output docx: %v", err)
+	}
+
+	// The paragraph keeps its text box, rendered in both forms.
+	if got := strings.Join(documentLines(archive.Content), "\n"); got != "Contract C-12VIP: Acme\nVIP: Acme" {
+		t.Errorf("document lines = %q", got)
+	}
+	if strings.Contains(archive.Content, "{{") {
+		t.Errorf("document has tags left:\n%s", archive.Content)
+	}
+
+	tests := []struct {
+		part string
+		want []string
+	}{
+		{"word/footnotes.xml", []string{"Signed for Acme"}},
+		{"word/endnotes.xml", []string{"1. Net 30", "2. No refunds"}},
+		{"word/comments.xml", []string{"Check the TIN of Acme"}},
+	}
+	for _, tt := range tests {
+		if got := documentLines(archive.Parts[tt.part]); strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s lines = %q, want %q", tt.part, got, tt.want)
+		}
+	}
+	if core := archive.Parts["docProps/core.xml"]; !strings.Contains(core, "<dc:title>Contract with Acme</dc:title>") {
+		t.Errorf("core properties = %s", core)
+	}
+	if custom := archive.Parts["docProps/custom.xml"]; !strings.Contains(custom, "<vt:lpwstr>Acme &amp; Co</vt:lpwstr>") {
+		t.Errorf("custom properties = %s", custom)
+	}
+
+	info, err := Inspect(template)
+	if err != nil {
+		t.Fatalf("Inspect: %v", err)
+	}
+	parts := map[string]int{}
+	for _, tag := range info.Tags {
+		parts[tag.Location.Part]++
+	}
+	want := map[string]int{
+		"word/document.xml":   4, // the text box once: mc:Fallback is skipped
+		"word/footnotes.xml":  1,
+		"word/endnotes.xml":   4,
+		"word/comments.xml":   1,
+		"docProps/core.xml":   1,
+		"docProps/custom.xml": 1,
+	}
+	for part, n := range want {
+		if parts[part] != n {
+			t.Errorf("%d tags in %s, want %d (all: %v)", parts[part], part, n, parts)
+		}
+	}
+}
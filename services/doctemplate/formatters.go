@@ -0,0 +1,512 @@
+package doctemplate
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/erniealice/fycha-golang/services/barcode"
+)
+
+// Formatters transform a placeholder's value before it is written:
+//
+//	{{total | currency:"PHP"}}          ₱18,500.00
+//	{{date | date:"January 2, 2006"}}   March 8, 2026
+//	{{qty | number:2}}                  1,234.50
+//	{{amount | words:"Pesos"}}          One Thousand Two Hundred Thirty-Four Pesos and 50/100
+//	{{client.name | upper}}             ACME CORPORATION
+//
+// Formatters chain left to right; each after the first receives the string
+// produced by the one before. Arguments follow the name after a colon,
+// separated by colons or commas, and may be quoted. A placeholder whose
+// formatter is unknown or fails is left in the output unchanged, so the
+// problem is visible in the generated document (see Options for stricter
+// policies).
+
+// Formatter converts a value to its display text. args are the formatter's
+// arguments as written in the template, with quotes removed.
+type Formatter func(value any, args []string) (string, error)
+
+var (
+	formattersMu sync.RWMutex
+	formatters   = map[string]Formatter{
+		"currency": formatCurrency,
+		"date":     formatDate,
+		"number":   formatNumber,
+		"words":    formatWords,
+		"upper":    func(v any, _ []string) (string, error) { return strings.ToUpper(valueString(v)), nil },
+		"lower":    func(v any, _ []string) (string, error) { return strings.ToLower(valueString(v)), nil },
+	}
+)
+
+// RegisterFormatter makes a formatter available to all templates under name,
+// replacing any formatter (including a built-in one) of the same name.
+// Register formatters during initialization, before templates are processed.
+func RegisterFormatter(name string, fn Formatter) {
+	formattersMu.Lock()
+	defer formattersMu.Unlock()
+	formatters[name] = fn
+}
+
+func lookupFormatter(name string) (Formatter, bool) {
+	formattersMu.RLock()
+	defer formattersMu.RUnlock()
+	fn, ok := formatters[name]
+	return fn, ok
+}
+
+// Image formatters turn a value into a picture for an image placeholder —
+// the verification QR code of a receipt, the barcode of an asset tag:
+//
+//	{{%receipt.verify_url | qrcode}}               QR code, error correction level M
+//	{{%receipt.verify_url | qrcode:"H" width=3cm}} level H (or L, Q), 3cm wide
+//	{{%asset.tag | barcode height=1.2cm}}          Code 128 barcode
+//	{{receipt.number | qrcode}}                    the same as {{%receipt.number | qrcode}}
+//
+// The image formatter comes last; text formatters before it shape the text
+// that is encoded, e.g. {{%asset.tag | upper | barcode}}. The picture is
+// sized like any image (see image.go); without a size, QR codes are drawn
+// at 0.75mm and barcodes at 0.33mm per module.
+
+// ImageFormatter converts a value to an image. args are the formatter's
+// arguments as written in the template, with quotes removed. The Width and
+// Height of the returned Image are its default size, used when the
+// template gives none.
+type ImageFormatter func(value any, args []string) (Image, error)
+
+var imageFormatters = map[string]ImageFormatter{
+	"qrcode":  formatQRCode,
+	"barcode": formatBarcode,
+}
+
+// RegisterImageFormatter makes an image formatter available to all
+// templates under name, replacing any image formatter (including a
+// built-in one) of the same name. Register formatters during
+// initialization, before templates are processed.
+func RegisterImageFormatter(name string, fn ImageFormatter) {
+	formattersMu.Lock()
+	defer formattersMu.Unlock()
+	imageFormatters[name] = fn
+}
+
+func lookupImageFormatter(name string) (ImageFormatter, bool) {
+	formattersMu.RLock()
+	defer formattersMu.RUnlock()
+	fn, ok := imageFormatters[name]
+	return fn, ok
+}
+
+// formatterCall is one "| name:args" step of a placeholder.
+type formatterCall struct {
+	name string
+	args []string
+}
+
+// placeholderExpr is a parsed placeholder such as
+// `total | currency:"PHP" ?? "N/A"`.
+type placeholderExpr struct {
+	path  string
+	calls []formatterCall
+	// fallback is the text written when the path is missing or nil, given
+	// after "??". hasFallback tells an empty fallback ("") from none.
+	fallback    string
+	hasFallback bool
+}
+
+// parsePlaceholderExpr splits a placeholder such as
+// `total | currency:"PHP"` into its path and formatter calls, and a default
+// value after "??" if there is one.
+func parsePlaceholderExpr(expr string) placeholderExpr {
+	var pe placeholderExpr
+	expr = quoteReplacer.Replace(expr)
+	if before, after, ok := cutUnquoted(expr, "??"); ok {
+		expr = before
+		pe.fallback = unquote(strings.TrimSpace(after))
+		pe.hasFallback = true
+	}
+	parts := splitUnquoted(expr, "|")
+	pe.path = strings.TrimSpace(parts[0])
+	for _, part := range parts[1:] {
+		pe.calls = append(pe.calls, parseFormatterCall(part))
+	}
+	return pe
+}
+
+// parseFormatterCall parses one formatter step such as `currency:"PHP":0`.
+func parseFormatterCall(s string) formatterCall {
+	name, rawArgs, _ := strings.Cut(strings.TrimSpace(s), ":")
+	call := formatterCall{name: strings.TrimSpace(name)}
+	if strings.TrimSpace(rawArgs) != "" {
+		for _, arg := range splitUnquoted(rawArgs, ":,") {
+			call.args = append(call.args, unquote(strings.TrimSpace(arg)))
+		}
+	}
+	return call
+}
+
+// cutUnquoted is strings.Cut for the first occurrence of sep outside single
+// or double quotes.
+func cutUnquoted(s, sep string) (before, after string, found bool) {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case strings.HasPrefix(s[i:], sep):
+			return s[:i], s[i+len(sep):], true
+		}
+	}
+	return s, "", false
+}
+
+// splitUnquoted splits s at any of the separator characters outside single
+// or double quotes.
+func splitUnquoted(s, separators string) []string {
+	var parts []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case strings.IndexByte(separators, c) >= 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// applyFormatters runs value through calls in order.
+func applyFormatters(value any, calls []formatterCall) (string, error) {
+	if len(calls) == 0 {
+		return valueString(value), nil
+	}
+	for _, call := range calls {
+		fn, ok := lookupFormatter(call.name)
+		if !ok {
+			return "", fmt.Errorf("unknown formatter %q", call.name)
+		}
+		text, err := fn(value, call.args)
+		if err != nil {
+			return "", fmt.Errorf("formatter %q: %w", call.name, err)
+		}
+		value = text
+	}
+	return value.(string), nil
+}
+
+// ---------------------------------------------------------------------------
+// Built-in formatters
+// ---------------------------------------------------------------------------
+
+// formatQRCode draws the value as a QR code: qrcode, or qrcode:"H" for an
+// error correction level other than M.
+func formatQRCode(v any, args []string) (Image, error) {
+	text := valueString(v)
+	if text == "" {
+		return Image{}, errors.New("nothing to encode")
+	}
+	level := barcode.Medium
+	if len(args) > 0 {
+		var err error
+		if level, err = barcode.ParseLevel(args[0]); err != nil {
+			return Image{}, err
+		}
+	}
+	q, err := barcode.EncodeQR(text, level)
+	if err != nil {
+		return Image{}, err
+	}
+	data, err := q.PNG(10)
+	if err != nil {
+		return Image{}, err
+	}
+	width := float64(q.Size+2*barcode.QuietZone) * 0.75
+	return Image{Data: data, Width: fmt.Sprintf("%.2fmm", width), Description: text}, nil
+}
+
+// formatBarcode draws the value as a Code 128 barcode.
+func formatBarcode(v any, _ []string) (Image, error) {
+	b, err := barcode.EncodeCode128(valueString(v))
+	if err != nil {
+		return Image{}, err
+	}
+	data, err := b.PNG(3, 135)
+	if err != nil {
+		return Image{}, err
+	}
+	width := float64(b.Width()+2*barcode.Code128QuietZone) * 0.33
+	return Image{Data: data, Width: fmt.Sprintf("%.2fmm", width), Description: b.Content}, nil
+}
+
+// currencySymbols are the symbols used by the currency formatter; other
+// codes are written as a prefix ("SGD 1,000.00").
+var currencySymbols = map[string]string{
+	"PHP": "₱",
+	"USD": "$",
+	"EUR": "€",
+	"GBP": "£",
+	"JPY": "¥",
+}
+
+// zeroDecimalCurrencies have no minor unit.
+var zeroDecimalCurrencies = map[string]bool{"JPY": true, "KRW": true, "VND": true}
+
+// formatCurrency writes an amount with thousands separators and the
+// currency symbol: currency:"PHP" (the default) or currency:"USD":0 to
+// override the number of decimals.
+func formatCurrency(v any, args []string) (string, error) {
+	n, ok := toNumber(v)
+	if !ok {
+		return "", fmt.Errorf("%s is not a number", describe(v))
+	}
+	code := "PHP"
+	if len(args) > 0 && args[0] != "" {
+		code = strings.ToUpper(args[0])
+	}
+	decimals := 2
+	if zeroDecimalCurrencies[code] {
+		decimals = 0
+	}
+	if len(args) > 1 {
+		d, err := strconv.Atoi(args[1])
+		if err != nil || d < 0 {
+			return "", fmt.Errorf("decimals %q is not a non-negative integer", args[1])
+		}
+		decimals = d
+	}
+
+	amount := groupThousands(math.Abs(n), decimals)
+	symbol, known := currencySymbols[code]
+	if !known {
+		symbol = code + " "
+	}
+	if n < 0 && amount != groupThousands(0, decimals) {
+		return "-" + symbol + amount, nil
+	}
+	return symbol + amount, nil
+}
+
+// dateLayouts are the string forms the date formatter accepts as input.
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// formatDate formats a time.Time, an ISO 8601 string or Unix seconds using a
+// Go layout: date:"January 2, 2006" (the default) or date:"02/01/2006".
+func formatDate(v any, args []string) (string, error) {
+	layout := "January 2, 2006"
+	if len(args) > 0 && args[0] != "" {
+		layout = args[0]
+	}
+
+	var t time.Time
+	switch d := v.(type) {
+	case time.Time:
+		t = d
+	case *time.Time:
+		if d == nil {
+			return "", nil
+		}
+		t = *d
+	case string:
+		s := strings.TrimSpace(d)
+		if s == "" {
+			return "", nil
+		}
+		parsed := false
+		for _, in := range dateLayouts {
+			if p, err := time.Parse(in, s); err == nil {
+				t, parsed = p, true
+				break
+			}
+		}
+		if !parsed {
+			return "", fmt.Errorf("cannot parse %q as a date", d)
+		}
+	case nil:
+		return "", nil
+	default:
+		secs, ok := toNumber(v)
+		if !ok {
+			return "", fmt.Errorf("%s is not a date", describe(v))
+		}
+		t = time.Unix(int64(secs), 0).UTC()
+	}
+	return t.Format(layout), nil
+}
+
+// formatNumber writes a number with thousands separators: number:2 rounds to
+// two decimals; without an argument, whole numbers get none and fractions two.
+func formatNumber(v any, args []string) (string, error) {
+	n, ok := toNumber(v)
+	if !ok {
+		return "", fmt.Errorf("%s is not a number", describe(v))
+	}
+	decimals := 0
+	if n != math.Trunc(n) {
+		decimals = 2
+	}
+	if len(args) > 0 {
+		d, err := strconv.Atoi(args[0])
+		if err != nil || d < 0 {
+			return "", fmt.Errorf("decimals %q is not a non-negative integer", args[0])
+		}
+		decimals = d
+	}
+	text := groupThousands(math.Abs(n), decimals)
+	if n < 0 && text != groupThousands(0, decimals) {
+		return "-" + text, nil
+	}
+	return text, nil
+}
+
+// groupThousands formats a non-negative number with comma separators.
+func groupThousands(n float64, decimals int) string {
+	text := strconv.FormatFloat(n, 'f', decimals, 64)
+	whole, frac, hasFrac := strings.Cut(text, ".")
+	var sb strings.Builder
+	for i, c := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteRune(c)
+	}
+	if hasFrac {
+		sb.WriteByte('.')
+		sb.WriteString(frac)
+	}
+	return sb.String()
+}
+
+// formatWords spells out an amount the way checks and official receipts do.
+// Centavos are written as a fraction of 100, rounded like currency:
+//
+//	{{amount | words}}                 One Thousand Two Hundred Thirty-Four and 50/100
+//	{{amount | words:"Pesos"}}         One Thousand Two Hundred Thirty-Four Pesos and 50/100
+//	                                   (or "... Pesos Only" for a whole amount)
+//	{{amount | words:"Pesos":"Peso"}}  the second argument is the unit for one,
+//	                                   e.g. "One Peso Only"
+//
+// Without a singular form the unit is written as given, whatever the amount.
+func formatWords(v any, args []string) (string, error) {
+	n, ok := toNumber(v)
+	if !ok {
+		return "", fmt.Errorf("%s is not a number", describe(v))
+	}
+	if math.Abs(n) >= 1e15 {
+		return "", fmt.Errorf("%v is too large to spell out", n)
+	}
+	// Round through the decimal text, as currency does, so the centavos
+	// always match the printed amount: 0.015 is ₱0.01 and 01/100, where
+	// rounding 0.015*100 as a float would give 02/100.
+	wholeText, fractionText, _ := strings.Cut(strconv.FormatFloat(math.Abs(n), 'f', 2, 64), ".")
+	whole, err := strconv.ParseInt(wholeText, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("%v is not a spellable amount", n)
+	}
+	fraction, err := strconv.Atoi(fractionText)
+	if err != nil {
+		return "", fmt.Errorf("%v is not a spellable amount", n)
+	}
+
+	text := numberWords(whole)
+	if n < 0 && (whole != 0 || fraction != 0) {
+		text = "Minus " + text
+	}
+	unit := ""
+	if len(args) > 0 {
+		unit = args[0]
+	}
+	if whole == 1 && len(args) > 1 && args[1] != "" {
+		unit = args[1]
+	}
+	switch {
+	case unit != "" && fraction == 0:
+		return text + " " + unit + " Only", nil
+	case unit != "":
+		return fmt.Sprintf("%s %s and %02d/100", text, unit, fraction), nil
+	case fraction != 0:
+		return fmt.Sprintf("%s and %02d/100", text, fraction), nil
+	}
+	return text, nil
+}
+
+var (
+	smallNumberWords = []string{
+		"Zero", "One", "Two", "Three", "Four", "Five", "Six", "Seven", "Eight", "Nine", "Ten",
+		"Eleven", "Twelve", "Thirteen", "Fourteen", "Fifteen", "Sixteen", "Seventeen", "Eighteen", "Nineteen",
+	}
+	tensWords  = []string{"", "", "Twenty", "Thirty", "Forty", "Fifty", "Sixty", "Seventy", "Eighty", "Ninety"}
+	scaleWords = []string{"", "Thousand", "Million", "Billion", "Trillion"}
+)
+
+// numberWords spells out a non-negative whole number in English.
+func numberWords(n int64) string {
+	if n == 0 {
+		return smallNumberWords[0]
+	}
+	var groups []string
+	for scale := 0; n > 0; scale++ {
+		if chunk := n % 1000; chunk > 0 {
+			words := hundredsWords(chunk)
+			if scaleWords[scale] != "" {
+				words += " " + scaleWords[scale]
+			}
+			groups = append([]string{words}, groups...)
+		}
+		n /= 1000
+	}
+	return strings.Join(groups, " ")
+}
+
+// hundredsWords spells out 1..999.
+func hundredsWords(n int64) string {
+	var parts []string
+	if n >= 100 {
+		parts = append(parts, smallNumberWords[n/100]+" Hundred")
+		n %= 100
+	}
+	switch {
+	case n >= 20 && n%10 != 0:
+		parts = append(parts, tensWords[n/10]+"-"+smallNumberWords[n%10])
+	case n >= 20:
+		parts = append(parts, tensWords[n/10])
+	case n > 0:
+		parts = append(parts, smallNumberWords[n])
+	}
+	return strings.Join(parts, " ")
+}
+
+// describe names a value and its type for formatter errors.
+func describe(v any) string {
+	if s, ok := v.(string); ok {
+		return strconv.Quote(s)
+	}
+	return fmt.Sprintf("%v (%T)", v, v)
+}
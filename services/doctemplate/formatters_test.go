@@ -0,0 +1,122 @@
+package doctemplate
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatters(t *testing.T) {
+	data := map[string]any{
+		"total":    18500.5,
+		"negative": -1234.567,
+		"big":      "1234567",
+		"qty":      1234.5,
+		"count":    3,
+		"yen":      150000,
+		"sgd":      99.9,
+		"issued":   "2026-03-08",
+		"paid_at":  time.Date(2026, 3, 9, 14, 30, 0, 0, time.UTC),
+		"amount":   1234.56,
+		"check":    25000,
+		"one":      1,
+		"rounded":  1.005,
+		"odd_cent": 100.995,
+		"centavo":  0.015,
+		"name":     "Acme Corporation",
+		"tin":      nil,
+	}
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{`total | currency:"PHP"`, "₱18,500.50"},
+		{`total | currency`, "₱18,500.50"},
+		{`negative | currency:"USD"`, "-$1,234.57"},
+		{`yen | currency:"JPY"`, "¥150,000"},
+		{`sgd | currency:'SGD'`, "SGD 99.90"},
+		{`total | currency:"PHP":0`, "₱18,500"},
+		{`big | number`, "1,234,567"},
+		{`qty | number:2`, "1,234.50"},
+		{`qty | number`, "1,234.50"},
+		{`count | number:1`, "3.0"},
+		{`issued | date`, "March 8, 2026"},
+		{`issued | date:"January 2, 2006"`, "March 8, 2026"},
+		{`paid_at | date:"02/01/2006 15:04"`, "09/03/2026 14:30"},
+		{`issued | date:“Jan 2, 2006”`, "Mar 8, 2026"},
+		{`amount | words`, "One Thousand Two Hundred Thirty-Four and 56/100"},
+		{`amount | words:"Pesos"`, "One Thousand Two Hundred Thirty-Four Pesos and 56/100"},
+		{`check | words:"Pesos" | upper`, "TWENTY-FIVE THOUSAND PESOS ONLY"},
+		{`count | words`, "Three"},
+		{`one | words:"Pesos"`, "One Pesos Only"},
+		{`one | words:"Pesos":"Peso"`, "One Peso Only"},
+		{`rounded | currency`, "₱1.00"},
+		{`rounded | words:"Pesos":"Peso"`, "One Peso Only"},
+		{`odd_cent | currency`, "₱101.00"},
+		{`odd_cent | words:"Pesos":"Peso"`, "One Hundred One Pesos Only"},
+		{`centavo | currency`, "₱0.01"},
+		{`centavo | words`, "Zero and 01/100"},
+		{`check | words:"Pesos":"Peso"`, "Twenty-Five Thousand Pesos Only"},
+		{`name | upper`, "ACME CORPORATION"},
+		{`name|lower`, "acme corporation"},
+		{`name ?? "N/A"`, "Acme Corporation"},
+		{`nickname ?? "N/A"`, "N/A"},
+		{`tin ?? “—”`, "—"},
+		{`nickname ?? ""`, ""},
+		{`missing | upper ?? 'none'`, "none"},
+		{`total | currency:"??" ?? "-"`, "?? 18,500.50"},
+	}
+	for _, tt := range tests {
+		got, err := renderPlaceholder(tt.expr, data)
+		if err != nil || got != tt.want {
+			t.Errorf("renderPlaceholder(%q) = %q, %v; want %q", tt.expr, got, err, tt.want)
+		}
+	}
+
+	for _, expr := range []string{`name | currency`, `name | nosuch`, `issued | number:x`, `missing | upper`} {
+		if got, err := renderPlaceholder(expr, data); err == nil {
+			t.Errorf("renderPlaceholder(%q) = %q, want failure", expr, got)
+		}
+	}
+}
+
+func TestNumberWords(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "Zero"},
+		{15, "Fifteen"},
+		{40, "Forty"},
+		{101, "One Hundred One"},
+		{1000000, "One Million"},
+		{2003045, "Two Million Three Thousand Forty-Five"},
+		{987654321, "Nine Hundred Eighty-Seven Million Six Hundred Fifty-Four Thousand Three Hundred Twenty-One"},
+	}
+	for _, tt := range tests {
+		if got := numberWords(tt.n); got != tt.want {
+			t.Errorf("numberWords(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestProcessTemplate_Formatters(t *testing.T) {
+	RegisterFormatter("test_initials", func(v any, args []string) (string, error) {
+		var sb strings.Builder
+		for _, word := range strings.Fields(valueString(v)) {
+			sb.WriteString(word[:1])
+		}
+		return sb.String() + strings.Join(args, ""), nil
+	})
+
+	// The formatter expression is split across runs.
+	body := `<w:p><w:r><w:t>Total: {{total | curr</w:t></w:r><w:r><w:t>ency:"PHP"}}</w:t></w:r></w:p>` +
+		para("{{client | test_initials:'.'}}") +
+		para("{{total | nosuch}}")
+
+	got := renderBody(t, body, map[string]any{"total": 18500, "client": "Acme Corporation"})
+	want := []string{"Total: ₱18,500.00", "AC.", "{{total | nosuch}}"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got lines %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,548 @@
+package doctemplate
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // register decoders for image.DecodeConfig
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+// Image placeholders put pictures — logos, signatures, QR codes — into a
+// document. There are two ways to mark where a picture goes:
+//
+//	{{%logo}}                      a tag in the text; the picture is placed
+//	{{%signature width=4cm}}       inline at that point. Give a width, a
+//	{{%qr width=3cm height=3cm}}   height, or both to fit it in a box.
+//
+// or a picture inserted in Word whose alt text is {{%logo}} (or {{logo}}).
+// That picture keeps its position, wrapping and size; its image is swapped
+// and fitted inside its box. The alt text may carry width/height like a tag.
+//
+// The aspect ratio of the image is always preserved. Sizes take the units
+// cm, mm, in, pt or px (96 per inch; also the unit of a bare number). A
+// picture with no size is placed at 96 DPI, scaled down to at most 6 inches
+// wide.
+//
+// The value in the data map may be an Image, raw []byte, or a base64 string
+// (optionally a data: URI), or — from JSON — an object with "data" (base64),
+// and optional "width", "height" and "description". Sizes in the value take
+// precedence over sizes in the tag. A nil value removes the tag or picture;
+// a missing key leaves it untouched.
+//
+// A tag ending with an image formatter, {{%ref | qrcode}} or simply
+// {{ref | qrcode}}, draws the picture from the value instead (see
+// formatters.go).
+
+// Image is the value of an image placeholder.
+type Image struct {
+	// Data holds the PNG, JPEG or GIF bytes.
+	Data []byte
+	// Width and Height override the size given in the template, e.g. "4cm".
+	Width  string
+	Height string
+	// Description becomes the picture's alt text; it defaults to the key.
+	Description string
+}
+
+const (
+	emuPerPixel = 9525
+	emuPerInch  = 914400
+
+	// maxNaturalWidth caps pictures placed without any size.
+	maxNaturalWidth = 6 * emuPerInch
+)
+
+// altTextTagRegex matches the alt text of a tagged picture.
+var altTextTagRegex = regexp.MustCompile(`^\s*{{\s*%?\s*([^#^/{}][^{}]*?)\s*}}\s*$`)
+
+// imageInfo describes decoded image bytes.
+type imageInfo struct {
+	ext           string
+	contentType   string
+	width, height int
+}
+
+// decodeImageInfo detects the format and pixel size of an image.
+func decodeImageInfo(data []byte) (imageInfo, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return imageInfo{}, fmt.Errorf("unsupported image data (want PNG, JPEG or GIF): %w", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return imageInfo{}, fmt.Errorf("image has no size (%dx%d)", cfg.Width, cfg.Height)
+	}
+	info := imageInfo{ext: format, contentType: "image/" + format, width: cfg.Width, height: cfg.Height}
+	if format == "jpeg" {
+		info.ext = "jpg"
+	}
+	return info, nil
+}
+
+// imageTag is a parsed image tag: the data key, its formatters and its
+// size options.
+type imageTag struct {
+	key           string
+	calls         []formatterCall
+	width, height string
+}
+
+// parseImageTag parses the inside of an image tag, e.g.
+// `logo width=4cm` or `ref | qrcode:"H" width=3cm`.
+func parseImageTag(inner string) (imageTag, error) {
+	var tag imageTag
+	for i, part := range splitUnquoted(quoteReplacer.Replace(inner), "|") {
+		var fields []string
+		for _, f := range splitUnquoted(strings.TrimSpace(part), " \t") {
+			if f != "" {
+				fields = append(fields, f)
+			}
+		}
+		if len(fields) == 0 {
+			if i == 0 {
+				return imageTag{}, errors.New("image tag without a key")
+			}
+			return imageTag{}, fmt.Errorf("image %q: empty formatter", tag.key)
+		}
+		if i == 0 {
+			tag.key = fields[0]
+		} else {
+			tag.calls = append(tag.calls, parseFormatterCall(fields[0]))
+		}
+		for _, f := range fields[1:] {
+			name, value, _ := strings.Cut(f, "=")
+			switch value = unquote(value); strings.ToLower(name) {
+			case "width":
+				tag.width = value
+			case "height":
+				tag.height = value
+			default:
+				return imageTag{}, fmt.Errorf("image %q: unknown option %q (want width= or height=)", tag.key, f)
+			}
+		}
+	}
+	return tag, nil
+}
+
+// isImagePlaceholder reports whether the inside of a plain {{...}} tag is
+// an image placeholder: one ending with an image formatter, such as
+// {{ref | qrcode}}.
+func isImagePlaceholder(inner string) bool {
+	tag, err := parseImageTag(inner)
+	if err != nil || len(tag.calls) == 0 {
+		return false
+	}
+	_, ok := lookupImageFormatter(tag.calls[len(tag.calls)-1].name)
+	return ok
+}
+
+// imageValue returns the image for a tag's value: the value itself or, when
+// the tag has formatters, the image they make of it. sized tells whether
+// the template gives the picture a size, which then takes precedence over
+// the formatter's default size. A nil image means the value is nil: the
+// placeholder is to be removed.
+func imageValue(tag imageTag, value any, sized bool) (*Image, error) {
+	if len(tag.calls) == 0 || value == nil {
+		return toImage(value)
+	}
+	last := tag.calls[len(tag.calls)-1]
+	fn, ok := lookupImageFormatter(last.name)
+	if !ok {
+		return nil, fmt.Errorf("%q is not an image formatter", last.name)
+	}
+	if len(tag.calls) > 1 {
+		text, err := applyFormatters(value, tag.calls[:len(tag.calls)-1])
+		if err != nil {
+			return nil, err
+		}
+		value = text
+	}
+	img, err := fn(value, last.args)
+	if err != nil {
+		return nil, fmt.Errorf("formatter %q: %w", last.name, err)
+	}
+	if sized {
+		img.Width, img.Height = "", ""
+	}
+	return &img, nil
+}
+
+// toImage converts a data value to an Image. A nil image means the value
+// is nil: the placeholder is to be removed.
+func toImage(value any) (*Image, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case Image:
+		return &v, nil
+	case *Image:
+		return v, nil
+	case []byte:
+		return &Image{Data: v}, nil
+	case string:
+		data, err := decodeBase64Image(v)
+		if err != nil {
+			return nil, err
+		}
+		return &Image{Data: data}, nil
+	case map[string]any:
+		img, err := toImage(v["data"])
+		if err != nil || img == nil {
+			if err == nil {
+				err = errors.New(`object has no "data"`)
+			}
+			return nil, err
+		}
+		img.Width, _ = v["width"].(string)
+		img.Height, _ = v["height"].(string)
+		img.Description, _ = v["description"].(string)
+		return img, nil
+	}
+	return nil, fmt.Errorf("cannot use %T as an image", value)
+}
+
+// decodeBase64Image decodes a base64 string or data: URI.
+func decodeBase64Image(s string) ([]byte, error) {
+	if strings.HasPrefix(s, "data:") {
+		_, payload, ok := strings.Cut(s, ";base64,")
+		if !ok {
+			return nil, errors.New("data URI is not base64-encoded")
+		}
+		s = payload
+	}
+	s = strings.Join(strings.Fields(s), "")
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		if data, rawErr := base64.RawStdEncoding.DecodeString(s); rawErr == nil {
+			return data, nil
+		}
+		return nil, fmt.Errorf("decoding base64 image: %w", err)
+	}
+	return data, nil
+}
+
+// lengthUnits maps length units to EMU.
+var lengthUnits = map[string]float64{
+	"cm": 360000,
+	"mm": 36000,
+	"in": emuPerInch,
+	"pt": 12700,
+	"px": emuPerPixel,
+	"":   emuPerPixel,
+}
+
+// parseLength converts a length such as "4cm" to EMU. An empty string is 0.
+func parseLength(s string) (int64, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	if s == "" {
+		return 0, nil
+	}
+	num := strings.TrimRightFunc(s, func(r rune) bool { return r >= 'a' && r <= 'z' })
+	perUnit, ok := lengthUnits[strings.TrimSpace(s[len(num):])]
+	n, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+	if !ok || err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q (want e.g. 4cm, 30mm, 1.5in, 72pt or 200px)", s)
+	}
+	return int64(math.Round(n * perUnit)), nil
+}
+
+// imageExtent returns the size in EMU of a picture showing info. Given both
+// a width and a height (or, without either, a box from the template), the
+// image is fitted inside; given one, the other follows the aspect ratio.
+func imageExtent(info imageInfo, width, height string, boxCX, boxCY int64) (cx, cy int64, err error) {
+	w, err := parseLength(width)
+	if err != nil {
+		return 0, 0, err
+	}
+	h, err := parseLength(height)
+	if err != nil {
+		return 0, 0, err
+	}
+	if w == 0 && h == 0 {
+		w, h = boxCX, boxCY
+	}
+
+	pw, ph := float64(info.width), float64(info.height)
+	var scale float64
+	switch {
+	case w > 0 && h > 0:
+		scale = math.Min(float64(w)/pw, float64(h)/ph)
+	case w > 0:
+		scale = float64(w) / pw
+	case h > 0:
+		scale = float64(h) / ph
+	default:
+		scale = math.Min(emuPerPixel, maxNaturalWidth/pw)
+	}
+	return int64(math.Round(pw * scale)), int64(math.Round(ph * scale)), nil
+}
+
+// ---------------------------------------------------------------------------
+// Rendering
+// ---------------------------------------------------------------------------
+
+// processImages replaces the tagged pictures and image tags of a paragraph.
+func (r *renderer) processImages(p *etree.Element, data map[string]any) {
+	if r.archive == nil {
+		return
+	}
+	r.replaceTaggedPictures(p, data)
+	r.replaceImageTags(p, data)
+}
+
+// replaceTaggedPictures swaps the image of pictures whose alt text is a tag.
+func (r *renderer) replaceTaggedPictures(p *etree.Element, data map[string]any) {
+	for _, drawing := range p.FindElements(".//drawing") {
+		docPr := drawing.FindElement(".//docPr")
+		blip := drawing.FindElement(".//blip")
+		if docPr == nil || blip == nil {
+			continue
+		}
+		m := altTextTagRegex.FindStringSubmatch(docPr.SelectAttrValue("descr", ""))
+		if m == nil {
+			m = altTextTagRegex.FindStringSubmatch(docPr.SelectAttrValue("title", ""))
+		}
+		if m == nil {
+			continue
+		}
+		tag, err := parseImageTag(m[1])
+		if err != nil {
+			r.fail(err)
+			continue
+		}
+		value, ok := getPathValue(data, tag.key)
+		if !ok {
+			// A blanked picture is removed like a nil image.
+			if r.missingValue(m[0], tag.key, errMissingValue) == "" {
+				drawing.Parent().RemoveChild(drawing)
+			}
+			continue
+		}
+		extent := drawing.FindElement(".//extent")
+		var boxCX, boxCY int64
+		if extent != nil {
+			boxCX, _ = strconv.ParseInt(extent.SelectAttrValue("cx", "0"), 10, 64)
+			boxCY, _ = strconv.ParseInt(extent.SelectAttrValue("cy", "0"), 10, 64)
+		}
+		sized := tag.width != "" || tag.height != "" || boxCX > 0 && boxCY > 0
+		img, err := imageValue(tag, value, sized)
+		if err != nil {
+			r.fail(fmt.Errorf("image %q: %w", tag.key, err))
+			continue
+		}
+		if img == nil {
+			drawing.Parent().RemoveChild(drawing)
+			continue
+		}
+		relID, cx, cy, err := r.placeImage(tag, img, boxCX, boxCY)
+		if err != nil {
+			r.fail(err)
+			continue
+		}
+
+		blip.CreateAttr("r:embed", relID)
+		setExtent(drawing, cx, cy)
+		docPr.CreateAttr("descr", imageDescription(tag, img))
+		docPr.RemoveAttr("title")
+	}
+}
+
+// replaceImageTags replaces {{%key}} tags in the paragraph text with inline
+// pictures. Tags may be split across runs.
+func (r *renderer) replaceImageTags(p *etree.Element, data map[string]any) {
+	for from := 0; ; {
+		nodes := textNodes(p)
+		var sb strings.Builder
+		for _, t := range nodes {
+			sb.WriteString(t.Text())
+		}
+		text := sb.String()
+		if from >= len(text) {
+			return
+		}
+		start, end, inner := nextImageTag(text, from)
+		if start < 0 {
+			return
+		}
+		from = end
+
+		tag, err := parseImageTag(inner)
+		if err != nil {
+			r.fail(err)
+			continue
+		}
+		value, ok := getPathValue(data, tag.key)
+		if !ok {
+			if r.missingValue(text[start:end], tag.key, errMissingValue) == "" {
+				insertAtTag(nodes, start, end)
+				from = start
+			}
+			continue
+		}
+		img, err := imageValue(tag, value, tag.width != "" || tag.height != "")
+		if err != nil {
+			r.fail(fmt.Errorf("image %q: %w", tag.key, err))
+			continue
+		}
+
+		var run *etree.Element
+		if img != nil {
+			relID, cx, cy, err := r.placeImage(tag, img, 0, 0)
+			if err != nil {
+				r.fail(err)
+				continue
+			}
+			run = newInlinePicture(relID, r.archive.nextDrawingID(), imageDescription(tag, img), cx, cy)
+		}
+		insertAtTag(nodes, start, end, run)
+		from = start
+	}
+}
+
+// nextImageTag finds the first image tag in text at or after from: a
+// {{%key}} tag or a placeholder ending with an image formatter. It returns
+// its byte range and the text inside it (without the %), or start -1.
+func nextImageTag(text string, from int) (start, end int, inner string) {
+	for _, loc := range tagRegex.FindAllStringSubmatchIndex(text[from:], -1) {
+		start, end, inner = from+loc[0], from+loc[1], text[from+loc[2]:from+loc[3]]
+		if strings.HasPrefix(inner, "%") {
+			return start, end, strings.TrimSpace(inner[1:])
+		}
+		if (start == 0 || text[start-1] != '{') && isImagePlaceholder(inner) {
+			return start, end, inner
+		}
+	}
+	return -1, -1, ""
+}
+
+// placeImage adds img to the archive and computes its extent.
+func (r *renderer) placeImage(tag imageTag, img *Image, boxCX, boxCY int64) (relID string, cx, cy int64, err error) {
+	info, err := decodeImageInfo(img.Data)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("image %q: %w", tag.key, err)
+	}
+	width, height := tag.width, tag.height
+	if img.Width != "" || img.Height != "" {
+		width, height = img.Width, img.Height
+	}
+	cx, cy, err = imageExtent(info, width, height, boxCX, boxCY)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("image %q: %w", tag.key, err)
+	}
+	relID, err = r.archive.AddImage(r.part, img.Data)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("image %q: %w", tag.key, err)
+	}
+	return relID, cx, cy, nil
+}
+
+func imageDescription(tag imageTag, img *Image) string {
+	if img.Description != "" {
+		return img.Description
+	}
+	return tag.key
+}
+
+// setExtent sets the size of a drawing and of the picture shape inside it.
+func setExtent(drawing *etree.Element, cx, cy int64) {
+	for _, path := range []string{".//extent", ".//pic/spPr/xfrm/ext"} {
+		if el := drawing.FindElement(path); el != nil {
+			el.CreateAttr("cx", strconv.FormatInt(cx, 10))
+			el.CreateAttr("cy", strconv.FormatInt(cy, 10))
+		}
+	}
+}
+
+// insertAtTag removes the tag text spanning [start, end) of the paragraph
+// text held by nodes and inserts runs where the tag was, splitting the run
+// that contained the tag's start. Nil runs are skipped.
+func insertAtTag(nodes []*etree.Element, start, end int, runs ...*etree.Element) {
+	offset := 0
+	var startNode *etree.Element
+	var after string
+	for _, t := range nodes {
+		text := t.Text()
+		nodeStart, nodeEnd := offset, offset+len(text)
+		offset = nodeEnd
+		if nodeEnd <= start || nodeStart >= end {
+			continue
+		}
+		from, to := max(start-nodeStart, 0), min(end-nodeStart, len(text))
+		if startNode == nil {
+			startNode = t
+			after = text[to:]
+			setText(t, text[:from])
+			continue
+		}
+		setText(t, text[:from]+text[to:])
+	}
+	runs = slices.DeleteFunc(runs, func(run *etree.Element) bool { return run == nil })
+	if startNode == nil || len(runs) == 0 {
+		return
+	}
+
+	textRun := startNode.Parent()
+	if textRun == nil || textRun.Parent() == nil {
+		return
+	}
+	container := textRun.Parent()
+	index := textRun.Index() + 1
+	for i, run := range runs {
+		container.InsertChildAt(index+i, run)
+	}
+	if after != "" {
+		// Move the text that followed the tag in the same node behind the
+		// inserted runs, keeping the run's formatting.
+		tail := textRun.Copy()
+		for _, child := range tail.ChildElements() {
+			if child.Tag != "rPr" {
+				tail.RemoveChild(child)
+			}
+		}
+		setText(tail.CreateElement(startNode.FullTag()), after)
+		container.InsertChildAt(index+len(runs), tail)
+	}
+}
+
+// inlinePictureXML is the run of an inline picture. The drawing namespaces
+// are declared locally, as templates need not declare them.
+const inlinePictureXML = `<root xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">` +
+	`<w:r><w:drawing><wp:inline distT="0" distB="0" distL="0" distR="0" xmlns:wp="http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing">` +
+	`<wp:extent/><wp:effectExtent l="0" t="0" r="0" b="0"/><wp:docPr/>` +
+	`<wp:cNvGraphicFramePr><a:graphicFrameLocks xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" noChangeAspect="1"/></wp:cNvGraphicFramePr>` +
+	`<a:graphic xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main"><a:graphicData uri="http://schemas.openxmlformats.org/drawingml/2006/picture">` +
+	`<pic:pic xmlns:pic="http://schemas.openxmlformats.org/drawingml/2006/picture">` +
+	`<pic:nvPicPr><pic:cNvPr id="0"/><pic:cNvPicPr/></pic:nvPicPr>` +
+	`<pic:blipFill><a:blip xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"/><a:stretch><a:fillRect/></a:stretch></pic:blipFill>` +
+	`<pic:spPr><a:xfrm><a:off x="0" y="0"/><a:ext/></a:xfrm><a:prstGeom prst="rect"><a:avLst/></a:prstGeom></pic:spPr>` +
+	`</pic:pic></a:graphicData></a:graphic></wp:inline></w:drawing></w:r></root>`
+
+// newInlinePicture builds a run holding an inline picture.
+func newInlinePicture(relID string, id int, description string, cx, cy int64) *etree.Element {
+	doc := etree.NewDocument()
+	if err := doc.ReadFromString(inlinePictureXML); err != nil {
+		panic("doctemplate: invalid inline picture XML: " + err.Error())
+	}
+	run := doc.Root().SelectElement("r")
+	doc.Root().RemoveChild(run)
+
+	name := "Picture " + strconv.Itoa(id)
+	docPr := run.FindElement(".//docPr")
+	docPr.CreateAttr("id", strconv.Itoa(id))
+	docPr.CreateAttr("name", name)
+	docPr.CreateAttr("descr", description)
+	run.FindElement(".//cNvPr").CreateAttr("name", name)
+	run.FindElement(".//blip").CreateAttr("r:embed", relID)
+	setExtent(run, cx, cy)
+	return run
+}
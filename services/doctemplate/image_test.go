@@ -0,0 +1,204 @@
+package doctemplate
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/erniealice/fycha-golang/services/barcode"
+)
+
+// testPNG returns a blank PNG of the given pixel size.
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatalf("encoding PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func partText(t *testing.T, archive *DocxArchive, name string) string {
+	t.Helper()
+	data, ok, err := archive.readPart(name)
+	if err != nil || !ok {
+		t.Fatalf("part %s: found=%v err=%v", name, ok, err)
+	}
+	return string(data)
+}
+
+func TestProcessTemplate_ImageTags(t *testing.T) {
+	logo := testPNG(t, 200, 100)
+	body := para("{{%logo width=4cm}}") +
+		`<w:p><w:r><w:rPr><w:b/></w:rPr><w:t>Signed: {{%sig</w:t></w:r><w:r><w:t> height=1cm}} (approver)</w:t></w:r></w:p>` +
+		`<w:p><w:r><w:rPr><w:i/></w:rPr><w:t>Again: {{%again}} (copy)</w:t></w:r></w:p>` +
+		para("{{%removed}}") +
+		para("{{%missing}}")
+
+	archive := renderDocx(t, body, map[string]any{
+		"logo":    logo,
+		"sig":     map[string]any{"data": base64.StdEncoding.EncodeToString(testPNG(t, 300, 100)), "description": "Signature"},
+		"again":   Image{Data: logo, Width: "2cm"},
+		"removed": nil,
+	})
+	content := archive.Content
+
+	for _, want := range []string{
+		`<wp:extent cx="1440000" cy="720000"/>`, // 4cm wide, 2:1
+		`<wp:extent cx="1080000" cy="360000"/>`, // 1cm high, 3:1
+		`<wp:extent cx="720000" cy="360000"/>`,  // data overrides the tag size
+		`descr="Signature"`,
+		`(approver)`,
+		`{{%missing}}`,
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("document does not contain %s", want)
+		}
+	}
+	if strings.Contains(content, "{{%logo") || strings.Contains(content, "{{%sig") || strings.Contains(content, "{{%removed}}") {
+		t.Error("image tags left in the document")
+	}
+
+	// The picture sits between the text before and after the tag, and text
+	// after it in the same run keeps the run's formatting.
+	lines := documentLines(content)
+	if len(lines) != 3 || lines[0] != "Signed:  (approver)" || lines[1] != "Again:  (copy)" {
+		t.Errorf("lines = %q", lines)
+	}
+	if i := strings.Index(content, "Signed: "); i < 0 || !strings.Contains(content[i:], `<w:drawing>`) ||
+		strings.Index(content[i:], `<w:drawing>`) > strings.Index(content[i:], " (approver)") {
+		t.Error("signature picture is not between the surrounding text")
+	}
+	if !strings.Contains(content, `</w:drawing></w:r><w:r><w:rPr><w:i/></w:rPr><w:t xml:space="preserve"> (copy)</w:t></w:r>`) {
+		t.Errorf("text after the tag lost its run formatting:\n%s", content)
+	}
+
+	// The logo is stored once and related once; the signature separately.
+	if len(archive.Images) != 2 {
+		t.Errorf("media parts = %d, want 2", len(archive.Images))
+	}
+	rels := partText(t, archive, "word/_rels/document.xml.rels")
+	if strings.Count(rels, relTypeImage) != 2 || !strings.Contains(rels, `Target="media/image1.png"`) {
+		t.Errorf("relationships = %s", rels)
+	}
+	if types := partText(t, archive, "[Content_Types].xml"); !strings.Contains(types, `<Default Extension="png" ContentType="image/png"/>`) {
+		t.Errorf("content types = %s", types)
+	}
+}
+
+func TestProcessTemplate_TaggedPicture(t *testing.T) {
+	picture := func(altText string) string {
+		return `<w:p><w:r><w:drawing><wp:anchor xmlns:wp="http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing">` +
+			`<wp:extent cx="2000000" cy="2000000"/><wp:docPr id="7" name="Picture 7" descr="` + altText + `"/>` +
+			`<a:graphic xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main"><a:graphicData><pic:pic xmlns:pic="http://schemas.openxmlformats.org/drawingml/2006/picture">` +
+			`<pic:blipFill><a:blip xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships" r:embed="rId9"/></pic:blipFill>` +
+			`<pic:spPr><a:xfrm><a:off x="0" y="0"/><a:ext cx="2000000" cy="2000000"/></a:xfrm></pic:spPr>` +
+			`</pic:pic></a:graphicData></a:graphic></wp:anchor></w:drawing></w:r></w:p>`
+	}
+
+	archive := renderDocx(t, picture("{{%logo}}")+picture("{{ stamp }}"), map[string]any{
+		"logo":  testPNG(t, 400, 200),
+		"stamp": nil,
+	})
+	content := archive.Content
+
+	for _, want := range []string{
+		`<wp:extent cx="2000000" cy="1000000"/>`,
+		`<a:ext cx="2000000" cy="1000000"/>`,
+		`r:embed="rId1"`,
+		`descr="logo"`,
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("document does not contain %s", want)
+		}
+	}
+	if strings.Count(content, "<w:drawing>") != 1 {
+		t.Errorf("drawings = %d, want 1 (the nil stamp removed)", strings.Count(content, "<w:drawing>"))
+	}
+}
+
+func TestProcessTemplate_InvalidImage(t *testing.T) {
+	_, err := ProcessTemplate(testTemplate(t, para("{{%logo}}")), map[string]any{"logo": []byte("not an image")})
+	if err == nil || !strings.Contains(err.Error(), `image "logo"`) {
+		t.Errorf("error = %v, want an image error", err)
+	}
+}
+
+func TestProcessTemplate_ImageFormatters(t *testing.T) {
+	const url = "https://verify.example.com/or/OR-000123"
+	body := para("{{%receipt.url | qrcode}}") +
+		para("OR No. {{receipt.number}} {{receipt.number | barcode height=1cm}}") +
+		para(`{{%asset | upper | qrcode:"H" width=2cm}}`) +
+		para("{{%none | qrcode}}")
+
+	archive := renderDocx(t, body, map[string]any{
+		"receipt": map[string]any{"url": url, "number": "OR-000123"},
+		"asset":   "ast-7",
+		"none":    nil,
+	})
+	content := archive.Content
+
+	for _, want := range []string{
+		`<wp:extent cx="999000" cy="999000"/>`,  // version 3: 37 modules at 0.75mm
+		`<wp:extent cx="1056000" cy="360000"/>`, // 132 modules, 1cm high
+		`<wp:extent cx="720000" cy="720000"/>`,  // the tag size wins
+		`descr="` + url + `"`,
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("document does not contain %s", want)
+		}
+	}
+	if lines := documentLines(content); len(lines) != 1 || lines[0] != "OR No. OR-000123" {
+		t.Errorf("lines = %q", lines)
+	}
+
+	// The pictures are the codes of the formatted values.
+	q, err := barcode.EncodeQR("AST-7", barcode.High)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := q.PNG(10)
+	found := false
+	for _, data := range archive.Images {
+		found = found || bytes.Equal(data, want)
+	}
+	if len(archive.Images) != 3 || !found {
+		t.Errorf("got %d images, want 3 including the QR code of AST-7", len(archive.Images))
+	}
+
+	// A formatter that does not make images is an error.
+	if _, err := ProcessTemplate(testTemplate(t, para("{{%asset | upper}}")), map[string]any{"asset": "x"}); err == nil {
+		t.Error("ProcessTemplate succeeded with a text formatter on an image tag")
+	}
+}
+
+func TestImageExtent(t *testing.T) {
+	info := imageInfo{width: 960, height: 480}
+	tests := []struct {
+		width, height string
+		boxCX, boxCY  int64
+		wantCX        int64
+		wantCY        int64
+	}{
+		{"", "", 0, 0, 9144000 * 6 / 10, 9144000 * 3 / 10}, // 10in at 96 DPI, capped to 6in
+		{"10cm", "", 0, 0, 3600000, 1800000},
+		{"", "1in", 0, 0, 1828800, 914400},
+		{"10cm", "10cm", 0, 0, 3600000, 1800000},
+		{"", "", 1000000, 250000, 500000, 250000},
+		{"480px", "", 0, 0, 4572000, 2286000},
+		{"240", "", 0, 0, 2286000, 1143000},
+	}
+	for _, tt := range tests {
+		cx, cy, err := imageExtent(info, tt.width, tt.height, tt.boxCX, tt.boxCY)
+		if err != nil || cx != tt.wantCX || cy != tt.wantCY {
+			t.Errorf("imageExtent(%q, %q, %d, %d) = %d, %d, %v; want %d, %d",
+				tt.width, tt.height, tt.boxCX, tt.boxCY, cx, cy, err, tt.wantCX, tt.wantCY)
+		}
+	}
+	if _, _, err := imageExtent(info, "4furlongs", "", 0, 0); err == nil {
+		t.Error("unknown unit accepted")
+	}
+}
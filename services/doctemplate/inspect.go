@@ -0,0 +1,642 @@
+package doctemplate
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/beevik/etree"
+)
+
+// Inspect and Validate let callers check a template before using it, e.g.
+// when a template is uploaded. Inspect lists the tags a template uses;
+// Validate also checks them against sample data, so that a mistyped
+// {{client.nmae}} is caught before it reaches a customer.
+
+// TagKind classifies a template tag.
+type TagKind string
+
+const (
+	TagPlaceholder TagKind = "placeholder" // {{path}} or {{path | formatter}}
+	TagSection     TagKind = "section"     // {{#key}}: a loop, or a section over a map or value
+	TagInverted    TagKind = "inverted"    // {{^key}}
+	TagIf          TagKind = "if"          // {{#if expr}}
+	TagElse        TagKind = "else"        // {{else}}
+	TagClose       TagKind = "close"       // {{/key}} or {{/if}}
+	TagImage       TagKind = "image"       // {{%key}}, {{key | qrcode}} or a picture with that alt text
+	TagRichText    TagKind = "rich_text"   // {{{path}}} or {{{path | html}}}
+)
+
+// Location identifies the paragraph holding a tag.
+type Location struct {
+	// Part is the package part, e.g. "word/document.xml" or "word/header1.xml".
+	Part string
+	// Paragraph is the 1-based index of the paragraph within the part, in
+	// document order and counting paragraphs in table cells and text boxes.
+	// In document properties (docProps/core.xml) it counts property values.
+	Paragraph int
+	// Text is the start of the paragraph's text, for display.
+	Text string
+}
+
+func (l Location) String() string {
+	return fmt.Sprintf("%s paragraph %d (%q)", l.Part, l.Paragraph, l.Text)
+}
+
+// Tag is one tag found in a template.
+type Tag struct {
+	Kind TagKind
+	// Raw is the tag as written, e.g. `{{ total | currency:"PHP" }}`.
+	Raw string
+	// Path is the data path the tag reads, relative to its scope. It is empty
+	// for {{else}}, and for {{#if}} (see Condition).
+	Path string
+	// Condition is the expression of an {{#if}} tag; ConditionPaths are the
+	// data paths it reads.
+	Condition      string
+	ConditionPaths []string
+	// Formatters names the formatters of a placeholder, in order.
+	Formatters []string
+	// Default is the text after "??" in a placeholder such as
+	// {{name ?? "N/A"}}, written when the value is missing; HasDefault
+	// reports whether the placeholder has one.
+	Default    string
+	HasDefault bool
+	// Scope lists the enclosing {{#key}} sections, outermost first: a
+	// placeholder {{amount}} inside {{#invoices}}{{#items}} has the scope
+	// [invoices items].
+	Scope []string
+	// Block is true when the tag stands alone in its paragraph (or table
+	// row) and so delimits whole paragraphs or rows.
+	Block    bool
+	Location Location
+}
+
+// NestingPath returns the tag's path qualified by its scope, e.g.
+// "invoices.items.amount", or "invoices.number" for {{../number}} in the
+// same place.
+func (t Tag) NestingPath() string {
+	return qualifyPath(t.Scope, t.Path)
+}
+
+// ProblemKind classifies a template problem.
+type ProblemKind string
+
+const (
+	ProblemUnknownKey       ProblemKind = "unknown_key"       // the sample data has no value for a path
+	ProblemUnclosed         ProblemKind = "unclosed"          // a section or if has no closing tag
+	ProblemMismatched       ProblemKind = "mismatched"        // a closing or else tag does not fit the open block
+	ProblemUnexpected       ProblemKind = "unexpected"        // a closing or else tag with no open block
+	ProblemUnknownFormatter ProblemKind = "unknown_formatter" // a placeholder uses an unregistered formatter
+	ProblemInvalidTag       ProblemKind = "invalid_tag"       // a tag that cannot be parsed
+	ProblemInlineLoop       ProblemKind = "inline_loop"       // a loop over a list inside a paragraph
+)
+
+// Problem is an issue found in a template.
+type Problem struct {
+	Kind     ProblemKind
+	Message  string
+	Tag      string
+	Location Location
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s", p.Location, p.Message)
+}
+
+// TemplateInfo describes the tags of a template.
+type TemplateInfo struct {
+	// Tags lists every tag in document order: the body first, then headers
+	// and footers.
+	Tags []Tag
+	// Problems lists structural problems: unclosed, mismatched and
+	// unexpected markers, invalid tags and unknown formatters.
+	Problems []Problem
+}
+
+// Paths returns the distinct nesting paths read by placeholders, images,
+// sections and conditions, sorted. Loop variables such as @index are not
+// data paths and are left out.
+func (info *TemplateInfo) Paths() []string {
+	seen := make(map[string]bool)
+	for _, t := range info.Tags {
+		switch t.Kind {
+		case TagPlaceholder, TagRichText, TagImage, TagSection, TagInverted:
+			if !isLoopVariable(t.Path) {
+				seen[t.NestingPath()] = true
+			}
+		case TagIf:
+			for _, p := range t.ConditionPaths {
+				if !isLoopVariable(p) {
+					seen[Tag{Path: p, Scope: t.Scope}.NestingPath()] = true
+				}
+			}
+		}
+	}
+	paths := make([]string, 0, len(seen))
+	for p := range seen {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Sections returns the tags opening {{#key}} sections (typically loops).
+func (info *TemplateInfo) Sections() []Tag {
+	var sections []Tag
+	for _, t := range info.Tags {
+		if t.Kind == TagSection {
+			sections = append(sections, t)
+		}
+	}
+	return sections
+}
+
+// Inspect lists the tags of a DOCX template and its structural problems.
+// Tags split across runs are found like the engine finds them.
+func Inspect(template []byte) (*TemplateInfo, error) {
+	info, _, err := analyzeTemplate(template, nil)
+	return info, err
+}
+
+// Validate checks a DOCX template against sample data, returning the
+// structural problems Inspect finds plus paths the data does not provide
+// and loops placed inside a paragraph. Paths are resolved in scope the way
+// ProcessTemplate resolves them: inside a loop, against the list's items
+// (a path any item provides is accepted), with the loop variables and
+// "../" and "@root." paths. Nothing is checked inside a loop over an empty
+// list. A nil result means no problems.
+func Validate(template []byte, sampleData map[string]any) ([]Problem, error) {
+	if sampleData == nil {
+		sampleData = map[string]any{}
+	}
+	_, problems, err := analyzeTemplate(template, sampleData)
+	return problems, err
+}
+
+// analyzeTemplate walks every part that ProcessTemplate renders. With data,
+// it also resolves paths; problems then includes the data problems.
+func analyzeTemplate(template []byte, data map[string]any) (*TemplateInfo, []Problem, error) {
+	archive, err := ReadDocxBytes(template)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading docx: %w", err)
+	}
+
+	info := &TemplateInfo{}
+	var problems []Problem
+	for _, part := range archive.renderedParts() {
+		doc := etree.NewDocument()
+		if err := doc.ReadFromString(part.content); err != nil {
+			return nil, nil, fmt.Errorf("parsing %s: %w", part.name, err)
+		}
+		a := &analyzer{info: info, part: part.name, checkData: data != nil}
+		if data != nil {
+			a.contexts = [][]map[string]any{{data}}
+		}
+		if slices.Contains(propertyParts, part.name) {
+			a.properties(doc.Root())
+		} else {
+			a.walk(doc.Root())
+		}
+		a.finish()
+		problems = append(problems, a.problems...)
+	}
+	info.Problems = structuralProblems(problems)
+	if data == nil {
+		return info, nil, nil
+	}
+	return info, problems, nil
+}
+
+// renderedPart is an XML part ProcessTemplate renders.
+type renderedPart struct {
+	name    string
+	content string
+}
+
+// renderedParts returns the document, headers, footers and other rendered
+// parts in a stable order.
+func (archive *DocxArchive) renderedParts() []renderedPart {
+	parts := []renderedPart{{"word/document.xml", archive.Content}}
+	for _, m := range []map[string]string{archive.Headers, archive.Footers, archive.Parts} {
+		names := make([]string, 0, len(m))
+		for name := range m {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			parts = append(parts, renderedPart{name, m[name]})
+		}
+	}
+	return parts
+}
+
+func structuralProblems(problems []Problem) []Problem {
+	var out []Problem
+	for _, p := range problems {
+		if p.Kind != ProblemUnknownKey && p.Kind != ProblemInlineLoop {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// openBlock is a section or if awaiting its closing tag.
+type openBlock struct {
+	tag       Tag
+	marker    marker
+	container *etree.Element // parent of a block marker; the paragraph of an inline tag
+}
+
+// analyzer collects the tags of one part.
+type analyzer struct {
+	info      *TemplateInfo
+	part      string
+	paragraph int
+	stack     []openBlock
+	problems  []Problem
+
+	// checkData enables path resolution. contexts parallels stack (plus the
+	// root): the candidate scopes paths resolve against; a nil entry means
+	// the scope is unknown and nothing is checked.
+	checkData bool
+	contexts  [][]map[string]any
+}
+
+func (a *analyzer) walk(el *etree.Element) {
+	if el == nil {
+		return
+	}
+	for _, child := range el.ChildElements() {
+		if child.Space == "mc" && child.Tag == "Fallback" {
+			// The same content as mc:Choice (a text box in VML, say).
+			continue
+		}
+		if child.Tag == "p" {
+			a.paragraphTags(child)
+		}
+		// Nested content (cells, text boxes, content controls) follows its
+		// paragraph in document order.
+		a.walk(child)
+	}
+}
+
+func (a *analyzer) problem(kind ProblemKind, tag Tag, format string, args ...any) {
+	a.problems = append(a.problems, Problem{
+		Kind:     kind,
+		Message:  fmt.Sprintf(format, args...),
+		Tag:      tag.Raw,
+		Location: tag.Location,
+	})
+}
+
+// paragraphTags records the tags of one paragraph.
+func (a *analyzer) paragraphTags(p *etree.Element) {
+	a.paragraph++
+	text := paragraphText(p)
+	loc := Location{Part: a.part, Paragraph: a.paragraph, Text: excerpt(text)}
+
+	// A paragraph (or the row it fills) holding only a tag is a block marker.
+	var blockContainer *etree.Element
+	if m, ok := blockMarker(p); ok && m.kind != markerNone {
+		blockContainer = p.Parent()
+		if tc := p.Parent(); tc != nil && tc.Tag == "tc" {
+			if row := tc.Parent(); row != nil && row.Tag == "tr" {
+				if rm, ok := blockMarker(row); ok && rm == m {
+					blockContainer = row.Parent()
+				}
+			}
+		}
+	}
+
+	for _, loc2 := range tagRegex.FindAllStringSubmatchIndex(text, -1) {
+		raw, inner := text[loc2[0]:loc2[1]], text[loc2[2]:loc2[3]]
+		tag := Tag{Raw: raw, Location: loc, Block: blockContainer != nil, Scope: a.scope()}
+		container := p
+		if tag.Block {
+			container = blockContainer
+		}
+
+		if m, ok := parseMarker(inner); ok {
+			a.marker(tag, m, container)
+			continue
+		}
+		if strings.HasPrefix(inner, "%") {
+			a.image(tag, strings.TrimPrefix(inner, "%"))
+			continue
+		}
+		if start, end := loc2[0]-1, loc2[1]+1; start >= 0 && end <= len(text) && text[start] == '{' && text[end-1] == '}' {
+			tag.Raw = text[start:end]
+			tag.Kind = TagRichText
+		} else if isImagePlaceholder(inner) {
+			a.image(tag, inner)
+			continue
+		}
+		a.placeholder(tag, inner)
+	}
+
+	for _, docPr := range p.FindElements(".//drawing//docPr") {
+		for _, attr := range []string{"descr", "title"} {
+			if m := altTextTagRegex.FindStringSubmatch(docPr.SelectAttrValue(attr, "")); m != nil {
+				a.image(Tag{Raw: m[0], Location: loc, Scope: a.scope()}, m[1])
+				break
+			}
+		}
+	}
+}
+
+// properties records the tags in the values of a document properties
+// part. Each value counts as a paragraph for Location.
+func (a *analyzer) properties(root *etree.Element) {
+	if root == nil {
+		return
+	}
+	for _, el := range root.FindElements(".//*") {
+		if len(el.ChildElements()) > 0 {
+			continue
+		}
+		a.paragraph++
+		text := el.Text()
+		loc := Location{Part: a.part, Paragraph: a.paragraph, Text: excerpt(text)}
+		for _, m := range tagRegex.FindAllStringSubmatch(text, -1) {
+			tag := Tag{Raw: m[0], Location: loc}
+			if rich := richTagRegex.FindString(text); rich != "" && strings.Contains(rich, m[0]) {
+				tag.Raw = rich
+			}
+			if _, isMarker := parseMarker(m[1]); isMarker || strings.HasPrefix(m[1], "%") || isImagePlaceholder(m[1]) || tag.Raw != m[0] {
+				a.problem(ProblemInvalidTag, tag, "%s is not supported in document properties, which take plain values", tag.Raw)
+				continue
+			}
+			a.placeholder(tag, m[1])
+		}
+	}
+}
+
+// excerpt shortens paragraph text for a Location.
+func excerpt(text string) string {
+	text = strings.TrimSpace(text)
+	if utf8.RuneCountInString(text) <= 60 {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:57]) + "..."
+}
+
+// scope returns the keys of the enclosing sections.
+func (a *analyzer) scope() []string {
+	var keys []string
+	for _, b := range a.stack {
+		if b.marker.kind == markerSection {
+			keys = append(keys, b.marker.name)
+		}
+	}
+	return keys
+}
+
+func (a *analyzer) marker(tag Tag, m marker, container *etree.Element) {
+	switch m.kind {
+	case markerIf:
+		tag.Kind, tag.Condition = TagIf, m.expr
+		paths, err := conditionPaths(m.expr)
+		if err != nil {
+			a.problem(ProblemInvalidTag, tag, "invalid condition in %s: %v", tag.Raw, err)
+		}
+		tag.ConditionPaths = paths
+		for _, p := range paths {
+			a.checkPath(tag, p)
+		}
+	case markerSection:
+		tag.Kind, tag.Path = TagSection, m.name
+	case markerInverted:
+		tag.Kind, tag.Path = TagInverted, m.name
+	case markerElse:
+		tag.Kind = TagElse
+	case markerClose:
+		tag.Kind, tag.Path = TagClose, m.name
+	}
+	a.info.Tags = append(a.info.Tags, tag)
+
+	switch {
+	case m.opens():
+		a.open(tag, m, container)
+	case m.kind == markerElse:
+		if len(a.stack) == 0 {
+			a.problem(ProblemUnexpected, tag, "{{else}} outside any section or if")
+			return
+		}
+		if top := a.stack[len(a.stack)-1]; top.container != container {
+			a.problem(ProblemMismatched, tag, "{{else}} of %s (%s) must be %s", top.tag.Raw, top.tag.Location, placement(top.tag.Block))
+		}
+	case m.kind == markerClose:
+		a.close(tag, m, container)
+	}
+}
+
+// placement describes where the tags of a block must be.
+func placement(block bool) string {
+	if block {
+		return "alone in a paragraph (or row) next to the opening tag's, in the same table cell or body"
+	}
+	return "in the same paragraph as the opening tag"
+}
+
+func (a *analyzer) open(tag Tag, m marker, container *etree.Element) {
+	var ctx []map[string]any
+	if a.checkData {
+		ctx = a.contexts[len(a.contexts)-1]
+		switch m.kind {
+		case markerSection:
+			ctx = a.sectionContext(tag, m.name, ctx)
+		case markerInverted:
+			a.checkPath(tag, m.name)
+		}
+		a.contexts = append(a.contexts, ctx)
+	}
+	a.stack = append(a.stack, openBlock{tag: tag, marker: m, container: container})
+}
+
+// sectionContext resolves a {{#key}} section and returns the scope of its
+// content: the items of a list, a map, or the enclosing scope for other
+// values.
+func (a *analyzer) sectionContext(tag Tag, key string, ctx []map[string]any) []map[string]any {
+	if ctx == nil {
+		return nil
+	}
+	var next []map[string]any
+	found := false
+	for _, scope := range ctx {
+		value, ok := getPathValue(scope, key)
+		if !ok {
+			continue
+		}
+		found = true
+		if items, isList := asList(value); isList {
+			if !tag.Block {
+				a.problem(ProblemInlineLoop, tag, "%s loops over a list, which only works with the tag alone in its own paragraph or table row", tag.Raw)
+			}
+			for i, item := range items {
+				next = append(next, itemScope(item, scope, i, len(items)))
+			}
+			continue
+		}
+		if m, ok := value.(map[string]any); ok && tag.Block {
+			next = append(next, childScope(m, scope))
+			continue
+		}
+		next = append(next, scope)
+	}
+	if !found {
+		a.problem(ProblemUnknownKey, tag, "unknown key %q in %s", key, tag.Raw)
+	}
+	return next
+}
+
+func (a *analyzer) close(tag Tag, m marker, container *etree.Element) {
+	match := -1
+	for i := len(a.stack) - 1; i >= 0; i-- {
+		if a.stack[i].marker.closes(m) {
+			match = i
+			break
+		}
+	}
+	if match < 0 {
+		a.problem(ProblemUnexpected, tag, "%s closes nothing: no open {{#%s}}", tag.Raw, m.name)
+		return
+	}
+	for i := len(a.stack) - 1; i > match; i-- {
+		a.unclosed(a.stack[i], fmt.Sprintf(" before %s (%s)", tag.Raw, tag.Location))
+	}
+	if open := a.stack[match]; open.container != container {
+		a.problem(ProblemMismatched, tag, "%s closes %s (%s) but must be %s", tag.Raw, open.tag.Raw, open.tag.Location, placement(open.tag.Block))
+	}
+	a.pop(match)
+}
+
+func (a *analyzer) pop(depth int) {
+	a.stack = a.stack[:depth]
+	if a.checkData {
+		a.contexts = a.contexts[:depth+1]
+	}
+}
+
+func (a *analyzer) unclosed(b openBlock, where string) {
+	a.problem(ProblemUnclosed, b.tag, "%s is not closed%s: add {{/%s}}", b.tag.Raw, where, b.marker.name)
+}
+
+// finish reports the blocks left open at the end of the part.
+func (a *analyzer) finish() {
+	for i := len(a.stack) - 1; i >= 0; i-- {
+		a.unclosed(a.stack[i], "")
+	}
+	a.pop(0)
+}
+
+func (a *analyzer) image(tag Tag, inner string) {
+	tag.Kind = TagImage
+	it, err := parseImageTag(inner)
+	if err != nil {
+		a.info.Tags = append(a.info.Tags, tag)
+		a.problem(ProblemInvalidTag, tag, "%v", err)
+		return
+	}
+	tag.Path = it.key
+	for _, c := range it.calls {
+		tag.Formatters = append(tag.Formatters, c.name)
+	}
+	a.info.Tags = append(a.info.Tags, tag)
+	for _, size := range []string{it.width, it.height} {
+		if _, err := parseLength(size); err != nil {
+			a.problem(ProblemInvalidTag, tag, "%s: %v", tag.Raw, err)
+		}
+	}
+	for i, c := range it.calls {
+		if i == len(it.calls)-1 {
+			if _, ok := lookupImageFormatter(c.name); !ok {
+				a.problem(ProblemUnknownFormatter, tag, "%q in %s is not an image formatter", c.name, tag.Raw)
+			}
+		} else if _, ok := lookupFormatter(c.name); !ok {
+			a.problem(ProblemUnknownFormatter, tag, "unknown formatter %q in %s", c.name, tag.Raw)
+		}
+	}
+	a.checkPath(tag, it.key)
+}
+
+// placeholder records a placeholder, or a rich placeholder when tag.Kind
+// is already TagRichText; those may end with the html formatter.
+func (a *analyzer) placeholder(tag Tag, inner string) {
+	if tag.Kind != TagRichText {
+		tag.Kind = TagPlaceholder
+	}
+	pe := parsePlaceholderExpr(inner)
+	tag.Path = pe.path
+	tag.Default, tag.HasDefault = pe.fallback, pe.hasFallback
+	for i, c := range pe.calls {
+		tag.Formatters = append(tag.Formatters, c.name)
+		if tag.Kind == TagRichText && c.name == "html" && i == len(pe.calls)-1 {
+			continue
+		}
+		if _, ok := lookupFormatter(c.name); !ok {
+			a.problem(ProblemUnknownFormatter, tag, "unknown formatter %q in %s", c.name, tag.Raw)
+		}
+	}
+	a.info.Tags = append(a.info.Tags, tag)
+	if !tag.HasDefault {
+		a.checkPath(tag, pe.path)
+	}
+}
+
+// checkPath reports a path the sample data does not provide in the current
+// scope.
+func (a *analyzer) checkPath(tag Tag, path string) {
+	if !a.checkData || path == "" {
+		return
+	}
+	ctx := a.contexts[len(a.contexts)-1]
+	if ctx == nil {
+		return
+	}
+	for _, scope := range ctx {
+		if _, ok := getPathValue(scope, path); ok {
+			return
+		}
+	}
+	where := ""
+	if s := a.scope(); len(s) > 0 {
+		where = " inside {{#" + strings.Join(s, "}}/{{#") + "}}"
+	}
+	a.problem(ProblemUnknownKey, tag, "unknown key %q in %s%s", path, tag.Raw, where)
+}
+
+// conditionPaths returns the data paths an if expression reads.
+func conditionPaths(expr string) ([]string, error) {
+	tokens, err := tokenizeCondition(expr)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, tok := range tokens {
+		if tok.quoted || isConditionOperator(tok.text) {
+			continue
+		}
+		switch tok.text {
+		case "true", "false", "null", "nil":
+			continue
+		}
+		if _, isNumber := toNumber(tok.text); isNumber {
+			continue
+		}
+		paths = append(paths, tok.text)
+	}
+	return paths, nil
+}
+
+func isConditionOperator(s string) bool {
+	for _, op := range conditionOperators {
+		if s == op {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,258 @@
+package doctemplate
+
+import (
+	"strings"
+	"testing"
+)
+
+func tableRow(cells ...string) string {
+	var sb strings.Builder
+	sb.WriteString("<w:tr>")
+	for _, c := range cells {
+		sb.WriteString("<w:tc>" + para(c) + "</w:tc>")
+	}
+	sb.WriteString("</w:tr>")
+	return sb.String()
+}
+
+// invoiceBody is a template using every kind of tag.
+var invoiceBody = `<w:p><w:r><w:t>Invoice for {{cli</w:t></w:r><w:r><w:t>ent.name}}</w:t></w:r></w:p>` +
+	para("{{%logo width=3cm}}") +
+	"<w:tbl>" +
+	tableRow("{{#items}}", "") +
+	tableRow("{{description}}", `{{amount | currency:"PHP"}}`) +
+	tableRow("{{/items}}", "") +
+	"</w:tbl>" +
+	para(`{{#if status == "paid" &amp;&amp; total &gt; 0}}`) +
+	para("Paid {{paid_on | date}}") +
+	para("{{else}}") +
+	para("{{^overdue}}Due {{due_on}}{{/overdue}}") +
+	para("{{/if}}")
+
+func TestInspect(t *testing.T) {
+	info, err := Inspect(testTemplate(t, invoiceBody))
+	if err != nil {
+		t.Fatalf("Inspect: %v", err)
+	}
+	if len(info.Problems) != 0 {
+		t.Errorf("problems = %v", info.Problems)
+	}
+
+	want := []string{"client.name", "due_on", "items", "items.amount", "items.description", "logo", "overdue", "paid_on", "status", "total"}
+	if got := info.Paths(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Paths() = %v, want %v", got, want)
+	}
+	if s := info.Sections(); len(s) != 1 || s[0].Path != "items" || !s[0].Block {
+		t.Errorf("Sections() = %+v", s)
+	}
+
+	for _, tag := range info.Tags {
+		if tag.Path != "amount" {
+			continue
+		}
+		if tag.Raw != `{{amount | currency:"PHP"}}` || tag.NestingPath() != "items.amount" ||
+			len(tag.Formatters) != 1 || tag.Formatters[0] != "currency" || tag.Location.Paragraph != 6 {
+			t.Errorf("amount tag = %+v", tag)
+		}
+	}
+	if first := info.Tags[0]; first.Raw != "{{client.name}}" || first.Location.Text != "Invoice for {{client.name}}" {
+		t.Errorf("first tag = %+v", first)
+	}
+}
+
+func TestInspect_StructuralProblems(t *testing.T) {
+	body := para("{{#items}}") + // 1: never closed
+		para("{{name | titlecase}}") + // 2: unknown formatter
+		para("{{#if paid}}Paid") + // 3: inline if...
+		para("{{/if}}") + // 4: ...closed as a block
+		para("{{/client}}") + // 5: closes nothing
+		para("{{%logo size=big}}") // 6: invalid option
+
+	info, err := Inspect(testTemplate(t, body))
+	if err != nil {
+		t.Fatalf("Inspect: %v", err)
+	}
+	want := map[ProblemKind]int{
+		ProblemUnknownFormatter: 2,
+		ProblemMismatched:       4,
+		ProblemUnexpected:       5,
+		ProblemInvalidTag:       6,
+		ProblemUnclosed:         1,
+	}
+	if len(info.Problems) != len(want) {
+		t.Errorf("problems = %v", info.Problems)
+	}
+	for _, p := range info.Problems {
+		if want[p.Kind] != p.Location.Paragraph {
+			t.Errorf("%s problem at paragraph %d, want %d: %s", p.Kind, p.Location.Paragraph, want[p.Kind], p)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	template := testTemplate(t, invoiceBody+
+		para("{{client.nmae}}")+
+		para("{{#items}}")+para("{{descripton}}")+para("{{/items}}")+
+		para("{{#credits}}")+para("{{anything}}")+para("{{/credits}}")+
+		para("Tags: {{#tags}}{{.}}{{/tags}}")+
+		para(`{{nickname ?? "-"}}`))
+
+	sample := map[string]any{
+		"client":  map[string]any{"name": "Acme"},
+		"logo":    []byte{},
+		"status":  "open",
+		"total":   100,
+		"paid_on": "2026-03-01",
+		"due_on":  "2026-03-31",
+		"overdue": false,
+		"items": []any{
+			map[string]any{"description": "Hosting", "amount": 900},
+			map[string]any{"description": "Domain"},
+		},
+		"credits": []any{},
+		"tags":    []any{"a", "b"},
+	}
+	problems, err := Validate(template, sample)
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	want := []string{
+		`paragraph 14 ("{{client.nmae}}"): unknown key "client.nmae" in {{client.nmae}}`,
+		`paragraph 16 ("{{descripton}}"): unknown key "descripton" in {{descripton}} inside {{#items}}`,
+		`paragraph 21 ("Tags: {{#tags}}{{.}}{{/tags}}"): {{#tags}} loops over a list`,
+	}
+	if len(problems) != len(want) {
+		t.Errorf("problems = %v", problems)
+	}
+	for _, w := range want {
+		found := false
+		for _, p := range problems {
+			found = found || strings.Contains(p.String(), w)
+		}
+		if !found {
+			t.Errorf("no problem matching %q in %v", w, problems)
+		}
+	}
+
+	if problems, _ := Validate(testTemplate(t, invoiceBody), sample); len(problems) != 0 {
+		t.Errorf("valid template: problems = %v", problems)
+	}
+}
+
+func TestValidate_LoopScopes(t *testing.T) {
+	template := testTemplate(t, "<w:tbl>"+
+		tableRow("{{#items}}", "")+
+		tableRow("{{@number}}. {{description}}", "{{amount}} {{../currency}}")+
+		tableRow("{{/items}}", "")+
+		"</w:tbl>"+
+		para("{{#if @first}}x{{/if}}")+ // 7: outside any loop
+		para("{{#items}}")+
+		para("{{#parts}}")+
+		para("{{name}} of {{../description}} in {{@root.currency}} ({{../../vat}})")+ // 10
+		para("{{/parts}}")+
+		para("{{/items}}"))
+
+	info, err := Inspect(template)
+	if err != nil {
+		t.Fatalf("Inspect: %v", err)
+	}
+	want := []string{"currency", "items", "items.amount", "items.description", "items.parts", "items.parts.name", "vat"}
+	if got := info.Paths(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Paths() = %v, want %v", got, want)
+	}
+
+	problems, err := Validate(template, map[string]any{
+		"currency": "PHP",
+		"items": []any{
+			map[string]any{"description": "Desk", "amount": 100, "parts": []any{map[string]any{"name": "Top"}}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	wantProblems := []string{
+		`paragraph 7 ("{{#if @first}}x{{/if}}"): unknown key "@first"`,
+		`paragraph 10 ("{{name}} of {{../description}} in {{@root.currency}} ({{...."): unknown key "../../vat"`,
+	}
+	if len(problems) != len(wantProblems) {
+		t.Errorf("problems = %v", problems)
+	}
+	for _, w := range wantProblems {
+		found := false
+		for _, p := range problems {
+			found = found || strings.Contains(p.String(), w)
+		}
+		if !found {
+			t.Errorf("no problem matching %q in %v", w, problems)
+		}
+	}
+
+	t.Run("scalar items", func(t *testing.T) {
+		template := testTemplate(t, para("{{#tags}}")+para("{{.}} {{this | upper}}")+para("{{/tags}}"))
+		info, err := Inspect(template)
+		if err != nil {
+			t.Fatalf("Inspect: %v", err)
+		}
+		if got := info.Paths(); strings.Join(got, ",") != "tags" {
+			t.Errorf("Paths() = %v, want [tags]", got)
+		}
+		if problems, _ := Validate(template, map[string]any{"tags": []any{"a", "b"}}); len(problems) != 0 {
+			t.Errorf("problems = %v", problems)
+		}
+	})
+}
+
+func TestInspect_RichText(t *testing.T) {
+	template := testTemplate(t, para("Notes: {{{notes}}}")+
+		para("{{{terms | upper | html}}} and {{{terms | bogus}}}"))
+
+	info, err := Inspect(template)
+	if err != nil {
+		t.Fatalf("Inspect: %v", err)
+	}
+	var raws []string
+	for _, tag := range info.Tags {
+		if tag.Kind != TagRichText {
+			t.Errorf("tag %s has kind %s, want %s", tag.Raw, tag.Kind, TagRichText)
+		}
+		raws = append(raws, tag.Raw)
+	}
+	if got := strings.Join(raws, ","); got != "{{{notes}}},{{{terms | upper | html}}},{{{terms | bogus}}}" {
+		t.Errorf("tags = %s", got)
+	}
+	if got := strings.Join(info.Paths(), ","); got != "notes,terms" {
+		t.Errorf("Paths() = %s", got)
+	}
+	if len(info.Problems) != 1 || info.Problems[0].Kind != ProblemUnknownFormatter || !strings.Contains(info.Problems[0].Message, `"bogus"`) {
+		t.Errorf("problems = %v, want the bogus formatter only", info.Problems)
+	}
+}
+
+func TestInspect_ImageFormatters(t *testing.T) {
+	template := testTemplate(t, para("{{ref | qrcode}} {{%tag | upper | barcode height=1cm}}")+
+		para("{{%ref | upper}} {{%ref | bogus | qrcode}}"))
+
+	info, err := Inspect(template)
+	if err != nil {
+		t.Fatalf("Inspect: %v", err)
+	}
+	var kinds []string
+	for _, tag := range info.Tags {
+		kinds = append(kinds, string(tag.Kind)+":"+strings.Join(tag.Formatters, "+"))
+	}
+	if got := strings.Join(kinds, ","); got != "image:qrcode,image:upper+barcode,image:upper,image:bogus+qrcode" {
+		t.Errorf("tags = %s", got)
+	}
+	var messages []string
+	for _, p := range info.Problems {
+		messages = append(messages, p.Message)
+	}
+	want := []string{
+		`"upper" in {{%ref | upper}} is not an image formatter`,
+		`unknown formatter "bogus" in {{%ref | bogus | qrcode}}`,
+	}
+	if strings.Join(messages, "\n") != strings.Join(want, "\n") {
+		t.Errorf("problems = %q, want %q", messages, want)
+	}
+}
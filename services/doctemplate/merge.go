@@ -0,0 +1,716 @@
+package doctemplate
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+// MergeDocuments joins DOCX documents into one, each document starting on
+// a new page. It is meant for one template rendered for many records (mail
+// merge), such as a run of payslips:
+//
+//   - Every document becomes a section of its own, so its page setup,
+//     headers and footers are kept; identical headers and footers are
+//     stored once.
+//   - The first document provides the styles, theme and settings. Styles
+//     of later documents are added only when the first has none with the
+//     same id.
+//   - Numbered lists restart in every document; identical list
+//     definitions are stored once.
+//   - Images, hyperlinks and other related parts are carried over, each
+//     stored once however many documents use it. Footnotes and endnotes
+//     are carried over; comments of later documents are dropped.
+func MergeDocuments(docs [][]byte) ([]byte, error) {
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("no documents to merge")
+	}
+	base, err := ReadDocxBytes(docs[0])
+	if err != nil {
+		return nil, fmt.Errorf("reading document 1: %w", err)
+	}
+	m, err := newMerger(base)
+	if err != nil {
+		return nil, fmt.Errorf("reading document 1: %w", err)
+	}
+
+	sect := m.takeSectPr(m.body)
+	for i, data := range docs[1:] {
+		src, err := ReadDocxBytes(data)
+		if err != nil {
+			return nil, fmt.Errorf("reading document %d: %w", i+2, err)
+		}
+		content, next, err := m.importDocument(src, i+2)
+		if err != nil {
+			return nil, fmt.Errorf("merging document %d: %w", i+2, err)
+		}
+		m.sectionBreak(sect)
+		for _, el := range content {
+			m.body.AddChild(el)
+		}
+		sect = next
+	}
+	m.body.AddChild(sect)
+
+	if err := m.flush(); err != nil {
+		return nil, err
+	}
+	content, err := m.doc.WriteToString()
+	if err != nil {
+		return nil, fmt.Errorf("serializing document.xml: %w", err)
+	}
+	return base.WriteDocx(content, base.Headers, base.Footers)
+}
+
+// merger accumulates documents into the archive of the first one.
+type merger struct {
+	dst  *DocxArchive
+	doc  *etree.Document
+	body *etree.Element
+
+	// numbering and styles are the parsed parts of dst, loaded when a
+	// document needs them; nil if dst has no such part.
+	numbering       *etree.Document
+	numberingLoaded bool
+	styles          *etree.Document
+	stylesLoaded    bool
+	// abstractNums maps the content of each list definition in numbering
+	// to its abstractNumId.
+	abstractNums map[string]string
+	changed      map[string]*etree.Document
+
+	drawingID  int
+	bookmarkID int
+	bookmarks  map[string]bool
+	notes      map[string]int // last footnote and endnote id, by part
+
+	// parts maps the content of imported parts to their names in dst.
+	parts map[[sha256.Size]byte]string
+}
+
+func newMerger(dst *DocxArchive) (*merger, error) {
+	doc := etree.NewDocument()
+	doc.ReadSettings.PreserveCData = true
+	if err := doc.ReadFromString(dst.Content); err != nil {
+		return nil, fmt.Errorf("parsing document.xml: %w", err)
+	}
+	doc.WriteSettings.CanonicalEndTags = false
+	doc.WriteSettings.CanonicalText = false
+	doc.WriteSettings.CanonicalAttrVal = false
+	body := doc.FindElement("//body")
+	if body == nil {
+		return nil, fmt.Errorf("document.xml has no body")
+	}
+
+	m := &merger{
+		dst:       dst,
+		doc:       doc,
+		body:      body,
+		changed:   make(map[string]*etree.Document),
+		bookmarks: make(map[string]bool),
+		notes:     make(map[string]int),
+		parts:     make(map[[sha256.Size]byte]string),
+		drawingID: dst.nextDrawingID(),
+	}
+	for _, el := range body.FindElements(".//bookmarkStart") {
+		m.bookmarkID = max(m.bookmarkID, attrInt(el, "id"))
+		m.bookmarks[el.SelectAttrValue("w:name", "")] = true
+	}
+	return m, nil
+}
+
+func attrInt(el *etree.Element, key string) int {
+	n, _ := strconv.Atoi(el.SelectAttrValue(key, ""))
+	return n
+}
+
+// takeSectPr removes and returns the final section properties of a body,
+// or returns empty ones (the defaults) when there are none.
+func (m *merger) takeSectPr(body *etree.Element) *etree.Element {
+	children := body.ChildElements()
+	if n := len(children); n > 0 && children[n-1].Tag == "sectPr" {
+		body.RemoveChild(children[n-1])
+		return children[n-1]
+	}
+	return etree.NewElement("w:sectPr")
+}
+
+// sectionBreak ends the section made of the body's content so far with
+// sect, starting the next one on a new page. Section properties of a
+// section other than the last are held by its last paragraph.
+func (m *merger) sectionBreak(sect *etree.Element) {
+	setSectionType(sect, "nextPage")
+
+	children := m.body.ChildElements()
+	var p *etree.Element
+	if n := len(children); n > 0 && children[n-1].Tag == "p" {
+		p = children[n-1]
+	}
+	if p == nil || (p.SelectElement("pPr") != nil && p.SelectElement("pPr").SelectElement("sectPr") != nil) {
+		p = m.body.CreateElement("w:p")
+	}
+	pPr := p.SelectElement("pPr")
+	if pPr == nil {
+		pPr = etree.NewElement("w:pPr")
+		p.InsertChildAt(0, pPr)
+	}
+	if change := pPr.SelectElement("pPrChange"); change != nil {
+		pPr.InsertChildAt(change.Index(), sect)
+	} else {
+		pPr.AddChild(sect)
+	}
+}
+
+// setSectionType sets the w:type of section properties, which follows the
+// header, footer and note settings.
+func setSectionType(sect *etree.Element, sectionType string) {
+	typ := sect.SelectElement("type")
+	if typ == nil {
+		typ = etree.NewElement("w:type")
+		index := len(sect.Child)
+		for _, child := range sect.ChildElements() {
+			if !slices.Contains([]string{"headerReference", "footerReference", "footnotePr", "endnotePr"}, child.Tag) {
+				index = child.Index()
+				break
+			}
+		}
+		sect.InsertChildAt(index, typ)
+	}
+	typ.CreateAttr("w:val", sectionType)
+}
+
+// importDocument prepares the body content and final section properties of
+// document number n for the merged document.
+func (m *merger) importDocument(src *DocxArchive, n int) (content []*etree.Element, sect *etree.Element, err error) {
+	doc := etree.NewDocument()
+	doc.ReadSettings.PreserveCData = true
+	if err := doc.ReadFromString(src.Content); err != nil {
+		return nil, nil, fmt.Errorf("parsing document.xml: %w", err)
+	}
+	body := doc.FindElement("//body")
+	if body == nil {
+		return nil, nil, fmt.Errorf("document.xml has no body")
+	}
+	m.declareNamespaces(doc.Root())
+
+	sect = m.takeSectPr(body)
+	content = body.ChildElements()
+	all := append(append([]*etree.Element(nil), content...), sect)
+
+	im := newImporter(m, src, doc.Root(), "word/document.xml", "word/document.xml")
+	for _, el := range all {
+		if err := im.remap(el); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := m.importNumbering(src, all); err != nil {
+		return nil, nil, err
+	}
+	if err := m.importStyles(src); err != nil {
+		return nil, nil, err
+	}
+	for _, kind := range []string{"footnote", "endnote"} {
+		if err := m.importNotes(src, all, kind); err != nil {
+			return nil, nil, err
+		}
+	}
+	m.renumber(all, n)
+	return content, sect, nil
+}
+
+// declareNamespaces adds the namespace declarations of root that the
+// merged document lacks.
+func (m *merger) declareNamespaces(root *etree.Element) {
+	dst := m.doc.Root()
+	for _, attr := range root.Attr {
+		if attr.Space == "xmlns" && dst.SelectAttr("xmlns:"+attr.Key) == nil {
+			dst.CreateAttr("xmlns:"+attr.Key, attr.Value)
+		}
+	}
+}
+
+// renumber gives the drawings and bookmarks of imported content ids that
+// are unique in the merged document, and drops what cannot be carried over:
+// paragraph ids, which Word regenerates, and comments.
+func (m *merger) renumber(elements []*etree.Element, n int) {
+	bookmarkIDs := make(map[string]string)
+	var walk func(el *etree.Element)
+	walk = func(el *etree.Element) {
+		switch el.Tag {
+		case "docPr":
+			m.drawingID++
+			el.CreateAttr("id", strconv.Itoa(m.drawingID))
+		case "bookmarkStart", "bookmarkEnd":
+			old := el.SelectAttrValue("w:id", "")
+			id, ok := bookmarkIDs[old]
+			if !ok {
+				m.bookmarkID++
+				id = strconv.Itoa(m.bookmarkID)
+				bookmarkIDs[old] = id
+			}
+			el.CreateAttr("w:id", id)
+			if name := el.SelectAttrValue("w:name", ""); name != "" {
+				if m.bookmarks[name] {
+					name += "_" + strconv.Itoa(n)
+					el.CreateAttr("w:name", name)
+				}
+				m.bookmarks[name] = true
+			}
+		}
+		el.RemoveAttr("w14:paraId")
+		el.RemoveAttr("w14:textId")
+		for _, child := range el.ChildElements() {
+			switch child.Tag {
+			case "commentRangeStart", "commentRangeEnd", "commentReference":
+				el.RemoveChild(child)
+				continue
+			}
+			walk(child)
+		}
+	}
+	for _, el := range elements {
+		walk(el)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Relationships and parts
+// ---------------------------------------------------------------------------
+
+// importer carries the relationships of one part of a source document
+// over to a part of the merged document.
+type importer struct {
+	m       *merger
+	src     *DocxArchive
+	srcPart string
+	dstPart string
+	ids     map[string]string
+	// prefixes are the prefixes the source part binds to relNamespace
+	// (normally just "r"). Elements are remapped once detached from the
+	// part, where their prefixes no longer resolve.
+	prefixes map[string]bool
+}
+
+func newImporter(m *merger, src *DocxArchive, root *etree.Element, srcPart, dstPart string) *importer {
+	im := &importer{m: m, src: src, srcPart: srcPart, dstPart: dstPart, prefixes: make(map[string]bool)}
+	if root != nil {
+		for _, attr := range root.Attr {
+			if attr.Space == "xmlns" && attr.Value == relNamespace {
+				im.prefixes[attr.Key] = true
+			}
+		}
+	}
+	return im
+}
+
+// remap rewrites the relationship ids (r:id, r:embed, ...) used in el and
+// its descendants to relationships of the destination part.
+func (im *importer) remap(el *etree.Element) error {
+	for i, attr := range el.Attr {
+		if !im.prefixes[attr.Space] {
+			continue
+		}
+		id, err := im.relationship(attr.Value)
+		if err != nil {
+			return err
+		}
+		el.Attr[i].Value = id
+	}
+	for _, child := range el.ChildElements() {
+		if err := im.remap(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// relationship returns the id, in the destination part, of relationship id
+// of the source part, adding the relationship and its target as needed.
+func (im *importer) relationship(id string) (string, error) {
+	if mapped, ok := im.ids[id]; ok {
+		return mapped, nil
+	}
+	rel, ok, err := im.src.lookupRelationship(im.srcPart, id)
+	if err != nil || !ok {
+		return id, err
+	}
+
+	var mapped string
+	switch {
+	case rel.mode == "External":
+		mapped, err = im.m.dst.addRelationshipMode(im.dstPart, rel.relType, rel.target, rel.mode)
+	case rel.relType == relTypeImage && isDecodableImage(im.src, rel.target):
+		data, _, _ := im.src.readPart(rel.target)
+		mapped, err = im.m.dst.AddImage(im.dstPart, data)
+	default:
+		var name, target string
+		if name, err = im.m.importPart(im.src, rel.target); err != nil {
+			return "", err
+		}
+		if target, err = relativeTarget(im.dstPart, name); err != nil {
+			return "", err
+		}
+		mapped, err = im.m.dst.addRelationship(im.dstPart, rel.relType, target)
+	}
+	if err != nil {
+		return "", err
+	}
+	if im.ids == nil {
+		im.ids = make(map[string]string)
+	}
+	im.ids[id] = mapped
+	return mapped, nil
+}
+
+func isDecodableImage(archive *DocxArchive, name string) bool {
+	data, ok, err := archive.readPart(name)
+	if err != nil || !ok {
+		return false
+	}
+	_, err = decodeImageInfo(data)
+	return err == nil
+}
+
+// importPart copies part name of src, with the parts it relates to, into
+// the merged document and returns its name there. A part identical to one
+// already there (the same header in every document) is not copied again.
+func (m *merger) importPart(src *DocxArchive, name string) (string, error) {
+	data, ok, err := src.readPart(name)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("related part %s is missing", name)
+	}
+	rels, _, err := src.readPart(relationshipsPart(name))
+	if err != nil {
+		return "", err
+	}
+
+	// The same part under the same name with the same relationships: the
+	// documents share it.
+	if existing, ok, _ := m.dst.readPart(name); ok && bytes.Equal(existing, data) {
+		if dstRels, _, _ := m.dst.readPart(relationshipsPart(name)); bytes.Equal(dstRels, rels) {
+			return name, nil
+		}
+	}
+	sum := sha256.Sum256(append(append([]byte(name+"\x00"), data...), rels...))
+	if imported, ok := m.parts[sum]; ok {
+		return imported, nil
+	}
+
+	dstName := m.uniquePartName(name)
+	if len(rels) > 0 && strings.HasSuffix(name, ".xml") {
+		doc := etree.NewDocument()
+		doc.ReadSettings.PreserveCData = true
+		if err := doc.ReadFromBytes(data); err != nil {
+			return "", fmt.Errorf("parsing %s: %w", name, err)
+		}
+		im := newImporter(m, src, doc.Root(), name, dstName)
+		if doc.Root() != nil {
+			if err := im.remap(doc.Root()); err != nil {
+				return "", err
+			}
+		}
+		if data, err = doc.WriteToBytes(); err != nil {
+			return "", fmt.Errorf("serializing %s: %w", name, err)
+		}
+	}
+	m.dst.setPart(dstName, data)
+
+	contentType, override, err := src.contentType(name)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case override:
+		err = m.dst.ensureOverride(dstName, contentType)
+	case contentType != "":
+		err = m.dst.ensureContentType(strings.TrimPrefix(path.Ext(dstName), "."), contentType)
+	}
+	if err != nil {
+		return "", err
+	}
+	m.parts[sum] = dstName
+	return dstName, nil
+}
+
+// uniquePartName returns name if the merged document has no such part, or
+// else the name numbered after the existing ones: word/header1.xml →
+// word/header4.xml.
+func (m *merger) uniquePartName(name string) string {
+	if !m.dst.hasPart(name) {
+		return name
+	}
+	dir, file := path.Split(name)
+	ext := path.Ext(file)
+	stem := strings.TrimRight(strings.TrimSuffix(file, ext), "0123456789")
+	for n := 1; ; n++ {
+		candidate := dir + stem + strconv.Itoa(n) + ext
+		if !m.dst.hasPart(candidate) {
+			return candidate
+		}
+	}
+}
+
+// part returns a parsed part of the merged document for modification, or
+// nil if there is no such part. flush writes the parts back.
+func (m *merger) part(name string) (*etree.Document, error) {
+	if doc, ok := m.changed[name]; ok {
+		return doc, nil
+	}
+	if !m.dst.hasPart(name) {
+		return nil, nil
+	}
+	doc, err := m.dst.readXMLPart(name, "")
+	if err != nil {
+		return nil, err
+	}
+	m.changed[name] = doc
+	return doc, nil
+}
+
+func (m *merger) flush() error {
+	for name, doc := range m.changed {
+		if err := m.dst.writeXMLPart(name, doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// Numbering, styles and notes
+// ---------------------------------------------------------------------------
+
+// importNumbering gives the numbered paragraphs of imported content list
+// instances of their own in the merged numbering, so that each document's
+// lists start from the beginning.
+func (m *merger) importNumbering(src *DocxArchive, elements []*etree.Element) error {
+	var refs []*etree.Element
+	for _, el := range elements {
+		refs = append(refs, el.FindElements(".//numPr/numId")...)
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+	srcNumbering, err := src.readXMLPart("word/numbering.xml", "<numbering/>")
+	if err != nil {
+		return err
+	}
+	numbering, err := m.part("word/numbering.xml")
+	if err != nil {
+		return err
+	}
+	if numbering == nil {
+		// Give the merged document an empty numbering part like the source's.
+		shell := srcNumbering.Root().Copy()
+		for _, child := range shell.ChildElements() {
+			shell.RemoveChild(child)
+		}
+		numbering = etree.NewDocument()
+		numbering.CreateProcInst("xml", `version="1.0" encoding="UTF-8" standalone="yes"`)
+		numbering.SetRoot(shell)
+		m.changed["word/numbering.xml"] = numbering
+		if err := m.dst.ensureOverride("word/numbering.xml", "application/vnd.openxmlformats-officedocument.wordprocessingml.numbering+xml"); err != nil {
+			return err
+		}
+		if _, err := m.dst.addRelationship("word/document.xml", relTypeNumbering, "numbering.xml"); err != nil {
+			return err
+		}
+	}
+	root := numbering.Root()
+	if m.abstractNums == nil {
+		m.abstractNums = make(map[string]string)
+		for _, abs := range root.SelectElements("abstractNum") {
+			m.abstractNums[abstractNumKey(abs)] = abs.SelectAttrValue("w:abstractNumId", "")
+		}
+	}
+
+	srcNums := make(map[string]*etree.Element)
+	for _, num := range srcNumbering.Root().SelectElements("num") {
+		srcNums[num.SelectAttrValue("w:numId", "")] = num
+	}
+	srcAbstract := make(map[string]*etree.Element)
+	for _, abs := range srcNumbering.Root().SelectElements("abstractNum") {
+		srcAbstract[abs.SelectAttrValue("w:abstractNumId", "")] = abs
+	}
+
+	mapped := make(map[string]string)
+	for _, ref := range refs {
+		old := ref.SelectAttrValue("w:val", "")
+		if id, ok := mapped[old]; ok {
+			ref.CreateAttr("w:val", id)
+			continue
+		}
+		num := srcNums[old]
+		if num == nil || num.SelectElement("abstractNumId") == nil {
+			continue // "0" (no numbering) or unknown
+		}
+		abs := srcAbstract[num.SelectElement("abstractNumId").SelectAttrValue("w:val", "")]
+		if abs == nil {
+			continue
+		}
+
+		absID, ok := m.abstractNums[abstractNumKey(abs)]
+		if !ok {
+			absID = strconv.Itoa(maxAttr(root, "abstractNum", "w:abstractNumId") + 1)
+			copied := abs.Copy()
+			copied.CreateAttr("w:abstractNumId", absID)
+			insertAfterLast(root, "abstractNum", copied, "num")
+			m.abstractNums[abstractNumKey(abs)] = absID
+		}
+
+		id := strconv.Itoa(maxAttr(root, "num", "w:numId") + 1)
+		instance := num.Copy()
+		instance.CreateAttr("w:numId", id)
+		instance.SelectElement("abstractNumId").CreateAttr("w:val", absID)
+		overridden := make(map[string]bool)
+		for _, o := range instance.SelectElements("lvlOverride") {
+			overridden[o.SelectAttrValue("w:ilvl", "")] = true
+		}
+		for _, lvl := range abs.SelectElements("lvl") {
+			ilvl := lvl.SelectAttrValue("w:ilvl", "")
+			if overridden[ilvl] {
+				continue
+			}
+			start := "0"
+			if s := lvl.SelectElement("start"); s != nil {
+				start = s.SelectAttrValue("w:val", "0")
+			}
+			o := instance.CreateElement("w:lvlOverride")
+			o.CreateAttr("w:ilvl", ilvl)
+			o.CreateElement("w:startOverride").CreateAttr("w:val", start)
+		}
+		insertAfterLast(root, "num", instance, "numIdMacAtCleanup")
+
+		mapped[old] = id
+		ref.CreateAttr("w:val", id)
+	}
+	return nil
+}
+
+// abstractNumKey identifies a list definition by its content, leaving out
+// its id and the ids Word uses to tell copies apart.
+func abstractNumKey(abs *etree.Element) string {
+	c := abs.Copy()
+	c.RemoveAttr("w:abstractNumId")
+	for _, name := range []string{"nsid", "tmpl"} {
+		if el := c.SelectElement(name); el != nil {
+			c.RemoveChild(el)
+		}
+	}
+	doc := etree.NewDocument()
+	doc.SetRoot(c)
+	s, _ := doc.WriteToString()
+	return s
+}
+
+func maxAttr(root *etree.Element, tag, attr string) int {
+	n := 0
+	for _, el := range root.SelectElements(tag) {
+		n = max(n, attrInt(el, attr))
+	}
+	return n
+}
+
+// insertAfterLast inserts el after the last child named tag, or else before
+// the first child named before, or else at the end.
+func insertAfterLast(parent *etree.Element, tag string, el *etree.Element, before string) {
+	if existing := parent.SelectElements(tag); len(existing) > 0 {
+		parent.InsertChildAt(existing[len(existing)-1].Index()+1, el)
+		return
+	}
+	if next := parent.SelectElement(before); next != nil {
+		parent.InsertChildAt(next.Index(), el)
+		return
+	}
+	parent.AddChild(el)
+}
+
+// importStyles adds the styles of src that the merged document lacks.
+func (m *merger) importStyles(src *DocxArchive) error {
+	if !src.hasPart("word/styles.xml") {
+		return nil
+	}
+	styles, err := m.part("word/styles.xml")
+	if err != nil || styles == nil {
+		return err
+	}
+	srcStyles, err := src.readXMLPart("word/styles.xml", "")
+	if err != nil {
+		return err
+	}
+	ids := make(map[string]bool)
+	for _, style := range styles.Root().SelectElements("style") {
+		ids[style.SelectAttrValue("w:styleId", "")] = true
+	}
+	for _, style := range srcStyles.Root().SelectElements("style") {
+		if id := style.SelectAttrValue("w:styleId", ""); !ids[id] {
+			styles.Root().AddChild(style.Copy())
+			ids[id] = true
+		}
+	}
+	return nil
+}
+
+// importNotes copies the footnotes (kind "footnote") or endnotes
+// ("endnote") referenced by imported content into the merged document,
+// with new ids.
+func (m *merger) importNotes(src *DocxArchive, elements []*etree.Element, kind string) error {
+	var refs []*etree.Element
+	for _, el := range elements {
+		refs = append(refs, el.FindElements(".//"+kind+"Reference")...)
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+	name := "word/" + kind + "s.xml"
+	notes, err := m.part(name)
+	if err != nil {
+		return err
+	}
+	srcNotes, err := src.readXMLPart(name, "<notes/>")
+	if err != nil {
+		return err
+	}
+	if notes == nil || notes.Root() == nil {
+		// Nowhere to put the notes: drop the references.
+		for _, ref := range refs {
+			ref.Parent().RemoveChild(ref)
+		}
+		return nil
+	}
+	if _, ok := m.notes[name]; !ok {
+		m.notes[name] = maxAttr(notes.Root(), kind, "w:id")
+	}
+
+	byID := make(map[string]*etree.Element)
+	for _, note := range srcNotes.Root().SelectElements(kind) {
+		byID[note.SelectAttrValue("w:id", "")] = note
+	}
+	im := newImporter(m, src, srcNotes.Root(), name, name)
+	for _, ref := range refs {
+		note := byID[ref.SelectAttrValue("w:id", "")]
+		if note == nil {
+			ref.Parent().RemoveChild(ref)
+			continue
+		}
+		m.notes[name]++
+		id := strconv.Itoa(m.notes[name])
+		copied := note.Copy()
+		copied.CreateAttr("w:id", id)
+		if err := im.remap(copied); err != nil {
+			return err
+		}
+		notes.Root().AddChild(copied)
+		ref.CreateAttr("w:id", id)
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package doctemplate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/beevik/etree"
+)
+
+// mergeTemplate returns a template with a header, a numbered list, a
+// hyperlink, a bookmark and an image placeholder. header is the text of
+// the page header.
+func mergeTemplate(t *testing.T, header string) []byte {
+	t.Helper()
+
+	const ns = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"`
+	files := map[string]string{
+		"[Content_Types].xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/><Override PartName="/word/header1.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.header+xml"/><Override PartName="/word/numbering.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.numbering+xml"/><Override PartName="/word/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.styles+xml"/></Types>`,
+		"_rels/.rels": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/></Relationships>`,
+		"word/_rels/document.xml.rels": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/header" Target="header1.xml"/><Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/numbering" Target="numbering.xml"/><Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink" Target="https://example.com/pay" TargetMode="External"/><Relationship Id="rId4" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/></Relationships>`,
+		"word/header1.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:hdr ` + ns + `><w:p><w:r><w:t>` + header + `</w:t></w:r></w:p></w:hdr>`,
+		"word/numbering.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:numbering ` + ns + `><w:abstractNum w:abstractNumId="0"><w:nsid w:val="1A2B3C4D"/><w:lvl w:ilvl="0"><w:start w:val="1"/><w:numFmt w:val="decimal"/><w:lvlText w:val="%1."/></w:lvl></w:abstractNum><w:num w:numId="1"><w:abstractNumId w:val="0"/></w:num></w:numbering>`,
+		"word/styles.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:styles ` + ns + `><w:style w:type="paragraph" w:styleId="Normal"><w:name w:val="Normal"/></w:style></w:styles>`,
+		"word/document.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document ` + ns + `><w:body>` +
+			`<w:p><w:bookmarkStart w:id="0" w:name="top"/><w:r><w:t>Payslip for {{name}}</w:t></w:r><w:bookmarkEnd w:id="0"/></w:p>` +
+			`<w:p><w:pPr><w:numPr><w:ilvl w:val="0"/><w:numId w:val="1"/></w:numPr></w:pPr><w:r><w:t>Basic pay</w:t></w:r></w:p>` +
+			`<w:p><w:pPr><w:numPr><w:ilvl w:val="0"/><w:numId w:val="1"/></w:numPr></w:pPr><w:r><w:t>Allowances</w:t></w:r></w:p>` +
+			`<w:p><w:hyperlink r:id="rId3"><w:r><w:t>Pay online</w:t></w:r></w:hyperlink></w:p>` +
+			`<w:p><w:r><w:t>{{%logo}}</w:t></w:r></w:p>` +
+			`<w:sectPr><w:headerReference w:type="default" r:id="rId1"/><w:pgSz w:w="12240" w:h="15840"/></w:sectPr>` +
+			`</w:body></w:document>`,
+	}
+
+	return createTestPackage(t, files)
+}
+
+// sections returns the section properties of a body in document order.
+func sections(body *etree.Element) []*etree.Element {
+	return append(body.FindElements("./p/pPr/sectPr"), body.SelectElement("sectPr"))
+}
+
+// headerRefs returns the header relationship id of each section.
+func headerRefs(body *etree.Element) []string {
+	var ids []string
+	for _, sect := range sections(body) {
+		ids = append(ids, sect.SelectElement("headerReference").SelectAttrValue("r:id", ""))
+	}
+	return ids
+}
+
+// mergeRecords renders each template for a record named after it and
+// merges the results.
+func mergeRecords(t *testing.T, templates [][]byte, names []string) *DocxArchive {
+	t.Helper()
+	logo := testPNG(t, 20, 10)
+	var docs [][]byte
+	for i, template := range templates {
+		doc, err := ProcessTemplate(template, map[string]any{"name": names[i], "logo": logo})
+		if err != nil {
+			t.Fatalf("ProcessTemplate failed: %v", err)
+		}
+		docs = append(docs, doc)
+	}
+	merged, err := MergeDocuments(docs)
+	if err != nil {
+		t.Fatalf("MergeDocuments failed: %v", err)
+	}
+	archive, err := ReadDocxBytes(merged)
+	if err != nil {
+		t.Fatalf("failed to read merged docx: %v", err)
+	}
+	return archive
+}
+
+func parsePart(t *testing.T, archive *DocxArchive, name string) *etree.Element {
+	t.Helper()
+	doc := etree.NewDocument()
+	if err := doc.ReadFromString(partText(t, archive, name)); err != nil {
+		t.Fatalf("parsing %s: %v", name, err)
+	}
+	return doc.Root()
+}
+
+func attrValues(root *etree.Element, path, attr string) []string {
+	var values []string
+	for _, el := range root.FindElements(path) {
+		values = append(values, el.SelectAttrValue(attr, ""))
+	}
+	return values
+}
+
+func TestMergeDocuments(t *testing.T) {
+	template := mergeTemplate(t, "ACME Payroll")
+	archive := mergeRecords(t, [][]byte{template, template, template}, []string{"Ana", "Ben", "Cy"})
+
+	body := parsePart(t, archive, "word/document.xml").FindElement("body")
+	text := strings.Join(documentLines(archive.Content), "\n")
+	for _, want := range []string{"Payslip for Ana", "Payslip for Ben", "Payslip for Cy"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("merged document lacks %q:\n%s", want, text)
+		}
+	}
+
+	// One section per record; each but the last ends on its last paragraph
+	// and makes the next start on a new page.
+	breaks := body.FindElements("./p/pPr/sectPr")
+	if len(breaks) != 2 {
+		t.Fatalf("got %d section breaks, want 2", len(breaks))
+	}
+	for _, sect := range breaks {
+		if got := sect.FindElement("type").SelectAttrValue("w:val", ""); got != "nextPage" {
+			t.Errorf("section type = %q, want nextPage", got)
+		}
+		if sect.Index() != len(sect.Parent().Child)-1 {
+			t.Errorf("sectPr is not the last child of its pPr")
+		}
+	}
+	children := body.ChildElements()
+	if last := children[len(children)-1]; last.Tag != "sectPr" {
+		t.Errorf("body ends with %s, want sectPr", last.Tag)
+	}
+
+	// The identical header, image and hyperlink are stored once.
+	headers := headerRefs(body)
+	if len(headers) != 3 || headers[0] != headers[1] || headers[1] != headers[2] {
+		t.Errorf("header references = %v, want the same one three times", headers)
+	}
+	if len(archive.Headers) != 1 {
+		t.Errorf("got %d headers, want 1", len(archive.Headers))
+	}
+	if len(archive.Images) != 1 {
+		t.Errorf("got %d images, want 1", len(archive.Images))
+	}
+	links := attrValues(body, ".//hyperlink", "r:id")
+	if len(links) != 3 || links[0] != links[1] || links[1] != links[2] {
+		t.Errorf("hyperlink references = %v, want the same one three times", links)
+	}
+	if rels := partText(t, archive, "word/_rels/document.xml.rels"); strings.Count(rels, "https://example.com/pay") != 1 {
+		t.Errorf("hyperlink relationship not stored once:\n%s", rels)
+	}
+
+	// Ids are unique across records.
+	if ids := attrValues(body, ".//docPr", "id"); len(ids) != 3 || ids[0] == ids[1] || ids[1] == ids[2] || ids[0] == ids[2] {
+		t.Errorf("drawing ids = %v, want three distinct", ids)
+	}
+	if names := attrValues(body, ".//bookmarkStart", "w:name"); strings.Join(names, ",") != "top,top_2,top_3" {
+		t.Errorf("bookmark names = %v", names)
+	}
+	if ids := attrValues(body, ".//bookmarkStart", "w:id"); strings.Join(ids, ",") != "0,1,2" {
+		t.Errorf("bookmark ids = %v", ids)
+	}
+
+	// Each record's list restarts: its own w:num over the shared definition.
+	numIDs := attrValues(body, ".//numPr/numId", "w:val")
+	if strings.Join(numIDs, ",") != "1,1,2,2,3,3" {
+		t.Errorf("numIds = %v, want 1,1,2,2,3,3", numIDs)
+	}
+	numbering := parsePart(t, archive, "word/numbering.xml")
+	if n := len(numbering.SelectElements("abstractNum")); n != 1 {
+		t.Errorf("got %d abstractNum, want 1", n)
+	}
+	for _, num := range numbering.SelectElements("num")[1:] {
+		if num.FindElement("lvlOverride/startOverride[@w:val='1']") == nil {
+			t.Errorf("num %s does not restart its list", num.SelectAttrValue("w:numId", ""))
+		}
+	}
+}
+
+func TestMergeDocuments_DifferentHeaders(t *testing.T) {
+	archive := mergeRecords(t,
+		[][]byte{mergeTemplate(t, "Branch A"), mergeTemplate(t, "Branch B"), mergeTemplate(t, "Branch A")},
+		[]string{"Ana", "Ben", "Cy"})
+
+	body := parsePart(t, archive, "word/document.xml").FindElement("body")
+	headers := headerRefs(body)
+	if len(headers) != 3 || headers[0] == headers[1] || headers[0] != headers[2] {
+		t.Fatalf("header references = %v, want the second to differ", headers)
+	}
+	if got := archive.Headers["word/header2.xml"]; !strings.Contains(got, "Branch B") {
+		t.Errorf("word/header2.xml = %q, want the second header", got)
+	}
+	if types := partText(t, archive, "[Content_Types].xml"); !strings.Contains(types, `PartName="/word/header2.xml"`) {
+		t.Errorf("content types lack word/header2.xml:\n%s", types)
+	}
+	if rels := partText(t, archive, "word/_rels/document.xml.rels"); !strings.Contains(rels, `Target="header2.xml"`) {
+		t.Errorf("relationships lack header2.xml:\n%s", rels)
+	}
+}
+
+func TestMergeDocuments_Single(t *testing.T) {
+	if _, err := MergeDocuments(nil); err == nil {
+		t.Error("MergeDocuments(nil) succeeded, want an error")
+	}
+
+	archive := mergeRecords(t, [][]byte{mergeTemplate(t, "ACME Payroll")}, []string{"Ana"})
+	body := parsePart(t, archive, "word/document.xml").FindElement("body")
+	if n := len(body.FindElements("./p/pPr/sectPr")); n != 0 {
+		t.Errorf("got %d section breaks, want 0", n)
+	}
+}
@@ -0,0 +1,99 @@
+package doctemplate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MissingPolicy decides what happens to a placeholder that cannot be
+// rendered: its path is not in the data, or one of its formatters fails.
+// A placeholder with a default ({{name ?? "N/A"}}) is never missing.
+type MissingPolicy int
+
+const (
+	// MissingKeep leaves the placeholder text in the document, e.g.
+	// "{{client.name}}". This is the behaviour of ProcessTemplate.
+	MissingKeep MissingPolicy = iota
+	// MissingBlank removes the placeholder, leaving nothing in its place.
+	MissingBlank
+	// MissingError makes processing fail with a *MissingValuesError listing
+	// every placeholder that could not be rendered (strict mode).
+	MissingError
+)
+
+// Options controls ProcessTemplateWithOptions. The zero value behaves like
+// ProcessTemplate.
+type Options struct {
+	// Missing is the policy for placeholders that cannot be rendered.
+	Missing MissingPolicy
+}
+
+// ErrMissingValues is matched (errors.Is) by the *MissingValuesError that
+// strict mode returns.
+var ErrMissingValues = errors.New("doctemplate: missing template values")
+
+// MissingValuesError lists the placeholders that could not be rendered in
+// strict mode. Paths inside loops are qualified by the loop, e.g.
+// "items.description".
+type MissingValuesError struct {
+	// Keys are the missing data paths, in order of first appearance.
+	Keys []string
+	// Formatting describes placeholders whose formatter failed, e.g.
+	// `total: formatter "currency": "abc" is not a number`.
+	Formatting []string
+}
+
+func (e *MissingValuesError) Error() string {
+	var parts []string
+	if len(e.Keys) > 0 {
+		parts = append(parts, "missing values for "+strings.Join(e.Keys, ", "))
+	}
+	if len(e.Formatting) > 0 {
+		parts = append(parts, "cannot format "+strings.Join(e.Formatting, "; "))
+	}
+	return "doctemplate: " + strings.Join(parts, "; ")
+}
+
+func (e *MissingValuesError) Is(target error) bool {
+	return target == ErrMissingValues
+}
+
+// errMissingValue is returned by renderPlaceholder for a path that is not in
+// the data.
+var errMissingValue = errors.New("missing value")
+
+// missingValues collects the placeholders of one document that could not be
+// rendered, across its parts.
+type missingValues struct {
+	seen       map[string]bool
+	keys       []string
+	formatting []string
+}
+
+func (m *missingValues) add(path string, err error) {
+	if m.seen == nil {
+		m.seen = make(map[string]bool)
+	}
+	entry := path
+	if !errors.Is(err, errMissingValue) {
+		entry = fmt.Sprintf("%s: %v", path, err)
+	}
+	if m.seen[entry] {
+		return
+	}
+	m.seen[entry] = true
+	if errors.Is(err, errMissingValue) {
+		m.keys = append(m.keys, entry)
+	} else {
+		m.formatting = append(m.formatting, entry)
+	}
+}
+
+// err returns the strict-mode error, or nil when every value was rendered.
+func (m *missingValues) err() error {
+	if len(m.keys) == 0 && len(m.formatting) == 0 {
+		return nil
+	}
+	return &MissingValuesError{Keys: m.keys, Formatting: m.formatting}
+}
@@ -0,0 +1,101 @@
+package doctemplate
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// missingBody has values that are present, missing, defaulted and
+// unformattable, at the top level and inside a loop.
+var missingBody = para("Client: {{client.name}}") +
+	para("TIN: {{client.tin}}") +
+	para(`Terms: {{terms ?? "Due on receipt"}}`) +
+	para("Total: {{total | currency}}") +
+	para("{{#items}}") +
+	para("{{description}} / {{sku}}") +
+	para("{{/items}}") +
+	para("{{%logo}}")
+
+var missingData = map[string]any{
+	"client": map[string]any{"name": "Acme"},
+	"total":  "n/a",
+	"items": []any{
+		map[string]any{"description": "Hosting", "sku": "H-1"},
+		map[string]any{"description": "Domain"},
+		map[string]any{"description": "Support"},
+	},
+}
+
+func renderWithOptions(t *testing.T, opts Options) ([]string, error) {
+	t.Helper()
+	result, err := ProcessTemplateWithOptions(testTemplate(t, missingBody), missingData, opts)
+	if err != nil {
+		return nil, err
+	}
+	return documentLines(readDocx(t, result).Content), nil
+}
+
+func TestProcessTemplateWithOptions_MissingKeep(t *testing.T) {
+	lines, err := renderWithOptions(t, Options{})
+	if err != nil {
+		t.Fatalf("ProcessTemplateWithOptions: %v", err)
+	}
+	want := []string{
+		"Client: Acme",
+		"TIN: {{client.tin}}",
+		"Terms: Due on receipt",
+		"Total: {{total | currency}}",
+		"Hosting / H-1",
+		"Domain / {{sku}}",
+		"Support / {{sku}}",
+		"{{%logo}}",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("lines = %q, want %q", lines, want)
+	}
+}
+
+func TestProcessTemplateWithOptions_MissingBlank(t *testing.T) {
+	lines, err := renderWithOptions(t, Options{Missing: MissingBlank})
+	if err != nil {
+		t.Fatalf("ProcessTemplateWithOptions: %v", err)
+	}
+	want := []string{
+		"Client: Acme",
+		"TIN:",
+		"Terms: Due on receipt",
+		"Total:",
+		"Hosting / H-1",
+		"Domain /",
+		"Support /",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("lines = %q, want %q", lines, want)
+	}
+}
+
+func TestProcessTemplateWithOptions_MissingError(t *testing.T) {
+	_, err := renderWithOptions(t, Options{Missing: MissingError})
+	if !errors.Is(err, ErrMissingValues) {
+		t.Fatalf("error = %v, want ErrMissingValues", err)
+	}
+	var missing *MissingValuesError
+	if !errors.As(err, &missing) {
+		t.Fatalf("error %T is not a *MissingValuesError", err)
+	}
+	if got := strings.Join(missing.Keys, ","); got != "client.tin,items.sku,logo" {
+		t.Errorf("Keys = %v", missing.Keys)
+	}
+	if len(missing.Formatting) != 1 || !strings.HasPrefix(missing.Formatting[0], `total: formatter "currency"`) {
+		t.Errorf("Formatting = %q", missing.Formatting)
+	}
+	if !strings.Contains(err.Error(), "missing values for client.tin, items.sku, logo") {
+		t.Errorf("message = %q", err)
+	}
+
+	complete := map[string]any{"client": map[string]any{"name": "Acme", "tin": nil}, "total": 10, "logo": nil, "items": []any{}}
+	if _, err := ProcessTemplateWithOptions(testTemplate(t, missingBody), complete, Options{Missing: MissingError}); err != nil {
+		t.Errorf("complete data: %v", err)
+	}
+}
@@ -1,13 +1,14 @@
 package doctemplate
 
 import (
-	"fmt"
 	"regexp"
 	"strings"
 )
 
 // ReplacePlaceholders replaces placeholders in an XML string with values from a data map.
-// It skips special loop markers like {{#...}} and {{/...}}.
+// It skips special loop markers like {{#...}} and {{/...}} and applies
+// formatters such as {{total | currency:"PHP"}} and defaults such as
+// {{name ?? "N/A"}}.
 func ReplacePlaceholders(xmlContent string, data map[string]any) string {
 	re := regexp.MustCompile(`{{(.*?)}}`)
 
@@ -20,9 +21,15 @@ func ReplacePlaceholders(xmlContent string, data map[string]any) string {
 			return match // Return the original marker
 		}
 
-		keys := parsePlaceholder(trimmedPlaceholder)
-		if value, found := getNestedValue(keys, data); found {
-			return fmt.Sprintf("%v", value)
+		pe := parsePlaceholderExpr(trimmedPlaceholder)
+		value, found := getNestedValue(parsePlaceholder(pe.path), data)
+		if pe.hasFallback && (!found || value == nil) {
+			return pe.fallback
+		}
+		if found {
+			if text, err := applyFormatters(value, pe.calls); err == nil {
+				return text
+			}
 		}
 
 		// If the placeholder is not found, return the original placeholder
@@ -0,0 +1,680 @@
+package doctemplate
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+// Rich placeholders insert text that carries its own structure, such as the
+// notes of an invoice or the special terms of an agreement:
+//
+//	{{{notes}}}            multi-line text: line breaks become w:br and tabs
+//	                       w:tab, instead of vanishing
+//	{{{terms | html}}}     a safe subset of HTML: <b> <strong> <i> <em> <u>
+//	                       <s> <strike> <del> <br> <p> <div> <ul> <ol> <li>
+//	                       and <a href>
+//
+// The inserted text takes the formatting of the run holding the tag. HTML
+// paragraphs and list items split the tag's paragraph: they become
+// paragraphs of their own with its paragraph properties, lists are numbered
+// through word/numbering.xml and links become hyperlinks with external
+// relationships (http, https, mailto and tel only). Any other tag is
+// ignored, keeping its text; <script> and <style> are dropped with their
+// content. Values are only ever written as text, never as markup.
+//
+// Formatters and defaults work as in other placeholders; html must be the
+// last formatter.
+
+// richTagRegex matches a rich placeholder; group 1 is the trimmed inner text.
+var richTagRegex = regexp.MustCompile(`{{{\s*([^{}]*?)\s*}}}`)
+
+// richSpan is a piece of inline content: text, a line break or a tab, with
+// its formatting.
+type richSpan struct {
+	text                            string
+	br, tab                         bool
+	bold, italic, underline, strike bool
+	href                            string
+}
+
+// richBlock is a paragraph of rich content. list is nil outside lists.
+type richBlock struct {
+	spans []richSpan
+	list  *richList
+	level int // nesting depth of the list item, from 0
+}
+
+// richList is one <ul> or <ol>. Its items share a numbering instance.
+type richList struct {
+	ordered bool
+	numID   string
+}
+
+// parsePlainText converts multi-line text to a single block.
+func parsePlainText(text string) []richBlock {
+	text = strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\r", "\n")
+	var spans []richSpan
+	for i, line := range strings.Split(text, "\n") {
+		if i > 0 {
+			spans = append(spans, richSpan{br: true})
+		}
+		for j, part := range strings.Split(line, "\t") {
+			if j > 0 {
+				spans = append(spans, richSpan{tab: true})
+			}
+			if part != "" {
+				spans = append(spans, richSpan{text: part})
+			}
+		}
+	}
+	return []richBlock{{spans: spans}}
+}
+
+var (
+	htmlTokenRegex = regexp.MustCompile(`(?s)<!--.*?-->|<(/?)([a-zA-Z][a-zA-Z0-9]*)([^>]*)>`)
+	htmlHrefRegex  = regexp.MustCompile(`(?i)\bhref\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	whitespace     = regexp.MustCompile(`\s+`)
+)
+
+// htmlParser turns the supported HTML subset into blocks.
+type htmlParser struct {
+	blocks  []richBlock
+	current *richBlock
+
+	bold, italic, underline, strike int
+	href                            string
+	lists                           []*richList
+	skip                            string // element whose content is dropped
+}
+
+// parseHTML converts an HTML fragment to blocks of formatted spans.
+func parseHTML(fragment string) []richBlock {
+	p := &htmlParser{}
+	last := 0
+	for _, loc := range htmlTokenRegex.FindAllStringSubmatchIndex(fragment, -1) {
+		p.text(fragment[last:loc[0]])
+		last = loc[1]
+		if loc[4] < 0 {
+			continue // comment
+		}
+		closing := loc[3] > loc[2]
+		name := strings.ToLower(fragment[loc[4]:loc[5]])
+		p.tag(name, closing, fragment[loc[6]:loc[7]])
+	}
+	p.text(fragment[last:])
+	p.endBlock()
+	return p.blocks
+}
+
+func (p *htmlParser) text(raw string) {
+	if p.skip != "" || raw == "" {
+		return
+	}
+	text := whitespace.ReplaceAllString(html.UnescapeString(raw), " ")
+	if p.current == nil {
+		text = strings.TrimLeft(text, " ")
+		if text == "" {
+			return
+		}
+		p.startBlock(nil)
+	}
+	if n := len(p.current.spans); n > 0 && strings.HasSuffix(p.current.spans[n-1].text, " ") {
+		text = strings.TrimLeft(text, " ")
+	}
+	if text == "" {
+		return
+	}
+	p.current.spans = append(p.current.spans, richSpan{
+		text:      text,
+		bold:      p.bold > 0,
+		italic:    p.italic > 0,
+		underline: p.underline > 0,
+		strike:    p.strike > 0,
+		href:      p.href,
+	})
+}
+
+func (p *htmlParser) tag(name string, closing bool, attrs string) {
+	if p.skip != "" {
+		if closing && name == p.skip {
+			p.skip = ""
+		}
+		return
+	}
+	delta := 1
+	if closing {
+		delta = -1
+	}
+	switch name {
+	case "script", "style":
+		if !closing {
+			p.skip = name
+		}
+	case "b", "strong":
+		p.bold = max(p.bold+delta, 0)
+	case "i", "em":
+		p.italic = max(p.italic+delta, 0)
+	case "u", "ins":
+		p.underline = max(p.underline+delta, 0)
+	case "s", "strike", "del":
+		p.strike = max(p.strike+delta, 0)
+	case "a":
+		p.href = ""
+		if !closing {
+			p.href = safeHref(attrs)
+		}
+	case "br":
+		if p.current == nil {
+			p.startBlock(nil)
+		}
+		p.current.spans = append(p.current.spans, richSpan{br: true})
+	case "ul", "ol":
+		p.endBlock()
+		if closing {
+			if len(p.lists) > 0 {
+				p.lists = p.lists[:len(p.lists)-1]
+			}
+			return
+		}
+		p.lists = append(p.lists, &richList{ordered: name == "ol"})
+	case "li":
+		p.endBlock()
+		if !closing {
+			p.startBlock(p.currentList())
+		}
+	case "p", "div", "h1", "h2", "h3", "h4", "h5", "h6", "blockquote":
+		// A paragraph inside a list item continues the item.
+		if p.current != nil && p.current.list != nil && !closing && len(p.current.spans) == 0 {
+			return
+		}
+		p.endBlock()
+	}
+}
+
+func (p *htmlParser) currentList() *richList {
+	if len(p.lists) == 0 {
+		return nil
+	}
+	return p.lists[len(p.lists)-1]
+}
+
+func (p *htmlParser) startBlock(list *richList) {
+	p.blocks = append(p.blocks, richBlock{list: list, level: max(len(p.lists)-1, 0)})
+	p.current = &p.blocks[len(p.blocks)-1]
+}
+
+// endBlock closes the current block, trimming its trailing space and
+// dropping it if it is empty (other than an empty list item).
+func (p *htmlParser) endBlock() {
+	if p.current == nil {
+		return
+	}
+	spans := p.current.spans
+	if n := len(spans); n > 0 {
+		spans[n-1].text = strings.TrimRight(spans[n-1].text, " ")
+		if spans[n-1].text == "" && !spans[n-1].br {
+			p.current.spans = spans[:n-1]
+		}
+	}
+	if len(p.current.spans) == 0 && p.current.list == nil {
+		p.blocks = p.blocks[:len(p.blocks)-1]
+	}
+	p.current = nil
+}
+
+// safeHref returns the href of a link if it uses a scheme that is safe to
+// put in a document, or "".
+func safeHref(attrs string) string {
+	m := htmlHrefRegex.FindStringSubmatch(attrs)
+	if m == nil {
+		return ""
+	}
+	href := strings.TrimSpace(html.UnescapeString(m[1] + m[2] + m[3]))
+	lower := strings.ToLower(href)
+	for _, scheme := range []string{"http://", "https://", "mailto:", "tel:"} {
+		if strings.HasPrefix(lower, scheme) {
+			return href
+		}
+	}
+	return ""
+}
+
+// ---------------------------------------------------------------------------
+// Rendering
+// ---------------------------------------------------------------------------
+
+// processRichText replaces the {{{...}}} tags of a paragraph and returns
+// the paragraphs holding the paragraph's own remaining text: p and, when a
+// tag split it, the last paragraph of the split. It runs before the plain
+// placeholders are replaced, so values are never scanned for tags, and
+// processParagraph skips the text it writes.
+func (r *renderer) processRichText(p *etree.Element, data map[string]any) []*etree.Element {
+	paragraphs := []*etree.Element{p}
+	for from := 0; ; {
+		nodes := textNodes(p)
+		var sb strings.Builder
+		for _, t := range nodes {
+			sb.WriteString(t.Text())
+		}
+		text := sb.String()
+		if from >= len(text) {
+			return paragraphs
+		}
+		loc := richTagRegex.FindStringSubmatchIndex(text[from:])
+		if loc == nil {
+			return paragraphs
+		}
+		start, end := from+loc[0], from+loc[1]
+		expr := text[from+loc[2] : from+loc[3]]
+		from = end
+
+		pe := parsePlaceholderExpr(expr)
+		asHTML := len(pe.calls) > 0 && pe.calls[len(pe.calls)-1].name == "html"
+		if asHTML {
+			pe.calls = pe.calls[:len(pe.calls)-1]
+		}
+		value, err := "", errMissingValue
+		if pe.path != "" {
+			value, err = renderExpr(pe, data)
+		}
+		if err != nil {
+			if r.missingValue(text[start:end], pe.path, err) == "" {
+				insertAtTag(nodes, start, end)
+				from = start
+			}
+			continue
+		}
+
+		blocks := parsePlainText(value)
+		if asHTML {
+			blocks = parseHTML(value)
+		}
+		last, offset := r.insertRichText(p, nodes, start, end, text, blocks)
+		if last != p {
+			paragraphs = append(paragraphs, last)
+		}
+		p, from = last, offset
+	}
+}
+
+// insertRichText replaces the tag at [start, end) of text, the text of p,
+// with blocks. It returns the paragraph holding the rest of p's text and
+// the offset in its text at which that rest starts.
+func (r *renderer) insertRichText(p *etree.Element, nodes []*etree.Element, start, end int, text string, blocks []richBlock) (*etree.Element, int) {
+	base := runProperties(nodes, start)
+	tagRun := nodeAt(nodes, start).Parent()
+
+	// Paragraphs can only be split where the tag's run is a direct child of
+	// the paragraph (not inside a hyperlink, say); elsewhere blocks are
+	// separated by line breaks.
+	splittable := tagRun != nil && tagRun.Parent() == p
+	if !splittable || len(blocks) == 0 || (len(blocks) == 1 && blocks[0].list == nil) {
+		var spans []richSpan
+		for i, b := range blocks {
+			if i > 0 {
+				spans = append(spans, richSpan{br: true})
+			}
+			spans = append(spans, b.spans...)
+		}
+		linkIn := p
+		if !splittable {
+			linkIn = nil
+		}
+		insertAtTag(nodes, start, end, r.richRuns(spans, base, linkIn)...)
+		return p, start + spansLength(spans)
+	}
+
+	// Lists get paragraphs of their own, apart from the text around the tag.
+	if blocks[0].list != nil && strings.TrimSpace(text[:start]) != "" {
+		blocks = append([]richBlock{{}}, blocks...)
+	}
+	if blocks[len(blocks)-1].list != nil && strings.TrimSpace(text[end:]) != "" {
+		blocks = append(blocks, richBlock{})
+	}
+
+	// Insert the first block at the tag, followed by a marker run to split
+	// the paragraph at.
+	split := etree.NewElement("w:r")
+	insertAtTag(nodes, start, end, append(r.richRuns(blocks[0].spans, base, p), split)...)
+	var tail []*etree.Element
+	for _, tok := range slices.Clone(p.Child[split.Index()+1:]) {
+		if el, ok := tok.(*etree.Element); ok {
+			p.RemoveChild(el)
+			tail = append(tail, el)
+		}
+	}
+	p.RemoveChild(split)
+	r.applyList(p, blocks[0])
+
+	last := p
+	for _, b := range blocks[1:] {
+		next := etree.NewElement(p.FullTag())
+		if pPr := p.SelectElement("pPr"); pPr != nil {
+			pPr = pPr.Copy()
+			for _, name := range []string{"sectPr", "numPr"} {
+				if el := pPr.SelectElement(name); el != nil {
+					pPr.RemoveChild(el)
+				}
+			}
+			next.AddChild(pPr)
+		}
+		for _, run := range r.richRuns(b.spans, base, p) {
+			next.AddChild(run)
+		}
+		r.applyList(next, b)
+		last.Parent().InsertChildAt(last.Index()+1, next)
+		last = next
+	}
+
+	// The rest of the original paragraph, and its section properties, end
+	// the last one.
+	offset := len(paragraphText(last))
+	for _, el := range tail {
+		last.AddChild(el)
+	}
+	if sect := p.FindElement("./pPr/sectPr"); sect != nil && last != p {
+		p.SelectElement("pPr").RemoveChild(sect)
+		lastPPr := last.SelectElement("pPr")
+		if lastPPr == nil {
+			lastPPr = etree.NewElement("w:pPr")
+			last.InsertChildAt(0, lastPPr)
+		}
+		lastPPr.AddChild(sect)
+	}
+	return last, offset
+}
+
+func spansLength(spans []richSpan) int {
+	n := 0
+	for _, s := range spans {
+		n += len(s.text)
+	}
+	return n
+}
+
+// nodeAt returns the text node holding byte offset of the paragraph text.
+func nodeAt(nodes []*etree.Element, offset int) *etree.Element {
+	for _, t := range nodes {
+		if offset < len(t.Text()) {
+			return t
+		}
+		offset -= len(t.Text())
+	}
+	return nodes[len(nodes)-1]
+}
+
+// runProperties returns the run properties of the run holding byte offset
+// of the paragraph text, or nil.
+func runProperties(nodes []*etree.Element, offset int) *etree.Element {
+	if run := nodeAt(nodes, offset).Parent(); run != nil {
+		return run.SelectElement("rPr")
+	}
+	return nil
+}
+
+// richRuns builds the runs of spans, each formatted like base plus its own
+// formatting. Linked spans become hyperlinks when they are to be placed in
+// paragraph p and the archive can hold their relationships; with p nil
+// they are plain text.
+func (r *renderer) richRuns(spans []richSpan, base, p *etree.Element) []*etree.Element {
+	var out []*etree.Element
+	var link *etree.Element
+	linkHref := ""
+	for _, s := range spans {
+		run := etree.NewElement("w:r")
+		rPr := etree.NewElement("w:rPr")
+		if base != nil {
+			rPr = base.Copy()
+		}
+		if s.bold {
+			setRunProperty(rPr, "b", "")
+		}
+		if s.italic {
+			setRunProperty(rPr, "i", "")
+		}
+		if s.strike {
+			setRunProperty(rPr, "strike", "")
+		}
+		if s.underline {
+			setRunProperty(rPr, "u", "single")
+		}
+
+		href := ""
+		if p != nil && r.archive != nil {
+			href = s.href
+		}
+		if href != "" {
+			setRunProperty(rPr, "color", "0563C1")
+			setRunProperty(rPr, "u", "single")
+		}
+		if len(rPr.ChildElements()) > 0 {
+			run.AddChild(rPr)
+		}
+		switch {
+		case s.br:
+			run.CreateElement("w:br")
+		case s.tab:
+			run.CreateElement("w:tab")
+		default:
+			t := run.CreateElement("w:t")
+			setText(t, s.text)
+			if r.richText == nil {
+				r.richText = make(map[*etree.Element]bool)
+			}
+			r.richText[t] = true
+		}
+
+		if href == "" {
+			link, linkHref = nil, ""
+			out = append(out, run)
+			continue
+		}
+		if link == nil || href != linkHref {
+			relID, err := r.archive.addRelationshipMode(r.part, relTypeHyperlink, href, "External")
+			if err != nil {
+				r.fail(err)
+				out = append(out, run)
+				continue
+			}
+			link = etree.NewElement("w:hyperlink")
+			if !declaresPrefix(p, "r", relNamespace) {
+				link.CreateAttr("xmlns:r", relNamespace)
+			}
+			link.CreateAttr("r:id", relID)
+			link.CreateAttr("w:history", "1")
+			linkHref = href
+			out = append(out, link)
+		}
+		link.AddChild(run)
+	}
+	return out
+}
+
+// declaresPrefix reports whether el or one of its ancestors binds prefix
+// to namespace.
+func declaresPrefix(el *etree.Element, prefix, namespace string) bool {
+	for ; el != nil; el = el.Parent() {
+		if attr := el.SelectAttr("xmlns:" + prefix); attr != nil {
+			return attr.Value == namespace
+		}
+	}
+	return false
+}
+
+// runPropertyOrder is the order the schema requires for the children of
+// w:rPr.
+var runPropertyOrder = []string{
+	"rStyle", "rFonts", "b", "bCs", "i", "iCs", "caps", "smallCaps", "strike",
+	"dstrike", "outline", "shadow", "emboss", "imprint", "noProof",
+	"snapToGrid", "vanish", "webHidden", "color", "spacing", "w", "kern",
+	"position", "sz", "szCs", "highlight", "u", "effect", "bdr", "shd",
+	"fitText", "vertAlign", "rtl", "cs", "em", "lang", "eastAsianLayout",
+	"specVanish", "oMath",
+}
+
+// setRunProperty sets a run property, keeping the schema order. val is the
+// w:val attribute, or "" for none (a toggle such as w:b turns on).
+func setRunProperty(rPr *etree.Element, name, val string) {
+	el := rPr.SelectElement(name)
+	if el == nil {
+		el = etree.NewElement("w:" + name)
+		rank := propertyRank(name)
+		index := len(rPr.Child)
+		for _, child := range rPr.ChildElements() {
+			if propertyRank(child.Tag) > rank {
+				index = child.Index()
+				break
+			}
+		}
+		rPr.InsertChildAt(index, el)
+	}
+	el.RemoveAttr("w:val")
+	if val != "" {
+		el.CreateAttr("w:val", val)
+	}
+}
+
+func propertyRank(name string) int {
+	for i, n := range runPropertyOrder {
+		if n == name {
+			return i
+		}
+	}
+	return len(runPropertyOrder)
+}
+
+// applyList makes p an item of the list of b, at b's level. Its indentation
+// then comes from the list definition.
+func (r *renderer) applyList(p *etree.Element, b richBlock) {
+	if b.list == nil || r.archive == nil {
+		return
+	}
+	if b.list.numID == "" {
+		numID, err := r.archive.newList(b.list.ordered)
+		if err != nil {
+			r.fail(err)
+			return
+		}
+		b.list.numID = numID
+	}
+	pPr := p.SelectElement("pPr")
+	if pPr == nil {
+		pPr = etree.NewElement("w:pPr")
+		p.InsertChildAt(0, pPr)
+	}
+	for _, name := range []string{"numPr", "ind"} {
+		if el := pPr.SelectElement(name); el != nil {
+			pPr.RemoveChild(el)
+		}
+	}
+	numPr := etree.NewElement("w:numPr")
+	numPr.CreateElement("w:ilvl").CreateAttr("w:val", strconv.Itoa(min(b.level, 8)))
+	numPr.CreateElement("w:numId").CreateAttr("w:val", b.list.numID)
+	// numPr follows pStyle, keepNext, keepLines, pageBreakBefore,
+	// framePr and widowControl.
+	index := 0
+	for _, child := range pPr.ChildElements() {
+		switch child.Tag {
+		case "pStyle", "keepNext", "keepLines", "pageBreakBefore", "framePr", "widowControl":
+			index = child.Index() + 1
+		}
+	}
+	pPr.InsertChildAt(index, numPr)
+}
+
+// listDefinitionNames name the list definitions rich placeholders add to
+// word/numbering.xml, so each is added once.
+const (
+	bulletListName   = "doctemplate bullets"
+	numberedListName = "doctemplate numbering"
+)
+
+// newList adds a list instance to word/numbering.xml — creating the part if
+// the document has none — and returns its w:numId. Numbered lists start
+// from 1 in every instance.
+func (archive *DocxArchive) newList(ordered bool) (string, error) {
+	const name = "word/numbering.xml"
+	exists := archive.hasPart(name)
+	doc, err := archive.readXMLPart(name, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`+
+		`<w:numbering xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"></w:numbering>`)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		if err := archive.ensureOverride(name, "application/vnd.openxmlformats-officedocument.wordprocessingml.numbering+xml"); err != nil {
+			return "", err
+		}
+		if _, err := archive.addRelationship("word/document.xml", relTypeNumbering, "numbering.xml"); err != nil {
+			return "", err
+		}
+	}
+	root := doc.Root()
+
+	listName := bulletListName
+	if ordered {
+		listName = numberedListName
+	}
+	absID := ""
+	for _, abs := range root.SelectElements("abstractNum") {
+		if n := abs.SelectElement("name"); n != nil && n.SelectAttrValue("w:val", "") == listName {
+			absID = abs.SelectAttrValue("w:abstractNumId", "")
+		}
+	}
+	if absID == "" {
+		absID = strconv.Itoa(maxAttr(root, "abstractNum", "w:abstractNumId") + 1)
+		insertAfterLast(root, "abstractNum", listDefinition(absID, listName, ordered), "num")
+	}
+
+	numID := strconv.Itoa(maxAttr(root, "num", "w:numId") + 1)
+	num := etree.NewElement("w:num")
+	num.CreateAttr("w:numId", numID)
+	num.CreateElement("w:abstractNumId").CreateAttr("w:val", absID)
+	if ordered {
+		for level := range 9 {
+			o := num.CreateElement("w:lvlOverride")
+			o.CreateAttr("w:ilvl", strconv.Itoa(level))
+			o.CreateElement("w:startOverride").CreateAttr("w:val", "1")
+		}
+	}
+	insertAfterLast(root, "num", num, "numIdMacAtCleanup")
+
+	if err := archive.writeXMLPart(name, doc); err != nil {
+		return "", err
+	}
+	return numID, nil
+}
+
+// listDefinition builds a w:abstractNum of nine levels, indented half an
+// inch per level.
+func listDefinition(id, name string, ordered bool) *etree.Element {
+	bullets := []string{"•", "o", "▪"}
+	formats := []string{"decimal", "lowerLetter", "lowerRoman"}
+
+	abs := etree.NewElement("w:abstractNum")
+	abs.CreateAttr("w:abstractNumId", id)
+	abs.CreateElement("w:multiLevelType").CreateAttr("w:val", "hybridMultilevel")
+	abs.CreateElement("w:name").CreateAttr("w:val", name)
+	for level := range 9 {
+		lvl := abs.CreateElement("w:lvl")
+		lvl.CreateAttr("w:ilvl", strconv.Itoa(level))
+		lvl.CreateElement("w:start").CreateAttr("w:val", "1")
+		if ordered {
+			lvl.CreateElement("w:numFmt").CreateAttr("w:val", formats[level%3])
+			lvl.CreateElement("w:lvlText").CreateAttr("w:val", fmt.Sprintf("%%%d.", level+1))
+		} else {
+			lvl.CreateElement("w:numFmt").CreateAttr("w:val", "bullet")
+			lvl.CreateElement("w:lvlText").CreateAttr("w:val", bullets[level%3])
+		}
+		lvl.CreateElement("w:lvlJc").CreateAttr("w:val", "left")
+		ind := lvl.CreateElement("w:pPr").CreateElement("w:ind")
+		ind.CreateAttr("w:left", strconv.Itoa(720*(level+1)))
+		ind.CreateAttr("w:hanging", "360")
+	}
+	return abs
+}
@@ -0,0 +1,201 @@
+package doctemplate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/beevik/etree"
+)
+
+// renderParagraphs renders a body and returns its paragraphs.
+func renderParagraphs(t *testing.T, bodyXML string, data map[string]any) (*DocxArchive, []*etree.Element) {
+	t.Helper()
+	archive := renderDocx(t, bodyXML, data)
+	doc := etree.NewDocument()
+	if err := doc.ReadFromString(archive.Content); err != nil {
+		t.Fatalf("parsing document: %v", err)
+	}
+	return archive, doc.FindElements("//body/p")
+}
+
+func TestProcessTemplate_RichTextLines(t *testing.T) {
+	body := `<w:p><w:r><w:rPr><w:i/></w:rPr><w:t xml:space="preserve">Notes: {{{notes}}} ({{ref}})</w:t></w:r></w:p>` +
+		para("{{{missing}}}")
+
+	archive, paragraphs := renderParagraphs(t, body, map[string]any{
+		"notes": "Deliver by Friday\r\nGate 2\tDock B",
+		"ref":   "{{{notes}}}",
+	})
+
+	if len(paragraphs) != 2 {
+		t.Fatalf("got %d paragraphs, want 2", len(paragraphs))
+	}
+	p := paragraphs[0]
+	if got := paragraphText(p); got != "Notes: Deliver by FridayGate 2Dock B ({{{notes}}})" {
+		t.Errorf("text = %q", got)
+	}
+	if n := len(p.FindElements(".//br")); n != 1 {
+		t.Errorf("got %d line breaks, want 1", n)
+	}
+	if n := len(p.FindElements(".//tab")); n != 1 {
+		t.Errorf("got %d tabs, want 1", n)
+	}
+	for _, run := range p.SelectElements("r") {
+		if run.FindElement("rPr/i") == nil {
+			t.Errorf("run %d lost the italic formatting", run.Index())
+		}
+	}
+	if !strings.Contains(archive.Content, "{{{missing}}}") {
+		t.Error("missing rich placeholder was not kept")
+	}
+}
+
+func TestProcessTemplate_RichTextHTML(t *testing.T) {
+	body := `<w:p><w:pPr><w:jc w:val="both"/></w:pPr><w:r><w:rPr><w:sz w:val="20"/></w:rPr><w:t xml:space="preserve">Terms: {{{terms | html}}} Signed {{name}}</w:t></w:r></w:p>` +
+		para("{{{more | html}}}")
+
+	archive, paragraphs := renderParagraphs(t, body, map[string]any{
+		"name": "Ana",
+		"terms": `<p>Payment is due in <b>30 days</b>.</p>` +
+			`<ul><li>Bank <em>transfer</em></li><li>Cheque</li></ul>` +
+			`<ol><li>First</li><li>Second<ol><li>Nested</li></ol></li></ol>` +
+			`<p>See <a href="https://example.com/terms?a=1&amp;b=2">our terms</a> or ` +
+			`<a href="javascript:alert(1)">this</a>.<script>alert(1)</script></p>`,
+		"more": "Plain <u>under</u> &lt;b&gt; text",
+	})
+
+	var lines []string
+	for _, p := range paragraphs {
+		lines = append(lines, paragraphText(p))
+	}
+	want := []string{
+		"Terms: Payment is due in 30 days.",
+		"Bank transfer", "Cheque",
+		"First", "Second", "Nested",
+		"See our terms or this. Signed Ana",
+		"Plain under <b> text",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("paragraphs:\n%s\nwant:\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Every paragraph keeps the alignment; the runs keep the font size and
+	// add their own formatting.
+	for i, p := range paragraphs[:7] {
+		if p.FindElement("pPr/jc") == nil {
+			t.Errorf("paragraph %d lost its alignment", i)
+		}
+	}
+	bold := paragraphs[0].FindElement(".//r[t='30 days']")
+	if bold == nil || bold.FindElement("rPr/b") == nil || bold.FindElement("rPr/sz") == nil {
+		t.Errorf("bold run = %v", bold)
+	} else if bold.FindElement("rPr/b").Index() > bold.FindElement("rPr/sz").Index() {
+		t.Error("run properties are out of schema order")
+	}
+	if r := paragraphs[1].FindElement(".//r[t='transfer']"); r == nil || r.FindElement("rPr/i") == nil {
+		t.Error("emphasis not rendered as italic")
+	}
+	if r := paragraphs[7].FindElement(".//r[t='under']"); r == nil || r.FindElement("rPr/u") == nil {
+		t.Error("underline not rendered")
+	}
+
+	// Lists are numbered through numbering.xml: one instance per list,
+	// nested lists included.
+	var levels, numIDs []string
+	for _, p := range paragraphs[1:6] {
+		levels = append(levels, p.FindElement("pPr/numPr/ilvl").SelectAttrValue("w:val", ""))
+		numIDs = append(numIDs, p.FindElement("pPr/numPr/numId").SelectAttrValue("w:val", ""))
+	}
+	if strings.Join(levels, ",") != "0,0,0,0,1" {
+		t.Errorf("list levels = %v", levels)
+	}
+	if numIDs[0] != numIDs[1] || numIDs[2] != numIDs[3] || numIDs[0] == numIDs[2] || numIDs[4] == numIDs[2] {
+		t.Errorf("list numIds = %v", numIDs)
+	}
+	numbering := parsePart(t, archive, "word/numbering.xml")
+	if n := len(numbering.SelectElements("abstractNum")); n != 2 {
+		t.Errorf("got %d list definitions, want 2", n)
+	}
+	if types := partText(t, archive, "[Content_Types].xml"); !strings.Contains(types, `PartName="/word/numbering.xml"`) {
+		t.Errorf("content types lack numbering.xml:\n%s", types)
+	}
+
+	// Only the safe link becomes a hyperlink.
+	rels := partText(t, archive, "word/_rels/document.xml.rels")
+	if !strings.Contains(rels, `Target="https://example.com/terms?a=1&amp;b=2" TargetMode="External"`) {
+		t.Errorf("hyperlink relationship missing:\n%s", rels)
+	}
+	if strings.Contains(rels, "javascript") {
+		t.Errorf("unsafe link kept:\n%s", rels)
+	}
+	links := paragraphs[6].SelectElements("hyperlink")
+	if len(links) != 1 || links[0].FindElement("r/t").Text() != "our terms" {
+		t.Fatalf("hyperlinks = %v", links)
+	}
+	if id := links[0].SelectAttrValue("r:id", ""); !strings.Contains(rels, `Id="`+id+`"`) {
+		t.Errorf("hyperlink r:id %q not in relationships", id)
+	}
+}
+
+func TestProcessTemplate_RichTextSectionBreak(t *testing.T) {
+	body := `<w:p><w:pPr><w:sectPr><w:type w:val="nextPage"/></w:sectPr></w:pPr><w:r><w:t>{{{terms | html}}}</w:t></w:r></w:p>`
+
+	_, paragraphs := renderParagraphs(t, body, map[string]any{"terms": "<p>One</p><p>Two</p>"})
+	if len(paragraphs) != 2 {
+		t.Fatalf("got %d paragraphs, want 2", len(paragraphs))
+	}
+	if paragraphs[0].FindElement("pPr/sectPr") != nil || paragraphs[1].FindElement("pPr/sectPr") == nil {
+		t.Error("section properties did not move to the last paragraph")
+	}
+}
+
+func TestParseHTML(t *testing.T) {
+	tests := []struct {
+		html string
+		want string
+	}{
+		{"plain  text\n here", "plain text here"},
+		{"a<br>b", "a|b"},
+		{"<div>one</div>\n<div> two </div>", "one\ntwo"},
+		{"<style>p{}</style>x<!-- note -->y", "xy"},
+		{"<span>a</span> <span>b</span>", "a b"},
+		{"<ul><li><p>item</p></li></ul>", "• item"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		var lines []string
+		for _, b := range parseHTML(tt.html) {
+			var sb strings.Builder
+			if b.list != nil {
+				sb.WriteString("• ")
+			}
+			for _, s := range b.spans {
+				if s.br {
+					sb.WriteString("|")
+				}
+				sb.WriteString(s.text)
+			}
+			lines = append(lines, sb.String())
+		}
+		if got := strings.Join(lines, "\n"); got != tt.want {
+			t.Errorf("parseHTML(%q) = %q, want %q", tt.html, got, tt.want)
+		}
+	}
+}
+
+func TestSafeHref(t *testing.T) {
+	tests := map[string]string{
+		` href="https://example.com"`:    "https://example.com",
+		` HREF='mailto:a@example.com'`:   "mailto:a@example.com",
+		` href=tel:+6321234567 target=x`: "tel:+6321234567",
+		` href="javascript:alert(1)"`:    "",
+		` href="data:text/html,hi"`:      "",
+		` href="/relative"`:              "",
+		` title="no link"`:               "",
+	}
+	for attrs, want := range tests {
+		if got := safeHref(attrs); got != want {
+			t.Errorf("safeHref(%q) = %q, want %q", attrs, got, want)
+		}
+	}
+}
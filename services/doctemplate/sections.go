@@ -0,0 +1,793 @@
+package doctemplate
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/beevik/etree"
+)
+
+// Sections are the block tags of the template language:
+//
+//	{{#items}}...{{/items}}              loop over a list; a map or other truthy
+//	                                     value renders the block once
+//	{{^items}}...{{/items}}              inverted section: renders when items is
+//	                                     missing, false, zero, "" or empty
+//	{{#if expr}}...{{else}}...{{/if}}    conditional; {{else}} is optional and
+//	                                     also allowed in loops (rendered when
+//	                                     the list is empty)
+//
+// Inside a loop, paths resolve against the current item. The item's scope
+// also provides the loop variables @index (0-based), @number (1-based),
+// @first and @last; "../" reads from the enclosing scope ({{../currency}},
+// {{../../client.name}}) and "@root." from the top-level data. A section
+// over a map opens a scope of its own in the same way. {{.}} (or {{this}})
+// is the item itself, for lists of strings or numbers. Like any loop over a
+// list it needs the block form, one item per paragraph:
+//
+//	{{#tags}}
+//	{{.}}{{^@last}},{{/@last}}
+//	{{/tags}}
+//
+// A paragraph (or table row) holding nothing but one tag is a block marker:
+// the elements between the markers are kept, dropped or cloned as a unit, so
+// a block can span paragraphs, tables and rows. A tag pair inside a
+// paragraph is inline and shows or hides the text between the tags; when
+// that leaves the paragraph empty, the paragraph itself is removed.
+//
+// Conditions support paths, string/number/boolean literals, the comparisons
+// == != < <= > >=, and !, && and || (no parentheses), e.g.
+// {{#if status == "paid"}} or {{#if discount > 0 && !waived}}.
+
+// markerKind identifies a section tag.
+type markerKind int
+
+const (
+	markerNone     markerKind = iota
+	markerIf                  // {{#if expr}}
+	markerSection             // {{#key}}
+	markerInverted            // {{^key}}
+	markerElse                // {{else}}
+	markerClose               // {{/key}} or {{/if}}
+)
+
+// marker is a parsed section tag. name is the key (or "if"); expr is the
+// condition of an if tag.
+type marker struct {
+	kind markerKind
+	name string
+	expr string
+}
+
+// opens reports whether m starts a block.
+func (m marker) opens() bool {
+	return m.kind == markerIf || m.kind == markerSection || m.kind == markerInverted
+}
+
+// closes reports whether end closes the block opened by m.
+func (m marker) closes(end marker) bool {
+	return end.kind == markerClose && end.name == m.name
+}
+
+// tagRegex matches any {{...}} tag; group 1 is the trimmed inner text.
+var tagRegex = regexp.MustCompile(`{{\s*([^{}]*?)\s*}}`)
+
+// quoteReplacer straightens the typographic quotes Word's autocorrect puts in
+// condition literals.
+var quoteReplacer = strings.NewReplacer("“", `"`, "”", `"`, "‘", `'`, "’", `'`)
+
+// parseMarker parses the inner text of a tag. ok is false for value
+// placeholders.
+func parseMarker(inner string) (marker, bool) {
+	inner = strings.TrimSpace(inner)
+	switch {
+	case inner == "else":
+		return marker{kind: markerElse}, true
+	case strings.HasPrefix(inner, "#"):
+		rest := strings.TrimSpace(inner[1:])
+		if rest == "if" {
+			return marker{}, false
+		}
+		if expr, isIf := strings.CutPrefix(rest, "if "); isIf {
+			return marker{kind: markerIf, name: "if", expr: quoteReplacer.Replace(strings.TrimSpace(expr))}, true
+		}
+		return marker{kind: markerSection, name: rest}, rest != ""
+	case strings.HasPrefix(inner, "^"):
+		rest := strings.TrimSpace(inner[1:])
+		return marker{kind: markerInverted, name: rest}, rest != ""
+	case strings.HasPrefix(inner, "/"):
+		rest := strings.TrimSpace(inner[1:])
+		return marker{kind: markerClose, name: rest}, rest != ""
+	}
+	return marker{}, false
+}
+
+// blockMarker reports whether a paragraph or table row consists of exactly
+// one section tag.
+func blockMarker(el *etree.Element) (marker, bool) {
+	var text string
+	switch el.Tag {
+	case "p":
+		text = paragraphText(el)
+	case "tr":
+		text = rowText(el)
+	default:
+		return marker{}, false
+	}
+	text = strings.TrimSpace(text)
+	m := tagRegex.FindStringSubmatch(text)
+	if m == nil || m[0] != text {
+		return marker{}, false
+	}
+	return parseMarker(m[1])
+}
+
+// ---------------------------------------------------------------------------
+// Block-level sections
+// ---------------------------------------------------------------------------
+
+// processSiblings renders a run of sibling elements — body children, table
+// rows or the content of a table cell — expanding block sections and
+// rendering everything else with renderElement.
+func (r *renderer) processSiblings(parent *etree.Element, elements []*etree.Element, data map[string]any) {
+	for i := 0; i < len(elements); i++ {
+		el := elements[i]
+		m, ok := blockMarker(el)
+		if !ok || !m.opens() {
+			r.renderElement(parent, el, data)
+			continue
+		}
+		end, elseIdx := findBlockEnd(elements, i, m)
+		if end < 0 {
+			// Unclosed block: leave the marker untouched for the author to see.
+			continue
+		}
+		r.expandBlock(parent, elements[i:end+1], elseIdx-i, m, data)
+		i = end
+	}
+}
+
+// findBlockEnd returns the index of the marker closing the block opened at
+// start, and of its top-level {{else}} (-1 if none). end is -1 when the
+// block is not closed among elements.
+func findBlockEnd(elements []*etree.Element, start int, open marker) (end, elseIdx int) {
+	elseIdx = -1
+	depth := 0
+	for j := start + 1; j < len(elements); j++ {
+		m, ok := blockMarker(elements[j])
+		if !ok {
+			continue
+		}
+		switch {
+		case m.opens():
+			depth++
+		case m.kind == markerClose && depth > 0:
+			depth--
+		case m.kind == markerClose:
+			if open.closes(m) {
+				return j, elseIdx
+			}
+			return -1, -1
+		case m.kind == markerElse && depth == 0 && elseIdx < 0:
+			elseIdx = j
+		}
+	}
+	return -1, -1
+}
+
+// expandBlock replaces block (opening marker, content, closing marker) with
+// its rendered content. elseAt is the offset of {{else}} within block, or
+// negative.
+func (r *renderer) expandBlock(parent *etree.Element, block []*etree.Element, elseAt int, m marker, data map[string]any) {
+	content := block[1 : len(block)-1]
+	var alternative []*etree.Element
+	if elseAt > 0 {
+		content = block[1:elseAt]
+		alternative = block[elseAt+1 : len(block)-1]
+	}
+	anchor := block[len(block)-1]
+
+	var keep []*etree.Element
+	switch m.kind {
+	case markerIf:
+		keep = alternative
+		if evalCondition(m.expr, data) {
+			keep = content
+		}
+	case markerInverted:
+		value, _ := getPathValue(data, m.name)
+		if !truthy(value) {
+			keep = content
+		} else {
+			keep = alternative
+		}
+	case markerSection:
+		value, _ := getPathValue(data, m.name)
+		if items, isList := asList(value); isList {
+			if len(items) == 0 {
+				keep = alternative
+				break
+			}
+			// Clone the content once per item and render each clone in the
+			// item's scope.
+			r.scope = append(r.scope, m.name)
+			defer r.popScope()
+			for i, item := range items {
+				clones := make([]*etree.Element, len(content))
+				for k, el := range content {
+					clones[k] = el.Copy()
+					parent.InsertChild(anchor, clones[k])
+				}
+				r.processSiblings(parent, clones, itemScope(item, data, i, len(items)))
+			}
+			for _, el := range block {
+				parent.RemoveChild(el)
+			}
+			return
+		}
+		if scope, isMap := value.(map[string]any); isMap && len(scope) > 0 {
+			keep = content
+			data = childScope(scope, data)
+			r.scope = append(r.scope, m.name)
+			defer r.popScope()
+		} else if truthy(value) {
+			keep = content
+		} else {
+			keep = alternative
+		}
+	}
+
+	kept := make(map[*etree.Element]bool, len(keep))
+	for _, el := range keep {
+		kept[el] = true
+	}
+	for _, el := range block {
+		if !kept[el] {
+			parent.RemoveChild(el)
+		}
+	}
+	r.processSiblings(parent, keep, data)
+}
+
+// popScope leaves the innermost section scope.
+func (r *renderer) popScope() {
+	r.scope = r.scope[:len(r.scope)-1]
+}
+
+// renderElement renders one element that is not a block marker.
+func (r *renderer) renderElement(parent, el *etree.Element, data map[string]any) {
+	switch el.Tag {
+	case "p":
+		for _, box := range textBoxes(el) {
+			r.processSiblings(box, box.ChildElements(), data)
+		}
+		if processInlineSections(el, data) {
+			removeEmptyParagraph(parent, el)
+			return
+		}
+		r.processImages(el, data)
+		for _, p := range r.processRichText(el, data) {
+			r.processParagraph(p, data)
+		}
+		r.richText = nil
+	case "tbl":
+		r.processTable(el, data)
+	default:
+		// Rows, cells, content controls and other containers.
+		r.processSiblings(el, el.ChildElements(), data)
+	}
+}
+
+// removeEmptyParagraph removes a paragraph emptied by an inline section,
+// unless it carries section properties or pictures (text boxes included),
+// or is the last paragraph of a cell (a table cell must contain at least
+// one paragraph).
+func removeEmptyParagraph(parent, p *etree.Element) {
+	if p.FindElement("./pPr/sectPr") != nil || p.FindElement(".//drawing") != nil || p.FindElement(".//pict") != nil {
+		return
+	}
+	if parent.Tag == "tc" && len(parent.SelectElements("p")) <= 1 {
+		return
+	}
+	parent.RemoveChild(p)
+}
+
+// ---------------------------------------------------------------------------
+// Inline sections
+// ---------------------------------------------------------------------------
+
+// inlineTag is a section tag found in a paragraph's text.
+type inlineTag struct {
+	marker
+	start, end int // byte range in the paragraph text
+}
+
+// processInlineSections evaluates the section tags inside a paragraph,
+// removing the tags and the text of branches not taken. Tags may be split
+// across runs; the formatting of the remaining text is untouched. It
+// returns true when sections were evaluated and the paragraph has no text
+// left, so the caller can drop it.
+func processInlineSections(p *etree.Element, data map[string]any) (emptied bool) {
+	nodes := textNodes(p)
+	var sb strings.Builder
+	for _, t := range nodes {
+		sb.WriteString(t.Text())
+	}
+	text := sb.String()
+	if !strings.Contains(text, "{{") {
+		return false
+	}
+
+	var tags []inlineTag
+	for _, loc := range tagRegex.FindAllStringSubmatchIndex(text, -1) {
+		if m, ok := parseMarker(text[loc[2]:loc[3]]); ok {
+			tags = append(tags, inlineTag{marker: m, start: loc[0], end: loc[1]})
+		}
+	}
+	if len(tags) == 0 {
+		return false
+	}
+
+	remove := make([]bool, len(text))
+	cut := func(from, to int) {
+		for k := from; k < to; k++ {
+			remove[k] = true
+		}
+	}
+	evaluated := false
+	for i := 0; i < len(tags); i++ {
+		next, ok := evalInlineSection(tags, i, data, cut)
+		if ok {
+			evaluated = true
+			i = next
+		}
+	}
+	if !evaluated {
+		return false
+	}
+
+	// Write the surviving text back, node by node.
+	offset := 0
+	for _, t := range nodes {
+		original := t.Text()
+		var kept strings.Builder
+		for k := 0; k < len(original); k++ {
+			if !remove[offset+k] {
+				kept.WriteByte(original[k])
+			}
+		}
+		if kept.Len() != len(original) {
+			setText(t, kept.String())
+		}
+		offset += len(original)
+	}
+
+	for k := range text {
+		if !remove[k] && !unicode.IsSpace(rune(text[k])) {
+			return false
+		}
+	}
+	return true
+}
+
+// evalInlineSection evaluates the section opened by tags[i], marking removed
+// byte ranges through cut, and recurses into the branch that is kept. It
+// returns the index of the closing tag, or ok=false when tags[i] does not
+// open an inline section that can be evaluated (e.g. a loop over a list,
+// which only works as a block).
+func evalInlineSection(tags []inlineTag, i int, data map[string]any, cut func(from, to int)) (closeIdx int, ok bool) {
+	open := tags[i]
+	if !open.opens() {
+		return i, false
+	}
+	elseIdx, end, depth := -1, -1, 0
+	for j := i + 1; j < len(tags) && end < 0; j++ {
+		switch {
+		case tags[j].opens():
+			depth++
+		case tags[j].kind == markerClose && depth > 0:
+			depth--
+		case tags[j].kind == markerClose:
+			if !open.closes(tags[j].marker) {
+				return i, false
+			}
+			end = j
+		case tags[j].kind == markerElse && depth == 0 && elseIdx < 0:
+			elseIdx = j
+		}
+	}
+	if end < 0 {
+		return i, false
+	}
+
+	var show bool
+	switch open.kind {
+	case markerIf:
+		show = evalCondition(open.expr, data)
+	case markerInverted:
+		value, _ := getPathValue(data, open.name)
+		show = !truthy(value)
+	case markerSection:
+		value, _ := getPathValue(data, open.name)
+		if _, isList := asList(value); isList {
+			return i, false
+		}
+		show = truthy(value)
+	}
+
+	// Branches as tag index ranges: [i, elseIdx) and [elseIdx, end), or
+	// [i, end) without else.
+	thenEnd := end
+	if elseIdx >= 0 {
+		thenEnd = elseIdx
+	}
+	cut(open.start, open.end)
+	cut(tags[end].start, tags[end].end)
+	if elseIdx >= 0 {
+		cut(tags[elseIdx].start, tags[elseIdx].end)
+	}
+
+	keepFrom, keepTo := i, thenEnd
+	if !show {
+		cut(open.end, tags[thenEnd].start)
+		if elseIdx < 0 {
+			return end, true
+		}
+		keepFrom, keepTo = elseIdx, end
+	} else if elseIdx >= 0 {
+		cut(tags[elseIdx].end, tags[end].start)
+	}
+
+	// Nested sections inside the kept branch.
+	for k := keepFrom + 1; k < keepTo; k++ {
+		if next, nestedOK := evalInlineSection(tags, k, data, cut); nestedOK {
+			k = next
+		}
+	}
+	return end, true
+}
+
+// setText sets the text of a w:t element, preserving leading and trailing
+// spaces, which Word otherwise drops.
+func setText(t *etree.Element, text string) {
+	t.SetText(text)
+	if text != strings.TrimSpace(text) {
+		t.CreateAttr("xml:space", "preserve")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Values and conditions
+// ---------------------------------------------------------------------------
+
+// Keys a scope map gets besides the values of its item or map: the
+// enclosing scope and the top-level data.
+const (
+	parentKey = ".."
+	rootKey   = "@root"
+	thisKey   = "."
+)
+
+// childScope returns the data for the content of a section over value,
+// which can then reach the enclosing scope as "../" and the top-level
+// data as "@root.". value itself is not modified.
+func childScope(value, parent map[string]any) map[string]any {
+	scope := make(map[string]any, len(value)+2)
+	for k, v := range value {
+		scope[k] = v
+	}
+	scope[parentKey] = parent
+	root, ok := parent[rootKey]
+	if !ok {
+		root = parent
+	}
+	scope[rootKey] = root
+	return scope
+}
+
+// itemScope returns the data for item index of a loop over count items,
+// with the loop variables set. The item is also the scope's "." ({{.}} or
+// {{this}}), the only way to read an item that is not a map.
+func itemScope(item any, parent map[string]any, index, count int) map[string]any {
+	fields, _ := item.(map[string]any)
+	scope := childScope(fields, parent)
+	scope[thisKey] = item
+	scope["@index"] = index
+	scope["@number"] = index + 1
+	scope["@first"] = index == 0
+	scope["@last"] = index == count-1
+	return scope
+}
+
+// splitPath splits a path into map keys: "../../client.name" →
+// ["..", "..", "client", "name"].
+func splitPath(path string) []string {
+	var keys []string
+	for {
+		rest, ok := strings.CutPrefix(path, "../")
+		if !ok {
+			break
+		}
+		keys = append(keys, parentKey)
+		path = rest
+	}
+	if path == "." || path == "this" {
+		return append(keys, thisKey)
+	}
+	return append(keys, strings.Split(path, ".")...)
+}
+
+// qualifyPath expresses a path read in the given scope (the names of the
+// enclosing sections, outermost first) from the top level:
+// [invoices items] + "../number" → "invoices.number".
+func qualifyPath(scope []string, path string) string {
+	keys := splitPath(path)
+	if keys[0] == rootKey {
+		return strings.Join(keys[1:], ".")
+	}
+	if len(keys) == 1 && keys[0] == thisKey && len(scope) > 0 {
+		return strings.Join(scope, ".")
+	}
+	scope = scope[:len(scope):len(scope)]
+	for len(keys) > 1 && keys[0] == parentKey {
+		keys = keys[1:]
+		if len(scope) > 0 {
+			scope = scope[:len(scope)-1]
+		}
+	}
+	return strings.Join(append(scope, keys...), ".")
+}
+
+// isLoopVariable reports whether path names a loop variable, which is not
+// part of the data.
+func isLoopVariable(path string) bool {
+	switch path {
+	case "@index", "@number", "@first", "@last":
+		return true
+	}
+	return false
+}
+
+// asList normalizes the list types json.Unmarshal and callers produce.
+func asList(v any) ([]any, bool) {
+	switch l := v.(type) {
+	case []any:
+		return l, true
+	case []map[string]any:
+		items := make([]any, len(l))
+		for i, m := range l {
+			items[i] = m
+		}
+		return items, true
+	}
+	return nil, false
+}
+
+// truthy reports whether a value counts as set: not nil, false, zero, ""
+// or an empty list or map.
+func truthy(v any) bool {
+	switch t := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return t
+	case string:
+		return t != ""
+	case map[string]any:
+		return len(t) > 0
+	}
+	if l, isList := asList(v); isList {
+		return len(l) > 0
+	}
+	if n, isNumber := toNumber(v); isNumber {
+		return n != 0
+	}
+	return true
+}
+
+// toNumber converts numeric values and numeric strings to float64.
+func toNumber(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// evalCondition evaluates an if expression against data. Malformed
+// expressions evaluate to false.
+func evalCondition(expr string, data map[string]any) bool {
+	tokens, err := tokenizeCondition(expr)
+	if err != nil || len(tokens) == 0 {
+		return false
+	}
+	c := &conditionParser{tokens: tokens, data: data}
+	result := c.or()
+	return c.pos == len(tokens) && result
+}
+
+// conditionToken is a lexical token of a condition: an operator, a quoted
+// string literal (quoted=true) or a bare word (path, number, true/false).
+type conditionToken struct {
+	text   string
+	quoted bool
+}
+
+var conditionOperators = []string{"&&", "||", "==", "!=", ">=", "<=", ">", "<", "!"}
+
+func tokenizeCondition(expr string) ([]conditionToken, error) {
+	var tokens []conditionToken
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(expr[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string in %q", expr)
+			}
+			tokens = append(tokens, conditionToken{text: expr[i+1 : i+1+end], quoted: true})
+			i += end + 2
+		default:
+			op := ""
+			for _, candidate := range conditionOperators {
+				if strings.HasPrefix(expr[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op != "" {
+				tokens = append(tokens, conditionToken{text: op})
+				i += len(op)
+				continue
+			}
+			j := i
+			for j < len(expr) && !strings.ContainsRune(" \t\"'&|=!<>", rune(expr[j])) {
+				j++
+			}
+			tokens = append(tokens, conditionToken{text: expr[i:j]})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+// conditionParser is a recursive-descent evaluator over condition tokens.
+type conditionParser struct {
+	tokens []conditionToken
+	pos    int
+	data   map[string]any
+}
+
+func (c *conditionParser) peek(op string) bool {
+	return c.pos < len(c.tokens) && !c.tokens[c.pos].quoted && c.tokens[c.pos].text == op
+}
+
+func (c *conditionParser) or() bool {
+	result := c.and()
+	for c.peek("||") {
+		c.pos++
+		right := c.and()
+		result = result || right
+	}
+	return result
+}
+
+func (c *conditionParser) and() bool {
+	result := c.not()
+	for c.peek("&&") {
+		c.pos++
+		right := c.not()
+		result = result && right
+	}
+	return result
+}
+
+func (c *conditionParser) not() bool {
+	if c.peek("!") {
+		c.pos++
+		return !c.not()
+	}
+	return c.comparison()
+}
+
+func (c *conditionParser) comparison() bool {
+	left := c.operand()
+	for _, op := range []string{"==", "!=", ">=", "<=", ">", "<"} {
+		if c.peek(op) {
+			c.pos++
+			return compareValues(left, c.operand(), op)
+		}
+	}
+	return truthy(left)
+}
+
+func (c *conditionParser) operand() any {
+	if c.pos >= len(c.tokens) {
+		return nil
+	}
+	tok := c.tokens[c.pos]
+	c.pos++
+	if tok.quoted {
+		return tok.text
+	}
+	switch tok.text {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null", "nil":
+		return nil
+	}
+	if f, err := strconv.ParseFloat(tok.text, 64); err == nil {
+		return f
+	}
+	value, _ := getPathValue(c.data, tok.text)
+	return value
+}
+
+// compareValues compares numerically when both sides are numbers (or
+// numeric strings) and as text otherwise. A missing value equals "".
+func compareValues(a, b any, op string) bool {
+	if x, ok := toNumber(a); ok {
+		if y, ok := toNumber(b); ok {
+			switch op {
+			case "==":
+				return x == y
+			case "!=":
+				return x != y
+			case ">":
+				return x > y
+			case "<":
+				return x < y
+			case ">=":
+				return x >= y
+			case "<=":
+				return x <= y
+			}
+		}
+	}
+	x, y := valueString(a), valueString(b)
+	switch op {
+	case "==":
+		return x == y
+	case "!=":
+		return x != y
+	case ">":
+		return x > y
+	case "<":
+		return x < y
+	case ">=":
+		return x >= y
+	case "<=":
+		return x <= y
+	}
+	return false
+}
+
+// valueString renders a value the way placeholders do; nil is "".
+func valueString(v any) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", v)
+}
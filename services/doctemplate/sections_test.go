@@ -0,0 +1,373 @@
+package doctemplate
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// testTemplate wraps body XML in a minimal DOCX.
+func testTemplate(t *testing.T, bodyXML string) []byte {
+	t.Helper()
+	return createTestDocx(t, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>`+
+		bodyXML+`</w:body></w:document>`)
+}
+
+// readDocx reads a processed DOCX back into an archive.
+func readDocx(t *testing.T, result []byte) *DocxArchive {
+	t.Helper()
+	archive, err := ReadDocxBytes(result)
+	if err != nil {
+		t.Fatalf("failed to read output docx: %v", err)
+	}
+	return archive
+}
+
+// renderDocx runs a document body through ProcessTemplate and returns the
+// output archive.
+func renderDocx(t *testing.T, bodyXML string, data map[string]any) *DocxArchive {
+	t.Helper()
+	result, err := ProcessTemplate(testTemplate(t, bodyXML), data)
+	if err != nil {
+		t.Fatalf("ProcessTemplate failed: %v", err)
+	}
+	return readDocx(t, result)
+}
+
+// renderBody runs a document body through ProcessTemplate and returns the
+// text of each resulting paragraph, with table cells separated by " | ".
+func renderBody(t *testing.T, bodyXML string, data map[string]any) []string {
+	t.Helper()
+	return documentLines(renderDocx(t, bodyXML, data).Content)
+}
+
+var (
+	lineBreakRegex = regexp.MustCompile(`</w:p>`)
+	cellBreakRegex = regexp.MustCompile(`</w:p></w:tc>`)
+	rowBreakRegex  = regexp.MustCompile(`</w:tr>`)
+	xmlTagRegex    = regexp.MustCompile(`<[^>]+>`)
+)
+
+// documentLines extracts one line of text per paragraph or table row.
+func documentLines(content string) []string {
+	content = cellBreakRegex.ReplaceAllString(content, " | ")
+	content = rowBreakRegex.ReplaceAllString(content, "</w:p>")
+	var lines []string
+	for _, chunk := range lineBreakRegex.Split(content, -1) {
+		line := strings.TrimSpace(strings.Trim(strings.TrimSpace(xmlTagRegex.ReplaceAllString(chunk, "")), "|"))
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func para(text string) string {
+	return `<w:p><w:r><w:t xml:space="preserve">` + text + `</w:t></w:r></w:p>`
+}
+
+func TestProcessTemplate_BlockConditionals(t *testing.T) {
+	body := para("Invoice") +
+		para(`{{#if status == "paid"}}`) +
+		para("PAID on {{paid_on}}") +
+		para("{{else}}") +
+		para("Due {{due_on}}") +
+		para("{{/if}}") +
+		para("{{#if discount}}") +
+		para("Discount: {{discount}}") +
+		para("{{/if}}") +
+		para("{{^items}}") +
+		para("No items") +
+		para("{{/items}}") +
+		para("{{#client}}") +
+		para("Bill to {{name}}") +
+		para("{{/client}}")
+
+	tests := []struct {
+		name string
+		data map[string]any
+		want []string
+	}{
+		{
+			name: "paid with discount",
+			data: map[string]any{
+				"status": "paid", "paid_on": "2026-03-01", "discount": 150.5,
+				"items": []any{map[string]any{"sku": "A"}}, "client": map[string]any{"name": "Acme"},
+			},
+			want: []string{"Invoice", "PAID on 2026-03-01", "Discount: 150.5", "Bill to Acme"},
+		},
+		{
+			name: "unpaid, no discount, no items, no client",
+			data: map[string]any{"status": "open", "due_on": "2026-03-31", "discount": 0, "items": []any{}},
+			want: []string{"Invoice", "Due 2026-03-31", "No items"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := renderBody(t, body, tt.data)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got lines %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessTemplate_NestedBlocks(t *testing.T) {
+	body := para("{{#sections}}") +
+		para("{{title}}") +
+		para("{{#if draft}}") +
+		para("DRAFT") +
+		para("{{/if}}") +
+		para("{{/sections}}") +
+		para("{{#sections}}") +
+		para("{{else}}") +
+		para("Nothing to show") +
+		para("{{/sections}}")
+
+	got := renderBody(t, body, map[string]any{"sections": []any{
+		map[string]any{"title": "One", "draft": true},
+		map[string]any{"title": "Two"},
+	}})
+	want := []string{"One", "DRAFT", "Two"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got lines %q, want %q", got, want)
+	}
+
+	got = renderBody(t, body, map[string]any{"sections": []any{}})
+	want = []string{"Nothing to show"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("empty list: got lines %q, want %q", got, want)
+	}
+}
+
+func TestProcessTemplate_UnclosedBlock(t *testing.T) {
+	body := para("Before") +
+		para("{{#items}}") +
+		para("{{name}}")
+
+	got := renderBody(t, body, map[string]any{"name": "Acme", "items": []any{map[string]any{}}})
+	want := []string{"Before", "{{#items}}", "Acme"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got lines %q, want %q", got, want)
+	}
+}
+
+func TestProcessTemplate_TableRowConditionals(t *testing.T) {
+	row := func(cells ...string) string {
+		var sb strings.Builder
+		sb.WriteString("<w:tr>")
+		for _, c := range cells {
+			sb.WriteString("<w:tc>" + para(c) + "</w:tc>")
+		}
+		sb.WriteString("</w:tr>")
+		return sb.String()
+	}
+	body := "<w:tbl>" +
+		row("Item", "Amount") +
+		row("{{#items}}", "") +
+		row("{{description}}", "{{amount}}") +
+		row("{{/items}}", "") +
+		row("{{#if discount > 0}}", "") +
+		row("Discount", "{{discount}}") +
+		row("{{/if}}", "") +
+		row("Total", "{{#if total >= 1000}}{{total}} (VAT incl.){{else}}{{total}}{{/if}}") +
+		"</w:tbl>"
+
+	got := renderBody(t, body, map[string]any{
+		"items":    []any{map[string]any{"description": "Hosting", "amount": "900.00"}},
+		"discount": 0,
+		"total":    "900.00",
+	})
+	want := []string{"Item | Amount", "Hosting | 900.00", "Total | 900.00"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got lines %q, want %q", got, want)
+	}
+}
+
+func TestProcessTemplate_InlineConditionals(t *testing.T) {
+	// Tags split across runs the way Word saves them.
+	body := `<w:p><w:r><w:t>{{#if disc</w:t></w:r><w:r><w:t>ount}}Discount: </w:t></w:r>` +
+		`<w:r><w:rPr><w:b/></w:rPr><w:t>{{discount}}</w:t></w:r><w:r><w:t>{{/if}}</w:t></w:r></w:p>` +
+		para(`Status: {{#if status == “paid”}}Paid{{else}}Open{{/if}}{{^notes}} (no notes){{/notes}}`) +
+		para("Last line")
+
+	got := renderBody(t, body, map[string]any{"status": "paid"})
+	want := []string{"Status: Paid (no notes)", "Last line"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got lines %q, want %q", got, want)
+	}
+
+	got = renderBody(t, body, map[string]any{"status": "open", "discount": "50.00", "notes": "x"})
+	want = []string{"Discount: 50.00", "Status: Open", "Last line"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got lines %q, want %q", got, want)
+	}
+}
+
+func TestEvalCondition(t *testing.T) {
+	data := map[string]any{
+		"status": "paid",
+		"total":  1500.0,
+		"count":  "3",
+		"client": map[string]any{"vip": true, "name": ""},
+		"items":  []any{},
+	}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`status`, true},
+		{`missing`, false},
+		{`items`, false},
+		{`client.name`, false},
+		{`!client.name`, true},
+		{`status == "paid"`, true},
+		{`status != 'paid'`, false},
+		{`total > 1000`, true},
+		{`total <= 1000`, false},
+		{`count == 3`, true},
+		{`count >= 10`, false},
+		{`missing == ""`, true},
+		{`client.vip == true && total > 1000`, true},
+		{`client.vip && status == "open" || total < 2000`, true},
+		{`status == "paid`, false},
+		{`status ==`, false},
+	}
+	for _, tt := range tests {
+		if got := evalCondition(tt.expr, data); got != tt.want {
+			t.Errorf("evalCondition(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestProcessTemplate_LoopVariables(t *testing.T) {
+	body := para("{{#items}}") +
+		para("{{@number}}. {{name}} of {{../order}}{{#@first}} (first){{/@first}}{{#if @last}} (last){{/if}}") +
+		para("{{#parts}}") +
+		para("{{@index}}: {{part}} for {{../name}}, {{../../order}}, {{@root.order}}") +
+		para("{{/parts}}") +
+		para("{{/items}}") +
+		para("{{#client}}") +
+		para("{{name}} ordered {{../order}}") +
+		para("{{/client}}")
+
+	got := renderBody(t, body, map[string]any{
+		"order":  "SO-7",
+		"client": map[string]any{"name": "Acme"},
+		"items": []any{
+			map[string]any{"name": "Desk", "parts": []any{
+				map[string]any{"part": "Top"},
+				map[string]any{"part": "Legs"},
+			}},
+			map[string]any{"name": "Chair"},
+			map[string]any{"name": "Lamp", "parts": []map[string]any{{"part": "Bulb"}}},
+		},
+	})
+	want := []string{
+		"1. Desk of SO-7 (first)",
+		"0: Top for Desk, SO-7, SO-7",
+		"1: Legs for Desk, SO-7, SO-7",
+		"2. Chair of SO-7",
+		"3. Lamp of SO-7 (last)",
+		"0: Bulb for Lamp, SO-7, SO-7",
+		"Acme ordered SO-7",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got lines %q, want %q", got, want)
+	}
+}
+
+func TestProcessTemplate_ScalarItems(t *testing.T) {
+	body := para("{{#tags}}") +
+		para("{{@number}}. {{.}} / {{this | upper}}{{#if . == \"b\"}} (b){{/if}}{{^@last}},{{/@last}}") +
+		para("{{/tags}}") +
+		para("{{#amounts}}") +
+		para(`{{. | currency:"PHP"}} of {{../order}}`) +
+		para("{{/amounts}}")
+
+	got := renderBody(t, body, map[string]any{
+		"order":   "SO-7",
+		"tags":    []any{"a", "b", "c"},
+		"amounts": []any{1500, 250.5},
+	})
+	want := []string{
+		"1. a / A,",
+		"2. b / B (b),",
+		"3. c / C",
+		"₱1,500.00 of SO-7",
+		"₱250.50 of SO-7",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got lines %q, want %q", got, want)
+	}
+}
+
+func TestProcessTemplate_DeepNesting(t *testing.T) {
+	cell := func(paragraphs ...string) string {
+		return "<w:tc>" + strings.Join(paragraphs, "") + "</w:tc>"
+	}
+	row := func(cells ...string) string {
+		return "<w:tr>" + strings.Join(cells, "") + "</w:tr>"
+	}
+	// A table of projects; each project's cell holds a block loop over its
+	// phases, and each phase a table whose rows loop over its tasks, each
+	// task's cell listing its subtasks.
+	tasks := "<w:tbl>" +
+		row(cell(para("{{#tasks}}"))) +
+		row(cell(para("{{name}}"), para("{{#subtasks}}"), para("- {{title}} ({{../../phase}})"), para("{{/subtasks}}")), cell(para("{{hours}}h"))) +
+		row(cell(para("{{/tasks}}"))) +
+		"</w:tbl>"
+	body := "<w:tbl>" +
+		row(cell(para("{{#projects}}"))) +
+		row(cell(para("{{@number}}/{{name}}")), cell(para("{{#phases}}"), para("Phase {{phase}} of {{../name}}"), tasks, para("{{/phases}}"))) +
+		row(cell(para("{{/projects}}"))) +
+		"</w:tbl>"
+
+	got := renderBody(t, body, map[string]any{"projects": []any{
+		map[string]any{"name": "Web", "phases": []any{
+			map[string]any{"phase": "Build", "tasks": []any{
+				map[string]any{"name": "API", "hours": 8, "subtasks": []any{
+					map[string]any{"title": "Auth"},
+					map[string]any{"title": "Billing"},
+				}},
+				map[string]any{"name": "UI", "hours": 5},
+			}},
+			map[string]any{"phase": "Ship", "tasks": []any{}},
+		}},
+		map[string]any{"name": "App", "phases": []any{}},
+	}})
+	want := []string{
+		"1/Web | Phase Build of Web",
+		"API",
+		"- Auth (Build)",
+		"- Billing (Build) | 8h",
+		"UI | 5h",
+		"Phase Ship of Web",
+		"2/App",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got lines %q, want %q", got, want)
+	}
+}
+
+func TestQualifyPath(t *testing.T) {
+	tests := []struct {
+		scope []string
+		path  string
+		want  string
+	}{
+		{nil, "client.name", "client.name"},
+		{[]string{"items"}, "amount", "items.amount"},
+		{[]string{"invoices", "items"}, "../number", "invoices.number"},
+		{[]string{"invoices", "items"}, "../../currency", "currency"},
+		{[]string{"invoices", "items"}, "@root.client.name", "client.name"},
+		{[]string{"tags"}, ".", "tags"},
+	}
+	for _, tt := range tests {
+		if got := qualifyPath(tt.scope, tt.path); got != tt.want {
+			t.Errorf("qualifyPath(%v, %q) = %q, want %q", tt.scope, tt.path, got, tt.want)
+		}
+	}
+}
@@ -11,7 +11,7 @@ import (
 // Regular expressions to find placeholders and loop markers in the XML text.
 var (
 	// placeholderRegex matches simple placeholders like {{key.path}} or {{ key.path }}.
-	placeholderRegex = regexp.MustCompile(`{{\s*([^#{}][^{}]*?)\s*}}`)
+	placeholderRegex = regexp.MustCompile(`{{\s*([^#^/%{}][^{}]*?)\s*}}`)
 	// loopStartRegex matches loop start markers like {{#key}}.
 	loopStartRegex = regexp.MustCompile(`{{\s*#\s*([^{}]+)\s*}}`)
 	// loopEndRegex matches loop end markers like {{/key}}.
@@ -19,8 +19,15 @@ var (
 )
 
 // getPathValue retrieves a nested value from a map using a dot-separated path, returning the raw value.
+// Paths may start with "../" (the enclosing scope) or "@root." (the top-level data); see sections.go.
 func getPathValue(data map[string]any, path string) (any, bool) {
-	parts := strings.Split(path, ".")
+	parts := splitPath(path)
+	if parts[0] == rootKey {
+		if _, ok := data[rootKey]; !ok {
+			// At the top level the data is the root.
+			parts = parts[1:]
+		}
+	}
 	var current any = data
 
 	for _, part := range parts {
@@ -57,23 +64,6 @@ func extractPlaceholder(text string) string {
 	return ""
 }
 
-// extractLoopMarker extracts the key from a loop marker, e.g., "{{#propName}}" -> "propName".
-func extractLoopMarker(text string, prefix string) string {
-	var re *regexp.Regexp
-	if prefix == "#" {
-		re = loopStartRegex
-	} else if prefix == "/" {
-		re = loopEndRegex
-	} else {
-		return ""
-	}
-
-	if matches := re.FindStringSubmatch(text); len(matches) > 1 {
-		return strings.TrimSpace(matches[1])
-	}
-	return ""
-}
-
 // processParagraph handles placeholder replacement and loop detection within a <w:p> element.
 // It performs cross-run text accumulation to correctly handle placeholders split by Word
 // across multiple <w:r> elements (e.g., "{{" in one run, "client.name}}" in another).
@@ -81,8 +71,8 @@ func extractLoopMarker(text string, prefix string) string {
 // Returns:
 //   - loopName: non-empty if a {{#key}} loop start marker was found
 //   - endLoop: true if a {{/key}} loop end marker was found
-func processParagraph(p *etree.Element, data map[string]any) (loopName string, endLoop bool) {
-	allTextNodes := p.FindElements(".//t")
+func (r *renderer) processParagraph(p *etree.Element, data map[string]any) (loopName string, endLoop bool) {
+	allTextNodes := textNodes(p)
 	if len(allTextNodes) == 0 {
 		return "", false
 	}
@@ -91,6 +81,9 @@ func processParagraph(p *etree.Element, data map[string]any) (loopName string, e
 	var accNodes []*etree.Element
 
 	for _, t := range allTextNodes {
+		if r.richText[t] {
+			continue
+		}
 		text := t.Text()
 
 		// If not accumulating and no placeholder opener, skip this node entirely
@@ -124,7 +117,7 @@ func processParagraph(p *etree.Element, data map[string]any) (loopName string, e
 		}
 
 		// Replace all value placeholders {{key.path}} in the accumulated text
-		replaced := replaceInText(accumulated, data)
+		replaced := r.replaceInText(accumulated, data)
 
 		// Put the final result in the last text node, clear earlier ones
 		accNodes[len(accNodes)-1].SetText(replaced)
@@ -140,18 +133,52 @@ func processParagraph(p *etree.Element, data map[string]any) (loopName string, e
 	return "", false
 }
 
-// replaceInText replaces all {{key.path}} placeholders in a string with values from data.
-func replaceInText(text string, data map[string]any) string {
-	return placeholderRegex.ReplaceAllStringFunc(text, func(match string) string {
-		key := extractPlaceholder(match)
-		if key == "" {
-			return match
+// replaceInText replaces all {{key.path}} placeholders in a string with values
+// from data. Placeholders that cannot be rendered are handled according to
+// the renderer's missing-value policy. Rich placeholders ({{{key}}}, see
+// richtext.go) are left alone.
+func (r *renderer) replaceInText(text string, data map[string]any) string {
+	var sb strings.Builder
+	last := 0
+	for _, loc := range placeholderRegex.FindAllStringSubmatchIndex(text, -1) {
+		if loc[0] > 0 && text[loc[0]-1] == '{' {
+			continue
 		}
-		if val, ok := getReplaceValue(data, key); ok {
-			return val
+		sb.WriteString(text[last:loc[0]])
+		last = loc[1]
+		match, expr := text[loc[0]:loc[1]], text[loc[2]:loc[3]]
+		val, err := renderPlaceholder(expr, data)
+		if err != nil {
+			val = r.missingValue(match, parsePlaceholderExpr(expr).path, err)
 		}
-		return match
-	})
+		sb.WriteString(val)
+	}
+	sb.WriteString(text[last:])
+	return sb.String()
+}
+
+// renderPlaceholder resolves a placeholder expression — a path optionally
+// followed by formatters and a default, e.g. `total | currency:"PHP"` or
+// `name ?? "N/A"` — to its text. The error is errMissingValue when the path
+// is missing and there is no default, or the formatter's error.
+func renderPlaceholder(expr string, data map[string]any) (string, error) {
+	if expr == "" {
+		return "", errMissingValue
+	}
+	return renderExpr(parsePlaceholderExpr(expr), data)
+}
+
+// renderExpr resolves a parsed placeholder expression to its text, as
+// renderPlaceholder does.
+func renderExpr(pe placeholderExpr, data map[string]any) (string, error) {
+	val, ok := getPathValue(data, pe.path)
+	if pe.hasFallback && (!ok || val == nil) {
+		return pe.fallback, nil
+	}
+	if !ok {
+		return "", errMissingValue
+	}
+	return applyFormatters(val, pe.calls)
 }
 
 // clearNodes sets the text of all given elements to empty string.
@@ -161,22 +188,12 @@ func clearNodes(nodes []*etree.Element) {
 	}
 }
 
-// processElements recursively processes a slice of elements, applying templating logic.
-func processElements(elements []*etree.Element, data map[string]any) {
-	for _, el := range elements {
-		switch el.Tag {
-		case "p":
-			processParagraph(el, data)
-		case "tbl":
-			processTable(el, data)
-		}
-	}
-}
-
-// processTable handles table row cloning for array loops and placeholder
-// replacement in non-loop rows (e.g., header row, total row).
+// processTable renders the rows of a table. Rows consisting of a single
+// section tag are block markers (see sections.go), so rows can be looped
+// over or shown conditionally; every other row has its cells rendered like
+// body content, including nested tables and blocks inside a cell.
 //
-// Table structure expected:
+// Table structure expected for a loop:
 //
 //	<w:tbl>
 //	  <w:tr>Header row (static)</w:tr>
@@ -185,118 +202,8 @@ func processElements(elements []*etree.Element, data map[string]any) {
 //	  <w:tr>{{/items}}</w:tr>           ← end marker row
 //	  <w:tr>Total: {{total}}</w:tr>     ← static row with placeholders
 //	</w:tbl>
-func processTable(tbl *etree.Element, data map[string]any) {
-	rows := tbl.FindElements("./tr")
-	if len(rows) == 0 {
-		return
-	}
-
-	// Scan rows to find loop markers
-	loopKey := ""
-	startIndex, endIndex := -1, -1
-
-	for i, row := range rows {
-		text := rowText(row)
-		if key := extractLoopMarker(text, "#"); key != "" {
-			loopKey = key
-			startIndex = i
-		} else if extractLoopMarker(text, "/") != "" && loopKey != "" {
-			endIndex = i
-			break
-		}
-	}
-
-	// If no loop markers found, just process all rows for simple placeholders
-	if startIndex == -1 || endIndex == -1 || loopKey == "" {
-		for _, row := range rows {
-			processRowCells(row, data)
-		}
-		return
-	}
-
-	// Process non-loop rows (header, total, etc.) for placeholder replacement
-	for i, row := range rows {
-		if i >= startIndex && i <= endIndex {
-			continue // Skip loop marker and template rows
-		}
-		processRowCells(row, data)
-	}
-
-	// Collect template rows (between start and end markers, exclusive)
-	var templateRows []*etree.Element
-	for i := startIndex + 1; i < endIndex; i++ {
-		templateRows = append(templateRows, rows[i])
-	}
-
-	if len(templateRows) == 0 {
-		// No template rows between markers — just remove markers
-		tbl.RemoveChild(rows[startIndex])
-		tbl.RemoveChild(rows[endIndex])
-		return
-	}
-
-	// Get the array data for looping
-	rawLoopData, ok := getPathValue(data, loopKey)
-	if !ok {
-		// No data — remove all loop rows (markers + templates)
-		for i := startIndex; i <= endIndex; i++ {
-			tbl.RemoveChild(rows[i])
-		}
-		return
-	}
-
-	loopData, ok := rawLoopData.([]any)
-	if !ok {
-		// Try []map[string]any → []any conversion
-		if mapSlice, isMapSlice := rawLoopData.([]map[string]any); isMapSlice {
-			loopData = make([]any, len(mapSlice))
-			for i, v := range mapSlice {
-				loopData[i] = v
-			}
-		} else {
-			return
-		}
-	}
-
-	if len(loopData) == 0 {
-		for i := startIndex; i <= endIndex; i++ {
-			tbl.RemoveChild(rows[i])
-		}
-		return
-	}
-
-	// The anchor is the end marker row — we insert cloned rows before it
-	anchor := rows[endIndex]
-
-	// For each array item, clone template rows and process placeholders
-	for _, itemData := range loopData {
-		itemMap, ok := itemData.(map[string]any)
-		if !ok {
-			continue
-		}
-
-		for _, tmplRow := range templateRows {
-			newRow := tmplRow.Copy()
-			processRowCells(newRow, itemMap)
-			tbl.InsertChild(anchor, newRow)
-		}
-	}
-
-	// Remove original marker rows and template rows
-	tbl.RemoveChild(rows[startIndex]) // {{#items}} row
-	for _, tmplRow := range templateRows {
-		tbl.RemoveChild(tmplRow)
-	}
-	tbl.RemoveChild(rows[endIndex]) // {{/items}} row
-}
-
-// processRowCells runs processParagraph on every cell's paragraphs in a table row.
-func processRowCells(row *etree.Element, data map[string]any) {
-	for _, cell := range row.FindElements(".//tc") {
-		for _, p := range cell.FindElements("./p") {
-			processParagraph(p, data)
-		}
-	}
+func (r *renderer) processTable(tbl *etree.Element, data map[string]any) {
+	r.processSiblings(tbl, tbl.ChildElements(), data)
 }
 
 // rowText concatenates all text content in a table row for marker detection.
@@ -309,156 +216,114 @@ func rowText(row *etree.Element) string {
 }
 
 // ProcessBody is the main entry point for processing the document body.
-// It uses clone-based iteration for body-level loops, supporting two-level
-// nesting (body-level loop wrapping table-level loops).
 //
-// For body-level loops ({{#key}}...{{/key}} as standalone paragraphs):
-//  1. Scans body children to find start/end marker paragraphs
-//  2. Collects all elements between markers as the "template block"
-//  3. Deep-copies the template block for each array item via element.Copy()
-//  4. Processes each clone with the current item's data (resolving nested table loops)
-//  5. Replaces the original markers and template block with processed clones
-//  6. Processes remaining elements (before/after) with root data
+// Standalone marker paragraphs delimit blocks ({{#key}}...{{/key}} loops and
+// sections, {{^key}} inverted sections, {{#if}}...{{else}}...{{/if}}). The
+// elements of a loop block are deep-copied once per array item and rendered
+// with that item's data, so table loops inside a body loop resolve against
+// the current item. Blocks nest to any depth; all other paragraphs and
+// tables are rendered with the data of the enclosing scope.
 func ProcessBody(body *etree.Element, data map[string]any) {
-	bodyItems := body.ChildElements()
-
-	// First pass: non-destructive scan for body-level loop markers
-	startIdx, endIdx, loopKey := findBodyLoopMarkers(bodyItems)
-
-	// No loop markers found — process all elements normally with root data
-	if startIdx == -1 || endIdx == -1 {
-		for _, el := range bodyItems {
-			switch el.Tag {
-			case "p":
-				processParagraph(el, data)
-			case "tbl":
-				processTable(el, data)
-			}
-		}
-		return
-	}
-
-	// Process elements before the loop with root data
-	for i := 0; i < startIdx; i++ {
-		el := bodyItems[i]
-		switch el.Tag {
-		case "p":
-			processParagraph(el, data)
-		case "tbl":
-			processTable(el, data)
-		}
-	}
-
-	// Collect the template block elements (between start and end markers, exclusive)
-	var templateBlock []*etree.Element
-	for i := startIdx + 1; i < endIdx; i++ {
-		templateBlock = append(templateBlock, bodyItems[i])
-	}
-
-	// Get the array data for the loop key
-	loopItems := resolveLoopData(data, loopKey)
-
-	// The anchor for insertion is the end marker paragraph
-	anchor := bodyItems[endIdx]
+	r := &renderer{}
+	r.processSiblings(body, body.ChildElements(), data)
+}
 
-	// Clone and process template block for each array item.
-	// Each clone gets processParagraph/processTable with the item's data,
-	// so nested table loops (e.g., {{#items}} inside a table) resolve
-	// against the current body-loop item's scope.
-	for _, itemData := range loopItems {
-		itemMap, ok := itemData.(map[string]any)
-		if !ok {
-			continue
-		}
-		for _, tmplEl := range templateBlock {
-			clone := tmplEl.Copy()
-			switch clone.Tag {
-			case "p":
-				processParagraph(clone, itemMap)
-			case "tbl":
-				processTable(clone, itemMap)
-			}
-			body.InsertChild(anchor, clone)
-		}
-	}
+// renderer carries the state of rendering one XML part of a document.
+type renderer struct {
+	// archive receives the media and relationships that image placeholders
+	// add. It is nil when a bare body is rendered (ProcessBody), in which
+	// case image placeholders are left untouched.
+	archive *DocxArchive
+	// part is the name of the part being rendered, e.g. "word/document.xml".
+	part string
+	// err is the first error met while rendering.
+	err error
+
+	// opts are the options the template is processed with.
+	opts Options
+	// missing collects the values that could not be rendered when
+	// opts.Missing is MissingError. It is shared by the parts of a document.
+	missing *missingValues
+	// scope holds the names of the sections being expanded, outermost
+	// first, so missing keys can be reported as "items.description".
+	scope []string
+	// richText holds the w:t elements written by rich placeholders in the
+	// paragraph being rendered; their text is data, not template.
+	richText map[*etree.Element]bool
+}
 
-	// Remove original start marker, template block elements, and end marker
-	body.RemoveChild(bodyItems[startIdx]) // {{#key}} paragraph
-	for _, tmplEl := range templateBlock {
-		body.RemoveChild(tmplEl)
-	}
-	body.RemoveChild(bodyItems[endIdx]) // {{/key}} paragraph
-
-	// Process elements after the loop with root data.
-	// Elements before the loop were already processed above.
-	// Cloned elements were processed with item data during insertion.
-	// Re-running processParagraph/processTable on those is safe because
-	// already-replaced text won't match placeholder patterns.
-	for i := endIdx + 1; i < len(bodyItems); i++ {
-		el := bodyItems[i]
-		switch el.Tag {
-		case "p":
-			processParagraph(el, data)
-		case "tbl":
-			processTable(el, data)
-		}
+// fail records err unless an earlier error was recorded.
+func (r *renderer) fail(err error) {
+	if r.err == nil {
+		r.err = err
 	}
 }
 
-// findBodyLoopMarkers scans body child elements for the first {{#key}}/{{/key}} pair.
-// It reads text without modifying the elements (non-destructive scan).
-func findBodyLoopMarkers(elements []*etree.Element) (startIdx, endIdx int, key string) {
-	startIdx = -1
-	endIdx = -1
-
-	for i, el := range elements {
-		if el.Tag != "p" {
-			continue
-		}
-		text := paragraphText(el)
-		trimmed := strings.TrimSpace(text)
-
-		if startIdx == -1 {
-			if m := loopStartRegex.FindStringSubmatch(trimmed); len(m) > 0 && m[0] == trimmed {
-				startIdx = i
-				key = strings.TrimSpace(m[1])
-			}
-		} else {
-			if m := loopEndRegex.FindStringSubmatch(trimmed); len(m) > 0 && m[0] == trimmed {
-				endIdx = i
-				return
-			}
+// missingValue returns the text to write for tag, whose path could not be
+// rendered because of err, under the missing-value policy.
+func (r *renderer) missingValue(tag, path string, err error) string {
+	switch r.opts.Missing {
+	case MissingBlank:
+		return ""
+	case MissingError:
+		if r.missing == nil {
+			r.missing = &missingValues{}
 		}
+		r.missing.add(qualifyPath(r.scope, path), err)
 	}
-	return -1, -1, ""
+	return tag
 }
 
 // paragraphText concatenates all text content in a paragraph for marker detection,
-// without modifying the element.
+// without modifying the element. Text boxes anchored in the paragraph are not included.
 func paragraphText(p *etree.Element) string {
 	var sb strings.Builder
-	for _, t := range p.FindElements(".//t") {
+	for _, t := range textNodes(p) {
 		sb.WriteString(t.Text())
 	}
 	return sb.String()
 }
 
-// resolveLoopData extracts and normalizes the array data for a loop key.
-func resolveLoopData(data map[string]any, key string) []any {
-	raw, ok := getPathValue(data, key)
-	if !ok {
-		return nil
+// textNodes returns the w:t elements of a paragraph in document order,
+// leaving out those of the paragraphs nested in it: the content of text
+// boxes (w:txbxContent), which is rendered like a body of its own.
+func textNodes(p *etree.Element) []*etree.Element {
+	var nodes []*etree.Element
+	var walk func(el *etree.Element)
+	walk = func(el *etree.Element) {
+		for _, child := range el.ChildElements() {
+			switch child.Tag {
+			case "t":
+				nodes = append(nodes, child)
+			case "p":
+				// A paragraph of a text box.
+			default:
+				walk(child)
+			}
+		}
 	}
+	walk(p)
+	return nodes
+}
 
-	if ld, isSlice := raw.([]any); isSlice {
-		return ld
+// textBoxes returns the content of the text boxes anchored in a paragraph,
+// not counting text boxes nested in those.
+func textBoxes(p *etree.Element) []*etree.Element {
+	var boxes []*etree.Element
+	for _, box := range p.FindElements(".//txbxContent") {
+		if owningParagraph(box) == p {
+			boxes = append(boxes, box)
+		}
 	}
-	if mapSlice, isMapSlice := raw.([]map[string]any); isMapSlice {
-		result := make([]any, len(mapSlice))
-		for i, v := range mapSlice {
-			result[i] = v
+	return boxes
+}
+
+// owningParagraph returns the innermost paragraph containing el.
+func owningParagraph(el *etree.Element) *etree.Element {
+	for owner := el.Parent(); owner != nil; owner = owner.Parent() {
+		if owner.Tag == "p" {
+			return owner
 		}
-		return result
 	}
 	return nil
 }
@@ -1,61 +1,138 @@
 package pdfconv
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 )
 
+// Converter converts DOCX bytes to PDF bytes. Implementations must be safe
+// for concurrent use.
+type Converter interface {
+	Convert(ctx context.Context, docx []byte) ([]byte, error)
+}
+
+// ConverterFunc adapts a function to the Converter interface.
+type ConverterFunc func(ctx context.Context, docx []byte) ([]byte, error)
+
+// Convert calls f(ctx, docx).
+func (f ConverterFunc) Convert(ctx context.Context, docx []byte) ([]byte, error) {
+	return f(ctx, docx)
+}
+
+// ErrUnavailable is returned when LibreOffice is not installed.
+var ErrUnavailable = errors.New("pdfconv: LibreOffice is not installed (see https://www.libreoffice.org/download/)")
+
+// DefaultTimeout bounds a conversion by ConvertDocxToPDF, and by a Pool
+// without PoolConfig.Timeout.
+const DefaultTimeout = 2 * time.Minute
+
+// Convert converts DOCX bytes to PDF bytes with a LibreOffice process
+// started for this conversion, using a fresh user profile so concurrent
+// calls do not collide. It stops when ctx is done. Starting LibreOffice
+// takes seconds; use a Pool for steady traffic.
+//
+// It returns ErrUnavailable if LibreOffice is not installed.
+func Convert(ctx context.Context, docx []byte) ([]byte, error) {
+	binary, err := findLibreOffice()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "pdfconv-*")
+	if err != nil {
+		return nil, fmt.Errorf("pdfconv: creating temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	return convertWith(ctx, binary, filepath.Join(tmpDir, "profile"), tmpDir, docx)
+}
+
 // ConvertDocxToPDF converts DOCX bytes to PDF bytes using LibreOffice headless.
 // It auto-detects the OS to find the LibreOffice binary.
 // If LibreOffice is not installed, it returns the original DOCX bytes with a false flag.
+//
+// Deprecated: use Convert, which takes a context, or a Pool.
 func ConvertDocxToPDF(docxBytes []byte) (pdfBytes []byte, ok bool, err error) {
-	binary, err := findLibreOffice()
-	if err != nil {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	pdfBytes, err = Convert(ctx, docxBytes)
+	if errors.Is(err, ErrUnavailable) {
 		log.Printf("pdfconv: LibreOffice not found, falling back to DOCX: %v", err)
 		return docxBytes, false, nil
 	}
-
-	// Create temp directory for the conversion
-	tmpDir, err := os.MkdirTemp("", "pdfconv-*")
 	if err != nil {
-		return nil, false, fmt.Errorf("pdfconv: creating temp dir: %w", err)
+		return nil, false, err
 	}
-	defer os.RemoveAll(tmpDir)
+	return pdfBytes, true, nil
+}
 
-	// Write DOCX to temp file
-	docxPath := filepath.Join(tmpDir, "input.docx")
-	if err := os.WriteFile(docxPath, docxBytes, 0644); err != nil {
-		return nil, false, fmt.Errorf("pdfconv: writing temp docx: %w", err)
+// convertWith runs one conversion with binary. profile is the LibreOffice
+// user installation to use: if an instance with that profile is running,
+// LibreOffice hands the conversion to it instead of starting up. dir holds
+// the input and output files.
+func convertWith(ctx context.Context, binary, profile, dir string, docx []byte) ([]byte, error) {
+	docxPath := filepath.Join(dir, "input.docx")
+	pdfPath := filepath.Join(dir, "input.pdf")
+	if err := os.WriteFile(docxPath, docx, 0644); err != nil {
+		return nil, fmt.Errorf("pdfconv: writing temp docx: %w", err)
 	}
+	defer os.Remove(docxPath)
+	defer os.Remove(pdfPath)
 
-	// Run LibreOffice headless conversion
-	cmd := exec.Command(binary,
+	cmd := exec.CommandContext(ctx, binary,
+		"-env:UserInstallation="+fileURL(profile),
 		"--headless",
 		"--norestore",
+		"--nologo",
 		"--convert-to", "pdf",
-		"--outdir", tmpDir,
+		"--outdir", dir,
 		docxPath,
 	)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	killGroupOnCancel(cmd)
+	cmd.WaitDelay = 5 * time.Second
 
 	if err := cmd.Run(); err != nil {
-		return nil, false, fmt.Errorf("pdfconv: libreoffice conversion failed: %w", err)
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("pdfconv: conversion stopped: %w", context.Cause(ctx))
+		}
+		return nil, fmt.Errorf("pdfconv: libreoffice conversion failed: %w: %s", err, strings.TrimSpace(output.String()))
 	}
 
-	// Read the resulting PDF
-	pdfPath := filepath.Join(tmpDir, "input.pdf")
-	pdfBytes, err = os.ReadFile(pdfPath)
+	pdfBytes, err := os.ReadFile(pdfPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("pdfconv: LibreOffice produced no PDF: %s", strings.TrimSpace(output.String()))
+	}
 	if err != nil {
-		return nil, false, fmt.Errorf("pdfconv: reading converted PDF: %w", err)
+		return nil, fmt.Errorf("pdfconv: reading converted PDF: %w", err)
 	}
+	return pdfBytes, nil
+}
 
-	return pdfBytes, true, nil
+// fileURL returns the file: URL LibreOffice expects for a directory.
+func fileURL(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path // C:/Users/... on Windows
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
 }
 
 // findLibreOffice locates the LibreOffice binary based on the OS.
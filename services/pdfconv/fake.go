@@ -0,0 +1,78 @@
+package pdfconv
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Fake is a Converter for tests that need no LibreOffice. It records the
+// documents it is given and returns PDF, or a blank one-page PDF when PDF
+// is nil. The zero value is ready to use.
+type Fake struct {
+	// PDF is returned by every conversion.
+	PDF []byte
+	// Err, if set, is returned instead.
+	Err error
+	// Delay is waited before returning, or until ctx is done.
+	Delay time.Duration
+
+	mu    sync.Mutex
+	calls [][]byte
+}
+
+// Convert records docx and returns f.PDF or f.Err.
+func (f *Fake) Convert(ctx context.Context, docx []byte) ([]byte, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, docx)
+	f.mu.Unlock()
+
+	if f.Delay > 0 {
+		timer := time.NewTimer(f.Delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("pdfconv: conversion stopped: %w", ctx.Err())
+		}
+	}
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	if f.PDF != nil {
+		return f.PDF, nil
+	}
+	return blankPDF(), nil
+}
+
+// Calls returns the documents converted so far, in order.
+func (f *Fake) Calls() [][]byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][]byte(nil), f.calls...)
+}
+
+// blankPDF returns a valid PDF with one empty A4 page.
+func blankPDF() []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595 842] >>",
+	}
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
@@ -0,0 +1,229 @@
+package pdfconv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned by Pool.Convert after Close has begun.
+var ErrClosed = errors.New("pdfconv: pool is closed")
+
+// Pool is a Converter that keeps LibreOffice instances running, so a
+// conversion does not pay the seconds LibreOffice takes to start.
+//
+// Each instance has its own user profile and converts one document at a
+// time; a conversion is handed to an idle instance, and requests beyond
+// PoolConfig.Size wait for one. An instance that exits, or whose conversion
+// times out, is restarted before its next conversion.
+type Pool struct {
+	cfg  PoolConfig
+	dir  string
+	idle chan *worker
+	done chan struct{}
+	size int // workers created, all returned to idle by Close
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// PoolConfig configures a Pool. Zero values fall back to the defaults noted
+// on each field.
+type PoolConfig struct {
+	// Size is the number of LibreOffice instances, and so of conversions
+	// running at once. Default 2.
+	Size int
+
+	// Timeout bounds one conversion, including the wait for an idle
+	// instance. Default DefaultTimeout.
+	Timeout time.Duration
+
+	// MaxConversions restarts an instance after this many conversions,
+	// releasing the memory LibreOffice accumulates. Default 200.
+	MaxConversions int
+
+	// Binary is the soffice executable. Default: found in PATH or the
+	// standard install locations.
+	Binary string
+
+	// Dir is where the pool creates its directory of profiles and scratch
+	// files, removed by Close. Default os.TempDir().
+	Dir string
+}
+
+// worker owns one LibreOffice instance. It is used by one conversion at a
+// time: whoever takes it from Pool.idle.
+type worker struct {
+	binary  string
+	profile string // LibreOffice user installation of the instance
+	scratch string // input and output files of the current conversion
+
+	instance    *exec.Cmd
+	exited      chan struct{} // closed when instance exits
+	conversions int
+}
+
+// NewPool starts cfg.Size LibreOffice instances. It returns ErrUnavailable
+// if LibreOffice is not installed. Call Close to stop them.
+func NewPool(cfg PoolConfig) (*Pool, error) {
+	if cfg.Size <= 0 {
+		cfg.Size = 2
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.MaxConversions <= 0 {
+		cfg.MaxConversions = 200
+	}
+	if cfg.Binary == "" {
+		binary, err := findLibreOffice()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+		}
+		cfg.Binary = binary
+	}
+
+	dir, err := os.MkdirTemp(cfg.Dir, "pdfconv-pool-*")
+	if err != nil {
+		return nil, fmt.Errorf("pdfconv: creating pool dir: %w", err)
+	}
+	p := &Pool{
+		cfg:  cfg,
+		dir:  dir,
+		idle: make(chan *worker, cfg.Size),
+		done: make(chan struct{}),
+	}
+	for i := range cfg.Size {
+		w := &worker{
+			binary:  cfg.Binary,
+			profile: filepath.Join(dir, fmt.Sprintf("profile-%d", i+1)),
+			scratch: filepath.Join(dir, fmt.Sprintf("work-%d", i+1)),
+		}
+		p.idle <- w
+		p.size++
+		if err := os.Mkdir(w.scratch, 0755); err != nil {
+			p.Close()
+			return nil, fmt.Errorf("pdfconv: creating pool dir: %w", err)
+		}
+		if err := w.start(); err != nil {
+			p.Close()
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Convert converts DOCX bytes to PDF bytes on an idle instance, waiting
+// for one if all are busy. It gives up when ctx is done or
+// PoolConfig.Timeout has passed, and returns ErrClosed once Close has begun.
+func (p *Pool) Convert(ctx context.Context, docx []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.Timeout)
+	defer cancel()
+
+	var w *worker
+	select {
+	case w = <-p.idle:
+	case <-p.done:
+		return nil, ErrClosed
+	case <-ctx.Done():
+		return nil, fmt.Errorf("pdfconv: waiting for a LibreOffice instance: %w", ctx.Err())
+	}
+	defer func() { p.idle <- w }()
+
+	select {
+	case <-p.done:
+		return nil, ErrClosed
+	default:
+	}
+	return w.convert(ctx, docx, p.cfg.MaxConversions)
+}
+
+// Close stops accepting conversions, waits for running ones to finish,
+// stops the instances and removes their profiles.
+func (p *Pool) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.done)
+		for range p.size {
+			w := <-p.idle
+			w.stop()
+		}
+		p.closeErr = os.RemoveAll(p.dir)
+	})
+	return p.closeErr
+}
+
+// start launches the instance: headless, without documents, waiting for
+// conversions handed over by launchers that use the same profile.
+func (w *worker) start() error {
+	// A killed instance leaves its profile locked.
+	os.Remove(filepath.Join(w.profile, ".lock"))
+
+	cmd := exec.Command(w.binary,
+		"-env:UserInstallation="+fileURL(w.profile),
+		"--headless",
+		"--invisible",
+		"--nocrashreport",
+		"--nodefault",
+		"--nologo",
+		"--nofirststartwizard",
+		"--norestore",
+	)
+	newProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("pdfconv: starting LibreOffice: %w", err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+	w.instance, w.exited, w.conversions = cmd, exited, 0
+	return nil
+}
+
+// running reports whether the instance is still alive.
+func (w *worker) running() bool {
+	if w.instance == nil {
+		return false
+	}
+	select {
+	case <-w.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// stop kills the instance and waits for it to exit.
+func (w *worker) stop() {
+	if w.instance == nil {
+		return
+	}
+	if w.running() {
+		killGroup(w.instance)
+	}
+	<-w.exited
+	w.instance = nil
+}
+
+func (w *worker) convert(ctx context.Context, docx []byte, maxConversions int) ([]byte, error) {
+	if !w.running() || w.conversions >= maxConversions {
+		w.stop()
+		if err := w.start(); err != nil {
+			return nil, err
+		}
+	}
+	w.conversions++
+
+	pdf, err := convertWith(ctx, w.binary, w.profile, w.scratch, docx)
+	if err != nil && ctx.Err() != nil {
+		// The instance may still be busy with the document.
+		w.stop()
+	}
+	return pdf, err
+}
@@ -0,0 +1,283 @@
+package pdfconv
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSoffice writes a stand-in for soffice and returns its path and the
+// directory it logs to. Without --convert-to it idles like an instance,
+// logging its profile to "instances"; with it, it logs the profile to
+// "conversions" and "copies" the input to the PDF. An input containing
+// "slow" hangs, one containing "broken" fails. While a conversion runs, it
+// appends the number of conversions running to "concurrency".
+func fakeSoffice(t *testing.T) (binary, logDir string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake soffice is a shell script")
+	}
+	dir := t.TempDir()
+	logDir = filepath.Join(dir, "log")
+	if err := os.Mkdir(logDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	script := `#!/bin/sh
+log=` + logDir + `
+profile= ; outdir= ; input= ; convert=
+while [ $# -gt 0 ]; do
+	case "$1" in
+	-env:UserInstallation=*) profile="${1#*=}" ;;
+	--convert-to) convert=1; shift ;;
+	--outdir) outdir="$2"; shift ;;
+	-*) ;;
+	*) input="$1" ;;
+	esac
+	shift
+done
+if [ -z "$convert" ]; then
+	echo "$profile" >> "$log/instances"
+	exec sleep 600
+fi
+echo "$profile" >> "$log/conversions"
+touch "$log/running.$$"
+ls "$log" | grep -c '^running\.' >> "$log/concurrency"
+if grep -q slow "$input"; then exec sleep 600; fi
+sleep 0.1
+rm "$log/running.$$"
+if grep -q broken "$input"; then echo "Error: source file could not be loaded"; exit 1; fi
+name=$(basename "$input" .docx)
+cp "$input" "$outdir/$name.pdf"
+`
+	binary = filepath.Join(dir, "soffice")
+	if err := os.WriteFile(binary, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return binary, logDir
+}
+
+// logLines returns the lines of a fake soffice log, or nil.
+func logLines(t *testing.T, logDir, name string) []string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(logDir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Fields(string(data))
+}
+
+// waitForLines waits until a fake soffice log has n lines.
+func waitForLines(t *testing.T, logDir, name string, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		lines := logLines(t, logDir, name)
+		if len(lines) >= n || time.Now().After(deadline) {
+			return lines
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func newTestPool(t *testing.T, cfg PoolConfig) (*Pool, string) {
+	t.Helper()
+	binary, logDir := fakeSoffice(t)
+	cfg.Binary = binary
+	cfg.Dir = t.TempDir()
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p, logDir
+}
+
+func TestPool_Convert(t *testing.T) {
+	p, logDir := newTestPool(t, PoolConfig{Size: 2})
+
+	instances := waitForLines(t, logDir, "instances", 2)
+	if len(instances) != 2 || instances[0] == instances[1] {
+		t.Fatalf("instances started with profiles %q, want 2 different ones", instances)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 6)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			docx := []byte(fmt.Sprintf("document %d", i))
+			pdf, err := p.Convert(context.Background(), docx)
+			if err == nil && !bytes.Equal(pdf, docx) {
+				err = fmt.Errorf("got %q", pdf)
+			}
+			errs[i] = err
+		}()
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("conversion %d: %v", i, err)
+		}
+	}
+
+	// Conversions ran on the instances' profiles, two at a time at most.
+	for _, profile := range logLines(t, logDir, "conversions") {
+		if !slices.Contains(instances, profile) {
+			t.Errorf("conversion used profile %s, not an instance's", profile)
+		}
+	}
+	if running := logLines(t, logDir, "concurrency"); slices.Max(running) > "2" {
+		t.Errorf("conversions running at once: %q, want at most 2", running)
+	}
+	if n := len(logLines(t, logDir, "instances")); n != 2 {
+		t.Errorf("%d instances started, want 2", n)
+	}
+}
+
+func TestPool_Timeout(t *testing.T) {
+	p, logDir := newTestPool(t, PoolConfig{Size: 1, Timeout: 300 * time.Millisecond})
+
+	start := time.Now()
+	_, err := p.Convert(context.Background(), []byte("slow"))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("slow conversion: err = %v, want deadline exceeded", err)
+	}
+	if d := time.Since(start); d > 3*time.Second {
+		t.Errorf("slow conversion returned after %v", d)
+	}
+
+	// The stuck instance is replaced and the next conversion works.
+	if pdf, err := p.Convert(context.Background(), []byte("quick")); err != nil || string(pdf) != "quick" {
+		t.Fatalf("conversion after timeout = %q, %v", pdf, err)
+	}
+	if n := len(waitForLines(t, logDir, "instances", 2)); n != 2 {
+		t.Errorf("%d instances started, want a restart", n)
+	}
+}
+
+func TestPool_WaitsForInstance(t *testing.T) {
+	p, _ := newTestPool(t, PoolConfig{Size: 1, Timeout: 2 * time.Second})
+
+	busy := make(chan error)
+	go func() {
+		_, err := p.Convert(context.Background(), []byte("slow"))
+		busy <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := p.Convert(ctx, []byte("waiting"))
+	if err == nil || !strings.Contains(err.Error(), "waiting for a LibreOffice instance") {
+		t.Errorf("err = %v, want a timeout waiting for the busy instance", err)
+	}
+	<-busy
+}
+
+func TestPool_Failure(t *testing.T) {
+	p, _ := newTestPool(t, PoolConfig{Size: 1})
+
+	_, err := p.Convert(context.Background(), []byte("broken"))
+	if err == nil || !strings.Contains(err.Error(), "source file could not be loaded") {
+		t.Fatalf("err = %v, want LibreOffice's message", err)
+	}
+	if _, err := p.Convert(context.Background(), []byte("fine")); err != nil {
+		t.Errorf("conversion after a failure: %v", err)
+	}
+}
+
+func TestPool_MaxConversions(t *testing.T) {
+	p, logDir := newTestPool(t, PoolConfig{Size: 1, MaxConversions: 2})
+
+	for i := range 5 {
+		if _, err := p.Convert(context.Background(), []byte{byte('a' + i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Started once, then restarted before conversions 3 and 5.
+	if n := len(waitForLines(t, logDir, "instances", 3)); n != 3 {
+		t.Errorf("%d instances started, want 3", n)
+	}
+}
+
+func TestPool_Close(t *testing.T) {
+	binary, _ := fakeSoffice(t)
+	dir := t.TempDir()
+	p, err := NewPool(PoolConfig{Size: 2, Binary: binary, Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := p.Convert(context.Background(), []byte("late")); !errors.Is(err, ErrClosed) {
+		t.Errorf("Convert after Close: err = %v, want ErrClosed", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Close left %d entries in the pool dir", len(entries))
+	}
+	if err := p.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+}
+
+func TestNewPool_MissingBinary(t *testing.T) {
+	_, err := NewPool(PoolConfig{Binary: filepath.Join(t.TempDir(), "soffice"), Dir: t.TempDir()})
+	if err == nil {
+		t.Fatal("NewPool succeeded without a binary")
+	}
+}
+
+func TestFake(t *testing.T) {
+	var f Fake
+	pdf, err := f.Convert(context.Background(), []byte("one"))
+	if err != nil || !bytes.HasPrefix(pdf, []byte("%PDF-")) || !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+		t.Fatalf("Convert = %q, %v", pdf, err)
+	}
+	f.Err = errors.New("boom")
+	if _, err := f.Convert(context.Background(), []byte("two")); err != f.Err {
+		t.Errorf("err = %v, want %v", err, f.Err)
+	}
+	f.Err, f.Delay = nil, time.Minute
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := f.Convert(ctx, []byte("three")); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want canceled", err)
+	}
+	if calls := f.Calls(); len(calls) != 3 || string(calls[2]) != "three" {
+		t.Errorf("calls = %q", calls)
+	}
+}
+
+func TestBlankPDF_XrefOffsets(t *testing.T) {
+	pdf := blankPDF()
+	for i := 1; i <= 3; i++ {
+		obj := fmt.Sprintf("%d 0 obj", i)
+		entry := fmt.Sprintf("%010d 00000 n ", bytes.Index(pdf, []byte(obj)))
+		if !bytes.Contains(pdf, []byte(entry)) {
+			t.Errorf("xref has no entry %q for object %d", entry, i)
+		}
+	}
+}
+
+func TestFileURL(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix paths")
+	}
+	if got := fileURL("/tmp/pdf conv/profile-1"); got != "file:///tmp/pdf%20conv/profile-1" {
+		t.Errorf("fileURL = %s", got)
+	}
+}
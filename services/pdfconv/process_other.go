@@ -0,0 +1,17 @@
+//go:build !unix
+
+package pdfconv
+
+import "os/exec"
+
+// newProcessGroup does nothing: killGroup kills the process only.
+func newProcessGroup(cmd *exec.Cmd) {}
+
+// killGroupOnCancel leaves cancellation to exec.CommandContext, which
+// kills the launcher process only.
+func killGroupOnCancel(cmd *exec.Cmd) {}
+
+// killGroup kills the process of cmd.
+func killGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
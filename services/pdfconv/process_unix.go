@@ -0,0 +1,28 @@
+//go:build unix
+
+package pdfconv
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// newProcessGroup runs cmd in its own process group, so killGroup reaches
+// soffice.bin too: the soffice launcher is a script, and killing it alone
+// would leave LibreOffice running.
+func newProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killGroupOnCancel runs cmd, created with exec.CommandContext, in its own
+// process group and makes cancellation kill the whole group.
+func killGroupOnCancel(cmd *exec.Cmd) {
+	newProcessGroup(cmd)
+	cmd.Cancel = func() error { return killGroup(cmd) }
+}
+
+// killGroup kills the process group of a command started with
+// newProcessGroup.
+func killGroup(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,117 @@
+package pdfreport
+
+import "strings"
+
+// The reports use the standard Helvetica fonts, which every PDF reader
+// has, so no font is embedded. Text is written in WinAnsiEncoding; the
+// peso sign, which it lacks, is drawn as a P with two bars (see pesoSign).
+
+// font is a standard Type 1 font and its glyph widths, in thousandths of
+// the font size, indexed by WinAnsi code.
+type font struct {
+	resource string // name in the page resources, e.g. "F1"
+	base     string // PostScript name
+	widths   [256]int
+}
+
+var (
+	regular = newFont("F1", "Helvetica", helveticaWidths, helveticaExtra)
+	bold    = newFont("F2", "Helvetica-Bold", helveticaBoldWidths, helveticaBoldExtra)
+	italic  = newFont("F3", "Helvetica-Oblique", helveticaWidths, helveticaExtra)
+)
+
+var fonts = []*font{regular, bold, italic}
+
+// Widths of the printable ASCII characters, 32 (space) to 126 (~), from
+// the Adobe font metrics.
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278, // space to /
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556, // 0 to ?
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778, // @ to O
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556, // P to _
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556, // ` to o
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584, // p to ~
+}
+
+var helveticaBoldWidths = [95]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
+
+// Widths of the WinAnsi characters above ASCII that reports use; accented
+// letters take the width of their base letter (see accentBase).
+var helveticaExtra = map[byte]int{
+	0x80: 556, 0x85: 1000, 0x91: 222, 0x92: 222, 0x93: 333, 0x94: 333, 0x95: 350, 0x96: 556, 0x97: 1000,
+	0xA0: 278, 0xA9: 737, 0xAE: 737, 0xB0: 400, 0xB1: 584, 0xB7: 278, 0xC6: 1000, 0xD7: 584, 0xDF: 611, 0xE6: 889, 0xF7: 584,
+}
+
+var helveticaBoldExtra = map[byte]int{
+	0x80: 556, 0x85: 1000, 0x91: 278, 0x92: 278, 0x93: 500, 0x94: 500, 0x95: 350, 0x96: 556, 0x97: 1000,
+	0xA0: 278, 0xA9: 737, 0xAE: 737, 0xB0: 400, 0xB1: 584, 0xB7: 278, 0xC6: 1000, 0xD7: 584, 0xDF: 611, 0xE6: 889, 0xF7: 611,
+}
+
+// accentBase gives the base letter of the Latin-1 letters 0xC0 to 0xFF;
+// '-' marks the others, whose widths are listed explicitly.
+const accentBase = "AAAAAA-CEEEEIIIIDNOOOOO-OUUUUYP-aaaaaa-ceeeeiiiidnooooo-ouuuuypy"
+
+// winAnsiSpecial maps the characters of WinAnsiEncoding 0x80 to 0x9F that
+// reports use.
+var winAnsiSpecial = map[rune]byte{
+	'€': 0x80, '…': 0x85, '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+}
+
+func newFont(resource, base string, ascii [95]int, extra map[byte]int) *font {
+	f := &font{resource: resource, base: base}
+	for i, w := range ascii {
+		f.widths[32+i] = w
+	}
+	for c := 0xA1; c <= 0xFF; c++ {
+		f.widths[c] = 556
+	}
+	for i, b := range accentBase {
+		if b != '-' {
+			f.widths[0xC0+i] = f.widths[b]
+		}
+	}
+	for c, w := range extra {
+		f.widths[c] = w
+	}
+	return f
+}
+
+// symbols spells out the symbols missing from WinAnsiEncoding that
+// reports use.
+var symbols = strings.NewReplacer("≠", "<>", "≤", "<=", "≥", ">=", "−", "-")
+
+// pesoSign is the peso sign (₱), missing from WinAnsiEncoding.
+const pesoSign = '₱'
+
+// encode returns the WinAnsi code of r; characters the encoding lacks
+// become '?'. The peso sign encodes as P, over which the bars are drawn.
+func encode(r rune) byte {
+	switch {
+	case r == pesoSign:
+		return 'P'
+	case r >= 32 && r < 127, r >= 0xA0 && r <= 0xFF:
+		return byte(r)
+	case r == '\t':
+		return ' '
+	}
+	if b, ok := winAnsiSpecial[r]; ok {
+		return b
+	}
+	return '?'
+}
+
+// width returns the width of s in points at the given size.
+func (f *font) width(s string, size float64) float64 {
+	total := 0
+	for _, r := range symbols.Replace(s) {
+		total += f.widths[encode(r)]
+	}
+	return float64(total) * size / 1000
+}
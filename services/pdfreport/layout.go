@@ -0,0 +1,315 @@
+package pdfreport
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Layout measurements, in points.
+const (
+	margin     = 40
+	footerLine = 24 // baseline of the footer
+	bottom     = margin + 14
+	cellPad    = 4
+	indentStep = 12
+
+	bodySize   = 9
+	headerSize = 8.5
+	noteSize   = 8.5
+
+	tableHeaderHeight = 20
+	signatureGap      = 28
+	signatureHeight   = 70
+	signaturesPerLine = 3
+)
+
+// rowHeights are the heights of the row styles.
+var rowHeights = map[Style]float64{
+	Line:     13,
+	Heading:  18,
+	Subtotal: 15,
+	Total:    19,
+	Note:     13,
+	Blank:    6,
+}
+
+// layout places a report on pages, top to bottom.
+type layout struct {
+	r      *Report
+	labels Labels
+
+	width, height float64
+	left, right   float64 // edges of the table
+	colX, colW    []float64
+
+	pages      []*page
+	page       *page
+	y          float64 // top of the space left on the page
+	rowsOnPage int
+	inTable    bool // new pages repeat the column headers
+}
+
+func newLayout(r *Report) *layout {
+	size := r.Size
+	if size.Width <= 0 || size.Height <= 0 {
+		size = A4
+	}
+	if r.Landscape {
+		size.Width, size.Height = size.Height, size.Width
+	}
+	l := &layout{
+		r:      r,
+		labels: r.Labels.withDefaults(),
+		width:  size.Width,
+		height: size.Height,
+		left:   margin,
+		right:  size.Width - margin,
+	}
+
+	total := 0.0
+	for _, c := range r.Columns {
+		total += columnWidth(c)
+	}
+	x := l.left
+	for _, c := range r.Columns {
+		w := (l.right - l.left) * columnWidth(c) / total
+		l.colX = append(l.colX, x)
+		l.colW = append(l.colW, w)
+		x += w
+	}
+	return l
+}
+
+func columnWidth(c Column) float64 {
+	if c.Width <= 0 {
+		return 1
+	}
+	return c.Width
+}
+
+func (l *layout) render() {
+	l.inTable = true
+	l.newPage()
+	for i, row := range l.r.Rows {
+		if row.Style == Blank && l.rowsOnPage == 0 {
+			continue
+		}
+		need := rowHeights[row.Style]
+		if row.Style == Heading && i+1 < len(l.r.Rows) {
+			need += rowHeights[l.r.Rows[i+1].Style]
+		}
+		if l.y-need < bottom && l.rowsOnPage > 0 {
+			l.newPage()
+		}
+		l.row(row)
+	}
+	l.inTable = false
+
+	l.notes()
+	l.signatures()
+	l.footers()
+}
+
+// newPage starts a page with the letterhead, the title and, within the
+// table, the column headers.
+func (l *layout) newPage() {
+	l.page = &page{}
+	l.pages = append(l.pages, l.page)
+	l.rowsOnPage = 0
+
+	y := l.height - margin
+	if name := l.r.Letterhead.Name; name != "" {
+		y -= 12
+		l.centered(y, bold, 14, name)
+	}
+	for _, line := range l.r.Letterhead.Lines {
+		y -= 11
+		l.centered(y, regular, 8.5, line)
+	}
+	if l.r.Title != "" {
+		y -= 22
+		l.centered(y, bold, 12, l.r.Title)
+	}
+	if l.r.Subtitle != "" {
+		y -= 13
+		l.centered(y, regular, 9.5, l.r.Subtitle)
+	}
+	l.y = y - 14
+
+	if l.inTable {
+		l.tableHeader()
+	}
+}
+
+func (l *layout) centered(y float64, f *font, size float64, s string) {
+	s = fit(f, size, s, l.right-l.left)
+	l.page.text((l.width-f.width(s, size))/2, y, f, size, s)
+}
+
+// tableHeader draws the column headers between two rules.
+func (l *layout) tableHeader() {
+	top := l.y
+	l.page.line(l.left, top, l.right, top, 0.8)
+	for i, c := range l.r.Columns {
+		l.cell(i, c.Align, top-12.5, bold, headerSize, c.Header, 0)
+	}
+	l.page.line(l.left, top-17, l.right, top-17, 0.5)
+	l.y -= tableHeaderHeight
+}
+
+// cell draws s in column i, fitted to its width.
+func (l *layout) cell(i int, align Align, y float64, f *font, size float64, s string, indent float64) {
+	x0 := l.colX[i] + cellPad + indent
+	x1 := l.colX[i] + l.colW[i] - cellPad
+	s = fit(f, size, s, x1-x0)
+	w := f.width(s, size)
+	switch align {
+	case Right:
+		x0 = x1 - w
+	case Center:
+		x0 += (x1 - x0 - w) / 2
+	}
+	l.page.text(x0, y, f, size, s)
+}
+
+func (l *layout) row(row Row) {
+	h := rowHeights[row.Style]
+	top := l.y
+	base := top - h + 4
+	if row.Style == Total {
+		base += 2
+	}
+	indent := float64(row.Indent * indentStep)
+
+	switch row.Style {
+	case Heading, Note:
+		if len(row.Cells) > 0 {
+			f := bold
+			if row.Style == Note {
+				f = italic
+			}
+			x := l.left + cellPad + indent
+			l.page.text(x, base, f, bodySize, fit(f, bodySize, row.Cells[0], l.right-cellPad-x))
+		}
+	case Line, Subtotal, Total:
+		f := regular
+		if row.Style != Line {
+			f = bold
+		}
+		for i, c := range l.r.Columns {
+			if i >= len(row.Cells) || row.Cells[i] == "" {
+				continue
+			}
+			in := 0.0
+			if i == l.r.IndentColumn {
+				in = indent
+			}
+			l.cell(i, c.Align, base, f, bodySize, row.Cells[i], in)
+			if row.Style == Line || c.Align != Right {
+				continue
+			}
+			// Rules cover the right two thirds of the column, whatever
+			// the width of the amount.
+			x0, x1 := l.colX[i]+cellPad+l.colW[i]/3, l.colX[i]+l.colW[i]-cellPad
+			l.page.line(x0, base+bodySize+1.5, x1, base+bodySize+1.5, 0.5)
+			if row.Style == Total {
+				l.page.line(x0, base-3, x1, base-3, 0.5)
+				l.page.line(x0, base-4.8, x1, base-4.8, 0.5)
+			}
+		}
+	}
+	l.y -= h
+	l.rowsOnPage++
+}
+
+// notes prints the report notes after the table, wrapped to its width.
+func (l *layout) notes() {
+	if len(l.r.Notes) == 0 {
+		return
+	}
+	l.y -= 8
+	for _, note := range l.r.Notes {
+		for _, line := range wrap(italic, noteSize, note, l.right-l.left) {
+			if l.y-11 < bottom {
+				l.newPage()
+			}
+			l.page.text(l.left, l.y-9, italic, noteSize, line)
+			l.y -= 11
+			l.rowsOnPage++
+		}
+	}
+}
+
+// signatures prints the signature block, on a new page if it does not fit
+// on the current one.
+func (l *layout) signatures() {
+	sigs := l.r.Signatures
+	if len(sigs) == 0 {
+		return
+	}
+	perLine := min(signaturesPerLine, len(sigs))
+	lines := (len(sigs) + perLine - 1) / perLine
+	if l.y-signatureGap-float64(lines)*signatureHeight < bottom && l.rowsOnPage > 0 {
+		l.newPage()
+	}
+	l.y -= signatureGap
+
+	blockW := (l.right - l.left) / float64(perLine)
+	for start := 0; start < len(sigs); start += perLine {
+		for j, s := range sigs[start:min(start+perLine, len(sigs))] {
+			x := l.left + float64(j)*blockW
+			w := blockW - 24
+			l.page.text(x, l.y-9, regular, bodySize, fit(regular, bodySize, s.Label, w))
+			l.page.line(x, l.y-40, x+w, l.y-40, 0.5)
+			l.page.text(x, l.y-51, bold, bodySize, fit(bold, bodySize, s.Name, w))
+			l.page.text(x, l.y-62, regular, noteSize, fit(regular, noteSize, s.Title, w))
+		}
+		l.y -= signatureHeight
+	}
+}
+
+// footers numbers the pages, now that their count is known.
+func (l *layout) footers() {
+	for i, p := range l.pages {
+		number := fmt.Sprintf(l.labels.Page, i+1, len(l.pages))
+		p.text(l.right-regular.width(number, 8), footerLine, regular, 8, number)
+		if !l.r.GeneratedAt.IsZero() {
+			p.text(l.left, footerLine, regular, 8, l.labels.Generated+" "+l.r.GeneratedAt.Format(l.labels.DateFormat))
+		}
+	}
+}
+
+// fit shortens s with an ellipsis until it is at most width wide.
+func fit(f *font, size float64, s string, width float64) string {
+	if f.width(s, size) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for n := len(runes) - 1; n > 0; n-- {
+		short := strings.TrimRight(string(runes[:n]), " ") + "…"
+		if f.width(short, size) <= width {
+			return short
+		}
+	}
+	return ""
+}
+
+// wrap breaks s into lines at most width wide, between words.
+func wrap(f *font, size float64, s string, width float64) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		if line != "" && f.width(line+" "+word, size) <= width {
+			line += " " + word
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+		line = fit(f, size, word, width)
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,136 @@
+package pdfreport
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// page is the content stream of one page, in PDF coordinates: points from
+// the bottom-left corner.
+type page struct {
+	content bytes.Buffer
+}
+
+// text draws s with its baseline starting at (x, y).
+func (p *page) text(x, y float64, f *font, size float64, s string) {
+	if s == "" {
+		return
+	}
+	s = symbols.Replace(s)
+	fmt.Fprintf(&p.content, "BT /%s %s Tf %s %s Td (%s) Tj ET\n", f.resource, num(size), num(x), num(y), escape(s))
+
+	// Cross the P of each peso sign with its two bars.
+	if !strings.ContainsRune(s, pesoSign) {
+		return
+	}
+	weight := 0.06
+	if f == bold {
+		weight = 0.09
+	}
+	offset := x
+	for _, r := range s {
+		if r == pesoSign {
+			for _, h := range []float64{0.60, 0.48} {
+				p.line(offset-0.05*size, y+h*size, offset+0.68*size, y+h*size, weight*size)
+			}
+		}
+		offset += float64(f.widths[encode(r)]) * size / 1000
+	}
+}
+
+// line strokes a straight line of the given width.
+func (p *page) line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(&p.content, "%s w %s %s m %s %s l S\n", num(width), num(x1), num(y1), num(x2), num(y2))
+}
+
+// num formats a coordinate with at most two decimals.
+func num(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+// escape encodes s as the body of a PDF string literal in WinAnsiEncoding.
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range symbols.Replace(s) {
+		c := encode(r)
+		switch {
+		case c == '(' || c == ')' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c >= 0x80:
+			fmt.Fprintf(&b, "\\%03o", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// writePDF assembles the pages into a PDF file. The objects are numbered:
+// 1 catalog, 2 page tree, 3 document info, then the fonts, then a page
+// dictionary and its content stream per page.
+func writePDF(pages []*page, width, height float64, title string, created time.Time) ([]byte, error) {
+	firstPage := 4 + len(fonts)
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+
+	info := "<< /Producer (fycha pdfreport)"
+	if title != "" {
+		info += " /Title (" + escape(title) + ")"
+	}
+	if !created.IsZero() {
+		info += " /CreationDate (D:" + created.UTC().Format("20060102150405") + "Z)"
+	}
+	info += " >>"
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		info,
+	}
+	var resources strings.Builder
+	resources.WriteString("<< /Font <<")
+	for i, f := range fonts {
+		objects = append(objects, fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", f.base))
+		fmt.Fprintf(&resources, " /%s %d 0 R", f.resource, 4+i)
+	}
+	resources.WriteString(" >> >>")
+
+	for i, p := range pages {
+		var stream bytes.Buffer
+		zw := zlib.NewWriter(&stream)
+		if _, err := zw.Write(p.content.Bytes()); err != nil {
+			return nil, fmt.Errorf("pdfreport: compressing page %d: %w", i+1, err)
+		}
+		if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("pdfreport: compressing page %d: %w", i+1, err)
+		}
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources %s /Contents %d 0 R >>",
+				num(width), num(height), resources.String(), firstPage+2*i+1),
+			fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", stream.Len(), stream.Bytes()),
+		)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info 3 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes(), nil
+}
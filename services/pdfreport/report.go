@@ -0,0 +1,194 @@
+// Package pdfreport renders tabular reports — financial statements, the
+// trial balance, ledgers — as paginated PDFs, in pure Go. Unlike the DOCX
+// templates, it needs no LibreOffice on the server.
+//
+//	pdf, err := pdfreport.Render(&pdfreport.Report{
+//		Letterhead: pdfreport.Letterhead{Name: "Glow Salon & Spa, Inc.", Lines: []string{"TIN 123-456-789-000"}},
+//		Title:      "Statement of Financial Position",
+//		Subtitle:   "As of March 31, 2026",
+//		Columns:    []pdfreport.Column{{Header: "Account", Width: 3}, {Header: "Amount", Align: pdfreport.Right}},
+//		Rows:       rows,
+//		Signatures: pdfreport.DefaultSignatures(),
+//	})
+//
+// Every page repeats the letterhead, the title and the column headers, and
+// is numbered "Page X of Y"; the signature block is kept on one page.
+package pdfreport
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Report is a document to render: a table under a letterhead, followed by
+// notes and signatures.
+type Report struct {
+	Letterhead Letterhead
+	Title      string
+	Subtitle   string // e.g. the period or "As of" date
+
+	Columns []Column
+	Rows    []Row
+
+	// IndentColumn is the column shifted right by Row.Indent, usually the
+	// account name.
+	IndentColumn int
+
+	// Notes are printed in italics after the table.
+	Notes []string
+
+	// Signatures are printed at the end, three to a line.
+	Signatures []Signature
+
+	// Size is the paper size. Default A4.
+	Size PageSize
+	// Landscape turns the paper sideways, for wide tables.
+	Landscape bool
+
+	// GeneratedAt, if set, is printed in the footer of every page and
+	// recorded as the creation date.
+	GeneratedAt time.Time
+
+	// Labels are the fixed texts of the report. Zero values fall back to
+	// the English defaults noted on each field.
+	Labels Labels
+}
+
+// Letterhead identifies the company at the top of every page.
+type Letterhead struct {
+	Name  string
+	Lines []string // address, TIN, and so on, one per line
+}
+
+// Column is a column of the table.
+type Column struct {
+	Header string
+	// Width is the share of the table width the column takes, relative to
+	// the others. Default 1.
+	Width float64
+	Align Align
+}
+
+// Align is the horizontal alignment of a column.
+type Align int
+
+const (
+	Left Align = iota
+	Right
+	Center
+)
+
+// Row is a row of the table.
+type Row struct {
+	Style Style
+	// Cells are the texts of the row, one per column. A Heading or Note
+	// row prints its first cell across the whole table.
+	Cells []string
+	// Indent shifts the text of Report.IndentColumn right by this many
+	// levels.
+	Indent int
+}
+
+// Style is how a row is drawn.
+type Style int
+
+const (
+	// Line is a regular row.
+	Line Style = iota
+	// Heading is a bold title across the table, kept on the same page as
+	// the row after it.
+	Heading
+	// Subtotal is a bold row with a rule above its right-aligned cells.
+	Subtotal
+	// Total is a bold row with a rule above its right-aligned cells and a
+	// double rule below them.
+	Total
+	// Note is an italic text across the table.
+	Note
+	// Blank is an empty gap between groups of rows.
+	Blank
+)
+
+// Signature is a signatory of the report: "Prepared by:", a line to sign
+// on, then the name and title printed under it.
+type Signature struct {
+	Label string
+	Name  string
+	Title string
+}
+
+// DefaultSignatures returns the usual blank Prepared, Reviewed and
+// Approved by blocks.
+func DefaultSignatures() []Signature {
+	return []Signature{
+		{Label: "Prepared by:"},
+		{Label: "Reviewed by:"},
+		{Label: "Approved by:"},
+	}
+}
+
+// AsOf returns the subtitle of a report at a date given as YYYY-MM-DD:
+// format, such as "As of %s", applied to the date written with layout,
+// such as "January 2, 2006". Empty format and layout take those English
+// defaults; a date that does not parse is used as given.
+func AsOf(format, layout, date string) string {
+	if format == "" {
+		format = "As of %s"
+	}
+	if layout == "" {
+		layout = "January 2, 2006"
+	}
+	if t, err := time.Parse("2006-01-02", date); err == nil {
+		date = t.Format(layout)
+	}
+	return fmt.Sprintf(format, date)
+}
+
+// PageSize is a paper size in points, portrait.
+type PageSize struct {
+	Width, Height float64
+}
+
+// Paper sizes.
+var (
+	A4     = PageSize{595.28, 841.89}
+	Letter = PageSize{612, 792}
+	Legal  = PageSize{612, 1008}
+)
+
+// Labels are the fixed texts of a report.
+type Labels struct {
+	// Page numbers each page, given the page and page count. Default
+	// "Page %d of %d".
+	Page string
+	// Generated precedes Report.GeneratedAt in the footer. Default
+	// "Generated".
+	Generated string
+	// DateFormat formats Report.GeneratedAt. Default
+	// "January 2, 2006 3:04 PM".
+	DateFormat string
+}
+
+func (l Labels) withDefaults() Labels {
+	if l.Page == "" {
+		l.Page = "Page %d of %d"
+	}
+	if l.Generated == "" {
+		l.Generated = "Generated"
+	}
+	if l.DateFormat == "" {
+		l.DateFormat = "January 2, 2006 3:04 PM"
+	}
+	return l
+}
+
+// Render lays out the report and returns it as a PDF.
+func Render(r *Report) ([]byte, error) {
+	if len(r.Columns) == 0 {
+		return nil, errors.New("pdfreport: report has no columns")
+	}
+	l := newLayout(r)
+	l.render()
+	return writePDF(l.pages, l.width, l.height, r.Title, r.GeneratedAt)
+}
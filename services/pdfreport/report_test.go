@@ -0,0 +1,275 @@
+package pdfreport
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pageContents checks the cross-reference table of pdf and returns the
+// decompressed content stream of each page.
+func pageContents(t *testing.T, pdf []byte) []string {
+	t.Helper()
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+		t.Fatalf("not a PDF: %.40q", pdf)
+	}
+	xref := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(pdf, -1)
+	for i, m := range xref {
+		off, _ := strconv.Atoi(string(m[1]))
+		if !bytes.HasPrefix(pdf[off:], []byte(fmt.Sprintf("%d 0 obj\n", i+1))) {
+			t.Fatalf("xref entry %d points at %.20q", i+1, pdf[off:])
+		}
+	}
+
+	var contents []string
+	rest := pdf
+	for {
+		start := bytes.Index(rest, []byte("stream\n"))
+		if start < 0 {
+			break
+		}
+		rest = rest[start+len("stream\n"):]
+		end := bytes.Index(rest, []byte("\nendstream"))
+		zr, err := zlib.NewReader(bytes.NewReader(rest[:end]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents = append(contents, string(data))
+		rest = rest[end+len("\nendstream"):]
+	}
+	if want := fmt.Sprintf("/Count %d >>", len(contents)); !bytes.Contains(pdf, []byte(want)) {
+		t.Fatalf("page tree does not have %q", want)
+	}
+	return contents
+}
+
+func testReport(rows []Row) *Report {
+	return &Report{
+		Letterhead: Letterhead{Name: "Glow Salon & Spa, Inc.", Lines: []string{"TIN 123-456-789-000"}},
+		Title:      "Trial Balance",
+		Subtitle:   "As of March 31, 2026",
+		Columns: []Column{
+			{Header: "Code"},
+			{Header: "Account", Width: 3},
+			{Header: "Debit", Width: 1.5, Align: Right},
+			{Header: "Credit", Width: 1.5, Align: Right},
+		},
+		IndentColumn: 1,
+		Rows:         rows,
+		Signatures:   DefaultSignatures(),
+	}
+}
+
+func lines(n int) []Row {
+	rows := make([]Row, n)
+	for i := range rows {
+		rows[i] = Row{Cells: []string{fmt.Sprint(1000 + i), fmt.Sprintf("Account %d", i), "1,000.00", ""}}
+	}
+	return rows
+}
+
+func TestRender_Pages(t *testing.T) {
+	pdf, err := Render(testReport(lines(150)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages := pageContents(t, pdf)
+	if len(pages) < 3 {
+		t.Fatalf("150 rows on %d pages, want at least 3", len(pages))
+	}
+	for i, content := range pages {
+		for _, want := range []string{
+			"(Glow Salon & Spa, Inc.) Tj",
+			"(Trial Balance) Tj",
+			"(As of March 31, 2026) Tj",
+			fmt.Sprintf("(Page %d of %d) Tj", i+1, len(pages)),
+		} {
+			if !strings.Contains(content, want) {
+				t.Errorf("page %d has no %s", i+1, want)
+			}
+		}
+		// Pages of the table repeat its headers.
+		if strings.Contains(content, "(Account 1") && !strings.Contains(content, "(Account) Tj") {
+			t.Errorf("page %d has no column headers", i+1)
+		}
+	}
+	for i := range 150 {
+		if n := strings.Count(strings.Join(pages, ""), fmt.Sprintf("(Account %d) Tj", i)); n != 1 {
+			t.Errorf("Account %d printed %d times", i, n)
+		}
+	}
+}
+
+func TestRender_HeadingKeptWithNextRow(t *testing.T) {
+	var rows []Row
+	for i := range 120 {
+		if i%7 == 0 {
+			rows = append(rows, Row{Style: Heading, Cells: []string{fmt.Sprintf("Group %d", i)}})
+		}
+		rows = append(rows, Row{Cells: []string{"", fmt.Sprintf("Account %d", i)}})
+	}
+	pdf, err := Render(testReport(rows))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, content := range pageContents(t, pdf) {
+		for i := 0; i < 120; i += 7 {
+			if strings.Contains(content, fmt.Sprintf("(Group %d) Tj", i)) && !strings.Contains(content, fmt.Sprintf("(Account %d) Tj", i)) {
+				t.Errorf("Group %d is apart from its first row", i)
+			}
+		}
+	}
+}
+
+func TestRender_SignaturesKeptTogether(t *testing.T) {
+	for n := 40; n < 60; n++ {
+		pdf, err := Render(testReport(lines(n)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages := pageContents(t, pdf)
+		last := pages[len(pages)-1]
+		for _, label := range []string{"(Prepared by:) Tj", "(Reviewed by:) Tj", "(Approved by:) Tj"} {
+			if !strings.Contains(last, label) {
+				t.Errorf("%d rows: %s is not on the last page", n, label)
+			}
+		}
+		if len(pages) > 1 && !strings.Contains(last, fmt.Sprintf("(Account %d) Tj", n-1)) && strings.Contains(last, "(Debit) Tj") {
+			t.Errorf("%d rows: page of signatures only repeats the column headers", n)
+		}
+	}
+}
+
+func TestRender_RowStyles(t *testing.T) {
+	report := testReport([]Row{
+		{Style: Heading, Cells: []string{"ASSETS"}},
+		{Cells: []string{"1010", "Cash (on hand)", "₱12,500.00", ""}, Indent: 1},
+		{Style: Subtotal, Cells: []string{"", "Total Current Assets", "₱12,500.00", ""}},
+		{Style: Blank},
+		{Style: Total, Cells: []string{"", "TOTAL", "₱12,500.00", "₱12,500.00"}},
+		{Style: Note, Cells: []string{"Debits equal credits."}},
+	})
+	report.Signatures[0].Name = "Maria Santos"
+	report.GeneratedAt = time.Date(2026, 4, 2, 15, 4, 0, 0, time.UTC)
+	pdf, err := Render(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := pageContents(t, pdf)[0]
+
+	for _, want := range []string{
+		"/F2 9 Tf 44 ",     // heading, bold, at the table's left
+		"/F1 9 Tf 129.61 ", // account name: column 1 at 113.61, padded and indented
+		`(Cash \(on hand\)) Tj`,
+		"/F2 9 Tf", // subtotal and total
+		"(P12,500.00) Tj",
+		"/F3 9 Tf", // note
+		"(Maria Santos) Tj",
+		"(Generated April 2, 2026 3:04 PM) Tj",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("content has no %q", want)
+		}
+	}
+	// Two bars per peso sign, of four; a rule above the subtotal, a rule
+	// above and two below each total amount.
+	if n := strings.Count(content, " l S\n"); n < 4*2+1+2*3+2+3 {
+		t.Errorf("%d lines drawn", n)
+	}
+	if !bytes.Contains(pdf, []byte("/CreationDate (D:20260402150400Z)")) {
+		t.Error("no creation date")
+	}
+}
+
+func TestRender_NoColumns(t *testing.T) {
+	if _, err := Render(&Report{Title: "Empty"}); err == nil {
+		t.Error("Render succeeded without columns")
+	}
+}
+
+func TestRender_Landscape(t *testing.T) {
+	report := testReport(lines(1))
+	report.Size, report.Landscape = Letter, true
+	pdf, err := Render(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(pdf, []byte("/MediaBox [0 0 792 612]")) {
+		t.Error("page is not landscape letter")
+	}
+}
+
+func TestAsOf(t *testing.T) {
+	tests := []struct {
+		format, layout, date string
+		want                 string
+	}{
+		{"", "", "2026-03-31", "As of March 31, 2026"},
+		{"Al %s", "02/01/2006", "2026-03-31", "Al 31/03/2026"},
+		{"", "", "end of March", "As of end of March"},
+	}
+	for _, tt := range tests {
+		if got := AsOf(tt.format, tt.layout, tt.date); got != tt.want {
+			t.Errorf("AsOf(%q, %q, %q) = %q, want %q", tt.format, tt.layout, tt.date, got, tt.want)
+		}
+	}
+}
+
+func TestEscape(t *testing.T) {
+	tests := map[string]string{
+		`Cash (petty) \ fund`: `Cash \(petty\) \\ fund`,
+		"Niño":                `Ni\361o`,
+		"Jan – Mar":           `Jan \226 Mar`,
+		"₱1.00":               "P1.00",
+		"A ≠ L + E":           "A <> L + E",
+		"日本":                  "??",
+	}
+	for in, want := range tests {
+		if got := escape(in); got != want {
+			t.Errorf("escape(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestWidth(t *testing.T) {
+	// Widths from the Adobe metrics of Helvetica and Helvetica-Bold.
+	if w := regular.width("Total", 10); w != 22.23 {
+		t.Errorf("Helvetica Total = %v", w)
+	}
+	if w := bold.width("Total", 10); w != 23.89 {
+		t.Errorf("Helvetica-Bold Total = %v", w)
+	}
+	if regular.width("é", 10) != regular.width("e", 10) {
+		t.Error("accented letter is not as wide as its base letter")
+	}
+}
+
+func TestFitAndWrap(t *testing.T) {
+	s := "Accumulated Depreciation - Furniture and Fixtures"
+	got := fit(regular, 9, s, 100)
+	if regular.width(got, 9) > 100 || !strings.HasSuffix(got, "…") {
+		t.Errorf("fit = %q, %v wide", got, regular.width(got, 9))
+	}
+	if fit(regular, 9, "Cash", 100) != "Cash" {
+		t.Error("fit shortened text that fits")
+	}
+	wrapped := wrap(regular, 9, s+" "+s, 150)
+	if len(wrapped) < 3 || strings.Join(wrapped, " ") != s+" "+s {
+		t.Errorf("wrap = %q", wrapped)
+	}
+	for _, line := range wrapped {
+		if regular.width(line, 9) > 150 {
+			t.Errorf("line %q too wide", line)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package xlsxtemplate
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+
+	"github.com/erniealice/fycha-golang/services/doctemplate"
+)
+
+// valueTagRegex matches a cell whose whole text is one bare placeholder,
+// {{path}}; such a cell takes the type of its value (number, date, boolean)
+// instead of becoming text.
+var valueTagRegex = regexp.MustCompile(`^\s*{{\s*([\w.]+)\s*}}\s*$`)
+
+// excelEpoch is day 0 of Excel's 1900 date system.
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// textNodes returns the <t> elements holding the text of a string item (an
+// <si> or <is>): its plain text or rich text runs, without phonetic runs.
+func textNodes(item *etree.Element) []*etree.Element {
+	if t := item.SelectElement("t"); t != nil {
+		return []*etree.Element{t}
+	}
+	var nodes []*etree.Element
+	for _, r := range item.SelectElements("r") {
+		if t := r.SelectElement("t"); t != nil {
+			nodes = append(nodes, t)
+		}
+	}
+	return nodes
+}
+
+// stringItem returns the string item of a text cell: its shared string or
+// its inline string. It is nil for other cells.
+func (wb *workbook) stringItem(c *etree.Element) *etree.Element {
+	switch c.SelectAttrValue("t", "") {
+	case "s":
+		v := c.SelectElement("v")
+		if v == nil {
+			return nil
+		}
+		i, err := strconv.Atoi(strings.TrimSpace(v.Text()))
+		if err != nil || i < 0 || i >= len(wb.strings) {
+			return nil
+		}
+		return wb.strings[i]
+	case "inlineStr":
+		return c.SelectElement("is")
+	}
+	return nil
+}
+
+// cellText returns the text of a text cell, or "" for other cells.
+func (wb *workbook) cellText(c *etree.Element) string {
+	item := wb.stringItem(c)
+	if item == nil {
+		return ""
+	}
+	var sb strings.Builder
+	for _, t := range textNodes(item) {
+		sb.WriteString(t.Text())
+	}
+	return sb.String()
+}
+
+// fillCell replaces the placeholders of a text cell with values from data,
+// after applying strip (which removes loop tags) to its text. Cells
+// without tags are left alone.
+func (wb *workbook) fillCell(c *etree.Element, data map[string]any, strip func(string) string) {
+	item := wb.stringItem(c)
+	if item == nil {
+		return
+	}
+	nodes := textNodes(item)
+	texts := make([]string, len(nodes))
+	var full strings.Builder
+	for i, t := range nodes {
+		texts[i] = t.Text()
+		full.WriteString(texts[i])
+	}
+	if !strings.Contains(full.String(), "{{") {
+		return
+	}
+	original := slices.Clone(texts)
+	text := full.String()
+	if strip != nil {
+		text = strip(text)
+	}
+
+	if m := valueTagRegex.FindStringSubmatch(text); m != nil {
+		if value, ok := getPathValue(data, m[1]); ok && setTypedValue(c, value) {
+			return
+		}
+	}
+
+	// Replace within each run when every tag lies inside one run, so rich
+	// text keeps its formatting; otherwise the text moves into the first run.
+	perRun := strip == nil
+	for _, s := range texts {
+		perRun = perRun && strings.Count(s, "{{") == strings.Count(s, "}}")
+	}
+	if perRun {
+		for i := range texts {
+			texts[i] = doctemplate.ReplacePlaceholders(texts[i], data)
+		}
+	} else {
+		texts = make([]string, len(nodes))
+		texts[0] = doctemplate.ReplacePlaceholders(text, data)
+	}
+	if strip == nil && slices.Equal(texts, original) {
+		return // only unresolved tags
+	}
+	if strings.Join(texts, "") == "" {
+		clearCell(c)
+		return
+	}
+
+	updated := item.Copy()
+	for i, t := range textNodes(updated) {
+		setText(t, texts[i])
+	}
+	wb.setStringItem(c, updated)
+}
+
+// setStringItem makes item the text of cell c: inline strings are replaced
+// in place, shared strings get a new entry in the shared string table.
+func (wb *workbook) setStringItem(c *etree.Element, item *etree.Element) {
+	if c.SelectAttrValue("t", "") == "inlineStr" {
+		if is := c.SelectElement("is"); is != nil {
+			c.RemoveChild(is)
+		}
+		c.AddChild(item)
+		return
+	}
+
+	index := -1
+	plain := item.SelectElement("t")
+	if plain != nil && len(item.ChildElements()) == 1 {
+		if i, ok := wb.newTexts[plain.Text()]; ok {
+			index = i
+		}
+	}
+	if index < 0 {
+		index = len(wb.strings)
+		wb.sst.AddChild(item)
+		wb.strings = append(wb.strings, item)
+		if plain != nil && len(item.ChildElements()) == 1 {
+			wb.newTexts[plain.Text()] = index
+		}
+	}
+	c.SelectElement("v").SetText(strconv.Itoa(index))
+}
+
+// setTypedValue writes value to cell c as a number, date or boolean. It
+// reports false for values that are written as text.
+func setTypedValue(c *etree.Element, value any) bool {
+	var v, typ string
+	switch x := value.(type) {
+	case bool:
+		v, typ = "0", "b"
+		if x {
+			v = "1"
+		}
+	case time.Time:
+		// Days since the epoch, with the time of day as the fraction. The
+		// cell's number format decides how it is shown.
+		_, offset := x.Zone()
+		days := float64(x.Unix()+int64(offset)-excelEpoch.Unix()) / 86400
+		v = strconv.FormatFloat(days, 'f', -1, 64)
+	case json.Number:
+		if _, err := x.Float64(); err != nil {
+			return false
+		}
+		v = x.String()
+	case float32:
+		return setTypedValue(c, float64(x))
+	case float64:
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return false
+		}
+		v = strconv.FormatFloat(x, 'f', -1, 64)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		v = fmt.Sprint(x)
+	default:
+		return false
+	}
+
+	clearCell(c)
+	if typ != "" {
+		c.CreateAttr("t", typ)
+	}
+	newChild(c, "v").SetText(v)
+	return true
+}
+
+// clearCell removes the value of cell c, keeping its position and style.
+func clearCell(c *etree.Element) {
+	c.RemoveAttr("t")
+	for _, name := range []string{"v", "is"} {
+		if el := c.SelectElement(name); el != nil {
+			c.RemoveChild(el)
+		}
+	}
+}
+
+// newChild adds a child element in the namespace of parent, so workbooks
+// written with a prefix (x:c) stay consistent.
+func newChild(parent *etree.Element, tag string) *etree.Element {
+	el := parent.CreateElement(tag)
+	el.Space = parent.Space
+	return el
+}
+
+// setText sets the text of a <t> element, preserving leading and trailing
+// spaces.
+func setText(t *etree.Element, text string) {
+	t.SetText(text)
+	if strings.TrimSpace(text) != text {
+		t.CreateAttr("xml:space", "preserve")
+	}
+}
+
+// getPathValue retrieves a nested value from a map using a dot-separated
+// path, the same way doctemplate resolves placeholders.
+func getPathValue(data map[string]any, path string) (any, bool) {
+	var current any = data
+	for _, part := range strings.Split(path, ".") {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if current, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
@@ -0,0 +1,105 @@
+package xlsxtemplate
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/beevik/etree"
+)
+
+// ProcessTemplate takes an XLSX template as bytes and a data map, fills
+// the {{placeholders}} of every worksheet, expands {{#rows}}...{{/rows}}
+// loops into repeated rows, and returns the processed XLSX as bytes. The
+// data map is the same as for doctemplate.ProcessTemplate.
+func ProcessTemplate(templateData []byte, data map[string]any) ([]byte, error) {
+	// Step 1: Read the XLSX archive
+	wb, err := readWorkbook(templateData)
+	if err != nil {
+		return nil, fmt.Errorf("reading xlsx: %w", err)
+	}
+
+	// Step 2: Expand loops and fill placeholders, sheet by sheet
+	for _, sh := range wb.sheets {
+		expandSharedFormulas(sh)
+	}
+	for _, sh := range wb.sheets {
+		if err := wb.processSheet(sh, data); err != nil {
+			return nil, fmt.Errorf("processing sheet %q: %w", sh.name, err)
+		}
+	}
+
+	// Step 3: Have formulas recalculated, since their inputs changed
+	wb.clearFormulaValues()
+	if err := wb.dropCalcChain(); err != nil {
+		return nil, fmt.Errorf("updating calculation settings: %w", err)
+	}
+	wb.updateStringCounts()
+
+	// Step 4: Write the modified XLSX
+	return wb.write()
+}
+
+// processSheet expands the loops of a sheet from top to bottom, then fills
+// the placeholders of the rows outside loops.
+func (wb *workbook) processSheet(sh *sheet, data map[string]any) error {
+	filled := make(map[*etree.Element]bool)
+	for from := 1; ; {
+		l, ok, err := wb.findLoop(sh, from)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		value, _ := getPathValue(data, l.name)
+		items := loopItems(value, data)
+		wb.expandLoop(sh, l, items, filled)
+		from = l.shift.start
+		if l.shift.height() > 0 {
+			from += len(items) * l.shift.height()
+		}
+	}
+
+	for _, row := range sh.data.SelectElements("row") {
+		if filled[row] {
+			continue
+		}
+		for _, c := range row.SelectElements("c") {
+			wb.fillCell(c, data, nil)
+		}
+	}
+	return nil
+}
+
+// clearFormulaValues removes the cached results of formulas, which may be
+// stale; dropCalcChain makes Excel recalculate them on open.
+func (wb *workbook) clearFormulaValues() {
+	for _, sh := range wb.sheets {
+		for _, c := range sh.data.FindElements("./row/c") {
+			if c.SelectElement("f") == nil {
+				continue
+			}
+			if v := c.SelectElement("v"); v != nil {
+				c.RemoveChild(v)
+			}
+		}
+	}
+}
+
+// updateStringCounts sets the counts of the shared string table after
+// strings were added.
+func (wb *workbook) updateStringCounts() {
+	if wb.sst == nil {
+		return
+	}
+	count := 0
+	for _, sh := range wb.sheets {
+		for _, c := range sh.data.FindElements("./row/c[@t='s']") {
+			if c.SelectElement("v") != nil {
+				count++
+			}
+		}
+	}
+	wb.sst.CreateAttr("count", strconv.Itoa(count))
+	wb.sst.CreateAttr("uniqueCount", strconv.Itoa(len(wb.strings)))
+}
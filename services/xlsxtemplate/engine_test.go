@@ -0,0 +1,325 @@
+package xlsxtemplate
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// createTestXlsx builds a minimal XLSX with two sheets, Sheet1 and Summary,
+// a shared string table and a calculation chain. sheetData are the rows of
+// each sheet; sheet1Extra is XML appended to Sheet1 after its sheetData.
+func createTestXlsx(t *testing.T, sheet1Data, sheet1Extra, summaryData string, sharedStrings []string, definedNames string) []byte {
+	t.Helper()
+
+	var sst strings.Builder
+	for _, s := range sharedStrings {
+		if strings.HasPrefix(s, "<r>") {
+			sst.WriteString("<si>" + s + "</si>")
+		} else {
+			sst.WriteString(`<si><t xml:space="preserve">` + s + `</t></si>`)
+		}
+	}
+	worksheet := func(data, extra string) string {
+		return `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><dimension ref="A1:E8"/><sheetData>` +
+			data + `</sheetData>` + extra + `</worksheet>`
+	}
+
+	files := map[string]string{
+		"[Content_Types].xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/calcChain.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.calcChain+xml"/></Types>`,
+		"_rels/.rels": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`,
+		"xl/workbook.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/><sheet name="Summary" sheetId="2" r:id="rId2"/></sheets>` +
+			definedNames + `</workbook>`,
+		"xl/_rels/workbook.xml.rels": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/><Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="/xl/worksheets/sheet2.xml"/><Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/sharedStrings" Target="sharedStrings.xml"/><Relationship Id="rId4" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/calcChain" Target="calcChain.xml"/></Relationships>`,
+		"xl/worksheets/sheet1.xml": worksheet(sheet1Data, sheet1Extra),
+		"xl/worksheets/sheet2.xml": worksheet(summaryData, ""),
+		"xl/sharedStrings.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` + sst.String() + `</sst>`,
+		"xl/calcChain.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<calcChain xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><c r="A1" i="2"/></calcChain>`,
+	}
+
+	buf := new(bytes.Buffer)
+	w := zip.NewWriter(buf)
+	for name, content := range files {
+		writer, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("failed to create zip entry %s: %v", name, err)
+		}
+		if _, err := writer.Write([]byte(content)); err != nil {
+			t.Fatalf("failed to write zip entry %s: %v", name, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to close zip writer: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// processTest runs ProcessTemplate and reads the result back.
+func processTest(t *testing.T, template []byte, data map[string]any) *workbook {
+	t.Helper()
+	result, err := ProcessTemplate(template, data)
+	if err != nil {
+		t.Fatalf("ProcessTemplate failed: %v", err)
+	}
+	wb, err := readWorkbook(result)
+	if err != nil {
+		t.Fatalf("failed to read output xlsx: %v", err)
+	}
+	return wb
+}
+
+// sheetCells returns the cells of a sheet by reference: formulas as
+// "=formula", text as its text, other values as written.
+func sheetCells(wb *workbook, index int) map[string]string {
+	cells := make(map[string]string)
+	for _, c := range wb.sheets[index].data.FindElements("./row/c") {
+		ref := c.SelectAttrValue("r", "")
+		switch {
+		case c.SelectElement("f") != nil:
+			cells[ref] = "=" + c.SelectElement("f").Text()
+		case wb.stringItem(c) != nil:
+			cells[ref] = wb.cellText(c)
+		case c.SelectElement("v") != nil:
+			cells[ref] = c.SelectElement("v").Text()
+		default:
+			cells[ref] = ""
+		}
+	}
+	return cells
+}
+
+func checkCells(t *testing.T, got map[string]string, want map[string]string) {
+	t.Helper()
+	for ref, w := range want {
+		if g, ok := got[ref]; !ok || g != w {
+			t.Errorf("cell %s = %q (present %v), want %q", ref, g, ok, w)
+		}
+	}
+}
+
+// s returns a shared string cell; f a formula cell.
+func s(ref string, index string) string {
+	return `<c r="` + ref + `" t="s" s="3"><v>` + index + `</v></c>`
+}
+
+func f(ref, formula string) string {
+	return `<c r="` + ref + `"><f>` + formula + `</f><v>0</v></c>`
+}
+
+func row(n string, cells ...string) string {
+	return `<row r="` + n + `">` + strings.Join(cells, "") + `</row>`
+}
+
+func TestProcessTemplate_Placeholders(t *testing.T) {
+	template := createTestXlsx(t,
+		row("1", s("A1", "0"), `<c r="B1" t="inlineStr"><is><t>Issued {{issued | date}}</t></is></c>`)+
+			row("2", s("A2", "1"), s("B2", "2"), s("C2", "3"), s("D2", "4"), s("E2", "5"))+
+			row("3", s("A3", "6"), s("B3", "1"), s("C3", "7")),
+		"", "", []string{
+			"Remittance for {{client.name}}",
+			"{{amount}}",
+			"{{paid}}",
+			"{{missing}}",
+			`<r><rPr><b/></rPr><t xml:space="preserve">Ref: </t></r><r><t>{{ref}}</t></r>`,
+			"{{ tin ?? \"N/A\" }}",
+			"{{nothing}}",
+			"{{amount | currency}}",
+		}, "")
+
+	wb := processTest(t, template, map[string]any{
+		"client":  map[string]any{"name": "Acme"},
+		"issued":  "2026-03-08",
+		"amount":  1250.5,
+		"paid":    true,
+		"ref":     "R-1",
+		"nothing": nil,
+	})
+
+	checkCells(t, sheetCells(wb, 0), map[string]string{
+		"A1": "Remittance for Acme",
+		"B1": "Issued March 8, 2026",
+		"A2": "1250.5",
+		"B2": "1",
+		"C2": "{{missing}}",
+		"D2": "Ref: R-1",
+		"E2": "N/A",
+		"A3": "",
+		"B3": "1250.5",
+		"C3": "₱1,250.50",
+	})
+
+	cells := wb.sheets[0].data.FindElements("./row/c")
+	if b2 := cells[3]; b2.SelectAttrValue("t", "") != "b" || b2.SelectAttrValue("s", "") != "3" {
+		t.Errorf("B2 should be a styled boolean cell: %v", b2.Attr)
+	}
+	if a2 := cells[2]; a2.SelectAttr("t") != nil {
+		t.Errorf("A2 should be a number cell: %v", a2.Attr)
+	}
+	d2 := wb.stringItem(cells[5])
+	if runs := d2.SelectElements("r"); len(runs) != 2 || runs[0].FindElement("./rPr/b") == nil {
+		t.Error("rich text runs of D2 were not kept")
+	}
+	if n := wb.sst.SelectAttrValue("uniqueCount", ""); n != "12" {
+		t.Errorf("uniqueCount = %s, want 12 (four strings added)", n)
+	}
+}
+
+func TestProcessTemplate_DateValue(t *testing.T) {
+	template := createTestXlsx(t, row("1", s("A1", "0")), "", "", []string{"{{due}}"}, "")
+	wb := processTest(t, template, map[string]any{"due": time.Date(2026, 3, 8, 12, 0, 0, 0, time.UTC)})
+	if got := sheetCells(wb, 0)["A1"]; got != "46089.5" {
+		t.Errorf("A1 = %q, want the serial date 46089.5", got)
+	}
+}
+
+func TestProcessTemplate_RowLoop(t *testing.T) {
+	template := createTestXlsx(t,
+		row("1", s("A1", "0"), s("B1", "1"))+
+			row("2", s("A2", "2"))+
+			row("3", s("A3", "3"), s("B3", "4"), f("C3", "B3*2"))+
+			row("4", s("A4", "5"))+
+			row("5", s("A5", "6"), f("B5", "SUM(B3:B3)"), f("C5", "B5*2"))+
+			row("7", s("A7", "7")),
+		`<mergeCells count="2"><mergeCell ref="A7:C7"/><mergeCell ref="D3:E3"/></mergeCells>`+
+			`<conditionalFormatting sqref="B3"><cfRule type="expression" dxfId="0" priority="1"><formula>B3&gt;1000</formula></cfRule></conditionalFormatting>`,
+		row("1", f("A1", "Sheet1!B5"))+row("2", f("A2", "SUM(Sheet1!B3:B3)"))+row("3", f("A3", `"Sheet1!B3"&amp;B3`)),
+		[]string{"Description", "Amount", "{{#rows}}", "{{description}}", "{{amount}}", "{{/rows}}", "Total", "Prepared by {{preparer}}"},
+		`<definedNames><definedName name="_xlnm.Print_Area" localSheetId="0">Sheet1!$A$1:$C$7</definedName><definedName name="Amounts">Sheet1!$B$3:$B$3</definedName></definedNames>`)
+
+	rows := []any{
+		map[string]any{"description": "Hosting", "amount": 900},
+		map[string]any{"description": "Domain", "amount": 15},
+		map[string]any{"description": "Support", "amount": 1200},
+		map[string]any{"description": "Backup", "amount": 50},
+	}
+	wb := processTest(t, template, map[string]any{"rows": rows, "preparer": "Ana"})
+
+	checkCells(t, sheetCells(wb, 0), map[string]string{
+		"A1": "Description",
+		"A2": "Hosting", "B2": "900", "C2": "=B2*2",
+		"A3": "Domain", "B3": "15", "C3": "=B3*2",
+		"A5": "Backup", "B5": "50", "C5": "=B5*2",
+		"A6": "Total", "B6": "=SUM(B2:B5)", "C6": "=B6*2",
+		"A8": "Prepared by Ana",
+	})
+	checkCells(t, sheetCells(wb, 1), map[string]string{
+		"A1": "=Sheet1!B6",
+		"A2": "=SUM(Sheet1!B2:B5)",
+		"A3": `="Sheet1!B3"&B3`,
+	})
+	if n := len(wb.sheets[0].data.SelectElements("row")); n != 7 {
+		t.Errorf("rows = %d, want 7", n)
+	}
+
+	root := wb.sheets[0].doc.Root()
+	var merges []string
+	for _, m := range root.FindElements("./mergeCells/mergeCell") {
+		merges = append(merges, m.SelectAttrValue("ref", ""))
+	}
+	if got := strings.Join(merges, " "); got != "A8:C8 D2:E2 D3:E3 D4:E4 D5:E5" {
+		t.Errorf("merged cells = %s", got)
+	}
+	if cf := root.SelectElement("conditionalFormatting"); cf.SelectAttrValue("sqref", "") != "B2:B5" ||
+		cf.FindElement(".//formula").Text() != "B2>1000" {
+		t.Errorf("conditional format = %s %s", cf.SelectAttrValue("sqref", ""), cf.FindElement(".//formula").Text())
+	}
+	if dim := root.SelectElement("dimension").SelectAttrValue("ref", ""); dim != "A1:E9" {
+		t.Errorf("dimension = %s", dim)
+	}
+
+	var names []string
+	for _, n := range wb.doc.FindElements("//definedName") {
+		names = append(names, n.Text())
+	}
+	if got := strings.Join(names, " "); got != "Sheet1!$A$1:$C$8 Sheet1!$B$2:$B$5" {
+		t.Errorf("defined names = %s", got)
+	}
+
+	for _, c := range wb.sheets[0].data.FindElements("./row/c") {
+		if c.SelectElement("f") != nil && c.SelectElement("v") != nil {
+			t.Errorf("formula cell %s kept its cached value", c.SelectAttrValue("r", ""))
+		}
+	}
+	if calcPr := wb.doc.Root().SelectElement("calcPr"); calcPr == nil || calcPr.SelectAttrValue("fullCalcOnLoad", "") != "1" {
+		t.Error("workbook is not set to recalculate on load")
+	}
+	for _, file := range wb.files {
+		if file.Name == "xl/calcChain.xml" {
+			t.Error("calcChain.xml was kept")
+		}
+	}
+}
+
+func TestProcessTemplate_InlineAndEmptyLoops(t *testing.T) {
+	template := createTestXlsx(t,
+		row("1", s("A1", "0"), s("B1", "1"))+
+			row("2", f("A2", "SUM(B1:B1)"))+
+			row("3", s("A3", "2"))+
+			row("4", s("A4", "3"))+
+			row("5", s("A5", "4"))+
+			row("6", f("A6", "SUM(B4:B4)+B4"))+
+			row("7", `<c r="C7"><f t="shared" ref="C7:C8" si="0">A7+$A$1</f><v>1</v></c>`)+
+			row("8", `<c r="C8"><f t="shared" si="0"/><v>1</v></c>`),
+		"", "",
+		[]string{"{{#lines}}{{code}}", "{{amount}}{{/lines}}", "{{#empty}}", "{{x}}", "{{/empty}}"}, "")
+
+	wb := processTest(t, template, map[string]any{
+		"lines": []any{
+			map[string]any{"code": "A-1", "amount": 10},
+			map[string]any{"code": "A-2", "amount": 20},
+		},
+		"empty": []any{},
+	})
+
+	checkCells(t, sheetCells(wb, 0), map[string]string{
+		"A1": "A-1", "B1": "10",
+		"A2": "A-2", "B2": "20",
+		"A3": "=SUM(B1:B2)",
+		"A4": "=SUM(#REF!)+#REF!",
+		"C5": "=A5+$A$1",
+		"C6": "=A6+$A$1",
+	})
+	if n := len(wb.sheets[0].data.SelectElements("row")); n != 6 {
+		t.Errorf("rows = %d, want 6", n)
+	}
+}
+
+func TestProcessTemplate_UnclosedLoop(t *testing.T) {
+	template := createTestXlsx(t, row("1", s("A1", "0")), "", "", []string{"{{#rows}}"}, "")
+	if _, err := ProcessTemplate(template, nil); err == nil || !strings.Contains(err.Error(), "not closed") {
+		t.Errorf("error = %v, want an unclosed loop error", err)
+	}
+}
+
+func TestMapFormulaRefs(t *testing.T) {
+	// Rows 3-5 hold a loop whose template is row 4; four copies move the
+	// rows below down by one.
+	shift := rowShift{start: 3, end: 5, first: 4, last: 4, copies: 4}
+	tests := []struct{ formula, want string }{
+		{"SUM(B4:B4)", "SUM(B3:B6)"},
+		{"B4*$C$1+LOG10(A9)", "B3*$C$1+LOG10(A10)"},
+		{`IF(A9>0,"A9",Rate)`, `IF(A10>0,"A9",Rate)`},
+		{"'My Sheet'!A9+Sheet1!A9", "'My Sheet'!A9+Sheet1!A10"},
+		{"Table1[Amount]+A6", "Table1[Amount]+A7"},
+		{"SUM(6:9)+SUM(A:A)", "SUM(7:10)+SUM(A:A)"},
+		{"1.5E+3*A2", "1.5E+3*A2"},
+	}
+	mapper := shift.formulaMapper("Sheet1", "Sheet1", -1)
+	for _, tt := range tests {
+		if got := mapFormulaRefs(tt.formula, mapper); got != tt.want {
+			t.Errorf("mapFormulaRefs(%q) = %q, want %q", tt.formula, got, tt.want)
+		}
+	}
+	if got := mapFormulaRefs("'My Sheet'!A9+A9", shift.formulaMapper("My Sheet", "Other", -1)); got != "'My Sheet'!A10+A9" {
+		t.Errorf("other sheet: %q", got)
+	}
+}
@@ -0,0 +1,289 @@
+package xlsxtemplate
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Limits of a worksheet.
+const (
+	maxColumn = 16384   // XFD
+	maxRow    = 1048576 // 2^20
+)
+
+// cellRef is a cell reference such as A1, $B$7, a whole column (A) or a
+// whole row (7). col or row is 0 when the reference has none.
+type cellRef struct {
+	col, row       int
+	colAbs, rowAbs bool
+}
+
+// refRange is a single cell reference or a range of two.
+type refRange struct {
+	from, to cellRef
+	isRange  bool
+}
+
+// parseCellRef parses a cell, column or row reference.
+func parseCellRef(s string) (cellRef, bool) {
+	var ref cellRef
+	i := 0
+	if i < len(s) && s[i] == '$' {
+		ref.colAbs = true
+		i++
+	}
+	start := i
+	for i < len(s) && isLetter(s[i]) {
+		ref.col = ref.col*26 + int(upper(s[i])-'A'+1)
+		i++
+	}
+	letters := i - start
+	if letters > 3 || ref.col > maxColumn {
+		return cellRef{}, false
+	}
+	if letters == 0 {
+		// A row reference: the "$" belongs to the row.
+		ref.rowAbs, ref.colAbs = ref.colAbs, false
+	} else if i < len(s) && s[i] == '$' {
+		ref.rowAbs = true
+		i++
+	}
+	if i == len(s) {
+		// A column reference; "$A$" is not one.
+		return ref, letters > 0 && !ref.rowAbs
+	}
+	n, err := strconv.Atoi(s[i:])
+	if err != nil || n < 1 || n > maxRow || s[i] == '+' || s[i] == '-' {
+		return cellRef{}, false
+	}
+	ref.row = n
+	return ref, true
+}
+
+func (c cellRef) String() string {
+	var sb strings.Builder
+	if c.col > 0 {
+		if c.colAbs {
+			sb.WriteByte('$')
+		}
+		sb.WriteString(columnName(c.col))
+	}
+	if c.row > 0 {
+		if c.rowAbs {
+			sb.WriteByte('$')
+		}
+		sb.WriteString(strconv.Itoa(c.row))
+	}
+	return sb.String()
+}
+
+// parseRange parses "A1", "A1:B5", "A:C" or "3:7". Whole columns and rows
+// are only accepted as ranges, and both ends must be of the same kind.
+func parseRange(s string) (refRange, bool) {
+	first, second, isRange := strings.Cut(s, ":")
+	from, ok := parseCellRef(first)
+	if !ok {
+		return refRange{}, false
+	}
+	if !isRange {
+		return refRange{from: from}, from.col > 0 && from.row > 0
+	}
+	to, ok := parseCellRef(second)
+	if !ok || (from.col == 0) != (to.col == 0) || (from.row == 0) != (to.row == 0) {
+		return refRange{}, false
+	}
+	return refRange{from: from, to: to, isRange: true}, true
+}
+
+func (r refRange) String() string {
+	if !r.isRange {
+		return r.from.String()
+	}
+	return r.from.String() + ":" + r.to.String()
+}
+
+// columnName converts a 1-based column number to its letters (1 → A).
+func columnName(col int) string {
+	var b []byte
+	for col > 0 {
+		col--
+		b = append([]byte{byte('A' + col%26)}, b...)
+		col /= 26
+	}
+	return string(b)
+}
+
+func isLetter(c byte) bool { return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' }
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func upper(c byte) byte {
+	if c >= 'a' && c <= 'z' {
+		return c - 'a' + 'A'
+	}
+	return c
+}
+
+// isNameChar reports whether c can be part of a name, number or reference
+// token in a formula.
+func isNameChar(c byte) bool {
+	return isLetter(c) || isDigit(c) || c == '_' || c == '.' || c == '$' || c == '\\' || c >= 0x80
+}
+
+func isRefChar(c byte) bool {
+	return isLetter(c) || isDigit(c) || c == '$'
+}
+
+// refMapper rewrites one reference. sheet is the sheet name the reference
+// is qualified with, or "" when it is not qualified. Returning false turns
+// the reference into #REF!.
+type refMapper func(sheet string, ref refRange) (refRange, bool)
+
+// mapFormulaRefs rewrites every cell and range reference in formula with
+// fn. String literals, structured references (Table1[Amount]), function
+// names and defined names are left alone.
+func mapFormulaRefs(formula string, fn refMapper) string {
+	var out strings.Builder
+	f := formula
+	for i := 0; i < len(f); {
+		c := f[i]
+		switch {
+		case c == '"':
+			j := i + 1
+			for j < len(f) {
+				if f[j] == '"' {
+					if j+1 < len(f) && f[j+1] == '"' {
+						j += 2
+						continue
+					}
+					break
+				}
+				j++
+			}
+			j = min(j+1, len(f))
+			out.WriteString(f[i:j])
+			i = j
+		case c == '[':
+			j, depth := i, 0
+			for ; j < len(f); j++ {
+				if f[j] == '[' {
+					depth++
+				} else if f[j] == ']' {
+					depth--
+					if depth == 0 {
+						break
+					}
+				}
+			}
+			j = min(j+1, len(f))
+			out.WriteString(f[i:j])
+			i = j
+		case c == '\'':
+			// A quoted sheet name: 'My Sheet'!A1.
+			j := i + 1
+			for j < len(f) {
+				if f[j] == '\'' {
+					if j+1 < len(f) && f[j+1] == '\'' {
+						j += 2
+						continue
+					}
+					break
+				}
+				j++
+			}
+			if j+1 < len(f) && f[j+1] == '!' {
+				sheet := strings.ReplaceAll(f[i+1:j], "''", "'")
+				i = mapRefAt(&out, f, j+2, f[i:j+2], sheet, fn)
+				continue
+			}
+			j = min(j+1, len(f))
+			out.WriteString(f[i:j])
+			i = j
+		case isNameChar(c):
+			j := i
+			for j < len(f) && isNameChar(f[j]) {
+				j++
+			}
+			if j < len(f) && f[j] == '!' {
+				i = mapRefAt(&out, f, j+1, f[i:j+1], f[i:j], fn)
+				continue
+			}
+			i = mapRefAt(&out, f, i, "", "", fn)
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return out.String()
+}
+
+// mapRefAt rewrites the reference starting at f[i], written after prefix
+// (a sheet qualifier, or ""), and returns the index after it. When f[i]
+// does not start a reference, the prefix and the next token are copied
+// unchanged.
+func mapRefAt(out *strings.Builder, f string, i int, prefix, sheet string, fn refMapper) int {
+	j := i
+	for j < len(f) && isRefChar(f[j]) {
+		j++
+	}
+	end := j
+	if j < len(f) && f[j] == ':' {
+		k := j + 1
+		for k < len(f) && isRefChar(f[k]) {
+			k++
+		}
+		if k > j+1 {
+			end = k
+		}
+	}
+	// A reference is not followed by more of a name or by "(" (LOG10()).
+	followed := func(n int) bool {
+		return n < len(f) && (isNameChar(f[n]) || f[n] == '(' || f[n] == '!')
+	}
+	ref, ok := parseRange(f[i:end])
+	if ok && !followed(end) && (i == 0 || prefix != "" || !isNameChar(f[i-1])) {
+		out.WriteString(mapOne(prefix, sheet, ref, fn))
+		return end
+	}
+	if end != j {
+		if ref, ok := parseRange(f[i:j]); ok && !followed(j) {
+			out.WriteString(mapOne(prefix, sheet, ref, fn))
+			return j
+		}
+	}
+	// Not a reference: copy the whole token.
+	for j < len(f) && isNameChar(f[j]) {
+		j++
+	}
+	if j == i && j < len(f) {
+		j++
+	}
+	out.WriteString(prefix)
+	out.WriteString(f[i:j])
+	return j
+}
+
+func mapOne(prefix, sheet string, ref refRange, fn refMapper) string {
+	mapped, ok := fn(sheet, ref)
+	if !ok {
+		return "#REF!"
+	}
+	return prefix + mapped.String()
+}
+
+// mapSqref rewrites a space-separated list of ranges, as used by sqref
+// attributes, dropping the ranges fn removes.
+func mapSqref(sqref string, fn func(refRange) (refRange, bool)) string {
+	var kept []string
+	for _, field := range strings.Fields(sqref) {
+		ref, ok := parseRange(field)
+		if !ok {
+			kept = append(kept, field)
+			continue
+		}
+		if mapped, ok := fn(ref); ok {
+			kept = append(kept, mapped.String())
+		}
+	}
+	return strings.Join(kept, " ")
+}
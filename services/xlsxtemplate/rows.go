@@ -0,0 +1,502 @@
+package xlsxtemplate
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+// loopOpenRegex matches a loop start tag like {{#rows}}.
+var loopOpenRegex = regexp.MustCompile(`{{\s*#\s*([\w.]+)\s*}}`)
+
+// loopTagRegex returns a regular expression matching the start or end tag
+// of the loop over name.
+func loopTagRegex(name string, open bool) *regexp.Regexp {
+	marker := "/"
+	if open {
+		marker = "#"
+	}
+	return regexp.MustCompile(`{{\s*` + marker + `\s*` + regexp.QuoteMeta(name) + `\s*}}`)
+}
+
+// rowShift describes how expanding a loop moves rows: rows start..end (the
+// loop with its tags) are replaced by copies of the template rows
+// first..last, one copy per item, and the rows below move by delta().
+type rowShift struct {
+	start, end  int
+	first, last int
+	copies      int
+}
+
+// height is the number of rows in one copy.
+func (s rowShift) height() int { return s.last - s.first + 1 }
+
+func (s rowShift) delta() int {
+	if s.height() <= 0 {
+		return -(s.end - s.start + 1)
+	}
+	return s.copies*s.height() - (s.end - s.start + 1)
+}
+
+// Positions of a row reference within a reference.
+const (
+	edgeNone  = iota // a single cell
+	edgeStart        // the first row of a range
+	edgeEnd          // the last row of a range
+)
+
+// mapRow moves the row of ref. copy is the index of the copy the reference
+// is written in, or -1 for references outside the loop: those see the
+// template rows as the whole block of copies, so a range over them (the
+// SUM of a total row) grows to cover every copy. References within a copy
+// to its template rows stay within the copy unless they are absolute.
+func (s rowShift) mapRow(ref cellRef, edge int, copy int) (int, bool) {
+	r := ref.row
+	switch {
+	case r == 0:
+		return 0, true
+	case r < s.start:
+		return r, true
+	case r > s.end:
+		return r + s.delta(), true
+	}
+
+	h := s.height()
+	if s.copies == 0 || h <= 0 {
+		// The rows are gone: ranges shrink, single cells are lost.
+		switch edge {
+		case edgeStart:
+			return s.start, true
+		case edgeEnd:
+			return s.start - 1, true
+		}
+		return 0, false
+	}
+	// Rows holding only a loop tag count as the template row next to them.
+	r = max(s.first, min(r, s.last))
+	offset := r - s.first
+	switch {
+	case copy >= 0 && !ref.rowAbs:
+		return s.start + copy*h + offset, true
+	case edge == edgeEnd:
+		return s.start + (s.copies-1)*h + offset, true
+	default:
+		return s.start + offset, true
+	}
+}
+
+// mapRange moves a cell or range reference; see mapRow.
+func (s rowShift) mapRange(ref refRange, copy int) (refRange, bool) {
+	if !ref.isRange {
+		row, ok := s.mapRow(ref.from, edgeNone, copy)
+		ref.from.row = row
+		return ref, ok
+	}
+	if ref.from.row == 0 {
+		return ref, true // whole columns
+	}
+	reversed := ref.from.row > ref.to.row
+	if reversed {
+		ref.from, ref.to = ref.to, ref.from
+	}
+	from, _ := s.mapRow(ref.from, edgeStart, copy)
+	to, _ := s.mapRow(ref.to, edgeEnd, copy)
+	if to < from || to < 1 {
+		return refRange{}, false
+	}
+	ref.from.row, ref.to.row = from, to
+	if reversed {
+		ref.from, ref.to = ref.to, ref.from
+	}
+	return ref, true
+}
+
+// formulaMapper returns the refMapper that moves the references of a
+// formula on sheet own (or of a defined name, own "") to sheet target.
+func (s rowShift) formulaMapper(target, own string, copy int) refMapper {
+	return func(sheet string, ref refRange) (refRange, bool) {
+		if sheet == "" {
+			sheet = own
+		}
+		if !strings.EqualFold(sheet, target) {
+			return ref, true
+		}
+		return s.mapRange(ref, copy)
+	}
+}
+
+// asRange turns a single cell into a one-cell range, so that an area such
+// as a conditional format on a template row grows with the copies.
+func asRange(ref refRange) refRange {
+	if !ref.isRange {
+		ref.to, ref.isRange = ref.from, true
+	}
+	return ref
+}
+
+// loop is a {{#name}} ... {{/name}} block of rows.
+type loop struct {
+	name  string
+	shift rowShift
+}
+
+// findLoop returns the first loop starting at or below row from.
+func (wb *workbook) findLoop(sh *sheet, from int) (loop, bool, error) {
+	rows := sh.data.SelectElements("row")
+	for i, row := range rows {
+		r := rowNumber(row)
+		if r < from {
+			continue
+		}
+		text := wb.rowText(row)
+		m := loopOpenRegex.FindStringSubmatchIndex(text)
+		if m == nil {
+			continue
+		}
+		name := text[m[2]:m[3]]
+		closeRegex := loopTagRegex(name, false)
+
+		l := loop{name: name, shift: rowShift{start: r, end: -1, first: r, last: r}}
+		if closeRegex.MatchString(text[m[1]:]) {
+			l.shift.end = r
+			return l, true, nil
+		}
+		for _, next := range rows[i+1:] {
+			nextText := wb.rowText(next)
+			if loc := closeRegex.FindStringIndex(nextText); loc != nil {
+				l.shift.end = rowNumber(next)
+				l.shift.last = l.shift.end
+				// A row holding nothing but a tag is not repeated.
+				if strings.TrimSpace(text) == text[m[0]:m[1]] {
+					l.shift.first = r + 1
+				}
+				if strings.TrimSpace(nextText) == nextText[loc[0]:loc[1]] {
+					l.shift.last = l.shift.end - 1
+				}
+				return l, true, nil
+			}
+		}
+		return loop{}, false, fmt.Errorf("{{#%s}} in row %d is not closed", name, r)
+	}
+	return loop{}, false, nil
+}
+
+// rowText concatenates the text of the cells in a row.
+func (wb *workbook) rowText(row *etree.Element) string {
+	var sb strings.Builder
+	for _, c := range row.SelectElements("c") {
+		sb.WriteString(wb.cellText(c))
+	}
+	return sb.String()
+}
+
+// loopItems returns the data of each copy of a loop over value, following
+// doctemplate's sections: a list repeats its (map) items, a map is rendered
+// once in its own scope, any other truthy value once with data.
+func loopItems(value any, data map[string]any) []map[string]any {
+	switch v := value.(type) {
+	case []any:
+		var items []map[string]any
+		for _, item := range v {
+			if m, ok := item.(map[string]any); ok {
+				items = append(items, m)
+			}
+		}
+		return items
+	case []map[string]any:
+		return v
+	case map[string]any:
+		if len(v) > 0 {
+			return []map[string]any{v}
+		}
+	case nil:
+	case bool:
+		if v {
+			return []map[string]any{data}
+		}
+	case string:
+		if v != "" {
+			return []map[string]any{data}
+		}
+	default:
+		return []map[string]any{data}
+	}
+	return nil
+}
+
+// expandLoop replaces the rows of l on sheet sh with one filled copy of its
+// template rows per item, and moves every reference to the rows below.
+// Copied rows are recorded in filled.
+func (wb *workbook) expandLoop(sh *sheet, l loop, items []map[string]any, filled map[*etree.Element]bool) {
+	s := l.shift
+	s.copies = len(items)
+	if s.height() <= 0 {
+		s.copies = 0
+	}
+	wb.shiftReferences(sh, s)
+
+	var template []*etree.Element
+	var anchor *etree.Element
+	for _, row := range sh.data.SelectElements("row") {
+		r := rowNumber(row)
+		switch {
+		case r >= s.start && r <= s.end:
+			if r >= s.first && r <= s.last {
+				template = append(template, row)
+			}
+			sh.data.RemoveChild(row)
+		case r > s.end:
+			if anchor == nil {
+				anchor = row
+			}
+			setRowNumber(row, r+s.delta())
+		}
+	}
+
+	openTag, closeTag := loopTagRegex(l.name, true), loopTagRegex(l.name, false)
+	strip := func(text string) string {
+		return closeTag.ReplaceAllString(openTag.ReplaceAllString(text, ""), "")
+	}
+	for i := 0; i < s.copies; i++ {
+		for _, row := range template {
+			clone := row.Copy()
+			setRowNumber(clone, s.start+i*s.height()+rowNumber(row)-s.first)
+			if anchor != nil {
+				sh.data.InsertChildAt(anchor.Index(), clone)
+			} else {
+				sh.data.AddChild(clone)
+			}
+			for _, c := range clone.SelectElements("c") {
+				if f := c.SelectElement("f"); f != nil {
+					shiftFormula(f, s, sh.name, sh.name, i)
+				}
+				wb.fillCell(c, items[i], strip)
+			}
+			filled[clone] = true
+		}
+	}
+}
+
+// shiftReferences moves the references to sheet sh's rows outside the loop
+// being expanded: formulas on every sheet, defined names, and the merged
+// cells, formats, validations, links, filters and tables of sh.
+func (wb *workbook) shiftReferences(sh *sheet, s rowShift) {
+	for _, other := range wb.sheets {
+		for _, row := range other.data.SelectElements("row") {
+			if r := rowNumber(row); other == sh && r >= s.start && r <= s.end {
+				continue // the loop's own rows are handled per copy
+			}
+			for _, c := range row.SelectElements("c") {
+				if f := c.SelectElement("f"); f != nil {
+					shiftFormula(f, s, sh.name, other.name, -1)
+				}
+			}
+		}
+	}
+	for _, name := range wb.doc.FindElements("//definedNames/definedName") {
+		name.SetText(mapFormulaRefs(name.Text(), s.formulaMapper(sh.name, "", -1)))
+	}
+
+	area := func(ref refRange) (refRange, bool) { return s.mapRange(asRange(ref), -1) }
+	cell := func(ref refRange) (refRange, bool) { return s.mapRange(ref, -1) }
+	root := sh.doc.Root()
+	// shiftAttr rewrites the ranges of an attribute and removes the element
+	// when none is left.
+	shiftAttr := func(el *etree.Element, attr string, fn func(refRange) (refRange, bool)) bool {
+		value := mapSqref(el.SelectAttrValue(attr, ""), fn)
+		if value == "" {
+			el.Parent().RemoveChild(el)
+			return false
+		}
+		el.CreateAttr(attr, value)
+		return true
+	}
+	for _, el := range root.SelectElements("dimension") {
+		shiftAttr(el, "ref", area)
+	}
+	formats := root.SelectElements("conditionalFormatting")
+	if validations := root.SelectElement("dataValidations"); validations != nil {
+		formats = append(formats, validations.SelectElements("dataValidation")...)
+	}
+	for _, el := range formats {
+		if !shiftAttr(el, "sqref", area) {
+			continue
+		}
+		for _, f := range el.FindElements(".//formula") {
+			f.SetText(mapFormulaRefs(f.Text(), s.formulaMapper(sh.name, sh.name, -1)))
+		}
+		for _, name := range []string{"formula1", "formula2"} {
+			if f := el.SelectElement(name); f != nil {
+				f.SetText(mapFormulaRefs(f.Text(), s.formulaMapper(sh.name, sh.name, -1)))
+			}
+		}
+	}
+	if links := root.SelectElement("hyperlinks"); links != nil {
+		for _, el := range links.SelectElements("hyperlink") {
+			shiftAttr(el, "ref", cell)
+		}
+	}
+	for _, el := range root.SelectElements("autoFilter") {
+		shiftAttr(el, "ref", area)
+	}
+	for _, table := range sh.tables {
+		if t := table.Root(); t != nil {
+			for _, el := range append([]*etree.Element{t}, t.SelectElements("autoFilter")...) {
+				if value := mapSqref(el.SelectAttrValue("ref", ""), area); value != "" {
+					el.CreateAttr("ref", value)
+				}
+			}
+		}
+	}
+	shiftMergedCells(root, s)
+}
+
+// shiftFormula moves the references of a formula cell's <f> element, and
+// the range of an array formula.
+func shiftFormula(f *etree.Element, s rowShift, target, own string, copy int) {
+	f.SetText(mapFormulaRefs(f.Text(), s.formulaMapper(target, own, copy)))
+	if ref := f.SelectAttr("ref"); ref != nil && target == own {
+		ref.Value = mapSqref(ref.Value, func(r refRange) (refRange, bool) { return s.mapRange(r, copy) })
+	}
+}
+
+// shiftMergedCells moves merged cells below the loop and repeats the ones
+// within the template rows for every copy.
+func shiftMergedCells(root *etree.Element, s rowShift) {
+	merges := root.SelectElement("mergeCells")
+	if merges == nil {
+		return
+	}
+	for _, el := range merges.SelectElements("mergeCell") {
+		ref, ok := parseRange(el.SelectAttrValue("ref", ""))
+		if !ok {
+			continue
+		}
+		merges.RemoveChild(el)
+		within := ref.from.row >= s.first && ref.to.row <= s.last
+		if within && s.copies > 0 && s.height() > 0 {
+			for i := 0; i < s.copies; i++ {
+				if mapped, ok := s.mapRange(ref, i); ok {
+					newChild(merges, "mergeCell").CreateAttr("ref", mapped.String())
+				}
+			}
+			continue
+		}
+		if ref.from.row >= s.start && ref.to.row <= s.end {
+			continue // on removed rows
+		}
+		if mapped, ok := s.mapRange(ref, -1); ok {
+			newChild(merges, "mergeCell").CreateAttr("ref", mapped.String())
+		}
+	}
+	count := len(merges.SelectElements("mergeCell"))
+	if count == 0 {
+		root.RemoveChild(merges)
+		return
+	}
+	merges.CreateAttr("count", strconv.Itoa(count))
+}
+
+// rowNumber returns the r attribute of a row.
+func rowNumber(row *etree.Element) int {
+	n, _ := strconv.Atoi(row.SelectAttrValue("r", ""))
+	return n
+}
+
+// setRowNumber renumbers a row and its cells.
+func setRowNumber(row *etree.Element, n int) {
+	row.CreateAttr("r", strconv.Itoa(n))
+	for _, c := range row.SelectElements("c") {
+		ref, _ := parseCellRef(c.SelectAttrValue("r", ""))
+		ref.row = n
+		c.CreateAttr("r", ref.String())
+	}
+}
+
+// normalizeRows gives every row and cell an explicit r attribute; both are
+// optional in SpreadsheetML and default to the next row or column.
+func normalizeRows(data *etree.Element) {
+	prev := 0
+	for _, row := range data.SelectElements("row") {
+		r := rowNumber(row)
+		if r <= prev {
+			r = prev + 1
+		}
+		prev = r
+		row.CreateAttr("r", strconv.Itoa(r))
+
+		col := 0
+		for _, c := range row.SelectElements("c") {
+			if ref, ok := parseCellRef(c.SelectAttrValue("r", "")); ok && ref.col > col {
+				col = ref.col
+			} else {
+				col++
+			}
+			c.CreateAttr("r", cellRef{col: col, row: r}.String())
+		}
+	}
+}
+
+// expandSharedFormulas writes out shared formulas, which store the formula
+// once for a range of cells, as one formula per cell so each can be moved
+// on its own.
+func expandSharedFormulas(sh *sheet) {
+	type master struct {
+		col, row int
+		formula  string
+	}
+	masters := make(map[string]master)
+	var shared []*etree.Element
+	for _, c := range sh.data.FindElements("./row/c") {
+		f := c.SelectElement("f")
+		if f == nil || f.SelectAttrValue("t", "") != "shared" {
+			continue
+		}
+		shared = append(shared, c)
+		if f.SelectAttr("ref") != nil && strings.TrimSpace(f.Text()) != "" {
+			at, _ := parseCellRef(c.SelectAttrValue("r", ""))
+			masters[f.SelectAttrValue("si", "")] = master{col: at.col, row: at.row, formula: f.Text()}
+		}
+	}
+	for _, c := range shared {
+		f := c.SelectElement("f")
+		m, ok := masters[f.SelectAttrValue("si", "")]
+		if !ok {
+			continue
+		}
+		at, _ := parseCellRef(c.SelectAttrValue("r", ""))
+		f.SetText(translateFormula(m.formula, at.row-m.row, at.col-m.col))
+		for _, attr := range []string{"t", "ref", "si"} {
+			f.RemoveAttr(attr)
+		}
+	}
+}
+
+// translateFormula moves the relative references of formula by rows and
+// cols, as when a formula is copied to another cell.
+func translateFormula(formula string, rows, cols int) string {
+	move := func(c cellRef) (cellRef, bool) {
+		if c.row > 0 && !c.rowAbs {
+			c.row += rows
+		}
+		if c.col > 0 && !c.colAbs {
+			c.col += cols
+		}
+		valid := c.row >= 0 && c.row <= maxRow && c.col >= 0 && c.col <= maxColumn &&
+			(c.row > 0 || rows == 0 || c.rowAbs) && (c.col > 0 || cols == 0 || c.colAbs)
+		return c, valid
+	}
+	return mapFormulaRefs(formula, func(_ string, ref refRange) (refRange, bool) {
+		var ok bool
+		if ref.from, ok = move(ref.from); !ok {
+			return ref, false
+		}
+		if ref.isRange {
+			ref.to, ok = move(ref.to)
+		}
+		return ref, ok
+	})
+}
@@ -0,0 +1,302 @@
+package xlsxtemplate
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+// Relationship types used by the template engine.
+const (
+	relTypeWorksheet     = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet"
+	relTypeSharedStrings = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/sharedStrings"
+	relTypeCalcChain     = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/calcChain"
+	relTypeTable         = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/table"
+)
+
+// workbook holds an XLSX archive with its XML parts parsed as needed.
+type workbook struct {
+	files []*zip.File
+	// docs are the parsed parts; they are written back in place of the
+	// original files.
+	docs map[string]*etree.Document
+	// removed are parts left out of the output.
+	removed map[string]bool
+
+	doc    *etree.Document // xl/workbook.xml
+	sheets []*sheet
+
+	// sst is the shared string table, or nil if the workbook has none.
+	sst      *etree.Element
+	strings  []*etree.Element // the sst's <si> items
+	newTexts map[string]int   // plain strings added to the sst, by text
+}
+
+// sheet is one worksheet of the workbook.
+type sheet struct {
+	name string
+	part string
+	doc  *etree.Document
+	// data is the <sheetData> element holding the rows.
+	data *etree.Element
+	// tables are the table parts (Excel tables) of the sheet.
+	tables []*etree.Document
+}
+
+// readWorkbook reads an XLSX file and parses its workbook, worksheets and
+// shared strings.
+func readWorkbook(data []byte) (*workbook, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	wb := &workbook{
+		files:    reader.File,
+		docs:     make(map[string]*etree.Document),
+		removed:  make(map[string]bool),
+		newTexts: make(map[string]int),
+	}
+
+	wb.doc, err = wb.part("xl/workbook.xml")
+	if err != nil {
+		return nil, err
+	}
+	if wb.doc == nil {
+		return nil, fmt.Errorf("not a workbook: xl/workbook.xml is missing")
+	}
+	rels, err := wb.relationships("xl/workbook.xml")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rel := range rels {
+		if rel.typ != relTypeSharedStrings {
+			continue
+		}
+		doc, err := wb.part(rel.target)
+		if err != nil {
+			return nil, err
+		}
+		if doc != nil && doc.Root() != nil {
+			wb.sst = doc.Root()
+			wb.strings = wb.sst.SelectElements("si")
+		}
+	}
+
+	byID := make(map[string]relationship, len(rels))
+	for _, rel := range rels {
+		byID[rel.id] = rel
+	}
+	for _, el := range wb.doc.FindElements("//sheets/sheet") {
+		rel, ok := byID[relID(el)]
+		if !ok || rel.typ != relTypeWorksheet {
+			continue // chart sheets and dialogs have no rows
+		}
+		doc, err := wb.part(rel.target)
+		if err != nil {
+			return nil, err
+		}
+		if doc == nil {
+			return nil, fmt.Errorf("sheet %q: %s is missing", el.SelectAttrValue("name", ""), rel.target)
+		}
+		sh := &sheet{name: el.SelectAttrValue("name", ""), part: rel.target, doc: doc, data: doc.FindElement("//sheetData")}
+		if sh.data == nil {
+			continue
+		}
+		normalizeRows(sh.data)
+
+		sheetRels, err := wb.relationships(rel.target)
+		if err != nil {
+			return nil, err
+		}
+		for _, sr := range sheetRels {
+			if sr.typ != relTypeTable {
+				continue
+			}
+			table, err := wb.part(sr.target)
+			if err != nil {
+				return nil, err
+			}
+			if table != nil {
+				sh.tables = append(sh.tables, table)
+			}
+		}
+		wb.sheets = append(wb.sheets, sh)
+	}
+	return wb, nil
+}
+
+// relID returns the r:id attribute of el.
+func relID(el *etree.Element) string {
+	for _, attr := range el.Attr {
+		if attr.Key == "id" && attr.Space != "" {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// part returns the parsed XML part name, or nil if the archive has no
+// such part.
+func (wb *workbook) part(name string) (*etree.Document, error) {
+	if doc, ok := wb.docs[name]; ok {
+		return doc, nil
+	}
+	for _, f := range wb.files {
+		if f.Name != name {
+			continue
+		}
+		content, err := readZipFile(f)
+		if err != nil {
+			return nil, err
+		}
+		doc := etree.NewDocument()
+		doc.ReadSettings.PreserveCData = true
+		if err := doc.ReadFromBytes(content); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", name, err)
+		}
+		wb.docs[name] = doc
+		return doc, nil
+	}
+	return nil, nil
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// relationship is one entry of a part's relationships, with its target
+// resolved to a part name.
+type relationship struct {
+	id, typ, target string
+	el              *etree.Element
+}
+
+// relationshipsPart returns the name of the relationships part of part,
+// e.g. "xl/_rels/workbook.xml.rels".
+func relationshipsPart(part string) string {
+	dir, file := path.Split(part)
+	return dir + "_rels/" + file + ".rels"
+}
+
+// relationships returns the internal relationships of part.
+func (wb *workbook) relationships(part string) ([]relationship, error) {
+	doc, err := wb.part(relationshipsPart(part))
+	if err != nil || doc == nil || doc.Root() == nil {
+		return nil, err
+	}
+	var rels []relationship
+	for _, el := range doc.Root().SelectElements("Relationship") {
+		if el.SelectAttrValue("TargetMode", "") == "External" {
+			continue
+		}
+		target := el.SelectAttrValue("Target", "")
+		if strings.HasPrefix(target, "/") {
+			target = strings.TrimPrefix(target, "/")
+		} else {
+			target = path.Join(path.Dir(part), target)
+		}
+		rels = append(rels, relationship{
+			id:     el.SelectAttrValue("Id", ""),
+			typ:    el.SelectAttrValue("Type", ""),
+			target: target,
+			el:     el,
+		})
+	}
+	return rels, nil
+}
+
+// dropCalcChain removes the calculation chain, which lists formula cells by
+// address and so is stale once rows move, and asks Excel to recalculate
+// every formula when the file is opened.
+func (wb *workbook) dropCalcChain() error {
+	rels, err := wb.relationships("xl/workbook.xml")
+	if err != nil {
+		return err
+	}
+	for _, rel := range rels {
+		if rel.typ != relTypeCalcChain {
+			continue
+		}
+		wb.removed[rel.target] = true
+		rel.el.Parent().RemoveChild(rel.el)
+		types, err := wb.part("[Content_Types].xml")
+		if err != nil {
+			return err
+		}
+		if types != nil && types.Root() != nil {
+			for _, o := range types.Root().SelectElements("Override") {
+				if o.SelectAttrValue("PartName", "") == "/"+rel.target {
+					types.Root().RemoveChild(o)
+				}
+			}
+		}
+	}
+
+	root := wb.doc.Root()
+	calcPr := root.SelectElement("calcPr")
+	if calcPr == nil {
+		calcPr = etree.NewElement("calcPr")
+		calcPr.Space = root.Space
+		// calcPr follows definedNames; keep it before any later elements.
+		index := len(root.Child)
+		for _, name := range []string{"oleSize", "customWorkbookViews", "pivotCaches", "smartTagPr", "smartTagTypes", "webPublishing", "fileRecoveryPr", "webPublishObjects", "extLst"} {
+			if el := root.SelectElement(name); el != nil && el.Index() < index {
+				index = el.Index()
+			}
+		}
+		root.InsertChildAt(index, calcPr)
+	}
+	calcPr.CreateAttr("fullCalcOnLoad", "1")
+	return nil
+}
+
+// write serializes the workbook to XLSX bytes: parsed parts replace the
+// original files and removed parts are left out.
+func (wb *workbook) write() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	zipWriter := zip.NewWriter(buf)
+
+	for _, file := range wb.files {
+		if wb.removed[file.Name] {
+			continue
+		}
+		writer, err := zipWriter.Create(file.Name)
+		if err != nil {
+			return nil, err
+		}
+		if doc, ok := wb.docs[file.Name]; ok {
+			doc.WriteSettings.CanonicalEndTags = false
+			doc.WriteSettings.CanonicalText = false
+			doc.WriteSettings.CanonicalAttrVal = false
+			if _, err := doc.WriteTo(writer); err != nil {
+				return nil, fmt.Errorf("writing %s: %w", file.Name, err)
+			}
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(writer, rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -1,7 +1,11 @@
 package financial
 
 import (
+	"log"
+	"net/http"
+
 	fycha "github.com/erniealice/fycha-golang"
+	"github.com/erniealice/fycha-golang/services/pdfreport"
 	balancesheetview "github.com/erniealice/fycha-golang/views/reports/balance_sheet"
 	cashflowview "github.com/erniealice/fycha-golang/views/reports/cash_flow"
 	equitychangesview "github.com/erniealice/fycha-golang/views/reports/equity_changes"
@@ -11,34 +15,68 @@ import (
 	"github.com/erniealice/pyeza-golang/view"
 )
 
+// routeRegistrarFull extends view.RouteRegistrar with HandleFunc support, for
+// the raw http.HandlerFunc PDF export routes.
+type routeRegistrarFull interface {
+	view.RouteRegistrar
+	HandleFunc(method, path string, handler http.HandlerFunc, middlewares ...string)
+}
+
+// handleFunc is a nil-safe helper that registers an http.HandlerFunc route if the
+// RouteRegistrar supports it, otherwise logs a warning and skips.
+func handleFunc(r view.RouteRegistrar, method, path string, handler http.HandlerFunc) {
+	if handler == nil {
+		return
+	}
+	if full, ok := r.(routeRegistrarFull); ok {
+		full.HandleFunc(method, path, handler)
+		return
+	}
+	log.Printf("fycha/financial: RouteRegistrar does not support HandleFunc — skipping %s %s", method, path)
+}
+
 // ModuleDeps holds all dependencies for the financial statements module.
 type ModuleDeps struct {
 	CommonLabels pyeza.CommonLabels
 	TableLabels  types.TableLabels
 	Labels       fycha.ReportsLabels
+
+	// Letterhead and Signatures are printed on the statement PDF exports.
+	Letterhead pdfreport.Letterhead
+	Signatures []pdfreport.Signature
 }
 
 // Module holds all constructed financial statement views.
 type Module struct {
-	incomeStatement view.View
-	balanceSheet    view.View
-	cashFlow        view.View
-	equityChanges   view.View
+	incomeStatement    view.View
+	incomeStatementPDF http.HandlerFunc
+	balanceSheet       view.View
+	balanceSheetPDF    http.HandlerFunc
+	cashFlow           view.View
+	equityChanges      view.View
 }
 
 // NewModule creates a financial statements module with real report views.
 func NewModule(deps *ModuleDeps) *Module {
+	isDeps := &incomestatementview.IncomeStatementDeps{
+		CommonLabels: deps.CommonLabels,
+		TableLabels:  deps.TableLabels,
+		Labels:       deps.Labels,
+		Letterhead:   deps.Letterhead,
+		Signatures:   deps.Signatures,
+	}
+	bsDeps := &balancesheetview.BalanceSheetDeps{
+		CommonLabels: deps.CommonLabels,
+		TableLabels:  deps.TableLabels,
+		Labels:       deps.Labels,
+		Letterhead:   deps.Letterhead,
+		Signatures:   deps.Signatures,
+	}
 	return &Module{
-		incomeStatement: incomestatementview.NewIncomeStatementView(&incomestatementview.IncomeStatementDeps{
-			CommonLabels: deps.CommonLabels,
-			TableLabels:  deps.TableLabels,
-			Labels:       deps.Labels,
-		}),
-		balanceSheet: balancesheetview.NewBalanceSheetView(&balancesheetview.BalanceSheetDeps{
-			CommonLabels: deps.CommonLabels,
-			TableLabels:  deps.TableLabels,
-			Labels:       deps.Labels,
-		}),
+		incomeStatement:    incomestatementview.NewIncomeStatementView(isDeps),
+		incomeStatementPDF: incomestatementview.NewPDFExportHandler(isDeps),
+		balanceSheet:       balancesheetview.NewBalanceSheetView(bsDeps),
+		balanceSheetPDF:    balancesheetview.NewPDFExportHandler(bsDeps),
 		cashFlow: cashflowview.NewCashFlowView(&cashflowview.CashFlowDeps{
 			CommonLabels: deps.CommonLabels,
 			TableLabels:  deps.TableLabels,
@@ -56,7 +94,9 @@ func NewModule(deps *ModuleDeps) *Module {
 // These routes live under the Reports app (active nav: "reports").
 func (m *Module) RegisterRoutes(r view.RouteRegistrar) {
 	r.GET(fycha.ReportsIncomeStatementURL, m.incomeStatement)
+	handleFunc(r, "GET", fycha.ReportsIncomeStatementPDFURL, m.incomeStatementPDF)
 	r.GET(fycha.ReportsBalanceSheetURL, m.balanceSheet)
+	handleFunc(r, "GET", fycha.ReportsBalanceSheetPDFURL, m.balanceSheetPDF)
 	r.GET(fycha.ReportsCashFlowURL, m.cashFlow)
 	r.GET(fycha.ReportsEquityChangesURL, m.equityChanges)
 }
@@ -0,0 +1,296 @@
+// Package journal_preview provides the posting preview page
+// (/app/ledger/journals/preview).
+//
+// The page dry-runs the auto-posting rules against the accounting events
+// recorded in a date range and shows the journal entries they would create,
+// with a balance badge per entry. Accountants approve all or some of the
+// balanced entries; approved events are created as draft journal entries
+// through eventbus.JournalPoster.CreateDraft and can then be reviewed and
+// posted from the draft journal list. Events that already have a journal
+// entry, posted by the live bus or approved earlier, are shown but cannot be
+// approved again.
+package journal_preview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	pyeza "github.com/erniealice/pyeza-golang"
+	"github.com/erniealice/pyeza-golang/types"
+	"github.com/erniealice/pyeza-golang/view"
+
+	fycha "github.com/erniealice/fycha-golang"
+	"github.com/erniealice/fycha-golang/eventbus"
+	reports "github.com/erniealice/fycha-golang/views/reports"
+)
+
+// ---------------------------------------------------------------------------
+// View dependencies + page data
+// ---------------------------------------------------------------------------
+
+// Deps holds view dependencies.
+type Deps struct {
+	Routes       fycha.JournalRoutes
+	Labels       fycha.JournalLabels
+	CommonLabels pyeza.CommonLabels
+	TableLabels  types.TableLabels
+
+	// Poster evaluates and creates the previewed entries. Wire the same
+	// JournalPoster the app registers on its event bus so the preview uses
+	// the same rules and workspace overrides.
+	Poster *eventbus.JournalPoster
+
+	// ListEvents returns the accounting events recorded between startDate
+	// and endDate (inclusive, YYYY-MM-DD) for the current workspace.
+	ListEvents func(ctx context.Context, startDate, endDate string) ([]eventbus.Event, error)
+}
+
+// PageData holds the data for the posting preview page.
+type PageData struct {
+	types.PageData
+	ContentTemplate string
+	Labels          fycha.JournalPreviewLabels
+	LineLabels      fycha.JournalLineLabels
+	StartDate       string
+	EndDate         string
+	PreviewURL      string
+	ApproveURL      string
+	Entries         []EntryRow
+	ApprovableCount int
+	NotWired        bool
+	CanApprove      bool
+}
+
+// EntryRow is the view-model for one previewed event.
+type EntryRow struct {
+	Key           string
+	EventType     string
+	SourceID      string
+	Description   string
+	EntryDate     string
+	Lines         []LineRow
+	TotalDebit    string
+	TotalCredit   string
+	Balanced      bool
+	IsReversal    bool
+	AlreadyPosted bool
+	Message       string // evaluation error, reversal note or existing entry
+	Approvable    bool
+}
+
+// LineRow is the view-model for one previewed journal line.
+type LineRow struct {
+	AccountID string
+	Memo      string
+	Debit     string
+	Credit    string
+}
+
+const dateLayout = "2006-01-02"
+
+// ---------------------------------------------------------------------------
+// Views
+// ---------------------------------------------------------------------------
+
+// NewView creates the posting preview page (full page).
+// Query params: start, end (YYYY-MM-DD). Defaults to the last 7 days.
+func NewView(deps *Deps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		startDate, endDate := dateRange(viewCtx.QueryParams["start"], viewCtx.QueryParams["end"])
+		perms := view.GetUserPermissions(ctx)
+
+		pageData := &PageData{
+			PageData: types.PageData{
+				CacheVersion:   viewCtx.CacheVersion,
+				Title:          deps.Labels.Preview.Heading,
+				CurrentPath:    viewCtx.CurrentPath,
+				ActiveNav:      deps.Routes.ActiveNav,
+				ActiveSubNav:   "journals-preview",
+				HeaderTitle:    deps.Labels.Preview.Heading,
+				HeaderSubtitle: deps.Labels.Preview.Subtitle,
+				HeaderIcon:     "icon-eye",
+				CommonLabels:   deps.CommonLabels,
+			},
+			ContentTemplate: "journal-preview-content",
+			Labels:          deps.Labels.Preview,
+			LineLabels:      deps.Labels.Lines,
+			StartDate:       startDate,
+			EndDate:         endDate,
+			PreviewURL:      deps.Routes.PreviewURL,
+			ApproveURL:      deps.Routes.PreviewApproveURL,
+			CanApprove:      perms.Can("journal", "create"),
+		}
+
+		previews, err := loadPreviews(ctx, deps, startDate, endDate)
+		if err != nil {
+			log.Printf("posting preview %s..%s: %v", startDate, endDate, err)
+			pageData.NotWired = true
+			return view.OK("journal-preview", pageData)
+		}
+
+		pageData.Entries, pageData.ApprovableCount = toEntryRows(previews)
+		return view.OK("journal-preview", pageData)
+	})
+}
+
+// NewApproveAction creates the approve action (POST only).
+// Form fields: start, end, and either all=1 or one event_key per checked entry.
+// The events are re-read and re-evaluated so only entries that are still
+// balanced under the current rules are created, as drafts.
+func NewApproveAction(deps *Deps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can("journal", "create") {
+			return view.Error(fmt.Errorf("permission denied"))
+		}
+
+		if err := viewCtx.Request.ParseForm(); err != nil {
+			return fycha.HTMXError(deps.Labels.Preview.InvalidRequest)
+		}
+		form := viewCtx.Request.Form
+		startDate, endDate := dateRange(form.Get("start"), form.Get("end"))
+		approveAll := form.Get("all") == "1"
+		selected := make(map[string]bool, len(form["event_key"]))
+		for _, key := range form["event_key"] {
+			selected[key] = true
+		}
+		if !approveAll && len(selected) == 0 {
+			return fycha.HTMXError(deps.Labels.Preview.NothingChosen)
+		}
+
+		previews, err := loadPreviews(ctx, deps, startDate, endDate)
+		if err != nil {
+			log.Printf("posting preview approve %s..%s: %v", startDate, endDate, err)
+			return fycha.HTMXError(deps.Labels.Preview.NotWired)
+		}
+
+		created, failed, posted := 0, 0, 0
+		for _, pv := range previews {
+			if !approveAll && selected[pv.Key()] && pv.AlreadyPosted {
+				// Posted since the page was rendered.
+				posted++
+				continue
+			}
+			if !approvable(pv) || !(approveAll || selected[pv.Key()]) {
+				continue
+			}
+			id, err := deps.Poster.CreateDraft(ctx, pv.Event)
+			if errors.Is(err, eventbus.ErrAlreadyPosted) {
+				// Posted since the preview was evaluated; nothing to do.
+				log.Printf("posting preview: %s source=%s already has journal entry %s", pv.Event.Type, pv.Event.SourceID, id)
+				posted++
+				continue
+			}
+			if err != nil {
+				log.Printf("posting preview: creating draft for %s source=%s: %v", pv.Event.Type, pv.Event.SourceID, err)
+				failed++
+				continue
+			}
+			log.Printf("posting preview: %s source=%s → draft journal entry %s", pv.Event.Type, pv.Event.SourceID, id)
+			created++
+		}
+
+		if failed > 0 {
+			return fycha.HTMXError(fmt.Sprintf("%s (%d of %d)", deps.Labels.Preview.ApproveError, failed, created+failed))
+		}
+		if created == 0 && posted > 0 {
+			return fycha.HTMXError(deps.Labels.Preview.AllPosted)
+		}
+		if created == 0 {
+			return fycha.HTMXError(deps.Labels.Preview.NothingChosen)
+		}
+		return fycha.HTMXSuccess("journal-preview-table")
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Helpers
+// ---------------------------------------------------------------------------
+
+// loadPreviews lists the events in range and dry-runs them.
+func loadPreviews(ctx context.Context, deps *Deps, startDate, endDate string) ([]eventbus.PostingPreview, error) {
+	if deps.Poster == nil || deps.ListEvents == nil {
+		return nil, fmt.Errorf("JournalPoster or ListEvents not wired")
+	}
+	events, err := deps.ListEvents(ctx, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	return deps.Poster.Preview(ctx, events), nil
+}
+
+// dateRange validates the requested range, defaulting to the last 7 days.
+func dateRange(start, end string) (string, string) {
+	now := time.Now()
+	if _, err := time.Parse(dateLayout, end); err != nil {
+		end = now.Format(dateLayout)
+	}
+	if _, err := time.Parse(dateLayout, start); err != nil {
+		start = now.AddDate(0, 0, -7).Format(dateLayout)
+	}
+	return start, end
+}
+
+// toEntryRows builds the entry rows and counts the approvable ones.
+func toEntryRows(previews []eventbus.PostingPreview) ([]EntryRow, int) {
+	rows := make([]EntryRow, 0, len(previews))
+	approvableCount := 0
+	for _, pv := range previews {
+		row := toEntryRow(pv)
+		if row.Approvable {
+			approvableCount++
+		}
+		rows = append(rows, row)
+	}
+	return rows, approvableCount
+}
+
+func toEntryRow(pv eventbus.PostingPreview) EntryRow {
+	_, isReversal := eventbus.OriginalEventType(pv.Event.Type)
+	row := EntryRow{
+		Key:           pv.Key(),
+		EventType:     pv.Event.Type,
+		SourceID:      pv.Event.SourceID,
+		TotalDebit:    formatPeso(pv.Debit),
+		TotalCredit:   formatPeso(pv.Credit),
+		Balanced:      pv.Balanced,
+		IsReversal:    isReversal,
+		AlreadyPosted: pv.AlreadyPosted,
+		Message:       pv.Note,
+		Approvable:    approvable(pv),
+	}
+	if pv.Err != nil {
+		row.Message = pv.Err.Error()
+	}
+	if pv.Entry != nil {
+		row.Description = pv.Entry.Description
+		row.EntryDate = pv.Entry.EntryDate.Format(dateLayout)
+		row.Lines = make([]LineRow, len(pv.Entry.Lines))
+		for i, l := range pv.Entry.Lines {
+			row.Lines[i] = LineRow{AccountID: l.AccountID, Memo: l.Memo}
+			if l.Debit != 0 {
+				row.Lines[i].Debit = formatPeso(l.Debit)
+			}
+			if l.Credit != 0 {
+				row.Lines[i].Credit = formatPeso(l.Credit)
+			}
+		}
+	}
+	return row
+}
+
+// approvable reports whether pv can be created as a draft. Reversal events
+// are shown for review only; they apply when published on the bus. Events
+// that already have an entry fail pv.OK.
+func approvable(pv eventbus.PostingPreview) bool {
+	_, isReversal := eventbus.OriginalEventType(pv.Event.Type)
+	return pv.OK() && !isReversal
+}
+
+// formatPeso formats centavos as a peso amount (e.g. "₱1,500.50").
+func formatPeso(centavos int64) string {
+	return reports.FormatCurrency(float64(centavos) / 100.0)
+}
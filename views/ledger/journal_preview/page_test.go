@@ -0,0 +1,175 @@
+package journal_preview
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/erniealice/pyeza-golang/types"
+	"github.com/erniealice/pyeza-golang/view"
+
+	jepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/ledger/journal_entry"
+
+	fycha "github.com/erniealice/fycha-golang"
+	"github.com/erniealice/fycha-golang/eventbus"
+)
+
+var revenuePayload = map[string]any{"amount": 1000.0, "account_ar": "ar", "account_rev": "rev"}
+
+// testDeps returns Deps whose poster records created entries in *created and
+// whose ListEvents returns events.
+func testDeps(events []eventbus.Event, created *[]string) *Deps {
+	store := eventbus.NewMemoryProcessedStore()
+	poster := eventbus.NewJournalPoster(&eventbus.JournalPosterDeps{
+		CreateJournalEntry: func(ctx context.Context, req *jepb.CreateJournalEntryRequest) (*jepb.CreateJournalEntryResponse, error) {
+			entry := req.GetData()
+			entry.Id = fmt.Sprintf("je-%d", len(*created)+1)
+			*created = append(*created, entry.Id)
+			return &jepb.CreateJournalEntryResponse{Success: true, Data: []*jepb.JournalEntry{entry}}, nil
+		},
+		CreateLines: func(ctx context.Context, journalEntryID string, lines []eventbus.JournalLine) error {
+			return nil
+		},
+		Processed: store,
+		Links:     store,
+	})
+	return &Deps{
+		Routes: fycha.DefaultJournalRoutes(),
+		Labels: fycha.DefaultJournalLabels(),
+		Poster: poster,
+		ListEvents: func(ctx context.Context, startDate, endDate string) ([]eventbus.Event, error) {
+			return events, nil
+		},
+	}
+}
+
+// ctxWithPerms returns a context with the given permission codes.
+func ctxWithPerms(codes ...string) context.Context {
+	return view.WithUserPermissions(context.Background(), types.NewUserPermissions(codes))
+}
+
+// approveRequest builds the approve POST for the given form body.
+func approveRequest(body string) *view.ViewContext {
+	req := httptest.NewRequest(http.MethodPost, "/action/ledger/journals/preview/approve", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return &view.ViewContext{Request: req}
+}
+
+func TestNewView_RendersPreviews(t *testing.T) {
+	t.Parallel()
+
+	var created []string
+	events := []eventbus.Event{
+		{ID: "e1", Type: eventbus.EventTypeRevenueCompleted, SourceID: "rev-1", Payload: revenuePayload},
+		{ID: "e2", Type: eventbus.EventTypeLoanReceived, SourceID: "loan-1", Payload: map[string]any{}},
+	}
+	deps := testDeps(events, &created)
+
+	req := httptest.NewRequest(http.MethodGet, "/app/ledger/journals/preview", nil)
+	result := NewView(deps).Handle(ctxWithPerms("journal:create"), &view.ViewContext{
+		Request:     req,
+		QueryParams: map[string]string{"start": "2026-04-01", "end": "2026-04-30"},
+	})
+	if result.Template != "journal-preview" {
+		t.Errorf("template = %q, want %q", result.Template, "journal-preview")
+	}
+	if len(created) != 0 {
+		t.Errorf("preview created %d entries", len(created))
+	}
+
+	rows, approvable := toEntryRows(deps.Poster.Preview(context.Background(), events))
+	if len(rows) != 2 || approvable != 1 {
+		t.Fatalf("rows = %d approvable = %d, want 2, 1", len(rows), approvable)
+	}
+	if r := rows[0]; !r.Approvable || !r.Balanced || r.TotalDebit != "₱1,000.00" || len(r.Lines) != 2 {
+		t.Errorf("balanced row = %+v", r)
+	}
+	if r := rows[1]; r.Approvable || r.Message == "" {
+		t.Errorf("invalid row = %+v, want an error message and no checkbox", r)
+	}
+}
+
+func TestNewApproveAction_Selected(t *testing.T) {
+	t.Parallel()
+
+	var created []string
+	deps := testDeps([]eventbus.Event{
+		{ID: "e1", Type: eventbus.EventTypeRevenueCompleted, SourceID: "rev-1", Payload: revenuePayload},
+		{ID: "e2", Type: eventbus.EventTypeRevenueCompleted, SourceID: "rev-2", Payload: revenuePayload},
+	}, &created)
+
+	form := url.Values{"event_key": {"e2"}}
+	result := NewApproveAction(deps).Handle(ctxWithPerms("journal:create"), approveRequest(form.Encode()))
+
+	if result.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want %d (%s)", result.StatusCode, http.StatusOK, result.Headers["HX-Error-Message"])
+	}
+	if len(created) != 1 {
+		t.Fatalf("created entries = %d, want 1", len(created))
+	}
+	pv := deps.Poster.Preview(context.Background(), []eventbus.Event{
+		{ID: "e2", Type: eventbus.EventTypeRevenueCompleted, SourceID: "rev-2", Payload: revenuePayload},
+	})[0]
+	if !pv.AlreadyPosted || pv.ExistingEntryID != created[0] {
+		t.Errorf("approved event preview = %+v, want already posted as %s", pv, created[0])
+	}
+}
+
+func TestNewApproveAction_Repeat(t *testing.T) {
+	t.Parallel()
+
+	var created []string
+	deps := testDeps([]eventbus.Event{
+		{ID: "e1", Type: eventbus.EventTypeRevenueCompleted, SourceID: "rev-1", Payload: revenuePayload},
+	}, &created)
+	action := NewApproveAction(deps)
+
+	first := action.Handle(ctxWithPerms("journal:create"), approveRequest("all=1"))
+	if first.StatusCode != http.StatusOK {
+		t.Fatalf("first approval status = %d (%s)", first.StatusCode, first.Headers["HX-Error-Message"])
+	}
+
+	for body, want := range map[string]string{
+		"all=1":        deps.Labels.Preview.NothingChosen,
+		"event_key=e1": deps.Labels.Preview.AllPosted,
+	} {
+		result := action.Handle(ctxWithPerms("journal:create"), approveRequest(body))
+		if result.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("%s: status = %d, want %d", body, result.StatusCode, http.StatusUnprocessableEntity)
+		}
+		if got := result.Headers["HX-Error-Message"]; got != want {
+			t.Errorf("%s: HX-Error-Message = %q, want %q", body, got, want)
+		}
+	}
+	if len(created) != 1 {
+		t.Errorf("created entries = %d, want 1 (repeat approval must not duplicate)", len(created))
+	}
+}
+
+func TestNewApproveAction_MalformedForm(t *testing.T) {
+	t.Parallel()
+
+	var created []string
+	deps := testDeps(nil, &created)
+
+	result := NewApproveAction(deps).Handle(ctxWithPerms("journal:create"), approveRequest("event_key=%zz"))
+	if got := result.Headers["HX-Error-Message"]; got != deps.Labels.Preview.InvalidRequest {
+		t.Errorf("HX-Error-Message = %q, want %q", got, deps.Labels.Preview.InvalidRequest)
+	}
+}
+
+func TestNewApproveAction_PermissionDenied(t *testing.T) {
+	t.Parallel()
+
+	var created []string
+	deps := testDeps(nil, &created)
+
+	result := NewApproveAction(deps).Handle(ctxWithPerms(), approveRequest("all=1"))
+	if result.Error == nil {
+		t.Fatal("expected error for permission denied")
+	}
+}
@@ -9,12 +9,15 @@ import (
 	"github.com/erniealice/pyeza-golang/view"
 
 	fycha "github.com/erniealice/fycha-golang"
+	"github.com/erniealice/fycha-golang/eventbus"
+	"github.com/erniealice/fycha-golang/services/pdfreport"
 	accountaction "github.com/erniealice/fycha-golang/views/ledger/action"
 	accountdetail "github.com/erniealice/fycha-golang/views/ledger/detail"
 	fiscalview "github.com/erniealice/fycha-golang/views/ledger/fiscal"
 	journalview "github.com/erniealice/fycha-golang/views/ledger/journal"
 	journalactionview "github.com/erniealice/fycha-golang/views/ledger/journal_action"
 	journaldetailview "github.com/erniealice/fycha-golang/views/ledger/journal_detail"
+	journalpreviewview "github.com/erniealice/fycha-golang/views/ledger/journal_preview"
 	accountlist "github.com/erniealice/fycha-golang/views/ledger/list"
 	recurringview "github.com/erniealice/fycha-golang/views/ledger/recurring"
 	ledgerreports "github.com/erniealice/fycha-golang/views/ledger/reports"
@@ -60,6 +63,10 @@ type ModuleDeps struct {
 	GetGeneralLedger func(ctx context.Context, accountID, startDate, endDate string) (*ledgerreports.GLAccountSection, error)
 	GetTrialBalance  func(ctx context.Context, asOfDate string) ([]ledgerreports.TBAccountRow, error)
 
+	// Letterhead and Signatures for the GL and Trial Balance PDF exports
+	Letterhead pdfreport.Letterhead
+	Signatures []pdfreport.Signature
+
 	// Journal Entry use cases (Phase 3)
 	GetJournalEntryListPageData func(ctx context.Context, req *journalentrypb.GetJournalEntryListPageDataRequest) (*journalentrypb.GetJournalEntryListPageDataResponse, error)
 	GetJournalEntryItemPageData func(ctx context.Context, req *journalentrypb.GetJournalEntryItemPageDataRequest) (*journalentrypb.GetJournalEntryItemPageDataResponse, error)
@@ -70,6 +77,12 @@ type ModuleDeps struct {
 	PostJournalEntry            func(ctx context.Context, req *journalentrypb.PostJournalEntryRequest) (*journalentrypb.PostJournalEntryResponse, error)
 	ReverseJournalEntry         func(ctx context.Context, req *journalentrypb.ReverseJournalEntryRequest) (*journalentrypb.ReverseJournalEntryResponse, error)
 
+	// Posting preview (nil-safe — the page shows a not-configured notice).
+	// JournalPoster should be the poster registered on the app's event bus;
+	// ListAccountingEvents returns the events recorded in a date range.
+	JournalPoster        *eventbus.JournalPoster
+	ListAccountingEvents func(ctx context.Context, startDate, endDate string) ([]eventbus.Event, error)
+
 	// FiscalPeriod use cases (Phase 3; nil-safe — falls back to mock data)
 	GetFiscalPeriodListPageData func(ctx context.Context) ([]*fiscalperiodpb.FiscalPeriod, error)
 	CreateFiscalPeriod          func(ctx context.Context, req *fiscalperiodpb.CreateFiscalPeriodRequest) (*fiscalperiodpb.CreateFiscalPeriodResponse, error)
@@ -107,6 +120,10 @@ type Module struct {
 	GeneralLedger view.View
 	TrialBalance  view.View
 
+	// Ledger statement PDF exports
+	generalLedgerPDFHandler http.HandlerFunc
+	trialBalancePDFHandler  http.HandlerFunc
+
 	// Journal Entry views (Phase 3)
 	JournalList    view.View
 	JournalDetail  view.View
@@ -116,6 +133,10 @@ type Module struct {
 	JournalReverse view.View
 	JournalDelete  view.View
 
+	// Posting preview (dry-run of auto-posting rules + batch approval)
+	JournalPreview        view.View
+	JournalPreviewApprove view.View
+
 	// FiscalPeriod views (Phase 3)
 	FiscalPeriodList  view.View
 	FiscalPeriodAdd   view.View
@@ -170,6 +191,8 @@ func NewModule(deps *ModuleDeps) *Module {
 		CommonLabels:     deps.CommonLabels,
 		TableLabels:      deps.TableLabels,
 		GetGeneralLedger: deps.GetGeneralLedger,
+		Letterhead:       deps.Letterhead,
+		Signatures:       deps.Signatures,
 	}
 	tbDeps := &ledgerreports.TrialBalanceDeps{
 		Routes:          statementRoutes,
@@ -177,6 +200,8 @@ func NewModule(deps *ModuleDeps) *Module {
 		CommonLabels:    deps.CommonLabels,
 		TableLabels:     deps.TableLabels,
 		GetTrialBalance: deps.GetTrialBalance,
+		Letterhead:      deps.Letterhead,
+		Signatures:      deps.Signatures,
 	}
 
 	journalListDeps := &journalview.Deps{
@@ -205,6 +230,15 @@ func NewModule(deps *ModuleDeps) *Module {
 		GetJournalEntryItemPageData: deps.GetJournalEntryItemPageData,
 	}
 
+	journalPreviewDeps := &journalpreviewview.Deps{
+		Routes:       deps.JournalRoutes,
+		Labels:       deps.JournalLabels,
+		CommonLabels: deps.CommonLabels,
+		TableLabels:  deps.TableLabels,
+		Poster:       deps.JournalPoster,
+		ListEvents:   deps.ListAccountingEvents,
+	}
+
 	fiscalDeps := &fiscalview.Deps{
 		Routes:                      deps.FiscalPeriodRoutes,
 		Labels:                      deps.FiscalPeriodLabels,
@@ -256,6 +290,8 @@ func NewModule(deps *ModuleDeps) *Module {
 		AccountTemplatesApply:   accountaction.NewApplyTemplateAction(actionDeps),
 		GeneralLedger:           ledgerreports.NewGeneralLedgerView(glDeps),
 		TrialBalance:            ledgerreports.NewTrialBalanceView(tbDeps),
+		generalLedgerPDFHandler: ledgerreports.NewGeneralLedgerPDFHandler(glDeps),
+		trialBalancePDFHandler:  ledgerreports.NewTrialBalancePDFHandler(tbDeps),
 
 		JournalList:    journalview.NewView(journalListDeps),
 		JournalDetail:  journaldetailview.NewView(journalDetailDeps),
@@ -265,6 +301,9 @@ func NewModule(deps *ModuleDeps) *Module {
 		JournalReverse: journalactionview.NewReverseAction(journalActionDeps),
 		JournalDelete:  journalactionview.NewDeleteAction(journalActionDeps),
 
+		JournalPreview:        journalpreviewview.NewView(journalPreviewDeps),
+		JournalPreviewApprove: journalpreviewview.NewApproveAction(journalPreviewDeps),
+
 		FiscalPeriodList:  fiscalview.NewView(fiscalDeps),
 		FiscalPeriodAdd:   fiscalview.NewAddAction(fiscalActionDeps),
 		FiscalPeriodClose: fiscalview.NewCloseAction(fiscalActionDeps),
@@ -312,10 +351,17 @@ func (m *Module) RegisterRoutes(r view.RouteRegistrar) {
 	r.POST(m.journalRoutes.PostURL, m.JournalPost)
 	r.POST(m.journalRoutes.ReverseURL, m.JournalReverse)
 	r.POST(m.journalRoutes.DeleteURL, m.JournalDelete)
+	// Posting preview — dry-run auto-posting, approve into drafts
+	r.GET(m.journalRoutes.PreviewURL, m.JournalPreview)
+	r.POST(m.journalRoutes.PreviewApproveURL, m.JournalPreviewApprove)
 
 	// Reports — Phase 3: real views with mock data
 	r.GET(m.statementRoutes.GeneralLedgerURL, m.GeneralLedger)
 	r.GET(m.statementRoutes.TrialBalanceURL, m.TrialBalance)
+	if full, ok := r.(routeRegistrarFull); ok {
+		full.HandleFunc("GET", m.statementRoutes.GeneralLedgerPDFURL, m.generalLedgerPDFHandler)
+		full.HandleFunc("GET", m.statementRoutes.TrialBalancePDFURL, m.trialBalancePDFHandler)
+	}
 
 	// Settings — Account Templates: real view
 	r.GET(m.routes.TemplatesURL, m.AccountTemplates)
@@ -6,6 +6,7 @@ package reports
 import (
 	"context"
 	"fmt"
+	"net/url"
 	"time"
 
 	pyeza "github.com/erniealice/pyeza-golang"
@@ -13,6 +14,7 @@ import (
 	"github.com/erniealice/pyeza-golang/view"
 
 	fycha "github.com/erniealice/fycha-golang"
+	"github.com/erniealice/fycha-golang/services/pdfreport"
 )
 
 // ---------------------------------------------------------------------------
@@ -58,6 +60,12 @@ type GeneralLedgerDeps struct {
 	CommonLabels pyeza.CommonLabels
 	TableLabels  types.TableLabels
 
+	// Letterhead heads every page of the PDF export, and Signatures close
+	// it. Nil Signatures print blank Prepared, Reviewed and Approved by
+	// blocks.
+	Letterhead pdfreport.Letterhead
+	Signatures []pdfreport.Signature
+
 	// GetGeneralLedger fetches journal lines for a given account and date range.
 	// Returning nil, nil means "no data" (renders empty state, not error).
 	// Phase 3: set to nil → mock data is used automatically.
@@ -77,7 +85,8 @@ type GeneralLedgerPageData struct {
 	EndDate     string
 
 	// Report state
-	HasData        bool // false when no account selected or no results
+	HasData        bool   // false when no account selected or no results
+	PDFURL         string // PDF export for the same account and dates
 	Section        *GLAccountSection
 	SummaryMetrics []fycha.SummaryMetric
 	Table          *types.TableConfig
@@ -96,17 +105,7 @@ func NewGeneralLedgerView(deps *GeneralLedgerDeps) view.View {
 		q := viewCtx.QueryParams
 
 		accountID := q["account_id"]
-		startDate := q["start"]
-		endDate := q["end"]
-
-		// Default date range: first day of current month → today
-		if startDate == "" {
-			now := time.Now()
-			startDate = fmt.Sprintf("%d-%02d-01", now.Year(), now.Month())
-		}
-		if endDate == "" {
-			endDate = time.Now().Format("2006-01-02")
-		}
+		startDate, endDate := resolveDateRange(q["start"], q["end"])
 
 		pageData := &GeneralLedgerPageData{
 			PageData: types.PageData{
@@ -135,22 +134,13 @@ func NewGeneralLedgerView(deps *GeneralLedgerDeps) view.View {
 			return view.OK("general-ledger", pageData)
 		}
 
-		// Fetch data (Phase 3: use mock if no real use case wired)
-		var section *GLAccountSection
-		if deps.GetGeneralLedger != nil {
-			s, err := deps.GetGeneralLedger(ctx, accountID, startDate, endDate)
-			if err == nil {
-				section = s
-			}
-		}
-		if section == nil {
-			section = mockGLSection(accountID, startDate, endDate)
-		}
+		section := loadGLSection(ctx, deps, accountID, startDate, endDate)
 
 		pageData.HasData = true
 		pageData.Section = section
 		pageData.AccountCode = section.AccountCode
 		pageData.AccountName = section.AccountName
+		pageData.PDFURL = buildGLPDFURL(deps.Routes.GeneralLedgerPDFURL, accountID, startDate, endDate)
 		pageData.SummaryMetrics = buildGLSummary(section, deps.Labels)
 		pageData.Table = buildGLTable(section, deps.TableLabels, deps.Labels)
 
@@ -161,6 +151,43 @@ func NewGeneralLedgerView(deps *GeneralLedgerDeps) view.View {
 	})
 }
 
+// resolveDateRange defaults the start date to the first day of the current
+// month and the end date to today.
+func resolveDateRange(startDate, endDate string) (string, string) {
+	if startDate == "" {
+		now := time.Now()
+		startDate = fmt.Sprintf("%d-%02d-01", now.Year(), now.Month())
+	}
+	if endDate == "" {
+		endDate = time.Now().Format("2006-01-02")
+	}
+	return startDate, endDate
+}
+
+// loadGLSection fetches the account's lines (Phase 3: uses mock if no real
+// use case is wired).
+func loadGLSection(ctx context.Context, deps *GeneralLedgerDeps, accountID, startDate, endDate string) *GLAccountSection {
+	var section *GLAccountSection
+	if deps.GetGeneralLedger != nil {
+		s, err := deps.GetGeneralLedger(ctx, accountID, startDate, endDate)
+		if err == nil {
+			section = s
+		}
+	}
+	if section == nil {
+		section = mockGLSection(accountID, startDate, endDate)
+	}
+	return section
+}
+
+func buildGLPDFURL(base, accountID, startDate, endDate string) string {
+	params := url.Values{}
+	params.Set("account_id", accountID)
+	params.Set("start", startDate)
+	params.Set("end", endDate)
+	return base + "?" + params.Encode()
+}
+
 // ---------------------------------------------------------------------------
 // Summary bar
 // ---------------------------------------------------------------------------
@@ -189,30 +216,7 @@ func buildGLTable(s *GLAccountSection, tableLabels types.TableLabels, labels fyc
 
 	rows := make([]types.TableRow, 0, len(s.Lines))
 	for i, line := range s.Lines {
-		debitVal := ""
-		creditVal := ""
-		balanceVal := ""
-
-		if !line.IsSpecialRow || line.SpecialRowType == "opening" || line.SpecialRowType == "closing" {
-			if line.RunningBalance != 0 {
-				balanceVal = formatCurrencyGL(line.RunningBalance)
-			}
-		}
-		if line.SpecialRowType == "totals" {
-			if line.Debit != 0 {
-				debitVal = formatCurrencyGL(line.Debit)
-			}
-			if line.Credit != 0 {
-				creditVal = formatCurrencyGL(line.Credit)
-			}
-		} else {
-			if line.Debit > 0 {
-				debitVal = formatCurrencyGL(line.Debit)
-			}
-			if line.Credit > 0 {
-				creditVal = formatCurrencyGL(line.Credit)
-			}
-		}
+		debitVal, creditVal, balanceVal := glAmounts(line)
 
 		entryCell := types.TableCell{Type: "text", Value: line.EntryNumber}
 		if line.EntryDetailURL != "" {
@@ -257,6 +261,32 @@ func buildGLTable(s *GLAccountSection, tableLabels types.TableLabels, labels fyc
 	}
 }
 
+// glAmounts formats the debit, credit and running balance of a line, blank
+// where the table leaves them empty.
+func glAmounts(line GLLine) (debit, credit, balance string) {
+	if !line.IsSpecialRow || line.SpecialRowType == "opening" || line.SpecialRowType == "closing" {
+		if line.RunningBalance != 0 {
+			balance = formatCurrencyGL(line.RunningBalance)
+		}
+	}
+	if line.SpecialRowType == "totals" {
+		if line.Debit != 0 {
+			debit = formatCurrencyGL(line.Debit)
+		}
+		if line.Credit != 0 {
+			credit = formatCurrencyGL(line.Credit)
+		}
+	} else {
+		if line.Debit > 0 {
+			debit = formatCurrencyGL(line.Debit)
+		}
+		if line.Credit > 0 {
+			credit = formatCurrencyGL(line.Credit)
+		}
+	}
+	return debit, credit, balance
+}
+
 // ---------------------------------------------------------------------------
 // Mock data (Phase 3)
 // ---------------------------------------------------------------------------
@@ -3,6 +3,7 @@ package reports
 import (
 	"context"
 	"fmt"
+	"net/url"
 	"time"
 
 	pyeza "github.com/erniealice/pyeza-golang"
@@ -10,6 +11,7 @@ import (
 	"github.com/erniealice/pyeza-golang/view"
 
 	fycha "github.com/erniealice/fycha-golang"
+	"github.com/erniealice/fycha-golang/services/pdfreport"
 )
 
 // ---------------------------------------------------------------------------
@@ -58,6 +60,12 @@ type TrialBalanceDeps struct {
 	CommonLabels pyeza.CommonLabels
 	TableLabels  types.TableLabels
 
+	// Letterhead heads every page of the PDF export, and Signatures close
+	// it. Nil Signatures print blank Prepared, Reviewed and Approved by
+	// blocks.
+	Letterhead pdfreport.Letterhead
+	Signatures []pdfreport.Signature
+
 	// GetTrialBalance fetches account balances as of the given date.
 	// Returning nil, nil means "no data" — mock data is used automatically.
 	// Phase 3: set to nil to rely on mock data.
@@ -71,6 +79,7 @@ type TrialBalancePageData struct {
 
 	// Filter state
 	AsOfDate string
+	PDFURL   string // PDF export for the same date
 
 	// Report data
 	HasData bool
@@ -89,45 +98,27 @@ type TrialBalancePageData struct {
 // NewTrialBalanceView creates the Trial Balance report view.
 func NewTrialBalanceView(deps *TrialBalanceDeps) view.View {
 	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
-		q := viewCtx.QueryParams
-
-		asOfDate := q["as_of"]
-		if asOfDate == "" {
-			// Default: last day of the current month
-			now := time.Now()
-			// First day of next month minus one day = last day of current month
-			lastDay := time.Date(now.Year(), now.Month()+1, 0, 0, 0, 0, 0, time.UTC)
-			asOfDate = lastDay.Format("2006-01-02")
-		}
+		asOfDate := resolveAsOfDate(viewCtx.QueryParams["as_of"])
 
 		pageData := &TrialBalancePageData{
 			PageData: types.PageData{
 				CacheVersion:   viewCtx.CacheVersion,
-				Title:          "Trial Balance",
+				Title:          deps.Labels.TrialBalance.Title,
 				CurrentPath:    viewCtx.CurrentPath,
 				ActiveNav:      deps.Routes.ActiveNav,
 				ActiveSubNav:   "trial-balance",
-				HeaderTitle:    "Trial Balance",
+				HeaderTitle:    deps.Labels.TrialBalance.Title,
 				HeaderSubtitle: "Verify that total debits equal total credits",
 				HeaderIcon:     "icon-check-square",
 				CommonLabels:   deps.CommonLabels,
 			},
 			ContentTemplate: "trial-balance-content",
 			AsOfDate:        asOfDate,
+			PDFURL:          deps.Routes.TrialBalancePDFURL + "?as_of=" + url.QueryEscape(asOfDate),
 			Labels:          deps.Labels,
 		}
 
-		// Fetch data (Phase 3: fall back to mock if no real use case wired)
-		var accounts []TBAccountRow
-		if deps.GetTrialBalance != nil {
-			rows, err := deps.GetTrialBalance(ctx, asOfDate)
-			if err == nil {
-				accounts = rows
-			}
-		}
-		if accounts == nil {
-			accounts = mockTBAccounts()
-		}
+		accounts := loadTBAccounts(ctx, deps, asOfDate)
 
 		if len(accounts) > 0 {
 			pageData.HasData = true
@@ -143,6 +134,34 @@ func NewTrialBalanceView(deps *TrialBalanceDeps) view.View {
 	})
 }
 
+// resolveAsOfDate defaults an empty as_of query param to the last day of
+// the current month.
+func resolveAsOfDate(asOfDate string) string {
+	if asOfDate == "" {
+		now := time.Now()
+		// First day of next month minus one day = last day of current month
+		lastDay := time.Date(now.Year(), now.Month()+1, 0, 0, 0, 0, 0, time.UTC)
+		asOfDate = lastDay.Format("2006-01-02")
+	}
+	return asOfDate
+}
+
+// loadTBAccounts fetches the account balances (Phase 3: falls back to mock
+// if no real use case is wired).
+func loadTBAccounts(ctx context.Context, deps *TrialBalanceDeps, asOfDate string) []TBAccountRow {
+	var accounts []TBAccountRow
+	if deps.GetTrialBalance != nil {
+		rows, err := deps.GetTrialBalance(ctx, asOfDate)
+		if err == nil {
+			accounts = rows
+		}
+	}
+	if accounts == nil {
+		accounts = mockTBAccounts()
+	}
+	return accounts
+}
+
 // ---------------------------------------------------------------------------
 // Group and totals builders
 // ---------------------------------------------------------------------------
@@ -0,0 +1,102 @@
+package reports
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/erniealice/fycha-golang/services/pdfreport"
+)
+
+// NewTrialBalancePDFHandler creates an http.HandlerFunc that renders the
+// Trial Balance as a PDF, as of the same date as the page view.
+func NewTrialBalancePDFHandler(deps *TrialBalanceDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		asOfDate := resolveAsOfDate(r.URL.Query().Get("as_of"))
+		groups := buildTBGroups(loadTBAccounts(r.Context(), deps, asOfDate))
+
+		pdf, err := pdfreport.Render(buildTBPDFReport(deps, groups, buildTBTotals(groups), asOfDate))
+		if err != nil {
+			log.Printf("trial_balance export: failed to render PDF: %v", err)
+			http.Error(w, "Failed to generate report", http.StatusInternalServerError)
+			return
+		}
+
+		filename := fmt.Sprintf("trial-balance-%s.pdf", asOfDate)
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+		w.Write(pdf)
+	}
+}
+
+// buildTBPDFReport lays out the same rows as buildTBTable: the accounts of
+// each element with their subtotal, then the grand total and the balance
+// check.
+func buildTBPDFReport(deps *TrialBalanceDeps, groups []TBElementGroup, totals TBTotals, asOfDate string) *pdfreport.Report {
+	var rows []pdfreport.Row
+	for _, g := range groups {
+		rows = append(rows, pdfreport.Row{Style: pdfreport.Heading, Cells: []string{g.Label}})
+		for _, acct := range g.Accounts {
+			rows = append(rows, pdfreport.Row{
+				Cells:  []string{acct.AccountCode, acct.AccountName, tbAmount(acct.Debit), tbAmount(acct.Credit)},
+				Indent: 1,
+			})
+		}
+		rows = append(rows,
+			pdfreport.Row{
+				Style: pdfreport.Subtotal,
+				Cells: []string{"", fmt.Sprintf("Subtotal: %s", g.Label), tbAmount(g.SubtotalDebit), tbAmount(g.SubtotalCredit)},
+			},
+			pdfreport.Row{Style: pdfreport.Blank},
+		)
+	}
+	rows = append(rows, pdfreport.Row{
+		Style: pdfreport.Total,
+		Cells: []string{"", "TOTAL", totals.TotalDebitStr, totals.TotalCreditStr},
+	})
+
+	note := fmt.Sprintf("Trial Balance is BALANCED: Total Debits = Total Credits = %s", totals.TotalDebitStr)
+	if !totals.IsBalanced {
+		note = fmt.Sprintf("Trial Balance is UNBALANCED. Debits: %s | Credits: %s | Difference: %s",
+			totals.TotalDebitStr, totals.TotalCreditStr, totals.DifferenceStr)
+	}
+
+	signatures := deps.Signatures
+	if signatures == nil {
+		signatures = pdfreport.DefaultSignatures()
+	}
+	return &pdfreport.Report{
+		Letterhead: deps.Letterhead,
+		Title:      deps.Labels.TrialBalance.Title,
+		Subtitle:   pdfreport.AsOf(deps.Labels.TrialBalance.AsOf, deps.Labels.TrialBalance.AsOfDateFormat, asOfDate),
+		Columns: []pdfreport.Column{
+			{Header: "Code", Width: 0.8},
+			{Header: "Account Name", Width: 3},
+			{Header: "Debit Balance", Width: 1.6, Align: pdfreport.Right},
+			{Header: "Credit Balance", Width: 1.6, Align: pdfreport.Right},
+		},
+		IndentColumn: 1,
+		Rows:         rows,
+		Notes:        []string{note},
+		Signatures:   signatures,
+		GeneratedAt:  time.Now(),
+	}
+}
+
+// tbAmount formats a balance, blank when zero as in the page's table.
+func tbAmount(amount float64) string {
+	if amount > 0 {
+		return formatCurrencyGL(amount)
+	}
+	return ""
+}
+
+// displayDate formats a YYYY-MM-DD date as "March 31, 2026", or returns it
+// unchanged if it does not parse.
+func displayDate(date string) string {
+	if t, err := time.Parse("2006-01-02", date); err == nil {
+		return t.Format("January 2, 2006")
+	}
+	return date
+}
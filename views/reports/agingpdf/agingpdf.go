@@ -0,0 +1,103 @@
+// Package agingpdf lays out the receivables and payables aging reports for
+// PDF export. Both reports share the page table's columns — the row
+// dimension, five age buckets, the total outstanding and the invoice
+// count — and the CSV export's totals row.
+package agingpdf
+
+import (
+	"time"
+
+	"github.com/erniealice/fycha-golang/services/pdfreport"
+)
+
+// Buckets are the amounts of a row in centavos, by age. The receivables
+// and payables aging messages both implement it.
+type Buckets interface {
+	GetCurrent() int64
+	GetDays_1_30() int64
+	GetDays_31_60() int64
+	GetDays_61_90() int64
+	GetDaysOver_90() int64
+}
+
+// Row is one line of the report.
+type Row struct {
+	Key     string
+	Buckets Buckets
+	Total   int64 // centavos outstanding
+	Count   string
+}
+
+// Report is an aging report to lay out.
+type Report struct {
+	Letterhead pdfreport.Letterhead
+	Signatures []pdfreport.Signature // default pdfreport.DefaultSignatures()
+
+	Title    string
+	Subtitle string
+
+	DimensionHeader string
+	BucketHeaders   []string // current, 1-30, 31-60, 61-90 and over 90 days
+	TotalHeader     string
+	CountHeader     string
+
+	Rows    []Row
+	Summary *Row // the totals row, omitted when nil
+
+	// FormatAmount formats an amount in pesos, as on the page.
+	FormatAmount func(float64) string
+}
+
+// Build returns the report as a landscape pdfreport.Report, so that all
+// eight columns fit.
+func Build(r *Report) *pdfreport.Report {
+	columns := []pdfreport.Column{{Header: r.DimensionHeader, Width: 2.4}}
+	for _, h := range r.BucketHeaders {
+		columns = append(columns, pdfreport.Column{Header: h, Width: 1.2, Align: pdfreport.Right})
+	}
+	columns = append(columns,
+		pdfreport.Column{Header: r.TotalHeader, Width: 1.4, Align: pdfreport.Right},
+		pdfreport.Column{Header: r.CountHeader, Width: 0.9, Align: pdfreport.Right},
+	)
+
+	var body []pdfreport.Row
+	for _, row := range r.Rows {
+		body = append(body, pdfreport.Row{Cells: r.cells(row)})
+	}
+	if r.Summary != nil {
+		body = append(body, pdfreport.Row{Style: pdfreport.Total, Cells: r.cells(*r.Summary)})
+	}
+
+	signatures := r.Signatures
+	if signatures == nil {
+		signatures = pdfreport.DefaultSignatures()
+	}
+	return &pdfreport.Report{
+		Letterhead:  r.Letterhead,
+		Title:       r.Title,
+		Subtitle:    r.Subtitle,
+		Columns:     columns,
+		Rows:        body,
+		Signatures:  signatures,
+		Landscape:   true,
+		GeneratedAt: time.Now(),
+	}
+}
+
+// cells formats one row, in the CSV export's column order.
+func (r *Report) cells(row Row) []string {
+	amounts := make([]int64, 5)
+	if b := row.Buckets; b != nil {
+		amounts = []int64{b.GetCurrent(), b.GetDays_1_30(), b.GetDays_31_60(), b.GetDays_61_90(), b.GetDaysOver_90()}
+	}
+	cells := []string{row.Key}
+	for _, amount := range amounts {
+		cells = append(cells, r.amount(amount))
+	}
+	return append(cells, r.amount(row.Total), row.Count)
+}
+
+// amount formats centavos with FormatAmount.
+func (r *Report) amount(centavos int64) string {
+	return r.FormatAmount(float64(centavos) / 100.0)
+}
@@ -0,0 +1,60 @@
+package agingpdf
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/erniealice/fycha-golang/services/pdfreport"
+)
+
+type buckets struct{ current, d30, d60, d90, over int64 }
+
+func (b *buckets) GetCurrent() int64     { return b.current }
+func (b *buckets) GetDays_1_30() int64   { return b.d30 }
+func (b *buckets) GetDays_31_60() int64  { return b.d60 }
+func (b *buckets) GetDays_61_90() int64  { return b.d90 }
+func (b *buckets) GetDaysOver_90() int64 { return b.over }
+
+func TestBuild(t *testing.T) {
+	report := Build(&Report{
+		Title:           "Receivables Aging",
+		Subtitle:        "As of March 31, 2026",
+		DimensionHeader: "Client",
+		BucketHeaders:   []string{"Current", "1-30", "31-60", "61-90", "90+"},
+		TotalHeader:     "Total",
+		CountHeader:     "Invoices",
+		Rows: []Row{
+			{Key: "Acme", Buckets: &buckets{current: 150050, over: 2000}, Total: 152050, Count: "3"},
+			{Key: "Empty", Total: 0, Count: "0"},
+		},
+		Summary:      &Row{Key: "TOTAL", Buckets: &buckets{current: 150050, over: 2000}, Total: 152050, Count: "3"},
+		FormatAmount: func(f float64) string { return fmt.Sprintf("%.2f", f) },
+	})
+
+	if len(report.Columns) != 8 || !report.Landscape {
+		t.Fatalf("columns = %d, landscape = %v; want 8, true", len(report.Columns), report.Landscape)
+	}
+	if len(report.Signatures) != len(pdfreport.DefaultSignatures()) {
+		t.Errorf("signatures = %d, want the defaults", len(report.Signatures))
+	}
+	want := []string{
+		"Acme 1500.50 0.00 0.00 0.00 20.00 1520.50 3",
+		"Empty 0.00 0.00 0.00 0.00 0.00 0.00 0",
+		"TOTAL 1500.50 0.00 0.00 0.00 20.00 1520.50 3",
+	}
+	if len(report.Rows) != len(want) {
+		t.Fatalf("rows = %d, want %d", len(report.Rows), len(want))
+	}
+	for i, row := range report.Rows {
+		if got := strings.Join(row.Cells, " "); got != want[i] {
+			t.Errorf("row %d = %q, want %q", i, got, want[i])
+		}
+	}
+	if report.Rows[2].Style != pdfreport.Total {
+		t.Errorf("summary style = %v, want Total", report.Rows[2].Style)
+	}
+	if _, err := pdfreport.Render(report); err != nil {
+		t.Errorf("Render: %v", err)
+	}
+}
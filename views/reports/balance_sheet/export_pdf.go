@@ -0,0 +1,94 @@
+package balance_sheet
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/erniealice/fycha-golang/services/pdfreport"
+)
+
+// NewPDFExportHandler creates an http.HandlerFunc that renders the balance
+// sheet as a PDF, as of the same date as the page view.
+func NewPDFExportHandler(deps *BalanceSheetDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		asOfDate := resolveAsOfDate(r.URL.Query().Get("as_of"))
+		sections := loadSections(r.Context(), deps, asOfDate)
+
+		pdf, err := pdfreport.Render(buildPDFReport(deps, sections, asOfDate))
+		if err != nil {
+			log.Printf("balance_sheet export: failed to render PDF: %v", err)
+			http.Error(w, "Failed to generate report", http.StatusInternalServerError)
+			return
+		}
+
+		filename := fmt.Sprintf("balance-sheet-%s.pdf", asOfDate)
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+		w.Write(pdf)
+	}
+}
+
+// buildPDFReport lays the statement out the way the page's table does,
+// ending with the Liabilities + Equity total and the A = L + E check.
+func buildPDFReport(deps *BalanceSheetDeps, sections []BSSection, asOfDate string) *pdfreport.Report {
+	var rows []pdfreport.Row
+	for _, s := range sections {
+		rows = append(rows, pdfreport.Row{Style: pdfreport.Heading, Cells: []string{s.Title}})
+		for _, c := range s.Classifications {
+			rows = append(rows, pdfreport.Row{Style: pdfreport.Heading, Cells: []string{c.Title}, Indent: 1})
+			rows = append(rows, pdfLines(c.Lines, 2)...)
+			rows = append(rows, pdfreport.Row{Style: pdfreport.Subtotal, Cells: []string{"", "Total " + c.Title, c.Subtotal}, Indent: 1})
+		}
+		rows = append(rows, pdfLines(s.Lines, 1)...)
+
+		total := pdfreport.Row{Style: pdfreport.Subtotal, Cells: []string{"", "TOTAL " + s.Title, s.Total}}
+		if s.IsBold {
+			total.Style = pdfreport.Total
+		}
+		rows = append(rows, total, pdfreport.Row{Style: pdfreport.Blank})
+	}
+
+	totalAssets, totalLiab, totalEquity := calcBSKPIs(sections)
+	_, equationMsg := checkEquation(totalAssets, totalLiab, totalEquity)
+	rows = append(rows, pdfreport.Row{
+		Style: pdfreport.Total,
+		Cells: []string{"", "TOTAL LIABILITIES + EQUITY", formatCurrencyFS(totalLiab + totalEquity)},
+	})
+
+	signatures := deps.Signatures
+	if signatures == nil {
+		signatures = pdfreport.DefaultSignatures()
+	}
+	return &pdfreport.Report{
+		Letterhead: deps.Letterhead,
+		Title:      deps.Labels.BalanceSheet.Title,
+		Subtitle:   pdfreport.AsOf(deps.Labels.BalanceSheet.AsOf, deps.Labels.BalanceSheet.AsOfDateFormat, asOfDate),
+		Columns: []pdfreport.Column{
+			{Header: "Code", Width: 0.8},
+			{Header: "Account", Width: 4},
+			{Header: "Amount", Width: 1.6, Align: pdfreport.Right},
+		},
+		IndentColumn: 1,
+		Rows:         rows,
+		Notes:        []string{equationMsg},
+		Signatures:   signatures,
+		GeneratedAt:  time.Now(),
+	}
+}
+
+func pdfLines(lines []BSLine, indent int) []pdfreport.Row {
+	rows := make([]pdfreport.Row, 0, len(lines))
+	for _, l := range lines {
+		row := pdfreport.Row{Cells: []string{l.Code, l.Name, l.Amount}, Indent: indent}
+		switch {
+		case l.IsSeparator:
+			row = pdfreport.Row{Style: pdfreport.Blank}
+		case l.IsSubtotal:
+			row.Style = pdfreport.Subtotal
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
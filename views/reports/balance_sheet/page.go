@@ -6,6 +6,7 @@ import (
 	"time"
 
 	fycha "github.com/erniealice/fycha-golang"
+	"github.com/erniealice/fycha-golang/services/pdfreport"
 	pyeza "github.com/erniealice/pyeza-golang"
 	"github.com/erniealice/pyeza-golang/types"
 	"github.com/erniealice/pyeza-golang/view"
@@ -51,6 +52,12 @@ type BalanceSheetDeps struct {
 	TableLabels  types.TableLabels
 	Labels       fycha.ReportsLabels
 
+	// Letterhead heads every page of the PDF export, and Signatures close
+	// it. Nil Signatures print blank Prepared, Reviewed and Approved by
+	// blocks.
+	Letterhead pdfreport.Letterhead
+	Signatures []pdfreport.Signature
+
 	// GetBalanceSheet fetches balance sheet data as of the given date.
 	// Phase 8: set to nil — mock data is used automatically.
 	GetBalanceSheet func(ctx context.Context, asOfDate string) ([]BSSection, error)
@@ -85,50 +92,13 @@ type BalanceSheetPageData struct {
 // NewBalanceSheetView creates the Balance Sheet report view.
 func NewBalanceSheetView(deps *BalanceSheetDeps) view.View {
 	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
-		q := viewCtx.QueryParams
-
-		asOfDate := q["as_of"]
-		if asOfDate == "" {
-			now := time.Now()
-			lastDay := time.Date(now.Year(), now.Month()+1, 0, 0, 0, 0, 0, time.UTC)
-			asOfDate = lastDay.Format("2006-01-02")
-		}
-
-		// Fetch sections
-		var sections []BSSection
-		if deps.GetBalanceSheet != nil {
-			ss, err := deps.GetBalanceSheet(ctx, asOfDate)
-			if err == nil {
-				sections = ss
-			}
-		}
-		if sections == nil {
-			sections = mockBSSections()
-		}
+		asOfDate := resolveAsOfDate(viewCtx.QueryParams["as_of"])
+		sections := loadSections(ctx, deps, asOfDate)
 
 		// Parse KPIs from sections
 		totalAssets, totalLiab, totalEquity := calcBSKPIs(sections)
 		totalLandE := totalLiab + totalEquity
-		diff := totalAssets - totalLandE
-		if diff < 0 {
-			diff = -diff
-		}
-		isBalanced := diff < 0.01
-
-		var equationMsg string
-		if isBalanced {
-			equationMsg = fmt.Sprintf("A = L + E verified: %s = %s + %s",
-				formatCurrencyFS(totalAssets),
-				formatCurrencyFS(totalLiab),
-				formatCurrencyFS(totalEquity),
-			)
-		} else {
-			equationMsg = fmt.Sprintf("Warning: Assets (%s) ≠ Liabilities + Equity (%s). Difference: %s",
-				formatCurrencyFS(totalAssets),
-				formatCurrencyFS(totalLandE),
-				formatCurrencyFS(diff),
-			)
-		}
+		isBalanced, equationMsg := checkEquation(totalAssets, totalLiab, totalEquity)
 
 		pageData := &BalanceSheetPageData{
 			PageData: types.PageData{
@@ -164,6 +134,55 @@ func NewBalanceSheetView(deps *BalanceSheetDeps) view.View {
 // Helpers
 // ---------------------------------------------------------------------------
 
+// resolveAsOfDate defaults an empty as_of query param to the last day of
+// the current month. Shared with the PDF export.
+func resolveAsOfDate(asOfDate string) string {
+	if asOfDate == "" {
+		now := time.Now()
+		lastDay := time.Date(now.Year(), now.Month()+1, 0, 0, 0, 0, 0, time.UTC)
+		asOfDate = lastDay.Format("2006-01-02")
+	}
+	return asOfDate
+}
+
+// loadSections fetches the balance sheet as of the date, falling back to
+// mock data when no data source is wired or it fails.
+func loadSections(ctx context.Context, deps *BalanceSheetDeps, asOfDate string) []BSSection {
+	var sections []BSSection
+	if deps.GetBalanceSheet != nil {
+		ss, err := deps.GetBalanceSheet(ctx, asOfDate)
+		if err == nil {
+			sections = ss
+		}
+	}
+	if sections == nil {
+		sections = mockBSSections()
+	}
+	return sections
+}
+
+// checkEquation verifies Assets = Liabilities + Equity and describes the
+// result.
+func checkEquation(totalAssets, totalLiab, totalEquity float64) (bool, string) {
+	totalLandE := totalLiab + totalEquity
+	diff := totalAssets - totalLandE
+	if diff < 0 {
+		diff = -diff
+	}
+	if diff < 0.01 {
+		return true, fmt.Sprintf("A = L + E verified: %s = %s + %s",
+			formatCurrencyFS(totalAssets),
+			formatCurrencyFS(totalLiab),
+			formatCurrencyFS(totalEquity),
+		)
+	}
+	return false, fmt.Sprintf("Warning: Assets (%s) ≠ Liabilities + Equity (%s). Difference: %s",
+		formatCurrencyFS(totalAssets),
+		formatCurrencyFS(totalLandE),
+		formatCurrencyFS(diff),
+	)
+}
+
 func calcBSKPIs(sections []BSSection) (totalAssets, totalLiab, totalEquity float64) {
 	for _, s := range sections {
 		switch s.Title {
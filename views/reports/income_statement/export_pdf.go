@@ -0,0 +1,102 @@
+package income_statement
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/erniealice/fycha-golang/services/pdfreport"
+)
+
+// NewPDFExportHandler creates an http.HandlerFunc that renders the income
+// statement as a PDF, for the same period as the page view.
+func NewPDFExportHandler(deps *IncomeStatementDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		_, startDate, endDate, periodLabel := resolvePeriod(q.Get("period"), q.Get("start"), q.Get("end"))
+		sections := loadSections(r.Context(), deps, startDate, endDate)
+
+		pdf, err := pdfreport.Render(buildPDFReport(deps, sections, periodLabel))
+		if err != nil {
+			log.Printf("income_statement export: failed to render PDF: %v", err)
+			http.Error(w, "Failed to generate report", http.StatusInternalServerError)
+			return
+		}
+
+		filename := fmt.Sprintf("income-statement-%s-%s.pdf", startDate, endDate)
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+		w.Write(pdf)
+	}
+}
+
+// buildPDFReport lays the statement out the way the page's table does:
+// a heading per section, its lines and groups, then its total. Computed
+// sections (Gross Profit, Net Income) are a single total line.
+func buildPDFReport(deps *IncomeStatementDeps, sections []ISStatementSection, periodLabel string) *pdfreport.Report {
+	var rows []pdfreport.Row
+	for i, s := range sections {
+		if i > 0 {
+			rows = append(rows, pdfreport.Row{Style: pdfreport.Blank})
+		}
+		if len(s.Lines) == 0 && len(s.Groups) == 0 {
+			rows = append(rows, pdfreport.Row{Style: pdfreport.Total, Cells: []string{"", s.Title, s.Subtotal}})
+			continue
+		}
+
+		rows = append(rows, pdfreport.Row{Style: pdfreport.Heading, Cells: []string{s.Title}})
+		rows = append(rows, pdfLines(s.Lines, 1)...)
+		for _, g := range s.Groups {
+			rows = append(rows, pdfreport.Row{Style: pdfreport.Heading, Cells: []string{g.Title}, Indent: 1})
+			rows = append(rows, pdfLines(g.Lines, 2)...)
+			rows = append(rows, pdfreport.Row{Style: pdfreport.Subtotal, Cells: []string{"", "Subtotal: " + g.Title, g.Subtotal}, Indent: 1})
+		}
+		if s.Subtotal != "" {
+			total := pdfreport.Row{Style: pdfreport.Subtotal, Cells: []string{"", "Total " + s.Title, s.Subtotal}}
+			if s.Bold {
+				total = pdfreport.Row{Style: pdfreport.Total, Cells: []string{"", s.Title, s.Subtotal}}
+			}
+			rows = append(rows, total)
+		}
+	}
+
+	signatures := deps.Signatures
+	if signatures == nil {
+		signatures = pdfreport.DefaultSignatures()
+	}
+	return &pdfreport.Report{
+		Letterhead: deps.Letterhead,
+		Title:      deps.Labels.IncomeStatement.Title,
+		Subtitle:   periodLabel,
+		Columns: []pdfreport.Column{
+			{Header: "Code", Width: 0.8},
+			{Header: "Account", Width: 3.2},
+			{Header: "This Period", Width: 1.6, Align: pdfreport.Right},
+			{Header: "Prior Period", Width: 1.6, Align: pdfreport.Right},
+			{Header: "Change", Width: 0.9, Align: pdfreport.Right},
+		},
+		IndentColumn: 1,
+		Rows:         rows,
+		Signatures:   signatures,
+		GeneratedAt:  time.Now(),
+	}
+}
+
+func pdfLines(lines []ISStatementLine, indent int) []pdfreport.Row {
+	rows := make([]pdfreport.Row, 0, len(lines))
+	for _, l := range lines {
+		row := pdfreport.Row{
+			Cells:  []string{l.Code, l.Name, l.CurrentPeriod, l.PriorPeriod, l.Change},
+			Indent: indent,
+		}
+		switch {
+		case l.IsSeparator:
+			row = pdfreport.Row{Style: pdfreport.Blank}
+		case l.IsTotal:
+			row.Style = pdfreport.Subtotal
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	fycha "github.com/erniealice/fycha-golang"
+	"github.com/erniealice/fycha-golang/services/pdfreport"
 	pyeza "github.com/erniealice/pyeza-golang"
 	"github.com/erniealice/pyeza-golang/types"
 	"github.com/erniealice/pyeza-golang/view"
@@ -53,6 +54,12 @@ type IncomeStatementDeps struct {
 	TableLabels  types.TableLabels
 	Labels       fycha.ReportsLabels
 
+	// Letterhead heads every page of the PDF export, and Signatures close
+	// it. Nil Signatures print blank Prepared, Reviewed and Approved by
+	// blocks.
+	Letterhead pdfreport.Letterhead
+	Signatures []pdfreport.Signature
+
 	// GetIncomeStatement fetches income statement data for the period.
 	// Phase 8: set to nil — mock data is used automatically.
 	GetIncomeStatement func(ctx context.Context, startDate, endDate string) ([]ISStatementSection, error)
@@ -90,45 +97,12 @@ func NewIncomeStatementView(deps *IncomeStatementDeps) view.View {
 	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
 		q := viewCtx.QueryParams
 
-		preset := q["period"]
-		if preset == "" {
-			preset = "thisMonth"
-		}
-		startDate := q["start"]
-		endDate := q["end"]
-
-		// Resolve date range from preset
-		start, end := fycha.ParsePeriodPreset(preset)
-		if preset == "custom" {
-			if t, err := time.Parse("2006-01-02", startDate); err == nil {
-				start = t
-			}
-			if t, err := time.Parse("2006-01-02", endDate); err == nil {
-				end = t
-			}
-		}
-
-		startDate = start.Format("2006-01-02")
-		endDate = end.Format("2006-01-02")
-		periodLabel := fmt.Sprintf("%s – %s",
-			start.Format("January 2, 2006"),
-			end.Format("January 2, 2006"),
-		)
+		preset, startDate, endDate, periodLabel := resolvePeriod(q["period"], q["start"], q["end"])
 
 		pl := deps.Labels.Period
 		periodPresets := fycha.DefaultPeriodPresets(pl, preset)
 
-		// Fetch sections
-		var sections []ISStatementSection
-		if deps.GetIncomeStatement != nil {
-			ss, err := deps.GetIncomeStatement(ctx, startDate, endDate)
-			if err == nil {
-				sections = ss
-			}
-		}
-		if sections == nil {
-			sections = mockISSections()
-		}
+		sections := loadSections(ctx, deps, startDate, endDate)
 
 		// Calculate KPIs from sections
 		totalRevenue, totalExpenses, netIncome := calcISKPIs(sections)
@@ -175,6 +149,47 @@ func NewIncomeStatementView(deps *IncomeStatementDeps) view.View {
 // Helpers
 // ---------------------------------------------------------------------------
 
+// resolvePeriod turns the period query params into the preset, the date
+// range as YYYY-MM-DD and its display label. Shared with the PDF export.
+func resolvePeriod(preset, startDate, endDate string) (string, string, string, string) {
+	if preset == "" {
+		preset = "thisMonth"
+	}
+
+	// Resolve date range from preset
+	start, end := fycha.ParsePeriodPreset(preset)
+	if preset == "custom" {
+		if t, err := time.Parse("2006-01-02", startDate); err == nil {
+			start = t
+		}
+		if t, err := time.Parse("2006-01-02", endDate); err == nil {
+			end = t
+		}
+	}
+
+	periodLabel := fmt.Sprintf("%s – %s",
+		start.Format("January 2, 2006"),
+		end.Format("January 2, 2006"),
+	)
+	return preset, start.Format("2006-01-02"), end.Format("2006-01-02"), periodLabel
+}
+
+// loadSections fetches the statement for the period, falling back to mock
+// data when no data source is wired or it fails.
+func loadSections(ctx context.Context, deps *IncomeStatementDeps, startDate, endDate string) []ISStatementSection {
+	var sections []ISStatementSection
+	if deps.GetIncomeStatement != nil {
+		ss, err := deps.GetIncomeStatement(ctx, startDate, endDate)
+		if err == nil {
+			sections = ss
+		}
+	}
+	if sections == nil {
+		sections = mockISSections()
+	}
+	return sections
+}
+
 func calcISKPIs(sections []ISStatementSection) (totalRevenue, totalExpenses, netIncome float64) {
 	// Sections are: Revenue, Cost of Sales, (Gross Profit calc), Operating Expenses,
 	// (Operating Income calc), Other Expenses, (Net Income calc).
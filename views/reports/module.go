@@ -9,6 +9,7 @@ import (
 	"github.com/erniealice/pyeza-golang/view"
 
 	fycha "github.com/erniealice/fycha-golang"
+	"github.com/erniealice/fycha-golang/services/pdfreport"
 	costsales "github.com/erniealice/fycha-golang/views/reports/cost_of_sales"
 	dashboardview "github.com/erniealice/fycha-golang/views/reports/dashboard"
 	expensesview "github.com/erniealice/fycha-golang/views/reports/expenses"
@@ -52,6 +53,10 @@ type ModuleDeps struct {
 	Labels       fycha.ReportsLabels
 	CommonLabels pyeza.CommonLabels
 	TableLabels  types.TableLabels
+
+	// Letterhead and Signatures are passed to the aging report PDF exports.
+	Letterhead pdfreport.Letterhead
+	Signatures []pdfreport.Signature
 }
 
 // Module holds all constructed report views.
@@ -71,8 +76,10 @@ type Module struct {
 	DisbursementReportExport http.HandlerFunc
 	ReceivablesAgingReport        view.View
 	ReceivablesAgingReportExport  http.HandlerFunc
+	ReceivablesAgingReportPDF     http.HandlerFunc
 	PayablesAgingReport           view.View
 	PayablesAgingReportExport     http.HandlerFunc
+	PayablesAgingReportPDF        http.HandlerFunc
 	CollectionSummaryReport       view.View
 	CollectionSummaryReportExport http.HandlerFunc
 }
@@ -148,6 +155,15 @@ func NewModule(deps *ModuleDeps) *Module {
 			TableLabels:  deps.TableLabels,
 			Routes:       deps.Routes,
 		}),
+		ReceivablesAgingReportPDF: receivablesagingreport.NewPDFExportHandler(&receivablesagingreport.Deps{
+			DB:           deps.DB,
+			Labels:       deps.Labels,
+			CommonLabels: deps.CommonLabels,
+			TableLabels:  deps.TableLabels,
+			Routes:       deps.Routes,
+			Letterhead:   deps.Letterhead,
+			Signatures:   deps.Signatures,
+		}),
 		PayablesAgingReport: payablesagingreport.NewView(&payablesagingreport.Deps{
 			DB:           deps.DB,
 			Labels:       deps.Labels,
@@ -162,6 +178,15 @@ func NewModule(deps *ModuleDeps) *Module {
 			TableLabels:  deps.TableLabels,
 			Routes:       deps.Routes,
 		}),
+		PayablesAgingReportPDF: payablesagingreport.NewPDFExportHandler(&payablesagingreport.Deps{
+			DB:           deps.DB,
+			Labels:       deps.Labels,
+			CommonLabels: deps.CommonLabels,
+			TableLabels:  deps.TableLabels,
+			Routes:       deps.Routes,
+			Letterhead:   deps.Letterhead,
+			Signatures:   deps.Signatures,
+		}),
 		CollectionSummaryReport: collectionsummaryreport.NewView(&collectionsummaryreport.Deps{
 			DB:           deps.DB,
 			Labels:       deps.Labels,
@@ -194,8 +219,10 @@ func (m *Module) RegisterRoutes(r view.RouteRegistrar) {
 	handleFunc(r, "GET", m.routes.DisbursementReportExportURL, m.DisbursementReportExport)
 	r.GET(m.routes.ReceivablesAgingReportURL, m.ReceivablesAgingReport)
 	handleFunc(r, "GET", m.routes.ReceivablesAgingReportExportURL, m.ReceivablesAgingReportExport)
+	handleFunc(r, "GET", m.routes.ReceivablesAgingReportPDFURL, m.ReceivablesAgingReportPDF)
 	r.GET(m.routes.PayablesAgingReportURL, m.PayablesAgingReport)
 	handleFunc(r, "GET", m.routes.PayablesAgingReportExportURL, m.PayablesAgingReportExport)
+	handleFunc(r, "GET", m.routes.PayablesAgingReportPDFURL, m.PayablesAgingReportPDF)
 	r.GET(m.routes.CollectionSummaryReportURL, m.CollectionSummaryReport)
 	handleFunc(r, "GET", m.routes.CollectionSummaryReportExportURL, m.CollectionSummaryReportExport)
 }
@@ -1,10 +1,12 @@
 package payables_aging_report
 
 import (
+	"context"
 	"encoding/csv"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"time"
 
 	payagingpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/ledger/reporting/payables_aging"
@@ -14,55 +16,13 @@ import (
 // It applies the same filters as the page view.
 func NewExportHandler(deps *Deps) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx := r.Context()
-		q := r.URL.Query()
-
-		// Parse the same query params as the page view
-		asOfDate := q.Get("as-of-date")
-		if asOfDate == "" {
-			asOfDate = time.Now().Format("2006-01-02")
-		}
-		rows := q.Get("rows")
-		if rows == "" {
-			rows = "supplier"
-		}
-
-		// Secondary filter IDs
-		supplierID := q.Get("supplier-id")
-		locationID := q.Get("location-id")
-		expenditureCategoryID := q.Get("expenditure-category-id")
-
-		// Build proto request
-		req := &payagingpb.PayablesAgingRequest{
-			AsOfDate:     &asOfDate,
-			RowDimension: rows,
-		}
-
-		// Apply optional secondary filters
-		if supplierID != "" {
-			req.SupplierId = &supplierID
-		}
-		if locationID != "" {
-			req.LocationId = &locationID
-		}
-		if expenditureCategoryID != "" {
-			req.ExpenditureCategoryId = &expenditureCategoryID
-		}
-
-		// Call data source
-		resp, err := deps.DB.GetPayablesAgingReport(ctx, req)
+		req, asOfDate, rows := parseExportRequest(r.URL.Query())
+		resp, err := fetchExportReport(r.Context(), deps, req)
 		if err != nil {
 			log.Printf("payables_aging_report export: failed to get report: %v", err)
 			http.Error(w, "Failed to generate report", http.StatusInternalServerError)
 			return
 		}
-		if resp == nil {
-			resp = &payagingpb.PayablesAgingResponse{
-				BucketLabels: []string{},
-				Rows:         []*payagingpb.PayablesAgingRow{},
-				Summary:      &payagingpb.PayablesAgingSummary{},
-			}
-		}
 
 		// Set CSV response headers
 		l := deps.Labels.PayablesAging
@@ -76,34 +36,9 @@ func NewExportHandler(deps *Deps) http.HandlerFunc {
 		writer := csv.NewWriter(w)
 		defer writer.Flush()
 
-		// Resolve bucket labels: prefer response labels, fall back to static labels
-		bucketLabels := resp.GetBucketLabels()
-		var bCurrent, b1to30, b31to60, b61to90, bOver90 string
-		if len(bucketLabels) >= 5 {
-			bCurrent = bucketLabels[0]
-			b1to30 = bucketLabels[1]
-			b31to60 = bucketLabels[2]
-			b61to90 = bucketLabels[3]
-			bOver90 = bucketLabels[4]
-		} else {
-			bCurrent = "Current"
-			b1to30 = "1-30 Days"
-			b31to60 = "31-60 Days"
-			b61to90 = "61-90 Days"
-			bOver90 = "Over 90 Days"
-		}
-
 		// Write header row
-		header := []string{
-			rowDimensionLabel(l, rows),
-			bCurrent,
-			b1to30,
-			b31to60,
-			b61to90,
-			bOver90,
-			"Total Outstanding",
-			"Invoice Count",
-		}
+		header := append([]string{rowDimensionLabel(l, rows)}, bucketHeaders(resp.GetBucketLabels())...)
+		header = append(header, "Total Outstanding", "Invoice Count")
 		if err := writer.Write(header); err != nil {
 			log.Printf("payables_aging_report export: failed to write CSV header: %v", err)
 			return
@@ -160,6 +95,68 @@ func NewExportHandler(deps *Deps) http.HandlerFunc {
 	}
 }
 
+// parseExportRequest builds the report request from the same query params
+// as the page view, and returns it with the as-of date and row dimension.
+func parseExportRequest(q url.Values) (*payagingpb.PayablesAgingRequest, string, string) {
+	asOfDate := q.Get("as-of-date")
+	if asOfDate == "" {
+		asOfDate = time.Now().Format("2006-01-02")
+	}
+	rows := q.Get("rows")
+	if rows == "" {
+		rows = "supplier"
+	}
+
+	// Secondary filter IDs
+	supplierID := q.Get("supplier-id")
+	locationID := q.Get("location-id")
+	expenditureCategoryID := q.Get("expenditure-category-id")
+
+	// Build proto request
+	req := &payagingpb.PayablesAgingRequest{
+		AsOfDate:     &asOfDate,
+		RowDimension: rows,
+	}
+
+	// Apply optional secondary filters
+	if supplierID != "" {
+		req.SupplierId = &supplierID
+	}
+	if locationID != "" {
+		req.LocationId = &locationID
+	}
+	if expenditureCategoryID != "" {
+		req.ExpenditureCategoryId = &expenditureCategoryID
+	}
+	return req, asOfDate, rows
+}
+
+// fetchExportReport calls the data source, treating a nil response as an
+// empty report.
+func fetchExportReport(ctx context.Context, deps *Deps, req *payagingpb.PayablesAgingRequest) (*payagingpb.PayablesAgingResponse, error) {
+	resp, err := deps.DB.GetPayablesAgingReport(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil {
+		resp = &payagingpb.PayablesAgingResponse{
+			BucketLabels: []string{},
+			Rows:         []*payagingpb.PayablesAgingRow{},
+			Summary:      &payagingpb.PayablesAgingSummary{},
+		}
+	}
+	return resp, nil
+}
+
+// bucketHeaders returns the five aging bucket headers: the response labels
+// when present, the static labels otherwise.
+func bucketHeaders(bucketLabels []string) []string {
+	if len(bucketLabels) >= 5 {
+		return bucketLabels[:5]
+	}
+	return []string{"Current", "1-30 Days", "31-60 Days", "61-90 Days", "Over 90 Days"}
+}
+
 // csvCurrency formats a centavo integer as a plain decimal string (e.g. "15000.50").
 // No commas, no currency symbol -- safe for CSV consumption.
 func csvCurrency(centavos int64) string {
@@ -0,0 +1,73 @@
+package payables_aging_report
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	payagingpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/ledger/reporting/payables_aging"
+
+	"github.com/erniealice/fycha-golang/services/pdfreport"
+	"github.com/erniealice/fycha-golang/views/reports/agingpdf"
+)
+
+// NewPDFExportHandler creates an http.HandlerFunc for PDF export of the payables aging report.
+// It applies the same filters as the page view.
+func NewPDFExportHandler(deps *Deps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, asOfDate, rows := parseExportRequest(r.URL.Query())
+		resp, err := fetchExportReport(r.Context(), deps, req)
+		if err != nil {
+			log.Printf("payables_aging_report export: failed to get report: %v", err)
+			http.Error(w, "Failed to generate report", http.StatusInternalServerError)
+			return
+		}
+
+		pdf, err := pdfreport.Render(buildPDFReport(deps, resp, asOfDate, rows))
+		if err != nil {
+			log.Printf("payables_aging_report export: failed to render PDF: %v", err)
+			http.Error(w, "Failed to generate report", http.StatusInternalServerError)
+			return
+		}
+
+		filename := fmt.Sprintf("payables-aging-%s.pdf", asOfDate)
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+		w.Write(pdf)
+	}
+}
+
+// buildPDFReport lays out the page table's columns and the CSV export's
+// totals row.
+func buildPDFReport(deps *Deps, resp *payagingpb.PayablesAgingResponse, asOfDate, rows string) *pdfreport.Report {
+	l := deps.Labels.PayablesAging
+
+	report := &agingpdf.Report{
+		Letterhead:      deps.Letterhead,
+		Signatures:      deps.Signatures,
+		Title:           l.PageTitle,
+		Subtitle:        pdfreport.AsOf(l.AsOf, l.AsOfDateFormat, asOfDate),
+		DimensionHeader: rowDimensionLabel(l, rows),
+		BucketHeaders:   bucketHeaders(resp.GetBucketLabels()),
+		TotalHeader:     l.TotalOutstanding,
+		CountHeader:     l.InvoiceCount,
+		FormatAmount:    formatCurrency,
+	}
+	for _, row := range resp.GetRows() {
+		report.Rows = append(report.Rows, agingpdf.Row{
+			Key:     row.GetRowKey(),
+			Buckets: row.GetBuckets(),
+			Total:   row.GetTotalOutstanding(),
+			Count:   fmt.Sprintf("%d", row.GetInvoiceCount()),
+		})
+	}
+	if summary := resp.GetSummary(); summary != nil && len(resp.GetRows()) > 0 {
+		report.Summary = &agingpdf.Row{
+			Key:     "TOTAL",
+			Buckets: summary.GetBuckets(),
+			Total:   summary.GetGrandTotalOutstanding(),
+			Count:   fmt.Sprintf("%d", summary.GetTotalInvoiceCount()),
+		}
+	}
+	return agingpdf.Build(report)
+}
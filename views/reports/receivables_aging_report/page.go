@@ -9,6 +9,7 @@ import (
 	"time"
 
 	fycha "github.com/erniealice/fycha-golang"
+	"github.com/erniealice/fycha-golang/services/pdfreport"
 
 	agingpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/ledger/reporting/receivables_aging"
 	lynguaV1 "github.com/erniealice/lyngua/golang/v1"
@@ -24,6 +25,11 @@ type Deps struct {
 	CommonLabels pyeza.CommonLabels
 	TableLabels  types.TableLabels
 	Routes       fycha.ReportsRoutes
+
+	// Letterhead and Signatures are printed on PDF exports. A nil
+	// Signatures uses pdfreport.DefaultSignatures.
+	Letterhead pdfreport.Letterhead
+	Signatures []pdfreport.Signature
 }
 
 // PageData holds the data for the receivables aging report page.